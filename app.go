@@ -1,11 +1,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/charmbracelet/colorprofile"
 	uv "github.com/charmbracelet/ultraviolet"
 	"github.com/charmbracelet/x/ansi"
 	t "github.com/darrenburns/terma"
@@ -19,6 +22,12 @@ const (
 	diffViewerScrollID    = "terma-diff-viewer-scroll"
 	diffSplitPaneID       = "terma-diff-split"
 	diffCommandPaletteID  = "terma-diff-command-palette"
+	diffCommentDialogID   = "terma-diff-comment-dialog"
+	diffCommentInputID    = "terma-diff-comment-input"
+	diffExportDialogID    = "terma-diff-export-dialog"
+	diffExportInputID     = "terma-diff-export-input"
+	diffHTMLDialogID      = "terma-diff-html-dialog"
+	diffHTMLInputID       = "terma-diff-html-input"
 	diffThemesPalette     = "Themes"
 	diffJumpScrollLines   = 10
 	treeSummaryCountAlpha = 0.65
@@ -36,8 +45,21 @@ type DvInitialState struct {
 	SidebarVisible   bool
 	ThemeName        string
 	IntralineStyle   IntralineStyleMode
+	PaletteVariant   PaletteVariant
 	ShowChangeSigns  bool
 	IgnoreWhitespace bool
+	WordDiff         bool
+	ShowWhitespace   bool
+	LargeFileLimits  largeFileLimits
+	SeenStatePath    string
+	KeyBindings      KeyBindings
+	// PaletteOptions holds the colour depth and config styles palettes are
+	// built with.
+	PaletteOptions paletteOptions
+	// UIStatePath enables restoring and saving UI state. ThemeExplicit keeps
+	// the startup theme, set by --theme or config, over a remembered one.
+	UIStatePath   string
+	ThemeExplicit bool
 }
 
 func DefaultDvInitialState() DvInitialState {
@@ -48,6 +70,12 @@ func DefaultDvInitialState() DvInitialState {
 		IntralineStyle:   IntralineStyleModeBackground,
 		ShowChangeSigns:  false,
 		IgnoreWhitespace: false,
+		LargeFileLimits: largeFileLimits{
+			MaxFileLines: defaultMaxFileLines,
+			MaxLineBytes: defaultMaxLineBytes,
+			MaxDiffBytes: defaultMaxDiffBytes,
+		},
+		KeyBindings: defaultKeyBindings(),
 	}
 }
 
@@ -66,6 +94,10 @@ func normalizeDvInitialState(initial DvInitialState) DvInitialState {
 		initial.IntralineStyle = defaults.IntralineStyle
 	}
 
+	if _, ok := paletteVariantSpecs[initial.PaletteVariant]; !ok {
+		initial.PaletteVariant = defaults.PaletteVariant
+	}
+
 	parsedThemeName, err := parseThemeName(initial.ThemeName)
 	if err != nil {
 		initial.ThemeName = defaults.ThemeName
@@ -73,6 +105,10 @@ func normalizeDvInitialState(initial DvInitialState) DvInitialState {
 		initial.ThemeName = parsedThemeName
 	}
 
+	if initial.KeyBindings == nil {
+		initial.KeyBindings = defaults.KeyBindings
+	}
+
 	return initial
 }
 
@@ -82,6 +118,21 @@ type diffScrollAnchor struct {
 	newLine int
 }
 
+// reviewLineSelection is the line range picked in the diff view for the next
+// review comment. anchor is where the selection started and active where it
+// was last extended to.
+type reviewLineSelection struct {
+	section DiffSection
+	path    string
+	side    ReviewCommentSide
+	anchor  int
+	active  int
+}
+
+func (s reviewLineSelection) span() reviewLineSpan {
+	return newReviewLineSpan(s.side, s.anchor, s.active)
+}
+
 type fileScrollState struct {
 	mode   DiffLayoutMode
 	offset int
@@ -95,9 +146,17 @@ type diffSectionState struct {
 	fileByPath         map[string]*DiffFile
 	filePathToTreePath map[string][]int
 	orderedFilePaths   []string
-	lastSelectedPath   string
-	additions          int
-	deletions          int
+	// hunkHashesByPath caches diffFileHunkHashes. Collapsed files are only
+	// hashed once their seen marks need it.
+	hunkHashesByPath map[string][]string
+	// lineMoves holds the moved lines of the files that aren't collapsed.
+	lineMoves sectionLineMoves
+	// collapsedByPath holds why files were collapsed instead of rendered.
+	collapsedByPath  map[string]string
+	lastSelectedPath string
+	additions        int
+	deletions        int
+	suspiciousChars  int
 }
 
 type infoCardStat struct {
@@ -113,6 +172,9 @@ type infoCardModel struct {
 	Stats      []infoCardStat
 	Actions    []string
 	Background t.ColorProvider
+	// Files is shown as a table with a histogram column, in FilesSort order.
+	Files     []diffStatRow
+	FilesSort diffStatSortMode
 }
 
 // Dv is a read-only, syntax-highlighted git diff viewer.
@@ -157,10 +219,21 @@ type Dv struct {
 	diffHardWrap         bool
 	diffHideChangeSigns  bool
 	diffIntralineStyle   IntralineStyleMode
+	diffPaletteVariant   PaletteVariant
+	paletteOptions       paletteOptions
 	diffIgnoreWhitespace bool
-	manualRefreshEnabled bool
-	focusedWidgetID      string
-	sidebarVisible       bool
+	diffWordDiff         bool
+	diffShowWhitespace   bool
+	largeFileLimits      largeFileLimits
+	// renderAnyway holds the collapsed files, by section, that were asked to
+	// render in full.
+	renderAnyway            map[DiffSection]map[string]bool
+	sectionStatSort         diffStatSortMode
+	manualRefreshEnabled    bool
+	ignoreWhitespaceEnabled bool
+	focusedWidgetID         string
+	sidebarVisible          bool
+	keyBindings             KeyBindings
 
 	dividerFocused        bool
 	dividerHovered        bool
@@ -179,7 +252,28 @@ type Dv struct {
 	layoutToggleScrollActiveSection DiffSection
 
 	fileScrollOffsets map[string]fileScrollState
-	reviewedByFile    map[string]bool
+	reviewedByFile    map[string]map[string]bool
+	seenStore         SeenStore
+	// seenStateErr is the last error loading or saving seen marks, shown in
+	// the status line until a save succeeds.
+	seenStateErr string
+	uiStateStore UIStateStore
+	// uiStateErr is the error loading remembered UI state, shown in the
+	// status line after the seen marks error.
+	uiStateErr string
+	// pickedTheme is the theme last chosen in the theme menu, in this run or
+	// a remembered one.
+	pickedTheme string
+
+	reviewComments      []ReviewComment
+	commentSelection    reviewLineSelection
+	commentDraft        *ReviewComment
+	commentInput        *t.TextAreaState
+	commentExportInput  *t.TextInputState
+	commentExportFormat ReviewCommentsExportFormat
+	commentExportErr    string
+	htmlExportInput     *t.TextInputState
+	htmlExportErr       string
 }
 
 func NewDv(provider DiffProvider, staged bool, initialState DvInitialState) *Dv {
@@ -223,20 +317,35 @@ func NewDv(provider DiffProvider, staged bool, initialState DvInitialState) *Dv
 		diffLayoutMode:       initialState.LayoutMode,
 		diffHideChangeSigns:  !initialState.ShowChangeSigns,
 		diffIntralineStyle:   initialState.IntralineStyle,
+		diffPaletteVariant:   initialState.PaletteVariant,
+		paletteOptions:       initialState.PaletteOptions,
 		diffIgnoreWhitespace: initialState.IgnoreWhitespace,
+		diffWordDiff:         initialState.WordDiff,
+		diffShowWhitespace:   initialState.ShowWhitespace,
+		largeFileLimits:      initialState.LargeFileLimits,
+		renderAnyway:         map[DiffSection]map[string]bool{},
 		manualRefreshEnabled: manualRefreshEnabled,
 		lastNonDividerFocus:  diffViewerScrollID,
 		focusReturnID:        diffViewerScrollID,
 		copyPathToClipboard:  copyPathToClipboardOSC52,
 		fileScrollOffsets:    map[string]fileScrollState{},
-		reviewedByFile:       map[string]bool{},
+		reviewedByFile:       map[string]map[string]bool{},
+		seenStore:            SeenStore{Path: initialState.SeenStatePath},
+		uiStateStore:         UIStateStore{Path: initialState.UIStatePath},
+		keyBindings:          initialState.KeyBindings,
+	}
+	app.ignoreWhitespaceEnabled = !app.isPipedDiffMode()
+	if ignoreWhitespaceProvider, ok := provider.(IgnoreWhitespaceCapable); ok {
+		app.ignoreWhitespaceEnabled = ignoreWhitespaceProvider.IgnoreWhitespaceEnabled()
 	}
-	if app.isPipedDiffMode() {
+	if !app.ignoreWhitespaceEnabled {
 		app.diffIgnoreWhitespace = false
 	}
 	app.configureDiffHorizontalScroll()
 	app.commandPalette = app.newCommandPalette()
 	app.refreshDiff()
+	app.loadSeenMarks()
+	app.loadUIState(!initialState.ThemeExplicit)
 	t.RequestFocus(diffViewerScrollID)
 	return app
 }
@@ -255,7 +364,23 @@ func newDiffSectionState() *diffSectionState {
 		fileByPath:         map[string]*DiffFile{},
 		filePathToTreePath: map[string][]int{},
 		orderedFilePaths:   []string{},
+		hunkHashesByPath:   map[string][]string{},
+		collapsedByPath:    map[string]string{},
+	}
+}
+
+// hunkHashes returns the hunk hashes of a file, hashing it on first use.
+func (s *diffSectionState) hunkHashes(filePath string) []string {
+	if hashes, ok := s.hunkHashesByPath[filePath]; ok {
+		return hashes
+	}
+	file := s.fileByPath[filePath]
+	if file == nil {
+		return nil
 	}
+	hashes := diffFileHunkHashes(file)
+	s.hunkHashesByPath[filePath] = hashes
+	return hashes
 }
 
 func newDiffSectionStateMap(sectionOrder []DiffSection) map[DiffSection]*diffSectionState {
@@ -457,52 +582,90 @@ func (a *Dv) toggleMode() {
 
 func (a *Dv) Keybinds() []t.Keybind {
 	showFilterFiles := a.focusedWidgetID == diffFilesTreeID || a.focusedWidgetID == diffViewerScrollID
-	keybinds := []t.Keybind{
-		{Key: "n", Name: "Next file", Action: func() { a.moveFileCursor(1) }},
-		{Key: "]", Name: "Next file", Action: func() { a.moveFileCursor(1) }},
-		{Key: "p", Name: "Prev file", Action: func() { a.moveFileCursor(-1) }},
-		{Key: "[", Name: "Prev file", Action: func() { a.moveFileCursor(-1) }},
-		{Key: "ctrl+j", Name: "Next file", Action: func() { a.moveFileCursor(1) }, Hidden: true},
-		{Key: "ctrl+k", Name: "Prev file", Action: func() { a.moveFileCursor(-1) }, Hidden: true},
-		{Key: "J", Name: "Jump down 10", Action: func() { a.jumpDiffVertical(diffJumpScrollLines) }, Hidden: true},
-		{Key: "K", Name: "Jump up 10", Action: func() { a.jumpDiffVertical(-diffJumpScrollLines) }, Hidden: true},
-		{Key: "/", Name: "Filter files", Action: a.openTreeFilter, Hidden: !showFilterFiles},
-		{Key: "b", Name: "Toggle sidebar", Action: a.toggleSidebar, Hidden: true},
-		{Key: "escape", Name: "Clear filter", Action: a.handleEscape, Hidden: true},
-		{Key: "r", Name: "Refresh", Action: a.manualRefresh, Hidden: true},
-		{Key: "s", Name: "Switch section", Action: a.switchSectionFocus, Hidden: true},
-		{Key: "y", Name: "Copy path", Action: a.copyActiveFilePath, Hidden: true},
-		{Key: "w", Name: "Toggle line wrap", Action: a.toggleDiffWrap, Hidden: true},
-		{Key: "v", Name: "Toggle split", Action: a.toggleDiffLayoutMode, Hidden: true},
-		{Key: "ctrl+h", Name: "Shift split left", Action: a.shiftSideBySideSplitLeft, Hidden: true},
-		{Key: "ctrl+l", Name: "Shift split right", Action: a.shiftSideBySideSplitRight, Hidden: true},
-		{Key: "i", Name: "Toggle intraline style", Action: a.toggleDiffIntralineStyle, Hidden: true},
-		{Key: "m", Name: "Toggle seen", Action: a.toggleActiveFileReviewed, Hidden: true},
-		{Key: "M", Name: "Clear all seen", Action: a.clearAllReviewed, Hidden: true},
-		{Key: "d", Name: "Focus divider", Action: a.focusDivider, Hidden: true},
-		{Key: "ctrl+p", Name: "Command palette", Action: a.togglePalette},
-		{Key: "t", Name: "Theme menu", Action: a.openThemePalette, Hidden: true},
-		{Key: "q", Name: "Quit", Action: t.Quit},
+	keybinds := []t.Keybind{}
+	bind := func(id string, action func(), hidden bool) {
+		spec, _ := keyActionByID(id)
+		binding := a.keyBindings[id]
+		for _, key := range binding.Keys {
+			keybinds = append(keybinds, t.Keybind{Key: key, Name: spec.Name, Action: action, Hidden: hidden})
+		}
+		for _, key := range binding.HiddenKeys {
+			keybinds = append(keybinds, t.Keybind{Key: key, Name: spec.Name, Action: action, Hidden: true})
+		}
 	}
+
+	bind(keyActionNextFile, func() { a.moveFileCursor(1) }, false)
+	bind(keyActionPrevFile, func() { a.moveFileCursor(-1) }, false)
+	bind(keyActionJumpDown, func() { a.jumpDiffVertical(diffJumpScrollLines) }, true)
+	bind(keyActionJumpUp, func() { a.jumpDiffVertical(-diffJumpScrollLines) }, true)
+	bind(keyActionFilterFiles, a.openTreeFilter, !showFilterFiles)
+	bind(keyActionToggleSidebar, a.toggleSidebar, true)
+	bind(keyActionClearFilter, a.handleEscape, true)
+	bind(keyActionRefresh, a.manualRefresh, true)
+	bind(keyActionSwitchSection, a.switchSectionFocus, true)
+	bind(keyActionCopyPath, a.copyActiveFilePath, true)
+	bind(keyActionToggleWrap, a.toggleDiffWrap, true)
+	bind(keyActionToggleSplit, a.toggleDiffLayoutMode, true)
+	bind(keyActionShiftSplitLeft, a.shiftSideBySideSplitLeft, true)
+	bind(keyActionShiftSplitRight, a.shiftSideBySideSplitRight, true)
+	bind(keyActionToggleIntralineStyle, a.toggleDiffIntralineStyle, true)
+	bind(keyActionToggleWordDiff, a.toggleDiffWordDiff, true)
+	bind(keyActionToggleWhitespace, a.toggleDiffShowWhitespace, true)
+	bind(keyActionToggleSeen, a.toggleActiveFileReviewed, true)
+	bind(keyActionToggleHunkSeen, a.toggleVisibleHunkReviewed, true)
+	bind(keyActionClearSeen, a.clearAllReviewed, true)
+	bind(keyActionComment, a.openReviewCommentDraft, true)
+	bind(keyActionFocusDivider, a.focusDivider, true)
+	bind(keyActionCommandPalette, a.togglePalette, false)
+	bind(keyActionThemeMenu, a.openThemePalette, true)
+	bind(keyActionQuit, t.Quit, false)
 	if a.canToggleDiffIgnoreWhitespace() {
-		keybinds = append(keybinds, t.Keybind{
-			Key:    "x",
-			Name:   "Toggle ignore whitespace",
-			Action: a.toggleDiffIgnoreWhitespace,
-			Hidden: true,
-		})
+		bind(keyActionToggleIgnoreWhitespace, a.toggleDiffIgnoreWhitespace, true)
+	}
+	if a.activeKind == DiffTreeNodeSection {
+		bind(keyActionSortFiles, a.cycleSectionStatSort, true)
+	}
+	if a.activeFileCollapsed() {
+		bind(keyActionRenderAnyway, a.renderActiveFileAnyway, false)
 	}
 	return keybinds
 }
 
+// keyPrompt tells the user how to run an action, for example "press r to
+// refresh", pointing at the command palette when the action has no key.
+func (a *Dv) keyPrompt(id string, purpose string) string {
+	if key := a.keyBindings[id].firstKey(); key != "" {
+		return fmt.Sprintf("press %s to %s", key, purpose)
+	}
+	return "use the command palette to " + purpose
+}
+
+func upperFirst(text string) string {
+	if text == "" {
+		return text
+	}
+	return strings.ToUpper(text[:1]) + text[1:]
+}
+
+// keyHint is the bracketed key shown next to an action in the command
+// palette and header, or "" when the action has no key.
+func (a *Dv) keyHint(id string) string {
+	key := a.keyBindings[id].firstKey()
+	if key == "" {
+		return ""
+	}
+	return "[" + key + "]"
+}
+
 func (a *Dv) Build(ctx t.BuildContext) t.Widget {
 	a.syncFocusState(ctx)
 	theme := ctx.Theme()
 	body := a.buildRightPane(theme)
 	if a.sidebarVisible {
-		dividerFg := dividerForeground(theme)
+		profile := a.paletteOptions.Profile
+		dividerFg := dividerForeground(theme, profile)
 		if a.dividerHovered {
-			dividerFg = dividerHoverForeground(theme)
+			dividerFg = dividerHoverForeground(theme, profile)
 		}
 		body = FocusAwareSplitPane{
 			SplitPane: t.SplitPane{
@@ -513,7 +676,7 @@ func (a *Dv) Build(ctx t.BuildContext) t.Widget {
 				MinPaneSize:            20,
 				DividerBackground:      theme.Background,
 				DividerForeground:      dividerFg,
-				DividerFocusForeground: dividerFocusForeground(theme),
+				DividerFocusForeground: dividerFocusForeground(theme, profile),
 				Hover: func(event t.HoverEvent) {
 					a.dividerHovered = event.Type == t.HoverEnter
 				},
@@ -543,7 +706,7 @@ func (a *Dv) Build(ctx t.BuildContext) t.Widget {
 					BackgroundColor: theme.Background,
 				},
 				Top: []t.Widget{a.buildHeader(theme)},
-				Bottom: []t.Widget{
+				Bottom: append(a.buildStatusLine(theme),
 					t.Row{
 						Style: t.Style{
 							Width:           t.Flex(1),
@@ -561,9 +724,12 @@ func (a *Dv) Build(ctx t.BuildContext) t.Widget {
 							t.Spacer{Width: t.Flex(1)},
 						},
 					},
-				},
+				),
 				Body: body,
 			},
+			a.buildReviewCommentDialog(theme),
+			a.buildReviewCommentsExportDialog(theme),
+			a.buildDiffHTMLExportDialog(theme),
 			t.CommandPalette{
 				ID:             diffCommandPaletteID,
 				State:          a.commandPalette,
@@ -578,6 +744,29 @@ func (a *Dv) Build(ctx t.BuildContext) t.Widget {
 	}
 }
 
+// buildStatusLine returns the row shown above the keybind bar for problems
+// that don't stop the diff from loading, or nothing when there are none.
+func (a *Dv) buildStatusLine(theme t.ThemeData) []t.Widget {
+	message := a.seenStateErr
+	if message == "" {
+		message = a.uiStateErr
+	}
+	if message == "" {
+		return nil
+	}
+	return []t.Widget{
+		t.Text{
+			Content: message,
+			Style: t.Style{
+				Width:           t.Flex(1),
+				Padding:         t.EdgeInsetsXY(1, 0),
+				ForegroundColor: theme.ErrorText,
+				BackgroundColor: theme.Background,
+			},
+		},
+	}
+}
+
 func (a *Dv) buildHeader(theme t.ThemeData) t.Widget {
 	repoName := "(unknown repo)"
 	if a.repoRoot != "" {
@@ -585,7 +774,7 @@ func (a *Dv) buildHeader(theme t.ThemeData) t.Widget {
 	}
 
 	rightWidget := t.Text{
-		Content: themeDisplayName(t.CurrentThemeName()) + " [t]",
+		Content: strings.TrimSpace(themeDisplayName(t.CurrentThemeName()) + " " + a.keyHint(keyActionThemeMenu)),
 		Style: t.Style{
 			Padding:         t.EdgeInsetsXY(1, 0),
 			ForegroundColor: theme.SecondaryText,
@@ -751,6 +940,13 @@ func (a *Dv) renderTreeNode(theme t.ThemeData, widgetFocused bool) func(node Dif
 		if isReviewed {
 			labelStyle.Strikethrough = true
 		}
+		changedSinceSeen := node.NodeKind == DiffTreeNodeFile && a.isChangedSinceSeen(node.Section, node.Path)
+		changedSinceSeenStyle := t.Style{ForegroundColor: theme.Warning, Italic: true}
+		suspiciousChars := 0
+		if node.NodeKind == DiffTreeNodeFile && node.File != nil {
+			suspiciousChars = node.File.SuspiciousChars
+		}
+		suspiciousStyle := t.Style{ForegroundColor: theme.Error, Bold: true}
 
 		if nodeCtx.Active {
 			if widgetFocused {
@@ -758,6 +954,8 @@ func (a *Dv) renderTreeNode(theme t.ThemeData, widgetFocused bool) func(node Dif
 				labelColor = theme.SelectionText
 				addColor = theme.SelectionText
 				delColor = theme.SelectionText
+				changedSinceSeenStyle.ForegroundColor = theme.SelectionText
+				suspiciousStyle.ForegroundColor = theme.SelectionText
 			} else {
 				rowStyle.BackgroundColor = unfocusedTreeCursorColor(theme)
 			}
@@ -804,6 +1002,12 @@ func (a *Dv) renderTreeNode(theme t.ThemeData, widgetFocused bool) func(node Dif
 			labelWidget,
 		}
 		children = append(children, t.Spacer{Width: t.Flex(1)})
+		if suspiciousChars > 0 {
+			children = append(children, t.Text{Content: fmt.Sprintf("%d suspicious ", suspiciousChars), Style: suspiciousStyle})
+		}
+		if changedSinceSeen {
+			children = append(children, t.Text{Content: "changed since seen ", Style: changedSinceSeenStyle})
+		}
 		if addText, delText := nonZeroChangeTexts(node.Additions, node.Deletions); addText != "" || delText != "" {
 			if addText != "" {
 				children = append(children, t.Text{Content: addText, Style: addStyle})
@@ -825,15 +1029,21 @@ func (a *Dv) renderTreeNode(theme t.ThemeData, widgetFocused bool) func(node Dif
 
 func (a *Dv) buildRightPane(theme t.ThemeData) t.Widget {
 	viewer := DiffView{
-		ID:              diffViewerID,
-		DisableFocus:    true,
-		State:           a.diffViewState,
-		VerticalScroll:  a.diffScrollState,
-		LayoutMode:      a.diffLayoutMode,
-		HardWrap:        a.diffHardWrap,
-		HideChangeSigns: a.diffHideChangeSigns,
-		IntralineStyle:  a.diffIntralineStyle,
-		Palette:         NewThemePalette(theme),
+		ID:               diffViewerID,
+		DisableFocus:     true,
+		State:            a.diffViewState,
+		VerticalScroll:   a.diffScrollState,
+		LayoutMode:       a.diffLayoutMode,
+		HardWrap:         a.diffHardWrap,
+		HideChangeSigns:  a.diffHideChangeSigns,
+		IntralineStyle:   a.diffIntralineStyle,
+		PaletteVariant:   a.diffPaletteVariant,
+		PaletteOptions:   a.paletteOptions,
+		SeenHunks:        a.activeSeenHunks(),
+		SelectedLines:    a.activeCommentSelection(),
+		OnLineClick:      a.handleDiffLineClick,
+		OnMovedLineClick: a.jumpToMovedLine,
+		Palette:          newThemePalette(theme, a.diffPaletteVariant, a.paletteOptions),
 		Style: t.Style{
 			Width:           t.Flex(1),
 			Padding:         t.EdgeInsets{},
@@ -946,10 +1156,14 @@ func (a *Dv) buildSectionInfoCard(theme t.ThemeData) t.Widget {
 	fileCount := 0
 	additions := 0
 	deletions := 0
+	suspiciousChars := 0
+	var files []diffStatRow
 	if state != nil {
 		fileCount = len(state.orderedFilePaths)
 		additions = state.additions
 		deletions = state.deletions
+		suspiciousChars = state.suspiciousChars
+		files = sortedDiffStatFileRows(state.files, a.sectionStatSort)
 	}
 
 	details := fmt.Sprintf("Changed files in this section: %d.", fileCount)
@@ -962,19 +1176,30 @@ func (a *Dv) buildSectionInfoCard(theme t.ThemeData) t.Widget {
 		a.dualActionHint("Next file", "Prev file", "Jump between files"),
 		a.actionHint("Filter files", "Filter files"),
 	}
+	if len(files) > 1 {
+		actions = append(actions, a.actionHint("Sort files", "Sort files by "+a.sectionStatSort.Next().DisplayName()))
+	}
 	if a.manualRefreshEnabled {
 		actions = append(actions, a.actionHint("Refresh", "Refresh diff"))
 	}
 
+	stats := []infoCardStat{
+		{Label: "Touched files", Value: fmt.Sprintf("%d", fileCount)},
+		{Label: "Additions", Value: fmt.Sprintf("+%d", additions), ValueColor: theme.Success, Colorized: true},
+		{Label: "Deletions", Value: fmt.Sprintf("-%d", deletions), ValueColor: theme.Error, Colorized: true},
+	}
+	if suspiciousChars > 0 {
+		details += fmt.Sprintf(" Added lines contain %d bidi, invisible, confusable or control characters.", suspiciousChars)
+		stats = append(stats, infoCardStat{Label: "Suspicious characters", Value: fmt.Sprintf("%d", suspiciousChars), ValueColor: theme.Error, Colorized: true})
+	}
+
 	return a.buildInfoCard(theme, infoCardModel{
 		Details:    details,
 		Background: sectionInfoCardBackground(theme, a.activeSection),
-		Stats: []infoCardStat{
-			{Label: "Touched files", Value: fmt.Sprintf("%d", fileCount)},
-			{Label: "Additions", Value: fmt.Sprintf("+%d", additions), ValueColor: theme.Success, Colorized: true},
-			{Label: "Deletions", Value: fmt.Sprintf("-%d", deletions), ValueColor: theme.Error, Colorized: true},
-		},
-		Actions: actions,
+		Stats:      stats,
+		Files:      files,
+		FilesSort:  a.sectionStatSort,
+		Actions:    actions,
 	})
 }
 
@@ -1072,6 +1297,13 @@ func (a *Dv) buildInfoCard(theme t.ThemeData, model infoCardModel) t.Widget {
 		}
 	}
 
+	if len(model.Files) > 0 {
+		if len(children) > 0 {
+			children = append(children, t.Spacer{Height: t.Cells(1)})
+		}
+		children = append(children, buildDiffStatTable(theme, model.Files, model.FilesSort)...)
+	}
+
 	if len(model.Actions) > 0 {
 		if len(children) > 0 {
 			children = append(children, t.Spacer{Height: t.Cells(1)})
@@ -1119,6 +1351,61 @@ func (a *Dv) emptyStateActionHints() []string {
 	return actions
 }
 
+// buildDiffStatTable lays files out as a table of change counts with a
+// histogram column scaled to the largest file.
+func buildDiffStatTable(theme t.ThemeData, rows []diffStatRow, sortMode diffStatSortMode) []t.Widget {
+	maxChanged := 0
+	for _, row := range rows {
+		maxChanged = max(maxChanged, row.Changed())
+	}
+	column := func(label string, mode diffStatSortMode) string {
+		if mode == sortMode {
+			return label + "▾"
+		}
+		return label
+	}
+
+	widgets := []t.Widget{
+		t.Text{
+			Content: "Files by " + sortMode.DisplayName(),
+			Style: t.Style{
+				ForegroundColor: theme.Text,
+				Bold:            true,
+			},
+		},
+		t.Text{
+			Content: fmt.Sprintf("%8s %8s %8s  %-*s  %s",
+				column("Changed", diffStatSortChanged),
+				column("Added", diffStatSortAdditions),
+				column("Removed", diffStatSortDeletions),
+				diffStatHistogramWidth, "Histogram",
+				column("File", diffStatSortPath),
+			),
+			Style: t.Style{
+				ForegroundColor: theme.TextMuted,
+			},
+		},
+	}
+	for _, row := range rows {
+		changed := fmt.Sprintf("%d", row.Changed())
+		if row.Binary && row.Changed() == 0 {
+			changed = "Bin"
+		}
+		plus, minus := diffStatBarCounts(row.Additions, row.Deletions, maxChanged, diffStatHistogramWidth)
+		widgets = append(widgets, t.Text{
+			Spans: []t.Span{
+				t.StyledSpan(fmt.Sprintf("%8s ", changed), t.SpanStyle{Foreground: theme.Text}),
+				t.StyledSpan(fmt.Sprintf("%8s ", fmt.Sprintf("+%d", row.Additions)), t.SpanStyle{Foreground: theme.Success}),
+				t.StyledSpan(fmt.Sprintf("%8s  ", fmt.Sprintf("-%d", row.Deletions)), t.SpanStyle{Foreground: theme.Error}),
+				t.StyledSpan(strings.Repeat("+", plus), t.SpanStyle{Foreground: theme.Success}),
+				t.StyledSpan(strings.Repeat("-", minus), t.SpanStyle{Foreground: theme.Error}),
+				t.StyledSpan(strings.Repeat(" ", diffStatHistogramWidth-plus-minus+2)+row.Path, t.SpanStyle{Foreground: theme.Text}),
+			},
+		})
+	}
+	return widgets
+}
+
 func (a *Dv) actionHint(actionName string, description string) string {
 	key := a.keybindKeyByName(actionName)
 	if key == "" {
@@ -1304,10 +1591,14 @@ func (a *Dv) buildHeaderModeIndicator(theme t.ThemeData) t.Widget {
 		t.StyledSpan(a.diffLayoutModeLabel(), t.SpanStyle{
 			Foreground: theme.Text,
 		}),
-		t.PlainSpan(" "),
-		t.StyledSpan("[v]", t.SpanStyle{
-			Foreground: theme.Text,
-		}),
+	}
+	if hint := a.keyHint(keyActionToggleSplit); hint != "" {
+		spans = append(spans,
+			t.PlainSpan(" "),
+			t.StyledSpan(hint, t.SpanStyle{
+				Foreground: theme.Text,
+			}),
+		)
 	}
 	if a.canToggleDiffIgnoreWhitespace() {
 		ignoreWsLabel := "whitespace:off"
@@ -1319,11 +1610,15 @@ func (a *Dv) buildHeaderModeIndicator(theme t.ThemeData) t.Widget {
 			t.StyledSpan(ignoreWsLabel, t.SpanStyle{
 				Foreground: theme.Text,
 			}),
-			t.PlainSpan(" "),
-			t.StyledSpan("[x]", t.SpanStyle{
-				Foreground: theme.Text,
-			}),
 		)
+		if hint := a.keyHint(keyActionToggleIgnoreWhitespace); hint != "" {
+			spans = append(spans,
+				t.PlainSpan(" "),
+				t.StyledSpan(hint, t.SpanStyle{
+					Foreground: theme.Text,
+				}),
+			)
+		}
 	}
 	return t.Text{Spans: spans}
 }
@@ -1343,7 +1638,7 @@ func (a *Dv) manualRefresh() {
 }
 
 func (a *Dv) canToggleDiffIgnoreWhitespace() bool {
-	return !a.isPipedDiffMode()
+	return a.ignoreWhitespaceEnabled
 }
 
 func (a *Dv) canCopyActiveFilePath() bool {
@@ -1396,6 +1691,7 @@ func (a *Dv) refreshDiff() {
 	}
 	nextSections := newDiffSectionStateMap(a.sectionOrder)
 
+	diffBytes := 0
 	for idx, section := range a.sectionOrder {
 		raw, err := a.provider.LoadDiff(section == DiffSectionStaged, a.diffIgnoreWhitespace)
 		if err != nil {
@@ -1417,15 +1713,36 @@ func (a *Dv) refreshDiff() {
 		state.renderedByPath = make(map[string]*RenderedFile, len(state.files))
 		state.sideRenderedByPath = make(map[string]*SideBySideRenderedFile, len(state.files))
 		state.fileByPath = make(map[string]*DiffFile, len(state.files))
+		state.hunkHashesByPath = make(map[string][]string, len(state.files))
+		state.collapsedByPath = make(map[string]string)
+		attributes := a.loadPathAttributes(state.files)
+		// Collapsed files are left out of move detection, so that a huge
+		// generated file neither slows it down nor claims moved lines that
+		// aren't shown.
+		var expanded []*DiffFile
 		for _, file := range state.files {
 			if file == nil {
 				continue
 			}
 			state.fileByPath[file.DisplayPath] = file
-			state.renderedByPath[file.DisplayPath] = buildRenderedFile(file)
-			state.sideRenderedByPath[file.DisplayPath] = buildSideBySideRenderedFile(file)
+			if reason := largeFileCollapseReason(file, attributes[file.DisplayPath], a.largeFileLimits, diffBytes); reason != "" {
+				state.collapsedByPath[file.DisplayPath] = reason
+				continue
+			}
+			_, bytes, _ := diffFileSize(file)
+			diffBytes += bytes
+			state.hunkHashesByPath[file.DisplayPath] = diffFileHunkHashes(file)
+			expanded = append(expanded, file)
+		}
+		state.lineMoves = detectMovedLines(expanded)
+		for _, file := range state.files {
+			if file == nil {
+				continue
+			}
+			state.renderedByPath[file.DisplayPath], state.sideRenderedByPath[file.DisplayPath] = a.renderFile(section, state, file)
 			state.additions += file.Additions
 			state.deletions += file.Deletions
+			state.suspiciousChars += file.SuspiciousChars
 		}
 
 		roots, localTreePaths, orderedFilePaths := buildDiffTreeForSection(section, state.files)
@@ -1606,7 +1923,7 @@ func (a *Dv) onTreeCursorChange(node DiffTreeNodeData) {
 		if state := a.sectionState(a.activeSection); state != nil {
 			state.lastSelectedPath = node.Path
 		}
-		a.diffViewState.SetRenderedPair(rendered, sideRendered)
+		a.setActiveRenderedPair(rendered, sideRendered)
 		a.restoreFileScrollOffset(node.Path)
 	}
 }
@@ -1622,20 +1939,101 @@ func (a *Dv) setActiveFile(file *DiffFile) {
 	if state := a.sectionState(a.activeSection); state != nil {
 		state.lastSelectedPath = file.DisplayPath
 	}
-	rendered, ok := a.renderedByPath[file.DisplayPath]
-	if !ok {
-		rendered = buildRenderedFile(file)
+	rendered, renderedOK := a.renderedByPath[file.DisplayPath]
+	sideRendered, sideOK := a.sideRenderedByPath[file.DisplayPath]
+	if !renderedOK || !sideOK {
+		rendered, sideRendered = a.renderFile(a.activeSection, a.sectionState(a.activeSection), file)
 		a.renderedByPath[file.DisplayPath] = rendered
-	}
-	sideRendered, ok := a.sideRenderedByPath[file.DisplayPath]
-	if !ok {
-		sideRendered = buildSideBySideRenderedFile(file)
 		a.sideRenderedByPath[file.DisplayPath] = sideRendered
 	}
-	a.diffViewState.SetRenderedPair(rendered, sideRendered)
+	a.setActiveRenderedPair(rendered, sideRendered)
 	a.restoreFileScrollOffset(file.DisplayPath)
 }
 
+// renderFile builds both layouts of file, or a placeholder when state
+// collapsed it and it has not been rendered anyway. Files rendered anyway skip
+// syntax highlighting and intraline changes.
+func (a *Dv) renderFile(section DiffSection, state *diffSectionState, file *DiffFile) (*RenderedFile, *SideBySideRenderedFile) {
+	options := a.fileRenderOptions(state, file.DisplayPath)
+	if state != nil {
+		if reason := state.collapsedByPath[file.DisplayPath]; reason != "" {
+			if !a.renderAnyway[section][file.DisplayPath] {
+				rendered := buildCollapsedRenderedFile(file, reason, upperFirst(a.keyPrompt(keyActionRenderAnyway, "render it anyway"))+".")
+				return rendered, buildSideBySideFromRendered(rendered)
+			}
+			options.Plain = true
+		}
+	}
+	return buildRenderedFileWithOptions(file, options), buildSideBySideRenderedFileWithOptions(file, options)
+}
+
+// loadPathAttributes looks up the .gitattributes that collapse files, when
+// the provider can. Files are left to the size limits if the lookup fails.
+func (a *Dv) loadPathAttributes(files []*DiffFile) map[string]pathAttributes {
+	provider, ok := a.provider.(PathAttributesProvider)
+	if !ok || len(files) == 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		if file != nil {
+			paths = append(paths, file.DisplayPath)
+		}
+	}
+	attributes, err := provider.PathAttributes(paths)
+	if err != nil {
+		return nil
+	}
+	return attributes
+}
+
+// activeFileCollapsed reports whether the active file is showing its
+// collapsed placeholder.
+func (a *Dv) activeFileCollapsed() bool {
+	section, filePath, ok := a.activeReviewTarget()
+	if !ok {
+		return false
+	}
+	state := a.sectionState(section)
+	return state != nil && state.collapsedByPath[filePath] != "" && !a.renderAnyway[section][filePath]
+}
+
+// renderActiveFileAnyway renders the collapsed active file in full.
+func (a *Dv) renderActiveFileAnyway() {
+	if !a.activeFileCollapsed() {
+		return
+	}
+	section, filePath, _ := a.activeReviewTarget()
+	state := a.sectionState(section)
+	file := state.fileByPath[filePath]
+	if file == nil {
+		return
+	}
+	if a.renderAnyway[section] == nil {
+		a.renderAnyway[section] = map[string]bool{}
+	}
+	a.renderAnyway[section][filePath] = true
+	rendered, sideRendered := a.renderFile(section, state, file)
+	state.renderedByPath[filePath] = rendered
+	state.sideRenderedByPath[filePath] = sideRendered
+	a.setActiveRenderedPair(rendered, sideRendered)
+	a.setDiffVerticalOffset(0)
+}
+
+// fileRenderOptions returns how the file at filePath in state is rendered.
+func (a *Dv) fileRenderOptions(state *diffSectionState, filePath string) fileRenderOptions {
+	options := fileRenderOptions{
+		Intraline:      true,
+		WordDiff:       a.diffWordDiff,
+		ShowWhitespace: a.diffShowWhitespace,
+		FileHeaders:    fileHeadersEnabled(a.provider),
+	}
+	if state != nil {
+		options.Moves = state.lineMoves[filePath]
+	}
+	return options
+}
+
 func (a *Dv) setActiveDirectory(node DiffTreeNodeData) {
 	if node.Section != "" {
 		a.setActiveSection(node.Section)
@@ -1834,15 +2232,231 @@ func (a *Dv) activeReviewTarget() (section DiffSection, filePath string, ok bool
 	return section, a.activePath, true
 }
 
+// isReviewed reports whether every hunk of a file has been marked seen.
 func (a *Dv) isReviewed(section DiffSection, filePath string) bool {
-	if a.reviewedByFile == nil {
+	seen := a.seenHunkHashes(section, filePath)
+	if len(seen) == 0 {
+		return false
+	}
+	hashes := a.currentHunkHashes(section, filePath)
+	if len(hashes) == 0 {
+		return false
+	}
+	for _, hash := range hashes {
+		if !seen[hash] {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *Dv) isHunkReviewed(section DiffSection, filePath string, hunkIdx int) bool {
+	hashes := a.currentHunkHashes(section, filePath)
+	if hunkIdx < 0 || hunkIdx >= len(hashes) {
+		return false
+	}
+	return a.seenHunkHashes(section, filePath)[hashes[hunkIdx]]
+}
+
+// isChangedSinceSeen reports whether a hunk of the file was marked seen but
+// no longer appears in its diff, or the whole file was seen and has gained a
+// hunk since.
+func (a *Dv) isChangedSinceSeen(section DiffSection, filePath string) bool {
+	seen := a.seenHunkHashes(section, filePath)
+	if len(seen) == 0 {
+		return false
+	}
+	hashes := a.currentHunkHashes(section, filePath)
+	if len(hashes) == 0 {
 		return false
 	}
+	current := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		current[hash] = true
+		if seen[seenAllHunksMark] && !seen[hash] {
+			return true
+		}
+	}
+	for hash := range seen {
+		if hash != seenAllHunksMark && !current[hash] {
+			return true
+		}
+	}
+	return false
+}
+
+// seenHunkHashes returns the seen marks of a file made in the current
+// whitespace mode.
+func (a *Dv) seenHunkHashes(section DiffSection, filePath string) map[string]bool {
+	if a.reviewedByFile == nil {
+		return nil
+	}
 	key := diffFileReviewKey(section, filePath)
 	if key == "" {
-		return false
+		return nil
+	}
+	var seen map[string]bool
+	for mark := range a.reviewedByFile[key] {
+		hash, ignoreWhitespace := strings.CutPrefix(mark, seenIgnoreWhitespacePrefix)
+		if ignoreWhitespace != a.diffIgnoreWhitespace {
+			continue
+		}
+		if seen == nil {
+			seen = map[string]bool{}
+		}
+		seen[hash] = true
+	}
+	return seen
+}
+
+func (a *Dv) currentHunkHashes(section DiffSection, filePath string) []string {
+	state := a.sectionState(section)
+	if state == nil {
+		return nil
+	}
+	return state.hunkHashes(filePath)
+}
+
+// setSeenHunks records which hunks of a file are seen in the current
+// whitespace mode, dropping marks for hunks that are no longer part of the
+// diff. Marks made in the other mode are kept.
+func (a *Dv) setSeenHunks(section DiffSection, filePath string, seen map[string]bool) {
+	key := diffFileReviewKey(section, filePath)
+	if key == "" {
+		return
+	}
+	if a.reviewedByFile == nil {
+		a.reviewedByFile = map[string]map[string]bool{}
+	}
+	hashes := a.currentHunkHashes(section, filePath)
+	next := map[string]bool{}
+	for _, hash := range hashes {
+		if seen[hash] {
+			next[hash] = true
+		}
+	}
+	if len(next) > 0 && len(next) == len(hashes) {
+		next[seenAllHunksMark] = true
+	}
+	prefix := ""
+	if a.diffIgnoreWhitespace {
+		prefix = seenIgnoreWhitespacePrefix
+	}
+	marks := make(map[string]bool, len(next))
+	for hash := range next {
+		marks[prefix+hash] = true
+	}
+	for mark := range a.reviewedByFile[key] {
+		if strings.HasPrefix(mark, seenIgnoreWhitespacePrefix) != a.diffIgnoreWhitespace {
+			marks[mark] = true
+		}
+	}
+	if len(marks) == 0 {
+		delete(a.reviewedByFile, key)
+	} else {
+		a.reviewedByFile[key] = marks
+	}
+	a.saveSeenMarks()
+}
+
+func (a *Dv) seenScope() string {
+	providerScope := ""
+	if scoped, ok := a.provider.(SeenScopeDescriber); ok {
+		providerScope = scoped.SeenScope()
+	}
+	return seenStateScope(a.repoRoot, providerScope)
+}
+
+func (a *Dv) loadSeenMarks() {
+	marks, err := a.seenStore.Load(a.seenScope())
+	if err != nil {
+		a.seenStateErr = "Seen marks: " + err.Error()
+		return
+	}
+	if len(marks) == 0 {
+		return
+	}
+	a.reviewedByFile = marks
+}
+
+func (a *Dv) saveSeenMarks() {
+	if err := a.seenStore.Save(a.seenScope(), a.reviewedByFile); err != nil {
+		a.seenStateErr = "Seen marks not saved: " + err.Error()
+		return
+	}
+	a.seenStateErr = ""
+}
+
+// loadUIState restores the layout remembered for the repo. The remembered
+// theme is only applied when restoreTheme is set.
+func (a *Dv) loadUIState(restoreTheme bool) {
+	if a.uiStateStore.Path == "" || a.repoRoot == "" {
+		return
+	}
+	ui, err := a.uiStateStore.Load(a.repoRoot)
+	if err != nil {
+		a.uiStateErr = "Layout: " + err.Error()
+		return
+	}
+	if ui.SidebarRatio != nil && *ui.SidebarRatio > 0 && *ui.SidebarRatio < 1 {
+		a.splitState.SetPosition(*ui.SidebarRatio)
+	}
+	if ui.SplitRatio != nil {
+		a.diffViewState.SetSideBySideSplitRatio(*ui.SplitRatio)
+	}
+	if ui.Wrap != nil {
+		a.diffHardWrap = *ui.Wrap
+	}
+	if ui.Theme != "" {
+		a.pickedTheme = ui.Theme
+		if themeName, err := parseThemeName(ui.Theme); err == nil && restoreTheme {
+			t.SetTheme(themeName)
+		}
+	}
+	if len(ui.CollapsedDirs) > 0 {
+		dirKeys := map[string]bool{}
+		collectDiffTreeDirKeys(a.treeState.Nodes.Peek(), dirKeys)
+		collapsed := map[string]bool{}
+		for _, key := range ui.CollapsedDirs {
+			if dirKeys[key] {
+				collapsed[key] = true
+			}
+		}
+		a.treeState.Collapsed.Set(collapsed)
+	}
+}
+
+// saveUIState remembers the current layout for the repo.
+func (a *Dv) saveUIState() error {
+	if a.uiStateStore.Path == "" || a.repoRoot == "" {
+		return nil
+	}
+	sidebarRatio := a.splitState.GetPosition()
+	splitRatio := a.diffViewState.SideBySideSplitRatio()
+	wrap := a.diffHardWrap
+	var collapsed []string
+	for key, isCollapsed := range a.treeState.Collapsed.Peek() {
+		if isCollapsed {
+			collapsed = append(collapsed, key)
+		}
+	}
+	sort.Strings(collapsed)
+	return a.uiStateStore.Save(a.repoRoot, UIState{
+		SidebarRatio:  &sidebarRatio,
+		SplitRatio:    &splitRatio,
+		Wrap:          &wrap,
+		Theme:         a.pickedTheme,
+		CollapsedDirs: collapsed,
+	})
+}
+
+func collectDiffTreeDirKeys(nodes []t.TreeNode[DiffTreeNodeData], keys map[string]bool) {
+	for _, node := range nodes {
+		if node.Data.IsDir && node.Data.NodeKey != "" {
+			keys[node.Data.NodeKey] = true
+		}
+		collectDiffTreeDirKeys(node.Children, keys)
 	}
-	return a.reviewedByFile[key]
 }
 
 func (a *Dv) rememberActiveFileScrollOffset() {
@@ -2139,18 +2753,18 @@ func findRenderedRowForAnchor(lines []RenderedDiffLine, anchor diffScrollAnchor)
 	}
 
 	switch anchor.kind {
-	case RenderedLineAdd:
+	case RenderedLineAdd, RenderedLineMovedAdd, RenderedLineWordDiff:
 		if anchor.newLine > 0 {
 			if idx := find(func(line RenderedDiffLine) bool {
-				return line.Kind == RenderedLineAdd && line.NewLine == anchor.newLine
+				return line.Kind.isAddition() && line.NewLine == anchor.newLine
 			}); idx >= 0 {
 				return idx
 			}
 		}
-	case RenderedLineRemove:
+	case RenderedLineRemove, RenderedLineMovedRemove:
 		if anchor.oldLine > 0 {
 			if idx := find(func(line RenderedDiffLine) bool {
-				return line.Kind == RenderedLineRemove && line.OldLine == anchor.oldLine
+				return line.Kind.isRemoval() && line.OldLine == anchor.oldLine
 			}); idx >= 0 {
 				return idx
 			}
@@ -2214,18 +2828,18 @@ func findSideBySideRowForAnchor(rows []SideBySideRenderedRow, anchor diffScrollA
 	}
 
 	switch anchor.kind {
-	case RenderedLineAdd:
+	case RenderedLineAdd, RenderedLineMovedAdd, RenderedLineWordDiff:
 		if anchor.newLine > 0 {
 			if idx := find(func(rowAnchor diffScrollAnchor) bool {
-				return rowAnchor.kind == RenderedLineAdd && rowAnchor.newLine == anchor.newLine
+				return rowAnchor.kind.isAddition() && rowAnchor.newLine == anchor.newLine
 			}); idx >= 0 {
 				return idx
 			}
 		}
-	case RenderedLineRemove:
+	case RenderedLineRemove, RenderedLineMovedRemove:
 		if anchor.oldLine > 0 {
 			if idx := find(func(rowAnchor diffScrollAnchor) bool {
-				return rowAnchor.kind == RenderedLineRemove && rowAnchor.oldLine == anchor.oldLine
+				return rowAnchor.kind.isRemoval() && rowAnchor.oldLine == anchor.oldLine
 			}); idx >= 0 {
 				return idx
 			}
@@ -2297,6 +2911,10 @@ func (a *Dv) toggleDiffIgnoreWhitespace() {
 	a.refreshDiff()
 }
 
+func (a *Dv) cycleSectionStatSort() {
+	a.sectionStatSort = a.sectionStatSort.Next()
+}
+
 func (a *Dv) toggleDiffIntralineStyle() {
 	switch a.diffIntralineStyle {
 	case IntralineStyleModeBackground:
@@ -2308,23 +2926,170 @@ func (a *Dv) toggleDiffIntralineStyle() {
 	}
 }
 
-func (a *Dv) toggleActiveFileReviewed() {
-	section, filePath, ok := a.activeReviewTarget()
-	if !ok {
-		return
-	}
-	if a.reviewedByFile == nil {
-		a.reviewedByFile = map[string]bool{}
-	}
-	key := diffFileReviewKey(section, filePath)
-	if key == "" {
-		return
-	}
-	if a.reviewedByFile[key] {
-		delete(a.reviewedByFile, key)
+// toggleDiffWordDiff switches between line and word diffs.
+func (a *Dv) toggleDiffWordDiff() {
+	a.diffWordDiff = !a.diffWordDiff
+	a.rerenderFiles()
+}
+
+// toggleDiffShowWhitespace switches whitespace glyphs on and off.
+func (a *Dv) toggleDiffShowWhitespace() {
+	a.diffShowWhitespace = !a.diffShowWhitespace
+	a.rerenderFiles()
+}
+
+// rerenderFiles renders the loaded files again after a rendering option
+// changes, without reloading the diff.
+func (a *Dv) rerenderFiles() {
+	for _, section := range a.sectionOrder {
+		state := a.sectionState(section)
+		if state == nil {
+			continue
+		}
+		for _, file := range state.files {
+			if file == nil {
+				continue
+			}
+			state.renderedByPath[file.DisplayPath], state.sideRenderedByPath[file.DisplayPath] = a.renderFile(section, state, file)
+		}
+	}
+	// Decorating the active file again shows its new rendering.
+	a.refreshActiveReviewComments()
+}
+
+func (a *Dv) toggleActiveFileReviewed() {
+	section, filePath, ok := a.activeReviewTarget()
+	if !ok {
+		return
+	}
+	if a.isReviewed(section, filePath) {
+		a.setSeenHunks(section, filePath, nil)
+		return
+	}
+	seen := map[string]bool{}
+	for _, hash := range a.currentHunkHashes(section, filePath) {
+		seen[hash] = true
+	}
+	a.setSeenHunks(section, filePath, seen)
+}
+
+// toggleVisibleHunkReviewed toggles the seen mark of the hunk at the top of
+// the diff viewport.
+func (a *Dv) toggleVisibleHunkReviewed() {
+	section, filePath, ok := a.activeReviewTarget()
+	if !ok {
 		return
 	}
-	a.reviewedByFile[key] = true
+	hunkIdx, ok := a.visibleHunkIndex()
+	if !ok {
+		return
+	}
+	hashes := a.currentHunkHashes(section, filePath)
+	if hunkIdx >= len(hashes) {
+		return
+	}
+	seen := map[string]bool{}
+	for hash := range a.seenHunkHashes(section, filePath) {
+		seen[hash] = true
+	}
+	if seen[hashes[hunkIdx]] {
+		delete(seen, hashes[hunkIdx])
+	} else {
+		seen[hashes[hunkIdx]] = true
+	}
+	a.setSeenHunks(section, filePath, seen)
+}
+
+// visibleHunkIndex returns the 0-based index of the first hunk shown at or
+// below the top of the diff viewport.
+func (a *Dv) visibleHunkIndex() (int, bool) {
+	if a.diffViewState == nil || a.diffScrollState == nil {
+		return 0, false
+	}
+	top := max(0, a.diffScrollState.Offset.Peek())
+	span := max(1, a.diffViewState.ViewportHeight())
+	for row := top; row < top+span; row++ {
+		hunk, ok := a.diffRowHunk(a.diffLayoutMode, row)
+		if !ok {
+			break
+		}
+		if hunk > 0 {
+			return hunk - 1, true
+		}
+	}
+	return 0, false
+}
+
+// diffRowHunk returns the 1-based hunk number rendered at a visual row, or 0
+// when the row is outside of any hunk.
+func (a *Dv) diffRowHunk(mode DiffLayoutMode, row int) (int, bool) {
+	line, sideRow, ok := a.diffRenderedRowAt(mode, row)
+	if !ok {
+		return 0, false
+	}
+	if mode == DiffLayoutSideBySide {
+		return sideRowHunk(sideRow), true
+	}
+	return line.Hunk, true
+}
+
+// diffRenderedRowAt returns the rendered line (unified) or row (side-by-side)
+// drawn at a visual row of the diff view.
+func (a *Dv) diffRenderedRowAt(mode DiffLayoutMode, row int) (RenderedDiffLine, SideBySideRenderedRow, bool) {
+	if mode == DiffLayoutSideBySide {
+		sideBySide := a.diffViewState.SideBySide.Peek()
+		if sideBySide == nil {
+			return RenderedDiffLine{}, SideBySideRenderedRow{}, false
+		}
+		if a.diffHardWrap && a.diffViewState.ViewportWidth() > 0 {
+			viewportWidth := a.diffViewState.ViewportWidth()
+			panes := sideBySidePaneLayout(viewportWidth, sideBySide, a.diffHideChangeSigns, a.diffViewState.SideBySideSplitRatio())
+			line, _, ok := wrappedSideRowAtRow(sideBySide.Rows, panes, viewportWidth, row)
+			return RenderedDiffLine{}, line, ok
+		}
+		if row < 0 || row >= len(sideBySide.Rows) {
+			return RenderedDiffLine{}, SideBySideRenderedRow{}, false
+		}
+		return RenderedDiffLine{}, sideBySide.Rows[row], true
+	}
+
+	rendered := a.diffViewState.Rendered.Peek()
+	if rendered == nil {
+		return RenderedDiffLine{}, SideBySideRenderedRow{}, false
+	}
+	if a.diffHardWrap && a.diffViewState.ViewportWidth() > 0 {
+		wrapWidth := max(1, a.diffViewState.ViewportWidth()-renderedGutterWidth(rendered, a.diffHideChangeSigns))
+		line, _, ok := wrappedLineAtRow(rendered.Lines, wrapWidth, row)
+		return line, SideBySideRenderedRow{}, ok
+	}
+	if row < 0 || row >= len(rendered.Lines) {
+		return RenderedDiffLine{}, SideBySideRenderedRow{}, false
+	}
+	return rendered.Lines[row], SideBySideRenderedRow{}, true
+}
+
+// activeSeenHunks returns the 1-based numbers of seen hunks in the active file.
+func (a *Dv) activeSeenHunks() map[int]bool {
+	section, filePath, ok := a.activeReviewTarget()
+	if !ok {
+		return nil
+	}
+	return a.seenHunksForFile(section, filePath)
+}
+
+// seenHunksForFile returns the 1-based numbers of seen hunks in a file.
+func (a *Dv) seenHunksForFile(section DiffSection, filePath string) map[int]bool {
+	seen := a.seenHunkHashes(section, filePath)
+	if len(seen) == 0 {
+		return nil
+	}
+	hunks := map[int]bool{}
+	for idx, hash := range a.currentHunkHashes(section, filePath) {
+		if seen[hash] {
+			hunks[idx+1] = true
+		}
+	}
+	return hunks
 }
 
 func (a *Dv) clearAllReviewed() {
@@ -2332,6 +3097,479 @@ func (a *Dv) clearAllReviewed() {
 		return
 	}
 	clear(a.reviewedByFile)
+	a.saveSeenMarks()
+}
+
+// setActiveRenderedPair shows the active file's cached render, with its
+// review comments inserted as annotation rows.
+func (a *Dv) setActiveRenderedPair(rendered *RenderedFile, sideRendered *SideBySideRenderedFile) {
+	comments := a.activeReviewComments()
+	a.diffViewState.SetRenderedPair(
+		decorateRenderedWithReviewComments(rendered, comments),
+		decorateSideBySideWithReviewComments(sideRendered, comments),
+	)
+}
+
+// refreshActiveReviewComments re-decorates the active file after its comments
+// change, keeping the current scroll position.
+func (a *Dv) refreshActiveReviewComments() {
+	_, filePath, ok := a.activeReviewTarget()
+	if !ok {
+		return
+	}
+	rendered, ok := a.renderedByPath[filePath]
+	if !ok {
+		return
+	}
+	sideRendered := a.sideRenderedByPath[filePath]
+	if sideRendered == nil {
+		sideRendered = buildSideBySideFromRendered(rendered)
+	}
+	offset := a.currentDiffVerticalOffset()
+	scrollX := a.diffViewState.ScrollX.Peek()
+	a.setActiveRenderedPair(rendered, sideRendered)
+	a.setDiffVerticalOffset(offset)
+	a.diffViewState.ScrollX.Set(scrollX)
+	a.clampDiffHorizontalScroll()
+}
+
+func (a *Dv) activeReviewComments() []ReviewComment {
+	section, filePath, ok := a.activeReviewTarget()
+	if !ok {
+		return nil
+	}
+	return a.reviewCommentsForFile(section, filePath)
+}
+
+func (a *Dv) reviewCommentsForFile(section DiffSection, filePath string) []ReviewComment {
+	var comments []ReviewComment
+	for _, comment := range a.reviewComments {
+		if comment.Section == section && comment.Path == filePath {
+			comments = append(comments, comment)
+		}
+	}
+	return comments
+}
+
+// handleDiffLineClick selects the clicked line for commenting. Shift-click
+// extends the selection into a range; clicking a lone selected line again
+// clears it.
+func (a *Dv) handleDiffLineClick(side ReviewCommentSide, line int, extend bool) {
+	section, filePath, ok := a.activeReviewTarget()
+	if !ok {
+		return
+	}
+	current := a.commentSelection
+	sameSide := current.section == section && current.path == filePath && current.side == side && current.anchor > 0
+	switch {
+	case extend && sameSide:
+		current.active = line
+		a.commentSelection = current
+	case sameSide && current.anchor == line && current.active == line:
+		a.commentSelection = reviewLineSelection{}
+	default:
+		a.commentSelection = reviewLineSelection{section: section, path: filePath, side: side, anchor: line, active: line}
+	}
+}
+
+// activeCommentSelection returns the selected lines if they belong to the
+// active file.
+func (a *Dv) activeCommentSelection() reviewLineSpan {
+	section, filePath, ok := a.activeReviewTarget()
+	if !ok {
+		return reviewLineSpan{}
+	}
+	if a.commentSelection.section != section || a.commentSelection.path != filePath {
+		return reviewLineSpan{}
+	}
+	return a.commentSelection.span()
+}
+
+// visibleLineTarget returns the first commentable line at or below the top of
+// the diff viewport.
+func (a *Dv) visibleLineTarget() (ReviewCommentSide, int, bool) {
+	if a.diffViewState == nil || a.diffScrollState == nil {
+		return "", 0, false
+	}
+	top := max(0, a.diffScrollState.Offset.Peek())
+	span := max(1, a.diffViewState.ViewportHeight())
+	for row := top; row < top+span; row++ {
+		line, sideRow, ok := a.diffRenderedRowAt(a.diffLayoutMode, row)
+		if !ok {
+			break
+		}
+		if a.diffLayoutMode != DiffLayoutSideBySide {
+			if side, number, ok := unifiedLineTarget(line); ok {
+				return side, number, true
+			}
+			continue
+		}
+		if side, number, ok := sideCellLineTarget(sideRow.Right, false); ok {
+			return side, number, true
+		}
+		if side, number, ok := sideCellLineTarget(sideRow.Left, true); ok {
+			return side, number, true
+		}
+	}
+	return "", 0, false
+}
+
+// openReviewCommentDraft opens the comment editor for the selected lines, or
+// for the line at the top of the viewport when nothing is selected. An
+// existing comment on the same lines is opened for editing.
+func (a *Dv) openReviewCommentDraft() {
+	section, filePath, ok := a.activeReviewTarget()
+	if !ok {
+		return
+	}
+	span := a.activeCommentSelection()
+	if span.isEmpty() {
+		side, line, ok := a.visibleLineTarget()
+		if !ok {
+			return
+		}
+		span = newReviewLineSpan(side, line, line)
+	}
+	draft := ReviewComment{
+		Section:   section,
+		Path:      filePath,
+		Side:      span.side,
+		StartLine: span.start,
+		EndLine:   span.end,
+		Excerpt:   reviewCommentExcerpt(a.fileByPath[filePath], span),
+	}
+	for _, existing := range a.reviewComments {
+		if existing.sameTarget(draft) {
+			draft.Body = existing.Body
+			break
+		}
+	}
+	a.commentDraft = &draft
+	a.commentInput = t.NewTextAreaState(draft.Body)
+	a.requestFocusPastPalette(diffCommentInputID)
+}
+
+// saveReviewCommentDraft stores the draft. Saving an empty comment deletes
+// any existing comment on the same lines.
+func (a *Dv) saveReviewCommentDraft() {
+	if a.commentDraft == nil {
+		return
+	}
+	draft := *a.commentDraft
+	if a.commentInput != nil {
+		draft.Body = strings.TrimSpace(a.commentInput.GetText())
+	}
+	kept := a.reviewComments[:0]
+	for _, existing := range a.reviewComments {
+		if !existing.sameTarget(draft) {
+			kept = append(kept, existing)
+		}
+	}
+	a.reviewComments = kept
+	if draft.Body != "" {
+		a.reviewComments = append(a.reviewComments, draft)
+		sortReviewComments(a.reviewComments)
+	}
+	a.commentSelection = reviewLineSelection{}
+	a.closeReviewCommentDraft()
+	a.refreshActiveReviewComments()
+}
+
+func (a *Dv) closeReviewCommentDraft() {
+	a.commentDraft = nil
+	a.commentInput = nil
+	a.requestFocusPastPalette(diffViewerScrollID)
+}
+
+func (a *Dv) jumpToReviewComment(comment ReviewComment) {
+	if comment.Section != a.activeSection {
+		a.setActiveSection(comment.Section)
+	}
+	if !a.selectFilePath(comment.Path) {
+		return
+	}
+	anchor := diffScrollAnchor{kind: RenderedLineContext, newLine: comment.StartLine}
+	if comment.Side == ReviewCommentSideOld {
+		anchor = diffScrollAnchor{kind: RenderedLineRemove, oldLine: comment.StartLine}
+	}
+	if row, ok := a.diffOffsetForAnchor(a.diffLayoutMode, anchor); ok {
+		a.setDiffVerticalOffset(row)
+	}
+	a.requestFocusPastPalette(diffViewerScrollID)
+}
+
+// jumpToMovedLine shows the counterpart of a moved line, in whichever file
+// of the section it is in.
+func (a *Dv) jumpToMovedLine(move RenderedLineMove) {
+	if !a.selectFilePath(move.Path) {
+		return
+	}
+	anchor := diffScrollAnchor{kind: RenderedLineMovedAdd, newLine: move.Line}
+	if move.Side == ReviewCommentSideOld {
+		anchor = diffScrollAnchor{kind: RenderedLineMovedRemove, oldLine: move.Line}
+	}
+	if row, ok := a.diffOffsetForAnchor(a.diffLayoutMode, anchor); ok {
+		a.setDiffVerticalOffset(row)
+	}
+	a.requestFocusPastPalette(diffViewerScrollID)
+}
+
+func (a *Dv) defaultReviewCommentsExportPath(format ReviewCommentsExportFormat) string {
+	if a.repoRoot == "" {
+		return format.defaultFileName()
+	}
+	return filepath.Join(a.repoRoot, format.defaultFileName())
+}
+
+func (a *Dv) openReviewCommentsExport(format ReviewCommentsExportFormat) {
+	a.commentExportInput = t.NewTextInputState(a.defaultReviewCommentsExportPath(format))
+	a.commentExportFormat = format
+	a.commentExportErr = ""
+	a.requestFocusPastPalette(diffExportInputID)
+}
+
+func (a *Dv) exportReviewComments(path string) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		a.commentExportErr = "Enter a file path."
+		return
+	}
+	err := writeReviewCommentsExport(resolvePathArg(a.repoRoot, path), a.commentExportFormat, a.reviewComments, a.reviewCommentFile)
+	if err != nil {
+		a.commentExportErr = err.Error()
+		return
+	}
+	a.closeReviewCommentsExport()
+}
+
+// reviewCommentFile returns the current diff of a commented file.
+func (a *Dv) reviewCommentFile(section DiffSection, path string) *DiffFile {
+	state := a.sectionState(section)
+	if state == nil {
+		return nil
+	}
+	return state.fileByPath[path]
+}
+
+func (a *Dv) closeReviewCommentsExport() {
+	a.commentExportInput = nil
+	a.commentExportErr = ""
+	a.requestFocusPastPalette(diffViewerScrollID)
+}
+
+func (a *Dv) openDiffHTMLExport() {
+	path := defaultDiffHTMLExportName
+	if a.repoRoot != "" {
+		path = filepath.Join(a.repoRoot, defaultDiffHTMLExportName)
+	}
+	a.htmlExportInput = t.NewTextInputState(path)
+	a.htmlExportErr = ""
+	a.requestFocusPastPalette(diffHTMLInputID)
+}
+
+func (a *Dv) exportDiffHTML(path string) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		a.htmlExportErr = "Enter a file path."
+		return
+	}
+	if err := a.writeDiffHTML(resolvePathArg(a.repoRoot, path)); err != nil {
+		a.htmlExportErr = err.Error()
+		return
+	}
+	a.closeDiffHTMLExport()
+}
+
+func (a *Dv) closeDiffHTMLExport() {
+	a.htmlExportInput = nil
+	a.htmlExportErr = ""
+	a.requestFocusPastPalette(diffViewerScrollID)
+}
+
+// writeDiffHTML exports every section with the current layout, theme, seen
+// marks and review comments.
+func (a *Dv) writeDiffHTML(path string) error {
+	if a.loadErr != "" {
+		return errors.New(a.loadErr)
+	}
+	theme, _ := t.GetTheme(t.CurrentThemeName())
+	return writeDiffHTML(path, a.diffHTMLExport(theme))
+}
+
+func (a *Dv) diffHTMLExport(theme t.ThemeData) diffHTMLExport {
+	title := "dv"
+	if a.repoRoot != "" {
+		title = filepath.Base(a.repoRoot)
+	}
+	if a.branch != "" {
+		title += " (" + a.branch + ")"
+	}
+	export := diffHTMLExport{
+		Title:           title,
+		LayoutMode:      a.diffLayoutMode,
+		HideChangeSigns: a.diffHideChangeSigns,
+		IntralineStyle:  a.diffIntralineStyle,
+		PaletteVariant:  a.diffPaletteVariant,
+		PaletteOptions:  a.paletteOptions,
+		Theme:           theme,
+	}
+	for _, section := range a.sectionOrder {
+		state := a.sectionState(section)
+		if state == nil || len(state.orderedFilePaths) == 0 {
+			continue
+		}
+		exported := diffHTMLSection{Section: section, Roots: state.roots}
+		for _, filePath := range state.orderedFilePaths {
+			file := state.fileByPath[filePath]
+			if file == nil {
+				continue
+			}
+			comments := a.reviewCommentsForFile(section, filePath)
+			exported.Files = append(exported.Files, diffHTMLFile{
+				File:       file,
+				Rendered:   decorateRenderedWithReviewComments(state.renderedByPath[filePath], comments),
+				SideBySide: decorateSideBySideWithReviewComments(state.sideRenderedByPath[filePath], comments),
+				SeenHunks:  a.seenHunksForFile(section, filePath),
+			})
+		}
+		export.Sections = append(export.Sections, exported)
+	}
+	return export
+}
+
+func (a *Dv) buildReviewCommentDialog(theme t.ThemeData) t.Widget {
+	if a.commentDraft == nil || a.commentInput == nil {
+		return t.Dialog{ID: diffCommentDialogID}
+	}
+	draft := *a.commentDraft
+	return t.Dialog{
+		ID:        diffCommentDialogID,
+		Visible:   true,
+		Title:     "Comment",
+		OnDismiss: a.closeReviewCommentDraft,
+		Content: t.Column{
+			Spacing: 1,
+			Children: []t.Widget{
+				t.Text{
+					Content: draft.Path + " · " + draft.span().label(),
+					Style:   t.Style{ForegroundColor: theme.TextMuted},
+				},
+				t.TextArea{
+					ID:          diffCommentInputID,
+					State:       a.commentInput,
+					Placeholder: "Write a comment...",
+					OnSubmit:    func(string) { a.saveReviewCommentDraft() },
+					ExtraKeybinds: []t.Keybind{
+						{Key: "ctrl+s", Name: "Save", Action: a.saveReviewCommentDraft},
+					},
+					Style: t.Style{
+						Width:           t.Flex(1),
+						Height:          t.Cells(6),
+						BackgroundColor: theme.Background,
+					},
+				},
+				t.Text{
+					Content: "ctrl+s save · esc cancel · save empty to delete",
+					Style:   t.Style{ForegroundColor: theme.TextMuted},
+				},
+			},
+		},
+	}
+}
+
+func (a *Dv) buildReviewCommentsExportDialog(theme t.ThemeData) t.Widget {
+	if a.commentExportInput == nil {
+		return t.Dialog{ID: diffExportDialogID}
+	}
+	children := []t.Widget{
+		t.Text{
+			Content: fmt.Sprintf("Write %d comment(s) as %s to:", len(a.reviewComments), a.commentExportFormat.label()),
+			Style:   t.Style{ForegroundColor: theme.TextMuted},
+		},
+		t.TextInput{
+			ID:       diffExportInputID,
+			State:    a.commentExportInput,
+			OnSubmit: a.exportReviewComments,
+			Style: t.Style{
+				Width:           t.Flex(1),
+				BackgroundColor: theme.Background,
+			},
+		},
+	}
+	if a.commentExportErr != "" {
+		children = append(children, t.Text{
+			Content: a.commentExportErr,
+			Style:   t.Style{ForegroundColor: theme.ErrorText},
+		})
+	}
+	return t.Dialog{
+		ID:        diffExportDialogID,
+		Visible:   true,
+		Title:     "Export comments",
+		OnDismiss: a.closeReviewCommentsExport,
+		Content:   t.Column{Spacing: 1, Children: children},
+	}
+}
+
+func (a *Dv) buildDiffHTMLExportDialog(theme t.ThemeData) t.Widget {
+	if a.htmlExportInput == nil {
+		return t.Dialog{ID: diffHTMLDialogID}
+	}
+	children := []t.Widget{
+		t.Text{
+			Content: "Write the diff as a standalone HTML page to:",
+			Style:   t.Style{ForegroundColor: theme.TextMuted},
+		},
+		t.TextInput{
+			ID:       diffHTMLInputID,
+			State:    a.htmlExportInput,
+			OnSubmit: a.exportDiffHTML,
+			Style: t.Style{
+				Width:           t.Flex(1),
+				BackgroundColor: theme.Background,
+			},
+		},
+	}
+	if a.htmlExportErr != "" {
+		children = append(children, t.Text{
+			Content: a.htmlExportErr,
+			Style:   t.Style{ForegroundColor: theme.ErrorText},
+		})
+	}
+	return t.Dialog{
+		ID:        diffHTMLDialogID,
+		Visible:   true,
+		Title:     "Export diff to HTML",
+		OnDismiss: a.closeDiffHTMLExport,
+		Content:   t.Column{Spacing: 1, Children: children},
+	}
+}
+
+// requestFocusPastPalette focuses id, also when the request comes from a
+// command palette action that is about to close the palette.
+func (a *Dv) requestFocusPastPalette(id string) {
+	t.RequestFocus(id)
+	if a.commandPalette != nil && a.commandPalette.Visible.Peek() {
+		a.commandPalette.SetNextFocusIDOnClose(id)
+	}
+}
+
+func (a *Dv) reviewCommentItems() []t.CommandPaletteItem {
+	if len(a.reviewComments) == 0 {
+		return []t.CommandPaletteItem{{Label: "No comments yet", Disabled: true}}
+	}
+	items := make([]t.CommandPaletteItem, 0, len(a.reviewComments))
+	for _, comment := range a.reviewComments {
+		target := comment
+		firstLine, _, _ := strings.Cut(comment.Body, "\n")
+		items = append(items, t.CommandPaletteItem{
+			Label:      comment.Path + ":" + comment.LineLabel(),
+			FilterText: comment.Path + " " + comment.Body,
+			Hint:       firstLine,
+			Action:     a.paletteAction(func() { a.jumpToReviewComment(target) }),
+		})
+	}
+	return items
 }
 
 func (a *Dv) clampDiffHorizontalScroll() {
@@ -2515,7 +3753,7 @@ func (a *Dv) buildTreeFilterEmptyState(theme t.ThemeData) t.Widget {
 				},
 			},
 			t.Text{
-				Content: "Press escape to clear the filter.",
+				Content: upperFirst(a.keyPrompt(keyActionClearFilter, "clear the filter")) + ".",
 				Wrap:    t.WrapSoft,
 				Style: t.Style{
 					ForegroundColor: theme.TextMuted,
@@ -2625,23 +3863,30 @@ func isInvalidDividerReturnTarget(target string) bool {
 	return strings.HasPrefix(target, diffCommandPaletteID+"-")
 }
 
-func dividerFocusForeground(theme t.ThemeData) t.ColorProvider {
-	return dividerGradient(theme, theme.Accent)
+func dividerFocusForeground(theme t.ThemeData, profile colorprofile.Profile) t.ColorProvider {
+	return dividerGradient(theme, theme.Accent, profile)
 }
 
-func dividerHoverForeground(theme t.ThemeData) t.ColorProvider {
-	return dividerGradient(theme, dividerHoverColor(theme))
+func dividerHoverForeground(theme t.ThemeData, profile colorprofile.Profile) t.ColorProvider {
+	return dividerGradient(theme, dividerHoverColor(theme), profile)
 }
 
 func dividerHoverColor(theme t.ThemeData) t.Color {
 	return theme.Accent.WithAlpha(theme.Accent.Alpha() * 0.5)
 }
 
-func dividerForeground(theme t.ThemeData) t.ColorProvider {
-	return dividerGradient(theme, theme.TextDisabled)
+func dividerForeground(theme t.ThemeData, profile colorprofile.Profile) t.ColorProvider {
+	return dividerGradient(theme, theme.TextDisabled, profile)
 }
 
-func dividerGradient(theme t.ThemeData, center t.Color) t.ColorProvider {
+// dividerGradient fades the divider into the background at both ends. The
+// zero profile, like TrueColor, keeps the gradient.
+func dividerGradient(theme t.ThemeData, center t.Color, profile colorprofile.Profile) t.ColorProvider {
+	if profile != colorprofile.Unknown && profile < colorprofile.TrueColor {
+		// The faded ends would snap to the background, so draw the divider
+		// in one solid colour instead.
+		return quantizeColor(center.BlendOver(theme.Background), profile)
+	}
 	return t.NewGradient(theme.Background, center, theme.Background).WithAngle(0)
 }
 
@@ -2738,49 +3983,65 @@ func (a *Dv) commandPaletteItems() []t.CommandPaletteItem {
 		items = append(items, t.CommandPaletteItem{
 			Label:      "Switch section",
 			FilterText: "Switch section staged unstaged files",
-			Hint:       "[s]",
+			Hint:       a.keyHint(keyActionSwitchSection),
 			Action:     a.paletteAction(a.switchSectionFocus),
 		})
 	}
 	items = append(items, t.CommandPaletteItem{
 		Label:      "Refresh",
 		FilterText: "Refresh reload diff",
-		Hint:       "[r]",
+		Hint:       a.keyHint(keyActionRefresh),
 		Action:     a.paletteAction(a.manualRefresh),
 	})
 	if a.canCopyActiveFilePath() {
 		items = append(items, t.CommandPaletteItem{
 			Label:      "Copy path",
 			FilterText: "Copy path clipboard file directory",
-			Hint:       "[y]",
+			Hint:       a.keyHint(keyActionCopyPath),
 			Action:     a.paletteAction(a.copyActiveFilePath),
 		})
 	}
+	if a.activeFileCollapsed() {
+		items = append(items, t.CommandPaletteItem{
+			Label:      "Render file anyway",
+			FilterText: "Render file anyway collapsed large generated lockfile show full diff",
+			Hint:       a.keyHint(keyActionRenderAnyway),
+			Action:     a.paletteAction(a.renderActiveFileAnyway),
+		})
+	}
+	if a.activeKind == DiffTreeNodeSection {
+		items = append(items, t.CommandPaletteItem{
+			Label:      "Sort files by " + a.sectionStatSort.Next().DisplayName(),
+			FilterText: "Sort files section stats table histogram lines changed additions deletions path order",
+			Hint:       a.keyHint(keyActionSortFiles),
+			Action:     a.paletteAction(a.cycleSectionStatSort),
+		})
+	}
 	items = append(items,
 		t.CommandPaletteItem{Divider: "Layout"},
 		t.CommandPaletteItem{
 			Label:      "Toggle sidebar",
 			FilterText: "Toggle sidebar layout panel",
-			Hint:       "[b]",
+			Hint:       a.keyHint(keyActionToggleSidebar),
 			Action:     a.paletteAction(a.toggleSidebar),
 		},
 		t.CommandPaletteItem{
 			Label:      "Focus divider",
 			FilterText: "Focus divider split resize",
-			Hint:       "[d]",
+			Hint:       a.keyHint(keyActionFocusDivider),
 			Action:     a.focusDividerFromPalette,
 		},
 		t.CommandPaletteItem{Divider: "Appearance"},
 		t.CommandPaletteItem{
 			Label:      "Toggle line wrap",
 			FilterText: "Toggle line wrap hard wrap soft wrap",
-			Hint:       "[w]",
+			Hint:       a.keyHint(keyActionToggleWrap),
 			Action:     a.paletteAction(a.toggleDiffWrap),
 		},
 		t.CommandPaletteItem{
 			Label:      "Toggle split mode",
 			FilterText: "Toggle split mode side by side unified layout view",
-			Hint:       "[v]",
+			Hint:       a.keyHint(keyActionToggleSplit),
 			Action:     a.paletteAction(a.toggleDiffLayoutMode),
 		},
 	)
@@ -2803,7 +4064,7 @@ func (a *Dv) commandPaletteItems() []t.CommandPaletteItem {
 		items = append(items, t.CommandPaletteItem{
 			Label:      "Toggle ignore whitespace",
 			FilterText: "Toggle ignore whitespace differences -w ignore-all-space",
-			Hint:       "[x]",
+			Hint:       a.keyHint(keyActionToggleIgnoreWhitespace),
 			Action:     a.paletteAction(a.toggleDiffIgnoreWhitespace),
 		})
 	}
@@ -2811,24 +4072,74 @@ func (a *Dv) commandPaletteItems() []t.CommandPaletteItem {
 		t.CommandPaletteItem{
 			Label:      "Toggle seen",
 			FilterText: "Toggle seen mark file seen reviewed done checked",
-			Hint:       "[m]",
+			Hint:       a.keyHint(keyActionToggleSeen),
 			Action:     a.paletteAction(a.toggleActiveFileReviewed),
 		},
+		t.CommandPaletteItem{
+			Label:      "Toggle hunk seen",
+			FilterText: "Toggle hunk seen mark hunk seen reviewed visible on screen",
+			Hint:       a.keyHint(keyActionToggleHunkSeen),
+			Action:     a.paletteAction(a.toggleVisibleHunkReviewed),
+		},
 		t.CommandPaletteItem{
 			Label:      "Clear all seen",
 			FilterText: "Clear all seen marks reset seen reviewed",
-			Hint:       "[M]",
+			Hint:       a.keyHint(keyActionClearSeen),
 			Action:     a.paletteAction(a.clearAllReviewed),
 		},
+		t.CommandPaletteItem{
+			Label:      "Add comment",
+			FilterText: "Add comment review note annotate line range selection edit",
+			Hint:       a.keyHint(keyActionComment),
+			Action:     a.paletteAction(a.openReviewCommentDraft),
+		},
+		t.CommandPaletteItem{
+			Label:         "Comments",
+			FilterText:    "Comments list review notes jump",
+			ChildrenTitle: "Comments",
+			Children:      a.reviewCommentItems,
+		},
+		t.CommandPaletteItem{
+			Label:      "Export comments to Markdown",
+			FilterText: "Export comments to Markdown review notes save file",
+			Action:     a.paletteAction(func() { a.openReviewCommentsExport(ReviewCommentsExportMarkdown) }),
+		},
+		t.CommandPaletteItem{
+			Label:      "Export comments to GitHub review JSON",
+			FilterText: "Export comments to GitHub review JSON pull request api payload",
+			Action:     a.paletteAction(func() { a.openReviewCommentsExport(ReviewCommentsExportGitHub) }),
+		},
+		t.CommandPaletteItem{
+			Label:      "Export comments to GitLab discussions JSON",
+			FilterText: "Export comments to GitLab discussions JSON merge request api payload position",
+			Action:     a.paletteAction(func() { a.openReviewCommentsExport(ReviewCommentsExportGitLab) }),
+		},
+		t.CommandPaletteItem{
+			Label:      "Export diff to HTML",
+			FilterText: "Export diff to HTML standalone page share save file browser",
+			Action:     a.paletteAction(a.openDiffHTMLExport),
+		},
 		t.CommandPaletteItem{
 			Label:      "Toggle intraline style",
 			FilterText: "Toggle intraline style highlight background underline off disable changed characters",
-			Hint:       "[i]",
+			Hint:       a.keyHint(keyActionToggleIntralineStyle),
 			Action:     a.paletteAction(a.toggleDiffIntralineStyle),
 		},
+		t.CommandPaletteItem{
+			Label:      "Toggle word diff",
+			FilterText: "Toggle word diff words prose merged inline removed added --word-diff",
+			Hint:       a.keyHint(keyActionToggleWordDiff),
+			Action:     a.paletteAction(a.toggleDiffWordDiff),
+		},
+		t.CommandPaletteItem{
+			Label:      "Toggle whitespace",
+			FilterText: "Toggle show whitespace tabs trailing spaces crlf line endings invisible characters --show-whitespace",
+			Hint:       a.keyHint(keyActionToggleWhitespace),
+			Action:     a.paletteAction(a.toggleDiffShowWhitespace),
+		},
 		t.CommandPaletteItem{
 			Label:         "Theme",
-			Hint:          "[t]",
+			Hint:          a.keyHint(keyActionThemeMenu),
 			ChildrenTitle: diffThemesPalette,
 			Children:      a.themeItems,
 		},
@@ -2860,8 +4171,18 @@ func (a *Dv) themeItems() []t.CommandPaletteItem {
 		}
 	}
 
-	addGroup("Dark themes", t.DarkThemeNames())
-	addGroup("Light themes", t.LightThemeNames())
+	builtIn := func(names []string) []string {
+		filtered := make([]string, 0, len(names))
+		for _, name := range names {
+			if !userThemeNames[name] {
+				filtered = append(filtered, name)
+			}
+		}
+		return filtered
+	}
+	addGroup("Your themes", userThemeNameList())
+	addGroup("Dark themes", builtIn(t.DarkThemeNames()))
+	addGroup("Light themes", builtIn(t.LightThemeNames()))
 
 	return items
 }
@@ -2869,6 +4190,7 @@ func (a *Dv) themeItems() []t.CommandPaletteItem {
 func (a *Dv) setThemeAction(themeName string) func() {
 	return func() {
 		t.SetTheme(themeName)
+		a.pickedTheme = themeName
 		a.commitThemePreview()
 		if a.commandPalette != nil {
 			a.commandPalette.Close(false)
@@ -3015,18 +4337,45 @@ func (a *Dv) sidebarSeenLineTotals() (seenLines int, totalLines int, seenFiles i
 			if file == nil {
 				continue
 			}
-			lineCount := file.Additions + file.Deletions
-			totalLines += lineCount
-			if !a.isReviewed(section, filePath) {
+			totalLines += file.Additions + file.Deletions
+			fileSeenLines, anySeen := a.seenChangedLines(section, filePath, file)
+			if !anySeen {
 				continue
 			}
 			seenFiles++
-			seenLines += lineCount
+			seenLines += fileSeenLines
 		}
 	}
 	return seenLines, totalLines, seenFiles
 }
 
+// seenChangedLines counts the added and removed lines in seen hunks of a file.
+func (a *Dv) seenChangedLines(section DiffSection, filePath string, file *DiffFile) (lines int, anySeen bool) {
+	seen := a.seenHunkHashes(section, filePath)
+	if len(seen) == 0 {
+		return 0, false
+	}
+	hashes := a.currentHunkHashes(section, filePath)
+	if len(file.Hunks) == 0 {
+		if len(hashes) == 1 && seen[hashes[0]] {
+			return file.Additions + file.Deletions, true
+		}
+		return 0, false
+	}
+	for idx, hunk := range file.Hunks {
+		if idx >= len(hashes) || !seen[hashes[idx]] {
+			continue
+		}
+		anySeen = true
+		for _, line := range hunk.Lines {
+			if line.Kind == DiffLineAdd || line.Kind == DiffLineRemove {
+				lines++
+			}
+		}
+	}
+	return lines, anySeen
+}
+
 func (a *Dv) sidebarTotals() (additions int, deletions int) {
 	for _, section := range a.sectionOrder {
 		state := a.sectionState(section)
@@ -3075,13 +4424,16 @@ func (a *Dv) isPipedDiffMode() bool {
 }
 
 func (a *Dv) emptyMessageParts() (heading string, details string) {
+	if describer, ok := a.provider.(EmptyStateDescriber); ok {
+		return describer.EmptyMessageParts(a.diffIgnoreWhitespace, a.keyPrompt)
+	}
 	if a.isPipedDiffMode() {
 		return "No files in piped diff.", "Run your diff command again and pipe it into dv."
 	}
 	if a.diffIgnoreWhitespace {
-		return "No staged or unstaged changes (ignoring whitespace).", "Whitespace-only changes are hidden. Press x to toggle ignore whitespace."
+		return "No staged or unstaged changes (ignoring whitespace).", "Whitespace-only changes are hidden. " + upperFirst(a.keyPrompt(keyActionToggleIgnoreWhitespace, "toggle ignore whitespace")) + "."
 	}
-	return "No staged or unstaged changes.", "Make edits or stage files, then press r to refresh."
+	return "No staged or unstaged changes.", "Make edits or stage files, then " + a.keyPrompt(keyActionRefresh, "refresh") + "."
 }
 
 func (a *Dv) errorMessage() string {
@@ -3092,7 +4444,7 @@ func (a *Dv) errorMessage() string {
 	if !a.manualRefreshEnabled {
 		return "Failed to load git diff:\n\n" + msg + "\n\nRun the command again to retry."
 	}
-	return "Failed to load git diff:\n\n" + msg + "\n\nPress r to retry."
+	return "Failed to load git diff:\n\n" + msg + "\n\n" + upperFirst(a.keyPrompt(keyActionRefresh, "retry")) + "."
 }
 
 func (a *Dv) filePathsForNavigation() []string {
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -681,6 +683,42 @@ func TestDv_KeybindsHideCommandsExposedInPalette(tt *testing.T) {
 	require.True(tt, keybindIsHidden(keybinds, "t"))
 }
 
+func TestDv_CustomKeyBindingsAreReflectedInHints(tt *testing.T) {
+	bindings, err := resolveKeyBindings(map[string]keyList{
+		keyActionNextFile:    {"N"},
+		keyActionPrevFile:    {"P"},
+		keyActionJumpDown:    {"ctrl+f"},
+		keyActionJumpUp:      {"ctrl+b"},
+		keyActionToggleSplit: {"S"},
+		keyActionRefresh:     {},
+	}, nil)
+	require.NoError(tt, err)
+	initial := DefaultDvInitialState()
+	initial.KeyBindings = bindings
+	app := NewDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diffForPaths("a.go")}}, false, initial)
+	theme, ok := t.GetTheme(t.CurrentThemeName())
+	require.True(tt, ok)
+
+	keybinds := app.Keybinds()
+	keybind, ok := findKeybindByKey(keybinds, "N")
+	require.True(tt, ok)
+	require.Equal(tt, "Next file", keybind.Name)
+	require.False(tt, keybind.Hidden)
+	for _, key := range []string{"n", "]", "ctrl+j", "J", "v", "r"} {
+		_, ok := findKeybindByKey(keybinds, key)
+		require.False(tt, ok, key)
+	}
+	keybind, ok = findKeybindByKey(keybinds, "S")
+	require.True(tt, ok)
+	require.Equal(tt, "Toggle split", keybind.Name)
+
+	require.Equal(tt, "[N]/[P] Jump between files", app.dualActionHint("Next file", "Prev file", "Jump between files"))
+	require.Equal(tt, "Refresh diff", app.actionHint("Refresh", "Refresh diff"))
+	require.Equal(tt, "[S]", findPaletteItemByLabel(app.commandPaletteItems(), "Toggle split mode").Hint)
+	require.Equal(tt, "", findPaletteItemByLabel(app.commandPaletteItems(), "Refresh").Hint)
+	require.Equal(tt, []string{"unified [S] whitespace:off [x]"}, widgetTextContents(app.buildHeaderModeIndicator(theme)))
+}
+
 func TestDv_KeybindsIncludeSidebarToggle(tt *testing.T) {
 	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo"}, false)
 	keybind, ok := findKeybindByKey(app.Keybinds(), "b")
@@ -1009,6 +1047,23 @@ func TestDv_ThemesMenuSelectsCurrentThemeByDefault(tt *testing.T) {
 	require.Equal(tt, currentTheme, t.CurrentThemeName())
 }
 
+func TestDv_ThemesMenuListsUserThemesFirst(tt *testing.T) {
+	require.NoError(tt, registerUserThemes(map[string]userThemeConfig{"test-menu-theme": {Extends: "nord"}}, newUserThemeStyles()))
+
+	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo"}, false)
+	items := app.themeItems()
+	require.Equal(tt, "Your themes", items[0].Divider)
+
+	count := 0
+	for _, item := range items {
+		if name, _ := item.Data.(string); name == "test-menu-theme" {
+			count++
+		}
+	}
+	require.Equal(tt, 1, count)
+	require.Contains(tt, paletteThemeNames(items[:len(userThemeNameList())+1]), "test-menu-theme")
+}
+
 func TestDv_ThemePreviewRevertsWhenLeavingThemesMenu(tt *testing.T) {
 	originalTheme := t.CurrentThemeName()
 	defer t.SetTheme(originalTheme)
@@ -1282,6 +1337,32 @@ func TestDv_RenderTreeNodeHighlightsMatchWithDefaultStyle(tt *testing.T) {
 	require.True(tt, found, "expected at least one highlighted span")
 }
 
+func TestDv_FlagsSuspiciousCharsInTreeAndSectionInfoCard(tt *testing.T) {
+	diff := strings.Replace(diffForPaths("a.go", "b.go"), "+new\n", "+n\u202eew\n", 1)
+	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diff}}, false)
+	theme, ok := t.GetTheme(t.CurrentThemeName())
+	require.True(tt, ok)
+	require.Equal(tt, 1, app.sectionState(DiffSectionUnstaged).fileByPath["a.go"].SuspiciousChars)
+
+	render := app.renderTreeNode(theme, false)
+	for _, tc := range []struct {
+		path string
+		want bool
+	}{{"a.go", true}, {"b.go", false}} {
+		file := app.sectionState(DiffSectionUnstaged).fileByPath[tc.path]
+		node := DiffTreeNodeData{Name: tc.path, Path: tc.path, File: file, NodeKind: DiffTreeNodeFile, Section: DiffSectionUnstaged}
+		texts := widgetTextContents(render(node, t.TreeNodeContext{}, t.MatchResult{}))
+		require.Equal(tt, tc.want, indexOfTextContaining(texts, "1 suspicious") >= 0, tc.path)
+	}
+
+	app.onTreeCursorChange(app.treeState.Nodes.Peek()[0].Data)
+	texts := widgetTextContents(app.buildSectionInfoCard(theme))
+	require.GreaterOrEqual(tt, indexOfTextContaining(texts, "Suspicious characters: 1"), 0)
+
+	rendered := app.sectionState(DiffSectionUnstaged).renderedByPath["a.go"]
+	require.Equal(tt, "n<U+202E>ew", lineText(rendered.Lines[2]))
+}
+
 func TestDv_RenderTreeNodeOmitsZeroStats(tt *testing.T) {
 	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diffForPaths("server.go")}}, false)
 	theme, ok := t.GetTheme(t.CurrentThemeName())
@@ -1884,6 +1965,42 @@ func TestDv_RightPaneShowsSectionInfoCard(tt *testing.T) {
 	require.NotEqual(tt, theme.Background, stagedCard.Style.BackgroundColor.ColorAt(10, 1, 5, 0))
 }
 
+func TestDv_SectionInfoCardShowsSortableFileTable(tt *testing.T) {
+	diff := diffForPaths("a.go") + strings.Join([]string{
+		"diff --git a/b.go b/b.go",
+		"--- a/b.go",
+		"+++ b/b.go",
+		"@@ -1 +1,3 @@",
+		"-old",
+		"+new",
+		"+one",
+		"+two",
+		"",
+	}, "\n")
+	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diff}}, false)
+	theme, ok := t.GetTheme(t.CurrentThemeName())
+	require.True(tt, ok)
+	app.onTreeCursorChange(app.treeState.Nodes.Peek()[0].Data)
+
+	texts := widgetTextContents(app.buildSectionInfoCard(theme))
+	header := indexOfTextContaining(texts, "Files by lines changed")
+	require.GreaterOrEqual(tt, header, 0)
+	require.Contains(tt, texts[header+1], "Changed▾")
+	require.Equal(tt, "       4       +3       -1  +++-                  b.go", texts[header+2])
+	require.Equal(tt, "       2       +1       -1  +-                    a.go", texts[header+3])
+	require.GreaterOrEqual(tt, indexOfTextContaining(texts, "[o] Sort files by additions"), 0)
+
+	findPaletteItemByLabel(app.commandPaletteItems(), "Sort files by additions").Action()
+	require.Equal(tt, diffStatSortAdditions, app.sectionStatSort)
+	app.cycleSectionStatSort()
+	app.cycleSectionStatSort()
+	texts = widgetTextContents(app.buildSectionInfoCard(theme))
+	header = indexOfTextContaining(texts, "Files by path")
+	require.GreaterOrEqual(tt, header, 0)
+	require.Contains(tt, texts[header+2], "a.go")
+	require.Contains(tt, texts[header+3], "b.go")
+}
+
 func TestDv_RightPaneShowsDirectoryInfoCard(tt *testing.T) {
 	app := newTestDv(&scriptedDiffProvider{
 		repoRoot: "/tmp/repo",
@@ -2536,6 +2653,315 @@ func TestDv_ReviewedMarksPersistAcrossRefresh(tt *testing.T) {
 	require.True(tt, app.isReviewed(section, filePath))
 }
 
+func TestDv_ReviewedMarkRevertsWhenDiffChanges(tt *testing.T) {
+	provider := &scriptedDiffProvider{
+		repoRoot: "/tmp/repo",
+		diffs: []string{
+			diffForPathWithStats("a.txt", 1, 0),
+			diffForPathWithStats("a.txt", 2, 0),
+		},
+	}
+	app := newTestDv(provider, false)
+	section, filePath, ok := app.activeReviewTarget()
+	require.True(tt, ok)
+
+	app.toggleActiveFileReviewed()
+	require.True(tt, app.isReviewed(section, filePath))
+	require.False(tt, app.isChangedSinceSeen(section, filePath))
+
+	app.refreshDiff()
+	require.False(tt, app.isReviewed(section, filePath))
+	require.True(tt, app.isChangedSinceSeen(section, filePath))
+
+	app.toggleActiveFileReviewed()
+	require.True(tt, app.isReviewed(section, filePath))
+	require.False(tt, app.isChangedSinceSeen(section, filePath))
+}
+
+func TestDv_ReviewedMarksPersistAcrossSessions(tt *testing.T) {
+	initialState := DefaultDvInitialState()
+	initialState.SeenStatePath = filepath.Join(tt.TempDir(), "seen.json")
+	newProvider := func(diff string) *scriptedDiffProvider {
+		return &scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diff}}
+	}
+
+	first := newTestDv(newProvider(diffForPaths("a.txt")), false, initialState)
+	section, filePath, ok := first.activeReviewTarget()
+	require.True(tt, ok)
+	first.toggleActiveFileReviewed()
+
+	second := newTestDv(newProvider(diffForPaths("a.txt")), false, initialState)
+	require.True(tt, second.isReviewed(section, filePath))
+
+	changed := newTestDv(newProvider(diffForPathWithStats("a.txt", 3, 0)), false, initialState)
+	require.False(tt, changed.isReviewed(section, filePath))
+	require.True(tt, changed.isChangedSinceSeen(section, filePath))
+
+	changed.toggleActiveFileReviewed()
+	changed.clearAllReviewed()
+	third := newTestDv(newProvider(diffForPaths("a.txt")), false, initialState)
+	require.False(tt, third.isReviewed(section, filePath))
+}
+
+func TestDv_SeenStateErrorsAreShownInStatusLine(tt *testing.T) {
+	dir := tt.TempDir()
+	initialState := DefaultDvInitialState()
+	initialState.SeenStatePath = filepath.Join(dir, "seen.json")
+	require.NoError(tt, os.WriteFile(initialState.SeenStatePath, []byte("{not json"), 0o644))
+	newProvider := func() *scriptedDiffProvider {
+		return &scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diffForPaths("a.txt")}}
+	}
+
+	app := newTestDv(newProvider(), false, initialState)
+	require.Contains(tt, app.seenStateErr, "started fresh")
+	require.Len(tt, app.buildStatusLine(t.ThemeData{}), 1)
+
+	// Marks made after the corrupt file was moved aside are saved again.
+	section, filePath, ok := app.activeReviewTarget()
+	require.True(tt, ok)
+	app.toggleActiveFileReviewed()
+	require.Empty(tt, app.seenStateErr)
+	require.Empty(tt, app.buildStatusLine(t.ThemeData{}))
+	require.True(tt, newTestDv(newProvider(), false, initialState).isReviewed(section, filePath))
+
+	// A save that fails is reported rather than dropped.
+	initialState.SeenStatePath = filepath.Join(dir, "missing", "seen.json")
+	require.NoError(tt, os.WriteFile(filepath.Join(dir, "missing"), nil, 0o644))
+	failing := newTestDv(newProvider(), false, initialState)
+	failing.toggleActiveFileReviewed()
+	require.Contains(tt, failing.seenStateErr, "Seen marks not saved")
+}
+
+func TestDv_UIStatePersistsAcrossSessions(tt *testing.T) {
+	originalTheme := t.CurrentThemeName()
+	defer t.SetTheme(originalTheme)
+
+	initialState := DefaultDvInitialState()
+	initialState.UIStatePath = filepath.Join(tt.TempDir(), "ui.json")
+	newProvider := func() *scriptedDiffProvider {
+		return &scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diffForPaths("src/a.txt", "docs/b.txt")}}
+	}
+
+	first := newTestDv(newProvider(), false, initialState)
+	dirKeys := map[string]bool{}
+	collectDiffTreeDirKeys(first.treeState.Nodes.Peek(), dirKeys)
+	collapsedKey := diffDirectoryNodeKey(first.activeSection, "src")
+	require.True(tt, dirKeys[collapsedKey])
+	first.treeState.Collapsed.Set(map[string]bool{collapsedKey: true})
+	first.splitState.SetPosition(0.42)
+	first.diffViewState.SetSideBySideSplitRatio(0.6)
+	first.toggleDiffWrap()
+	first.setThemeAction(t.ThemeNameNord)()
+	require.NoError(tt, first.saveUIState())
+
+	t.SetTheme(originalTheme)
+	second := newTestDv(newProvider(), false, initialState)
+	require.InDelta(tt, 0.42, second.splitState.GetPosition(), 0.0001)
+	require.InDelta(tt, 0.6, second.diffViewState.SideBySideSplitRatio(), 0.0001)
+	require.True(tt, second.diffHardWrap)
+	require.Equal(tt, t.ThemeNameNord, t.CurrentThemeName())
+	require.Equal(tt, map[string]bool{collapsedKey: true}, second.treeState.Collapsed.Peek())
+
+	// A theme given with --theme or config wins over the remembered one,
+	// which is kept for the next run.
+	initialState.ThemeName = t.ThemeNameDracula
+	initialState.ThemeExplicit = true
+	third := newTestDv(newProvider(), false, initialState)
+	require.Equal(tt, t.ThemeNameDracula, t.CurrentThemeName())
+	require.True(tt, third.diffHardWrap)
+	require.NoError(tt, third.saveUIState())
+	ui, err := UIStateStore{Path: initialState.UIStatePath}.Load("/tmp/repo")
+	require.NoError(tt, err)
+	require.Equal(tt, t.ThemeNameNord, ui.Theme)
+}
+
+func TestDv_CorruptUIStateIsMovedAsideAndShown(tt *testing.T) {
+	initialState := DefaultDvInitialState()
+	initialState.UIStatePath = filepath.Join(tt.TempDir(), "ui.json")
+	require.NoError(tt, os.WriteFile(initialState.UIStatePath, []byte("{not json"), 0o644))
+
+	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diffForPaths("a.txt")}}, false, initialState)
+	require.Contains(tt, app.uiStateErr, "started fresh")
+	require.Len(tt, app.buildStatusLine(t.ThemeData{}), 1)
+
+	app.toggleDiffWrap()
+	require.NoError(tt, app.saveUIState())
+	ui, err := UIStateStore{Path: initialState.UIStatePath}.Load("/tmp/repo")
+	require.NoError(tt, err)
+	require.True(tt, *ui.Wrap)
+}
+
+func TestDv_UIStateIsNotPersistedByDefault(tt *testing.T) {
+	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diffForPaths("a.txt")}}, false)
+	app.toggleDiffWrap()
+	require.NoError(tt, app.saveUIState())
+
+	second := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diffForPaths("a.txt")}}, false)
+	require.False(tt, second.diffHardWrap)
+}
+
+func twoHunkDiff(path string, secondAdded string) string {
+	return "diff --git a/" + path + " b/" + path + "\n" +
+		"--- a/" + path + "\n" +
+		"+++ b/" + path + "\n" +
+		"@@ -1,2 +1,3 @@\n" +
+		" one\n" +
+		"+first\n" +
+		" two\n" +
+		"@@ -10,2 +11,4 @@\n" +
+		" ten\n" +
+		"+" + secondAdded + "\n" +
+		"+more\n" +
+		" eleven\n"
+}
+
+func TestDv_ToggleVisibleHunkReviewedDerivesFileSeenState(tt *testing.T) {
+	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{twoHunkDiff("a.txt", "second")}}, false)
+	section, filePath, ok := app.activeReviewTarget()
+	require.True(tt, ok)
+
+	app.toggleVisibleHunkReviewed()
+	require.True(tt, app.isHunkReviewed(section, filePath, 0))
+	require.False(tt, app.isHunkReviewed(section, filePath, 1))
+	require.False(tt, app.isReviewed(section, filePath))
+	require.Equal(tt, map[int]bool{1: true}, app.activeSeenHunks())
+
+	seenLines, totalLines, seenFiles := app.sidebarSeenLineTotals()
+	require.Equal(tt, 1, seenLines)
+	require.Equal(tt, 3, totalLines)
+	require.Equal(tt, 1, seenFiles)
+
+	rendered := app.diffViewState.Rendered.Peek()
+	require.NotNil(tt, rendered)
+	secondHunkRow := -1
+	for idx, line := range rendered.Lines {
+		if line.Kind == RenderedLineHunkHeader && line.Hunk == 2 {
+			secondHunkRow = idx
+			break
+		}
+	}
+	require.Greater(tt, secondHunkRow, 0)
+	app.diffScrollState.Offset.Set(secondHunkRow)
+	app.toggleVisibleHunkReviewed()
+	require.True(tt, app.isReviewed(section, filePath))
+
+	app.toggleActiveFileReviewed()
+	require.False(tt, app.isReviewed(section, filePath))
+	require.Empty(tt, app.reviewedByFile)
+}
+
+func TestDv_ChangedHunkRevertsToUnseenAndKeepsOtherHunks(tt *testing.T) {
+	app := newTestDv(&scriptedDiffProvider{
+		repoRoot: "/tmp/repo",
+		diffs: []string{
+			twoHunkDiff("a.txt", "second"),
+			twoHunkDiff("a.txt", "second edited"),
+		},
+	}, false)
+	section, filePath, ok := app.activeReviewTarget()
+	require.True(tt, ok)
+
+	app.toggleActiveFileReviewed()
+	require.True(tt, app.isReviewed(section, filePath))
+
+	app.refreshDiff()
+	require.False(tt, app.isReviewed(section, filePath))
+	require.True(tt, app.isHunkReviewed(section, filePath, 0))
+	require.False(tt, app.isHunkReviewed(section, filePath, 1))
+	require.True(tt, app.isChangedSinceSeen(section, filePath))
+}
+
+func TestDv_HunkAddedToSeenFileIsChangedSinceSeen(tt *testing.T) {
+	oneHunk := "diff --git a/a.txt b/a.txt\n" +
+		"--- a/a.txt\n" +
+		"+++ b/a.txt\n" +
+		"@@ -1,2 +1,3 @@\n" +
+		" one\n" +
+		"+first\n" +
+		" two\n"
+	app := newTestDv(&scriptedDiffProvider{
+		repoRoot: "/tmp/repo",
+		diffs:    []string{oneHunk, twoHunkDiff("a.txt", "second"), twoHunkDiff("a.txt", "second")},
+	}, false)
+	section, filePath, ok := app.activeReviewTarget()
+	require.True(tt, ok)
+
+	app.toggleActiveFileReviewed()
+	require.True(tt, app.isReviewed(section, filePath))
+
+	app.refreshDiff()
+	require.False(tt, app.isReviewed(section, filePath))
+	require.True(tt, app.isHunkReviewed(section, filePath, 0))
+	require.True(tt, app.isChangedSinceSeen(section, filePath))
+
+	// Seeing only part of a file does not flag hunks that were never seen.
+	app.toggleActiveFileReviewed()
+	app.toggleActiveFileReviewed()
+	app.toggleVisibleHunkReviewed()
+	app.refreshDiff()
+	require.True(tt, app.isHunkReviewed(section, filePath, 0))
+	require.False(tt, app.isChangedSinceSeen(section, filePath))
+}
+
+func TestDv_SeenMarksSurviveIgnoreWhitespaceToggle(tt *testing.T) {
+	ignoringWhitespace := "diff --git a/a.txt b/a.txt\n" +
+		"--- a/a.txt\n" +
+		"+++ b/a.txt\n" +
+		"@@ -1,2 +1,3 @@\n" +
+		" one\n" +
+		"+first\n" +
+		" two\n"
+	twoHunks := twoHunkDiff("a.txt", "second")
+	app := newTestDv(&scriptedDiffProvider{
+		repoRoot: "/tmp/repo",
+		diffs:    []string{twoHunks, ignoringWhitespace, twoHunks, ignoringWhitespace},
+	}, false)
+	section, filePath, ok := app.activeReviewTarget()
+	require.True(tt, ok)
+
+	app.toggleActiveFileReviewed()
+	require.True(tt, app.isReviewed(section, filePath))
+
+	// Hunks differ once whitespace is ignored, but that is not a change
+	// since the file was seen.
+	app.toggleDiffIgnoreWhitespace()
+	require.False(tt, app.isReviewed(section, filePath))
+	require.False(tt, app.isChangedSinceSeen(section, filePath))
+
+	// Marking the file in this mode keeps the marks made in the other.
+	app.toggleActiveFileReviewed()
+	require.True(tt, app.isReviewed(section, filePath))
+
+	app.toggleDiffIgnoreWhitespace()
+	require.True(tt, app.isReviewed(section, filePath))
+	require.False(tt, app.isChangedSinceSeen(section, filePath))
+
+	app.toggleDiffIgnoreWhitespace()
+	require.True(tt, app.isReviewed(section, filePath))
+}
+
+func TestDv_IdenticalHunksAreSeenSeparately(tt *testing.T) {
+	diff := "diff --git a/a.txt b/a.txt\n" +
+		"--- a/a.txt\n" +
+		"+++ b/a.txt\n" +
+		"@@ -1,2 +1,3 @@\n" +
+		" x\n" +
+		"+y\n" +
+		" z\n" +
+		"@@ -20,2 +21,3 @@\n" +
+		" x\n" +
+		"+y\n" +
+		" z\n"
+	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diff}}, false)
+	section, filePath, ok := app.activeReviewTarget()
+	require.True(tt, ok)
+
+	app.toggleVisibleHunkReviewed()
+	require.True(tt, app.isHunkReviewed(section, filePath, 0))
+	require.False(tt, app.isHunkReviewed(section, filePath, 1))
+	require.False(tt, app.isReviewed(section, filePath))
+}
+
 func TestDv_CommandPaletteSeenActionsToggleAndClear(tt *testing.T) {
 	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diffForPaths("a.txt")}}, false)
 	level := app.commandPalette.CurrentLevel()
@@ -3047,3 +3473,249 @@ func indexOfTextContaining(texts []string, needle string) int {
 	}
 	return -1
 }
+
+func TestDv_PathsModeKeepsIgnoreWhitespaceAndRefresh(tt *testing.T) {
+	dir := tt.TempDir()
+	writeTestFile(tt, dir+"/old.txt", "one\n")
+	writeTestFile(tt, dir+"/new.txt", "two\n")
+	provider, err := newPathsDiffProvider(dir, "old.txt", "new.txt", textconvOptions{})
+	require.NoError(tt, err)
+
+	app := newTestDv(provider, false, DvInitialState{IgnoreWhitespace: true})
+
+	require.True(tt, app.isPipedDiffMode())
+	require.True(tt, app.canToggleDiffIgnoreWhitespace())
+	require.True(tt, app.diffIgnoreWhitespace)
+	require.True(tt, app.manualRefreshEnabled)
+	require.Equal(tt, "new.txt", app.activePath)
+
+	writeTestFile(tt, dir+"/new.txt", "one\n")
+	app.manualRefresh()
+	heading, details := app.emptyMessageParts()
+	require.Equal(tt, "No differences between old.txt and new.txt (ignoring whitespace).", heading)
+	require.Equal(tt, "Edit either side, then press r to refresh.", details)
+
+	app.keyBindings[keyActionRefresh] = keyBinding{Keys: []string{"F5"}}
+	_, details = app.emptyMessageParts()
+	require.Equal(tt, "Edit either side, then press F5 to refresh.", details)
+}
+
+func TestDv_ReviewCommentOnSelectedRangeShowsInlineAndExports(tt *testing.T) {
+	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{twoHunkDiff("a.txt", "second")}}, false)
+
+	app.handleDiffLineClick(ReviewCommentSideNew, 12, false)
+	app.handleDiffLineClick(ReviewCommentSideNew, 13, true)
+	require.Equal(tt, newReviewLineSpan(ReviewCommentSideNew, 12, 13), app.activeCommentSelection())
+
+	app.openReviewCommentDraft()
+	require.NotNil(tt, app.commentDraft)
+	require.Equal(tt, []string{"+second", "+more"}, app.commentDraft.Excerpt)
+	app.commentInput.SetText("  split this up  ")
+	app.saveReviewCommentDraft()
+
+	require.Nil(tt, app.commentDraft)
+	require.True(tt, app.activeCommentSelection().isEmpty())
+	require.Len(tt, app.reviewComments, 1)
+	require.Equal(tt, "split this up", app.reviewComments[0].Body)
+
+	rendered := app.diffViewState.Rendered.Peek()
+	commentRows := 0
+	for idx, line := range rendered.Lines {
+		if line.Kind != RenderedLineComment {
+			continue
+		}
+		commentRows++
+		if commentRows == 1 {
+			require.Equal(tt, 13, rendered.Lines[idx-1].NewLine)
+		}
+	}
+	require.Equal(tt, 2, commentRows)
+
+	level := app.commandPalette.CurrentLevel()
+	commentsItem := findPaletteItemByLabel(level.Items, "Comments")
+	require.NotNil(tt, commentsItem.Children)
+	items := commentsItem.Children()
+	require.Len(tt, items, 1)
+	require.Equal(tt, "a.txt:L12-13", items[0].Label)
+
+	path := filepath.Join(tt.TempDir(), "review.md")
+	app.openReviewCommentsExport(ReviewCommentsExportMarkdown)
+	app.exportReviewComments(path)
+	require.Empty(tt, app.commentExportErr)
+	data, err := os.ReadFile(path)
+	require.NoError(tt, err)
+	require.Contains(tt, string(data), "### L12-13 (new)")
+	require.Contains(tt, string(data), "+second\n+more\n")
+}
+
+func TestDv_ReviewCommentSavedEmptyDeletesExisting(tt *testing.T) {
+	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{twoHunkDiff("a.txt", "second")}}, false)
+	app.diffViewState.SetViewport(80, 10, 0)
+
+	app.openReviewCommentDraft()
+	require.NotNil(tt, app.commentDraft)
+	require.Equal(tt, ReviewCommentSideNew, app.commentDraft.Side)
+	require.Equal(tt, 1, app.commentDraft.StartLine)
+	app.commentInput.SetText("first note")
+	app.saveReviewCommentDraft()
+	require.Len(tt, app.reviewComments, 1)
+
+	app.openReviewCommentDraft()
+	require.Equal(tt, "first note", app.commentInput.GetText())
+	app.commentInput.SetText("")
+	app.saveReviewCommentDraft()
+	require.Empty(tt, app.reviewComments)
+	for _, line := range app.diffViewState.Rendered.Peek().Lines {
+		require.NotEqual(tt, RenderedLineComment, line.Kind)
+	}
+}
+
+func TestDv_ExportDiffHTMLIncludesSeenStateAndComments(tt *testing.T) {
+	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", branch: "main", diffs: []string{twoHunkDiff("a.txt", "second")}}, false)
+	app.toggleActiveFileReviewed()
+	app.handleDiffLineClick(ReviewCommentSideNew, 12, false)
+	app.openReviewCommentDraft()
+	app.commentInput.SetText("needs a <test>")
+	app.saveReviewCommentDraft()
+
+	level := app.commandPalette.CurrentLevel()
+	require.NotNil(tt, findPaletteItemByLabel(level.Items, "Export diff to HTML").Action)
+
+	app.openDiffHTMLExport()
+	require.NotNil(tt, app.htmlExportInput)
+	require.Equal(tt, filepath.Join("/tmp/repo", defaultDiffHTMLExportName), app.htmlExportInput.GetText())
+
+	path := filepath.Join(tt.TempDir(), "review.html")
+	app.exportDiffHTML(path)
+	require.Empty(tt, app.htmlExportErr)
+	require.Nil(tt, app.htmlExportInput)
+
+	data, err := os.ReadFile(path)
+	require.NoError(tt, err)
+	page := string(data)
+	require.Contains(tt, page, "<title>repo (main)</title>")
+	require.Contains(tt, page, `<span class="badge">Seen</span>`)
+	require.Contains(tt, page, "needs a &lt;test&gt;")
+}
+
+func TestDv_JumpToMovedLineSelectsCounterpartFile(tt *testing.T) {
+	var builder strings.Builder
+	builder.WriteString("diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1 @@\n")
+	builder.WriteString(" keep\n-func movedHelper() {\n-\treturn computeAnswer()\n")
+	builder.WriteString("diff --git a/b.txt b/b.txt\n--- a/b.txt\n+++ b/b.txt\n@@ -1,30 +1,32 @@\n")
+	for idx := range 30 {
+		fmt.Fprintf(&builder, " line %d\n", idx+1)
+	}
+	builder.WriteString("+func movedHelper() {\n+\treturn computeAnswer()\n")
+
+	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{builder.String()}}, false)
+	app.selectFilePath("a.txt")
+	rendered := app.diffViewState.Rendered.Peek()
+	require.Equal(tt, RenderedLineMovedRemove, rendered.Lines[2].Kind)
+	require.Equal(tt, &RenderedLineMove{Path: "b.txt", Side: ReviewCommentSideNew, Line: 31}, rendered.Lines[2].Move)
+
+	app.jumpToMovedLine(*rendered.Lines[2].Move)
+	require.Equal(tt, "b.txt", app.activePath)
+	rendered = app.diffViewState.Rendered.Peek()
+	row := findRenderedRowForAnchor(rendered.Lines, diffScrollAnchor{kind: RenderedLineMovedAdd, newLine: 31})
+	require.Equal(tt, RenderedLineMovedAdd, rendered.Lines[row].Kind)
+	require.Equal(tt, row, app.diffScrollState.Offset.Peek())
+}
+
+func TestDv_ToggleWordDiffRerendersFiles(tt *testing.T) {
+	provider := &scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diffForPaths("a.txt")}}
+	app := newTestDv(provider, false)
+	rendered := app.diffViewState.Rendered.Peek()
+	require.Equal(tt, []string{"old", "new"}, []string{lineText(rendered.Lines[1]), lineText(rendered.Lines[2])})
+	collapsed := map[string]bool{"collapsed-dir": true}
+	app.treeState.Collapsed.Set(collapsed)
+	loads := len(provider.loadStaged)
+
+	app.toggleDiffWordDiff()
+	rendered = app.diffViewState.Rendered.Peek()
+	require.Len(tt, rendered.Lines, 2)
+	require.Equal(tt, "[-old-]{+new+}", lineText(rendered.Lines[1]))
+	// The loaded files are rendered again without reloading the diff or
+	// touching the sidebar.
+	require.Len(tt, provider.loadStaged, loads)
+	require.Equal(tt, collapsed, app.treeState.Collapsed.Peek())
+
+	app.toggleDiffWordDiff()
+	require.Len(tt, app.diffViewState.Rendered.Peek().Lines, 3)
+}
+
+func TestDv_ToggleShowWhitespaceRerendersFiles(tt *testing.T) {
+	diff := strings.Replace(diffForPaths("a.txt"), "-old\n", "-new\r\n", 1)
+	provider := &scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diff}}
+	app := newTestDv(provider, false)
+	rendered := app.diffViewState.Rendered.Peek()
+	require.Equal(tt, []string{"new", "new"}, []string{lineText(rendered.Lines[1]), lineText(rendered.Lines[2])})
+	collapsed := map[string]bool{"collapsed-dir": true}
+	app.treeState.Collapsed.Set(collapsed)
+	loads := len(provider.loadStaged)
+
+	app.toggleDiffShowWhitespace()
+	rendered = app.diffViewState.Rendered.Peek()
+	require.Equal(tt, []string{"new␍", "new"}, []string{lineText(rendered.Lines[1]), lineText(rendered.Lines[2])})
+	require.Len(tt, provider.loadStaged, loads)
+	require.Equal(tt, collapsed, app.treeState.Collapsed.Peek())
+
+	app.toggleDiffShowWhitespace()
+	require.Equal(tt, "new", lineText(app.diffViewState.Rendered.Peek().Lines[1]))
+}
+
+func TestDv_CollapsesLockfileUntilRenderedAnyway(tt *testing.T) {
+	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diffForPaths("yarn.lock", "a.txt")}}, false)
+	require.True(tt, app.selectFilePath("yarn.lock"))
+	rendered := app.diffViewState.Rendered.Peek()
+	texts := make([]string, 0, len(rendered.Lines))
+	for _, line := range rendered.Lines {
+		texts = append(texts, lineText(line))
+	}
+	require.Contains(tt, texts, "Collapsed: lockfile.")
+	require.Contains(tt, texts, "Press R to render it anyway.")
+
+	keybind, ok := findKeybindByKey(app.Keybinds(), "R")
+	require.True(tt, ok)
+	require.Equal(tt, "Render anyway", keybind.Name)
+	require.Equal(tt, "Render file anyway", findPaletteItemByLabel(app.commandPaletteItems(), "Render file anyway").Label)
+
+	keybind.Action()
+	rendered = app.diffViewState.Rendered.Peek()
+	require.Equal(tt, []string{"old", "new"}, []string{lineText(rendered.Lines[1]), lineText(rendered.Lines[2])})
+	_, ok = findKeybindByKey(app.Keybinds(), "R")
+	require.False(tt, ok)
+
+	require.True(tt, app.selectFilePath("a.txt"))
+	_, ok = findKeybindByKey(app.Keybinds(), "R")
+	require.False(tt, ok)
+}
+
+func TestDv_CollapsedFilesSkipMoveDetectionAndHashing(tt *testing.T) {
+	var builder strings.Builder
+	builder.WriteString("diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -1,3 +1 @@\n")
+	builder.WriteString(" keep\n-func movedHelper() {\n-\treturn computeAnswer()\n")
+	builder.WriteString("diff --git a/yarn.lock b/yarn.lock\n--- a/yarn.lock\n+++ b/yarn.lock\n@@ -1 +1,3 @@\n")
+	builder.WriteString(" keep\n+func movedHelper() {\n+\treturn computeAnswer()\n")
+
+	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{builder.String()}}, false)
+	state := app.sectionState(DiffSectionUnstaged)
+	require.NotEmpty(tt, state.collapsedByPath["yarn.lock"])
+	require.Nil(tt, state.lineMoves)
+	require.NotContains(tt, state.hunkHashesByPath, "yarn.lock")
+	require.Contains(tt, state.hunkHashesByPath, "a.txt")
+
+	require.Len(tt, app.currentHunkHashes(DiffSectionUnstaged, "yarn.lock"), 1)
+	require.Contains(tt, state.hunkHashesByPath, "yarn.lock")
+}
+
+func TestDv_CollapsesFilesOverLineLimit(tt *testing.T) {
+	initialState := DefaultDvInitialState()
+	initialState.LargeFileLimits = largeFileLimits{MaxFileLines: 1}
+	app := newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diffForPaths("a.txt")}}, false, initialState)
+	require.Equal(tt, "2 lines, over the --max-file-lines limit of 1", app.sectionState(DiffSectionUnstaged).collapsedByPath["a.txt"])
+
+	initialState.LargeFileLimits = largeFileLimits{}
+	app = newTestDv(&scriptedDiffProvider{repoRoot: "/tmp/repo", diffs: []string{diffForPaths("a.txt")}}, false, initialState)
+	require.Empty(tt, app.sectionState(DiffSectionUnstaged).collapsedByPath)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+
+	"github.com/charmbracelet/colorprofile"
+	t "github.com/darrenburns/terma"
+)
+
+// parseColorProfile parses --color. "auto" returns colorprofile.Unknown,
+// which resolveColorProfile replaces with the detected profile.
+func parseColorProfile(value string) (colorprofile.Profile, error) {
+	switch normalizeCLIValue(value) {
+	case "", "auto":
+		return colorprofile.Unknown, nil
+	case "truecolor", "24bit", "24-bit":
+		return colorprofile.TrueColor, nil
+	case "256", "ansi256":
+		return colorprofile.ANSI256, nil
+	case "16", "ansi":
+		return colorprofile.ANSI, nil
+	default:
+		return colorprofile.Unknown, fmt.Errorf("invalid --color value %q (expected \"auto\", \"truecolor\", \"256\", or \"16\")", value)
+	}
+}
+
+// resolveColorProfile returns requested unless it is colorprofile.Unknown, in
+// which case the profile is detected from output and env. Output that isn't a
+// terminal keeps full colour, since it usually ends up in a pager or a file.
+func resolveColorProfile(requested colorprofile.Profile, output io.Writer, env []string) colorprofile.Profile {
+	if requested != colorprofile.Unknown {
+		return requested
+	}
+	if profile := colorprofile.Detect(output, env); profile >= colorprofile.ANSI {
+		return profile
+	}
+	return colorprofile.TrueColor
+}
+
+// rendererColorProfile returns the colour depth the interactive view draws
+// with. The renderer detects its own depth from output and env and downsamples
+// anything deeper, so palettes are snapped to that depth when it is lower than
+// profile.
+func rendererColorProfile(profile colorprofile.Profile, output io.Writer, env []string) colorprofile.Profile {
+	if detected := colorprofile.Detect(output, env); detected >= colorprofile.ANSI && detected < profile {
+		return detected
+	}
+	return profile
+}
+
+// colorProfileWriter downsamples the escape sequences written to w to
+// profile, for output that doesn't go through the renderer.
+func colorProfileWriter(w io.Writer, profile colorprofile.Profile) io.Writer {
+	if profile >= colorprofile.TrueColor {
+		return w
+	}
+	return &colorprofile.Writer{Forward: w, Profile: profile}
+}
+
+// quantizeColor maps c to the nearest colour of profile. Translucent colours
+// are left alone, since what they look like depends on what they are drawn
+// over.
+func quantizeColor(c t.Color, profile colorprofile.Profile) t.Color {
+	if !c.IsSet() || !c.IsOpaque() || profile >= colorprofile.TrueColor || profile < colorprofile.ANSI {
+		return c
+	}
+	// Converting a palette colour again doesn't always give the same colour
+	// back, so convert until it does: the renderer converts whatever dv
+	// draws with once more.
+	for range 4 {
+		r, g, b := c.RGB()
+		converted := profile.Convert(color.RGBA{R: r, G: g, B: b, A: 0xff})
+		if converted == nil {
+			return c
+		}
+		cr, cg, cb, _ := converted.RGBA()
+		next := t.RGB(uint8(cr>>8), uint8(cg>>8), uint8(cb>>8))
+		if next == c {
+			break
+		}
+		c = next
+	}
+	return c
+}
+
+// quantizeTint quantizes tint, a blend of bg toward hue, blending further
+// toward hue until it no longer snaps to the same colour as bg or avoid. When
+// even hue snaps to one of those, darker and then lighter shades of hue are
+// tried.
+func quantizeTint(tint t.Color, bg t.Color, hue t.Color, avoid t.Color, profile colorprofile.Profile) t.Color {
+	const step = 0.1
+
+	quantizedBg := quantizeColor(bg, profile)
+	clashes := func(c t.Color) bool { return c == quantizedBg || c == avoid }
+	quantized := quantizeColor(tint, profile)
+	for amount := step; amount <= 1 && clashes(quantized); amount += step {
+		quantized = quantizeColor(tint.Blend(hue, amount), profile)
+	}
+	for amount := step; amount <= 1 && clashes(quantized); amount += step {
+		quantized = quantizeColor(hue.Darken(amount), profile)
+		if clashes(quantized) {
+			quantized = quantizeColor(hue.Lighten(amount), profile)
+		}
+	}
+	return quantized
+}
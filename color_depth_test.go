@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/charmbracelet/colorprofile"
+	t "github.com/darrenburns/terma"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseColorProfile(tt *testing.T) {
+	tests := map[string]colorprofile.Profile{
+		"auto":      colorprofile.Unknown,
+		"TrueColor": colorprofile.TrueColor,
+		"24bit":     colorprofile.TrueColor,
+		"256":       colorprofile.ANSI256,
+		"16":        colorprofile.ANSI,
+		"ansi":      colorprofile.ANSI,
+	}
+	for value, want := range tests {
+		got, err := parseColorProfile(value)
+		require.NoError(tt, err, value)
+		require.Equal(tt, want, got, value)
+	}
+
+	_, err := parseColorProfile("8")
+	require.ErrorContains(tt, err, `invalid --color value "8"`)
+}
+
+func TestResolveColorProfile(tt *testing.T) {
+	var out bytes.Buffer
+	require.Equal(tt, colorprofile.ANSI256, resolveColorProfile(colorprofile.ANSI256, &out, nil))
+	// Output that isn't a terminal keeps full colour for pagers and files.
+	require.Equal(tt, colorprofile.TrueColor, resolveColorProfile(colorprofile.Unknown, &out, []string{"TERM=xterm"}))
+}
+
+func TestRendererColorProfile_NeverExceedsDetectedDepth(tt *testing.T) {
+	var out bytes.Buffer
+	env := []string{"CLICOLOR_FORCE=1", "TERM=xterm-256color"}
+	require.Equal(tt, colorprofile.ANSI256, rendererColorProfile(colorprofile.TrueColor, &out, env))
+	require.Equal(tt, colorprofile.ANSI, rendererColorProfile(colorprofile.ANSI, &out, env))
+	// Output the renderer can't detect keeps the requested depth.
+	require.Equal(tt, colorprofile.ANSI256, rendererColorProfile(colorprofile.ANSI256, &out, nil))
+}
+
+func TestQuantizeColor(tt *testing.T) {
+	c := t.Hex("#2a3b4c")
+	require.Equal(tt, c, quantizeColor(c, colorprofile.TrueColor))
+
+	for _, profile := range []colorprofile.Profile{colorprofile.ANSI256, colorprofile.ANSI} {
+		quantized := quantizeColor(c, profile)
+		require.NotEqual(tt, c, quantized)
+		require.Equal(tt, quantized, quantizeColor(quantized, profile))
+	}
+
+	translucent := c.WithAlpha(0.5)
+	require.Equal(tt, translucent, quantizeColor(translucent, colorprofile.ANSI256))
+}
+
+func TestThemePalette_ReducedColorKeepsChangesVisible(tt *testing.T) {
+	variants := []PaletteVariant{PaletteVariantDefault, PaletteVariantDeuteranopia, PaletteVariantHighContrast}
+	for _, profile := range []colorprofile.Profile{colorprofile.ANSI256, colorprofile.ANSI} {
+		for _, name := range t.ThemeNames() {
+			theme, ok := t.GetTheme(name)
+			require.True(tt, ok)
+			background := quantizeColor(theme.Background, profile)
+			for _, variant := range variants {
+				palette := newThemePalette(theme, variant, paletteOptions{Profile: profile})
+				label := name + "/" + profile.String() + "/" + variant.DisplayName()
+
+				add, _ := palette.LineStyleForKind(RenderedLineAdd)
+				remove, _ := palette.LineStyleForKind(RenderedLineRemove)
+				addBg := add.BackgroundColor.ColorAt(1, 1, 0, 0)
+				removeBg := remove.BackgroundColor.ColorAt(1, 1, 0, 0)
+				require.NotEqual(tt, background, addBg, label)
+				require.NotEqual(tt, background, removeBg, label)
+				require.NotEqual(tt, addBg, removeBg, label)
+				require.Equal(tt, addBg, quantizeColor(addBg, profile), label)
+
+				addIntraline, _ := palette.IntralineOverlayStyle(IntralineMarkAdd, IntralineStyleModeBackground)
+				removeIntraline, _ := palette.IntralineOverlayStyle(IntralineMarkRemove, IntralineStyleModeBackground)
+				require.NotEqual(tt, addBg, addIntraline.Background, label)
+				require.NotEqual(tt, removeBg, removeIntraline.Background, label)
+				require.NotEqual(tt, addIntraline.Background, removeIntraline.Background, label)
+
+				keyword, _ := palette.StyleForRole(TokenRoleSyntaxKeyword)
+				require.Equal(tt, keyword.Foreground, quantizeColor(keyword.Foreground, profile), label)
+			}
+		}
+	}
+}
+
+func TestDividerGradient_IsSolidBelowTrueColor(tt *testing.T) {
+	theme, ok := t.GetTheme(t.ThemeNameNord)
+	require.True(tt, ok)
+
+	_, isGradient := dividerForeground(theme, colorprofile.TrueColor).(t.Gradient)
+	require.True(tt, isGradient)
+
+	solid, ok := dividerFocusForeground(theme, colorprofile.ANSI256).(t.Color)
+	require.True(tt, ok)
+	require.Equal(tt, solid, quantizeColor(solid, colorprofile.ANSI256))
+}
+
+func TestColorProfileWriter_DownsamplesTrueColorSequences(tt *testing.T) {
+	var out bytes.Buffer
+	_, err := colorProfileWriter(&out, colorprofile.ANSI256).Write([]byte("\x1b[38;2;42;59;76mx\x1b[0m"))
+	require.NoError(tt, err)
+	require.NotContains(tt, out.String(), "38;2;")
+	require.Contains(tt, out.String(), "38;5;")
+	require.Contains(tt, out.String(), "x")
+
+	require.Equal(tt, &out, colorProfileWriter(&out, colorprofile.TrueColor))
+}
@@ -13,31 +13,80 @@ import (
 
 const defaultConfigRelPath = "dv/config.yaml"
 
+// defaultRepoConfigName is the shared config file dv looks for in the root of
+// the repository.
+const defaultRepoConfigName = ".dv.yaml"
+
 const (
 	flagNameView             = "view"
 	flagNameSidebar          = "sidebar"
 	flagNameTheme            = "theme"
 	flagNameIntralineStyle   = "intraline-style"
+	flagNamePalette          = "palette"
+	flagNameColor            = "color"
 	flagNameShowSymbols      = "show-symbols"
 	flagNameIgnoreWhitespace = "ignore-whitespace"
+	flagNamePersistUIState   = "persist-ui-state"
+	flagNameWordDiff         = "word-diff"
+	flagNameShowWhitespace   = "show-whitespace"
+	flagNameMaxFileLines     = "max-file-lines"
+	flagNameMaxLineBytes     = "max-line-bytes"
+	flagNameMaxDiffBytes     = "max-diff-bytes"
+	flagNameTextconv         = "textconv"
+	configKeyKeys            = "keys"
+	configKeyThemes          = "themes"
+	configKeySyntaxOverrides = "syntax-overrides"
+	configKeyThemeLight      = "theme-light"
+	configKeyThemeDark       = "theme-dark"
+	configKeyDiffDrivers     = "diff-drivers"
 )
 
 type startupConfig struct {
 	View             *string `yaml:"view"`
 	Sidebar          *bool   `yaml:"sidebar"`
 	Theme            *string `yaml:"theme"`
+	ThemeLight       *string `yaml:"theme-light"`
+	ThemeDark        *string `yaml:"theme-dark"`
 	IntralineStyle   *string `yaml:"intraline-style"`
+	Palette          *string `yaml:"palette"`
+	Color            *string `yaml:"color"`
 	ShowSymbols      *bool   `yaml:"show-symbols"`
 	IgnoreWhitespace *bool   `yaml:"ignore-whitespace"`
+	PersistUIState   *bool   `yaml:"persist-ui-state"`
+	WordDiff         *bool   `yaml:"word-diff"`
+	ShowWhitespace   *bool   `yaml:"show-whitespace"`
+	MaxFileLines     *int    `yaml:"max-file-lines"`
+	MaxLineBytes     *int    `yaml:"max-line-bytes"`
+	MaxDiffBytes     *int    `yaml:"max-diff-bytes"`
+	Textconv         *bool   `yaml:"textconv"`
+	// Keys maps action IDs such as "next-file" to the keys that trigger them.
+	Keys map[string]keyList `yaml:"keys"`
+	// Themes defines user themes by name, alongside any in the themes directory.
+	Themes map[string]userThemeConfig `yaml:"themes"`
+	// SyntaxOverrides restyles syntax roles of existing themes, by theme name.
+	SyntaxOverrides map[string]map[string]syntaxStyleConfig `yaml:"syntax-overrides"`
+	// DiffDrivers sets up git diff drivers by name for --textconv.
+	DiffDrivers map[string]diffDriverConfig `yaml:"diff-drivers"`
 }
 
 type startupFlagValues struct {
 	ViewMode         string
 	SidebarVisible   bool
 	ThemeName        string
+	ThemeLight       string
+	ThemeDark        string
 	IntralineStyle   string
+	Palette          string
+	Color            string
 	ShowSymbols      bool
 	IgnoreWhitespace bool
+	PersistUIState   bool
+	WordDiff         bool
+	ShowWhitespace   bool
+	MaxFileLines     int
+	MaxLineBytes     int
+	MaxDiffBytes     int
+	Textconv         bool
 }
 
 type resolvedConfigPath struct {
@@ -64,15 +113,70 @@ func resolveStartupConfigPath(configHome string, explicitPath string, noConfig b
 	}
 }
 
+// startupConfigLayer is the config read from one file.
+type startupConfigLayer struct {
+	Path   string
+	Config startupConfig
+}
+
 func loadStartupConfig(configHome string, explicitPath string, noConfig bool) (startupConfig, error) {
+	layers, _, err := loadStartupConfigLayers(configHome, explicitPath, noConfig, "")
+	if err != nil {
+		return startupConfig{}, err
+	}
+	cfg, _ := mergeStartupConfigLayers(layers)
+	return cfg, nil
+}
+
+// loadStartupConfigLayers reads the repository's .dv.yaml, when repoRoot is
+// set, and then the user config, lowest precedence first. It also returns the
+// styles of the user themes they and the themes directory define.
+func loadStartupConfigLayers(configHome string, explicitPath string, noConfig bool, repoRoot string) ([]startupConfigLayer, userThemeStyles, error) {
+	styles := newUserThemeStyles()
 	path := resolveStartupConfigPath(configHome, explicitPath, noConfig)
 	if !path.Enabled {
-		return startupConfig{}, nil
+		return nil, styles, nil
 	}
-	return readStartupConfig(path.Path, path.Required)
+	if err := loadThemeFiles(filepath.Join(configHome, defaultThemesRelDir), styles); err != nil {
+		return nil, userThemeStyles{}, err
+	}
+
+	var layers []startupConfigLayer
+	if repoRoot != "" {
+		repoPath := filepath.Join(repoRoot, defaultRepoConfigName)
+		cfg, err := readStartupConfig(repoPath, false, styles)
+		if err != nil {
+			return nil, userThemeStyles{}, err
+		}
+		// Drivers run commands, so a cloned repository can't set them.
+		if len(cfg.DiffDrivers) > 0 {
+			return nil, userThemeStyles{}, fmt.Errorf("invalid config key %q in %q: diff drivers run commands, so they can only be set in your own config", configKeyDiffDrivers, repoPath)
+		}
+		layers = append(layers, startupConfigLayer{Path: repoPath, Config: cfg})
+	}
+	cfg, err := readStartupConfig(path.Path, path.Required, styles)
+	if err != nil {
+		return nil, userThemeStyles{}, err
+	}
+	layers = append(layers, startupConfigLayer{Path: path.Path, Config: cfg})
+
+	// Keys are checked once merged, since a layer may rebind an action whose
+	// default key a lower layer took. Theme names are too, since the
+	// repository config may name a theme the user config defines.
+	merged, sources := mergeStartupConfigLayers(layers)
+	if err := validateThemeNames(merged, sources); err != nil {
+		return nil, userThemeStyles{}, err
+	}
+	if _, err := resolveKeyBindings(merged.Keys, sources); err != nil {
+		return nil, userThemeStyles{}, fmt.Errorf("invalid config value for key %q: %w", configKeyKeys, err)
+	}
+	if _, err := resolveSyntaxOverrides(merged.SyntaxOverrides); err != nil {
+		return nil, userThemeStyles{}, fmt.Errorf("invalid config value for key %q: %w", configKeySyntaxOverrides, err)
+	}
+	return layers, styles, nil
 }
 
-func readStartupConfig(path string, required bool) (startupConfig, error) {
+func readStartupConfig(path string, required bool, styles userThemeStyles) (startupConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) && !required {
@@ -92,6 +196,10 @@ func readStartupConfig(path string, required bool) (startupConfig, error) {
 		return startupConfig{}, fmt.Errorf("parse config %q: %w", path, err)
 	}
 
+	if err := registerUserThemes(cfg.Themes, styles); err != nil {
+		return startupConfig{}, fmt.Errorf("invalid config value for key %q in %q: %w", configKeyThemes, path, err)
+	}
+
 	if err := validateStartupConfig(path, cfg); err != nil {
 		return startupConfig{}, err
 	}
@@ -99,6 +207,29 @@ func readStartupConfig(path string, required bool) (startupConfig, error) {
 	return cfg, nil
 }
 
+// validateThemeNames checks the theme settings of the merged config, once
+// every layer has registered its themes.
+func validateThemeNames(cfg startupConfig, sources map[string]string) error {
+	if cfg.Theme != nil && !isAutoThemeName(*cfg.Theme) {
+		if _, err := parseThemeName(*cfg.Theme); err != nil {
+			return fmt.Errorf("invalid config value for key %q in %q: %w", flagNameTheme, sources[flagNameTheme], err)
+		}
+	}
+
+	if cfg.ThemeLight != nil {
+		if _, err := parseThemeName(*cfg.ThemeLight); err != nil {
+			return fmt.Errorf("invalid config value for key %q in %q: %w", configKeyThemeLight, sources[configKeyThemeLight], err)
+		}
+	}
+
+	if cfg.ThemeDark != nil {
+		if _, err := parseThemeName(*cfg.ThemeDark); err != nil {
+			return fmt.Errorf("invalid config value for key %q in %q: %w", configKeyThemeDark, sources[configKeyThemeDark], err)
+		}
+	}
+	return nil
+}
+
 func validateStartupConfig(path string, cfg startupConfig) error {
 	if cfg.View != nil {
 		if _, err := parseDiffLayoutMode(*cfg.View); err != nil {
@@ -106,21 +237,54 @@ func validateStartupConfig(path string, cfg startupConfig) error {
 		}
 	}
 
-	if cfg.Theme != nil {
-		if _, err := parseThemeName(*cfg.Theme); err != nil {
-			return fmt.Errorf("invalid config value for key %q in %q: %w", flagNameTheme, path, err)
-		}
-	}
-
 	if cfg.IntralineStyle != nil {
 		if _, err := parseIntralineStyleMode(*cfg.IntralineStyle); err != nil {
 			return fmt.Errorf("invalid config value for key %q in %q: %w", flagNameIntralineStyle, path, err)
 		}
 	}
 
+	if cfg.Palette != nil {
+		if _, err := parsePaletteVariant(*cfg.Palette); err != nil {
+			return fmt.Errorf("invalid config value for key %q in %q: %w", flagNamePalette, path, err)
+		}
+	}
+
+	if cfg.Color != nil {
+		if _, err := parseColorProfile(*cfg.Color); err != nil {
+			return fmt.Errorf("invalid config value for key %q in %q: %w", flagNameColor, path, err)
+		}
+	}
+
+	limits := []struct {
+		key   string
+		value *int
+	}{
+		{flagNameMaxFileLines, cfg.MaxFileLines},
+		{flagNameMaxLineBytes, cfg.MaxLineBytes},
+		{flagNameMaxDiffBytes, cfg.MaxDiffBytes},
+	}
+	for _, limit := range limits {
+		if limit.value != nil && *limit.value < 0 {
+			return fmt.Errorf("invalid config value for key %q in %q: %d is negative; use 0 for no limit", limit.key, path, *limit.value)
+		}
+	}
+
+	for name := range cfg.DiffDrivers {
+		if err := validateDiffDriverName(name); err != nil {
+			return fmt.Errorf("invalid config value for key %q in %q: %w", configKeyDiffDrivers, path, err)
+		}
+	}
+
 	return nil
 }
 
+// themeSetExplicitly reports whether the theme was chosen by --theme or by
+// config, including `theme: auto` and its light and dark themes, rather than
+// left to the default.
+func themeSetExplicitly(cfg startupConfig, explicitlySet map[string]bool) bool {
+	return explicitlySet[flagNameTheme] || cfg.Theme != nil || cfg.ThemeLight != nil || cfg.ThemeDark != nil
+}
+
 func applyStartupConfig(values startupFlagValues, cfg startupConfig, explicitlySet map[string]bool) startupFlagValues {
 	if cfg.View != nil && !explicitlySet[flagNameView] {
 		values.ViewMode = *cfg.View
@@ -131,14 +295,165 @@ func applyStartupConfig(values startupFlagValues, cfg startupConfig, explicitlyS
 	if cfg.Theme != nil && !explicitlySet[flagNameTheme] {
 		values.ThemeName = *cfg.Theme
 	}
+	if cfg.ThemeLight != nil {
+		values.ThemeLight = *cfg.ThemeLight
+	}
+	if cfg.ThemeDark != nil {
+		values.ThemeDark = *cfg.ThemeDark
+	}
 	if cfg.IntralineStyle != nil && !explicitlySet[flagNameIntralineStyle] {
 		values.IntralineStyle = *cfg.IntralineStyle
 	}
+	if cfg.Palette != nil && !explicitlySet[flagNamePalette] {
+		values.Palette = *cfg.Palette
+	}
+	if cfg.Color != nil && !explicitlySet[flagNameColor] {
+		values.Color = *cfg.Color
+	}
 	if cfg.ShowSymbols != nil && !explicitlySet[flagNameShowSymbols] {
 		values.ShowSymbols = *cfg.ShowSymbols
 	}
 	if cfg.IgnoreWhitespace != nil && !explicitlySet[flagNameIgnoreWhitespace] {
 		values.IgnoreWhitespace = *cfg.IgnoreWhitespace
 	}
+	if cfg.PersistUIState != nil && !explicitlySet[flagNamePersistUIState] {
+		values.PersistUIState = *cfg.PersistUIState
+	}
+	if cfg.WordDiff != nil && !explicitlySet[flagNameWordDiff] {
+		values.WordDiff = *cfg.WordDiff
+	}
+	if cfg.ShowWhitespace != nil && !explicitlySet[flagNameShowWhitespace] {
+		values.ShowWhitespace = *cfg.ShowWhitespace
+	}
+	if cfg.MaxFileLines != nil && !explicitlySet[flagNameMaxFileLines] {
+		values.MaxFileLines = *cfg.MaxFileLines
+	}
+	if cfg.MaxLineBytes != nil && !explicitlySet[flagNameMaxLineBytes] {
+		values.MaxLineBytes = *cfg.MaxLineBytes
+	}
+	if cfg.MaxDiffBytes != nil && !explicitlySet[flagNameMaxDiffBytes] {
+		values.MaxDiffBytes = *cfg.MaxDiffBytes
+	}
+	if cfg.Textconv != nil && !explicitlySet[flagNameTextconv] {
+		values.Textconv = *cfg.Textconv
+	}
 	return values
 }
+
+// mergeStartupConfigLayers merges layers, later layers winning. Map sections
+// merge entry by entry, so a layer can rebind one action or restyle one role
+// without repeating the rest. sources maps each set key, such as "view",
+// "keys.next-file" or "syntax-overrides.nord.keyword", to the file it came
+// from.
+func mergeStartupConfigLayers(layers []startupConfigLayer) (merged startupConfig, sources map[string]string) {
+	sources = map[string]string{}
+	syntaxThemeKeys := map[string]string{}
+	for _, layer := range layers {
+		cfg := layer.Config
+		scalars := []struct {
+			key string
+			dst **string
+			src *string
+		}{
+			{flagNameView, &merged.View, cfg.View},
+			{flagNameTheme, &merged.Theme, cfg.Theme},
+			{configKeyThemeLight, &merged.ThemeLight, cfg.ThemeLight},
+			{configKeyThemeDark, &merged.ThemeDark, cfg.ThemeDark},
+			{flagNameIntralineStyle, &merged.IntralineStyle, cfg.IntralineStyle},
+			{flagNamePalette, &merged.Palette, cfg.Palette},
+			{flagNameColor, &merged.Color, cfg.Color},
+		}
+		for _, scalar := range scalars {
+			if scalar.src != nil {
+				*scalar.dst = scalar.src
+				sources[scalar.key] = layer.Path
+			}
+		}
+		booleans := []struct {
+			key string
+			dst **bool
+			src *bool
+		}{
+			{flagNameSidebar, &merged.Sidebar, cfg.Sidebar},
+			{flagNameShowSymbols, &merged.ShowSymbols, cfg.ShowSymbols},
+			{flagNameIgnoreWhitespace, &merged.IgnoreWhitespace, cfg.IgnoreWhitespace},
+			{flagNamePersistUIState, &merged.PersistUIState, cfg.PersistUIState},
+			{flagNameWordDiff, &merged.WordDiff, cfg.WordDiff},
+			{flagNameShowWhitespace, &merged.ShowWhitespace, cfg.ShowWhitespace},
+			{flagNameTextconv, &merged.Textconv, cfg.Textconv},
+		}
+		for _, boolean := range booleans {
+			if boolean.src != nil {
+				*boolean.dst = boolean.src
+				sources[boolean.key] = layer.Path
+			}
+		}
+		integers := []struct {
+			key string
+			dst **int
+			src *int
+		}{
+			{flagNameMaxFileLines, &merged.MaxFileLines, cfg.MaxFileLines},
+			{flagNameMaxLineBytes, &merged.MaxLineBytes, cfg.MaxLineBytes},
+			{flagNameMaxDiffBytes, &merged.MaxDiffBytes, cfg.MaxDiffBytes},
+		}
+		for _, integer := range integers {
+			if integer.src != nil {
+				*integer.dst = integer.src
+				sources[integer.key] = layer.Path
+			}
+		}
+
+		for action, keys := range cfg.Keys {
+			if merged.Keys == nil {
+				merged.Keys = map[string]keyList{}
+			}
+			merged.Keys[action] = keys
+			sources[configKeyKeys+"."+action] = layer.Path
+		}
+		for name, driver := range cfg.DiffDrivers {
+			if merged.DiffDrivers == nil {
+				merged.DiffDrivers = map[string]diffDriverConfig{}
+			}
+			merged.DiffDrivers[name] = driver
+			sources[configKeyDiffDrivers+"."+name] = layer.Path
+		}
+		for name, theme := range cfg.Themes {
+			if merged.Themes == nil {
+				merged.Themes = map[string]userThemeConfig{}
+			}
+			name = normalizeCLIValue(name)
+			merged.Themes[name] = theme
+			sources[configKeyThemes+"."+name] = layer.Path
+		}
+		for themeName, roles := range cfg.SyntaxOverrides {
+			// Layers may spell a theme differently; the first spelling is kept.
+			canonical := syntaxOverrideThemeKey(themeName)
+			key, ok := syntaxThemeKeys[canonical]
+			if !ok {
+				key = themeName
+				syntaxThemeKeys[canonical] = key
+			}
+			if merged.SyntaxOverrides == nil {
+				merged.SyntaxOverrides = map[string]map[string]syntaxStyleConfig{}
+			}
+			if merged.SyntaxOverrides[key] == nil {
+				merged.SyntaxOverrides[key] = map[string]syntaxStyleConfig{}
+			}
+			for role, style := range roles {
+				merged.SyntaxOverrides[key][role] = style
+				sources[configKeySyntaxOverrides+"."+canonical+"."+role] = layer.Path
+			}
+		}
+	}
+	return merged, sources
+}
+
+// syntaxOverrideThemeKey returns the registered name of a syntax-overrides
+// theme, or the normalized spelling when the theme is unknown.
+func syntaxOverrideThemeKey(name string) string {
+	if parsed, err := parseThemeName(name); err == nil {
+		return parsed
+	}
+	return normalizeCLIValue(name)
+}
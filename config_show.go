@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configSourceDefault = "default"
+
+// configShowLine is one line of `dv config show`. Source is empty on lines
+// that continue the value above them.
+type configShowLine struct {
+	Text   string
+	Source string
+}
+
+// runConfigCommand runs `dv config <subcommand>`.
+func runConfigCommand(w io.Writer, args []string, values startupFlagValues, cfg startupConfig, sources map[string]string, explicitlySet map[string]bool) error {
+	if len(args) != 1 || args[0] != "show" {
+		return errors.New("expected a config subcommand (usage: dv [flags] config show)")
+	}
+	lines, err := configShowLines(values, cfg, sources, explicitlySet)
+	if err != nil {
+		return err
+	}
+	return writeConfigShowLines(w, lines)
+}
+
+// configShowLines renders the effective config as YAML, each value annotated
+// with the flag, file or default it came from.
+func configShowLines(values startupFlagValues, cfg startupConfig, sources map[string]string, explicitlySet map[string]bool) ([]configShowLine, error) {
+	source := func(key string) string {
+		if explicitlySet[key] {
+			return "flag --" + key
+		}
+		if path, ok := sources[key]; ok {
+			return path
+		}
+		return configSourceDefault
+	}
+
+	var lines []configShowLine
+	scalars := []struct {
+		key   string
+		value any
+	}{
+		{flagNameView, values.ViewMode},
+		{flagNameSidebar, values.SidebarVisible},
+		{flagNameTheme, values.ThemeName},
+		{configKeyThemeLight, values.ThemeLight},
+		{configKeyThemeDark, values.ThemeDark},
+		{flagNameIntralineStyle, values.IntralineStyle},
+		{flagNamePalette, values.Palette},
+		{flagNameColor, values.Color},
+		{flagNameShowSymbols, values.ShowSymbols},
+		{flagNameIgnoreWhitespace, values.IgnoreWhitespace},
+		{flagNameWordDiff, values.WordDiff},
+		{flagNameShowWhitespace, values.ShowWhitespace},
+		{flagNameMaxFileLines, values.MaxFileLines},
+		{flagNameMaxLineBytes, values.MaxLineBytes},
+		{flagNameMaxDiffBytes, values.MaxDiffBytes},
+		{flagNameTextconv, values.Textconv},
+		{flagNamePersistUIState, values.PersistUIState},
+	}
+	for _, scalar := range scalars {
+		text, err := flowYAML(scalar.value)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, configShowLine{Text: scalar.key + ": " + text, Source: source(scalar.key)})
+	}
+
+	bindings, err := resolveKeyBindings(cfg.Keys, sources)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config value for key %q: %w", configKeyKeys, err)
+	}
+	lines = append(lines, configShowLine{Text: configKeyKeys + ":"})
+	for _, id := range keyActionIDs() {
+		binding := bindings[id]
+		keys := append(append([]string{}, binding.Keys...), binding.HiddenKeys...)
+		text, err := flowYAML(keys)
+		if err != nil {
+			return nil, err
+		}
+		keySource := source(configKeyKeys + "." + id)
+		// Hidden keys work but are left out of the keybind bar.
+		if len(binding.HiddenKeys) > 0 {
+			keySource += " (hidden: " + strings.Join(binding.HiddenKeys, ", ") + ")"
+		}
+		lines = append(lines, configShowLine{Text: "  " + id + ": " + text, Source: keySource})
+	}
+
+	if len(cfg.Themes) > 0 {
+		lines = append(lines, configShowLine{Text: configKeyThemes + ":"})
+		for _, name := range sortedKeys(cfg.Themes) {
+			lines = append(lines, configShowLine{Text: "  " + name + ":", Source: source(configKeyThemes + "." + name)})
+			var buf bytes.Buffer
+			encoder := yaml.NewEncoder(&buf)
+			encoder.SetIndent(2)
+			if err := encoder.Encode(cfg.Themes[name]); err != nil {
+				return nil, err
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+				if line == "{}" {
+					continue
+				}
+				lines = append(lines, configShowLine{Text: "    " + line})
+			}
+		}
+	}
+
+	if len(cfg.DiffDrivers) > 0 {
+		lines = append(lines, configShowLine{Text: configKeyDiffDrivers + ":"})
+		for _, name := range sortedKeys(cfg.DiffDrivers) {
+			text, err := flowYAML(cfg.DiffDrivers[name])
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, configShowLine{Text: "  " + name + ": " + text, Source: source(configKeyDiffDrivers + "." + name)})
+		}
+	}
+
+	if len(cfg.SyntaxOverrides) > 0 {
+		lines = append(lines, configShowLine{Text: configKeySyntaxOverrides + ":"})
+		for _, themeName := range sortedKeys(cfg.SyntaxOverrides) {
+			canonical := syntaxOverrideThemeKey(themeName)
+			lines = append(lines, configShowLine{Text: "  " + canonical + ":"})
+			roles := cfg.SyntaxOverrides[themeName]
+			for _, role := range sortedKeys(roles) {
+				text, err := flowYAML(roles[role])
+				if err != nil {
+					return nil, err
+				}
+				lines = append(lines, configShowLine{Text: "    " + role + ": " + text, Source: source(configKeySyntaxOverrides + "." + canonical + "." + role)})
+			}
+		}
+	}
+	return lines, nil
+}
+
+// writeConfigShowLines writes lines with their sources as comments aligned in
+// one column.
+func writeConfigShowLines(w io.Writer, lines []configShowLine) error {
+	width := 0
+	for _, line := range lines {
+		if line.Source != "" {
+			width = max(width, len(line.Text))
+		}
+	}
+	for _, line := range lines {
+		text := line.Text
+		if line.Source != "" {
+			text = fmt.Sprintf("%-*s  # %s", width, text, line.Source)
+		}
+		if _, err := fmt.Fprintln(w, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flowYAML encodes value as single-line YAML.
+func flowYAML(value any) (string, error) {
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return "", err
+	}
+	setFlowStyle(&node)
+	data, err := yaml.Marshal(&node)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+func setFlowStyle(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		node.Style |= yaml.FlowStyle
+	}
+	for _, child := range node.Content {
+		setFlowStyle(child)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunConfigCommand_ShowsValuesWithSources(t *testing.T) {
+	view := "split"
+	cfg := startupConfig{
+		View: &view,
+		Keys: map[string]keyList{"next-file": {"ctrl+f"}},
+		Themes: map[string]userThemeConfig{
+			"team": {Extends: "nord", Colors: map[string]string{"primary": "#ff0000"}},
+		},
+		SyntaxOverrides: map[string]map[string]syntaxStyleConfig{
+			"Nord": {"keyword": {Foreground: "#ff79c6"}},
+		},
+		DiffDrivers: map[string]diffDriverConfig{"pdf": {Textconv: "pdftotext - -"}},
+	}
+	sources := map[string]string{
+		flagNameView:                    "/repo/.dv.yaml",
+		"keys.next-file":                "/repo/.dv.yaml",
+		"themes.team":                   "/home/config.yaml",
+		"syntax-overrides.nord.keyword": "/home/config.yaml",
+		"diff-drivers.pdf":              "/home/config.yaml",
+	}
+	values := startupFlagValues{
+		ViewMode:       "split",
+		SidebarVisible: true,
+		ThemeName:      "nord",
+		ThemeLight:     defaultThemeLight,
+		ThemeDark:      defaultThemeDark,
+		IntralineStyle: "background",
+		Palette:        "default",
+		Color:          "auto",
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, runConfigCommand(&out, []string{"show"}, values, cfg, sources, map[string]bool{flagNameTheme: true}))
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	fields := map[string]string{}
+	for _, line := range lines {
+		text, source, ok := strings.Cut(line, "  # ")
+		if ok {
+			fields[strings.TrimSpace(text)] = source
+		}
+	}
+	require.Equal(t, "/repo/.dv.yaml", fields["view: split"])
+	require.Equal(t, "flag --theme", fields["theme: nord"])
+	require.Equal(t, "default", fields["sidebar: true"])
+	require.Equal(t, "/repo/.dv.yaml", fields["next-file: [ctrl+f]"])
+	require.Equal(t, "default (hidden: ctrl+k)", fields["prev-file: [p, '[', ctrl+k]"])
+	require.Equal(t, "/home/config.yaml", fields["team:"])
+	require.Equal(t, "/home/config.yaml", fields["keyword: {fg: '#ff79c6'}"])
+	require.Equal(t, "/home/config.yaml", fields["pdf: {textconv: pdftotext - -}"])
+	require.Equal(t, "default", fields["textconv: false"])
+	require.Contains(t, lines, "    extends: nord")
+	require.Contains(t, lines, "  nord:")
+
+	// Source comments line up in one column.
+	column := strings.Index(lines[0], "#")
+	for _, line := range lines {
+		if idx := strings.Index(line, "  # "); idx >= 0 {
+			require.Equal(t, column, idx+2, line)
+		}
+	}
+}
+
+func TestRunConfigCommand_RejectsUnknownSubcommand(t *testing.T) {
+	var out bytes.Buffer
+	err := runConfigCommand(&out, []string{"edit"}, startupFlagValues{}, startupConfig{}, nil, nil)
+	require.ErrorContains(t, err, "config show")
+	require.Empty(t, out.String())
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -99,6 +100,96 @@ intraline-style: off
 	require.Equal(t, "off", *cfg.IntralineStyle)
 }
 
+func TestLoadStartupConfig_ParsesKeys(t *testing.T) {
+	configHome := t.TempDir()
+	configPath := filepath.Join(configHome, "custom.yaml")
+	writeTestConfig(t, configPath, `
+keys:
+  next-file: N
+  prev-file: [P, ctrl+b]
+  jump-down: []
+  jump-up: []
+`)
+
+	cfg, err := loadStartupConfig(configHome, configPath, false)
+	require.NoError(t, err)
+	require.Equal(t, map[string]keyList{
+		"next-file": {"N"},
+		"prev-file": {"P", "ctrl+b"},
+		"jump-down": {},
+		"jump-up":   {},
+	}, cfg.Keys)
+}
+
+func TestLoadStartupConfig_RegistersThemes(t *testing.T) {
+	configHome := t.TempDir()
+	themesDir := filepath.Join(configHome, defaultThemesRelDir)
+	require.NoError(t, os.MkdirAll(themesDir, 0o755))
+	writeTestConfig(t, filepath.Join(themesDir, "test-config-file-theme.yaml"), "extends: dracula\n")
+	configPath := filepath.Join(configHome, "custom.yaml")
+	writeTestConfig(t, configPath, `
+theme: test-config-theme
+themes:
+  test-config-theme:
+    extends: test-config-file-theme
+    colors:
+      primary: "#ff0000"
+`)
+
+	cfg, err := loadStartupConfig(configHome, configPath, false)
+	require.NoError(t, err)
+	require.Equal(t, "test-config-theme", *cfg.Theme)
+	_, err = parseThemeName("test-config-file-theme")
+	require.NoError(t, err)
+	_, err = parseThemeName("test-config-theme")
+	require.NoError(t, err)
+}
+
+func TestLoadStartupConfig_AppliesSyntaxOverrides(t *testing.T) {
+	configHome := t.TempDir()
+	configPath := filepath.Join(configHome, "custom.yaml")
+	writeTestConfig(t, configPath, `
+syntax-overrides:
+  Nord:
+    keyword: "#ff79c6"
+    comment: {fg: "#aaaaaa", italic: true}
+    string: {bold: true}
+`)
+
+	cfg, err := loadStartupConfig(configHome, configPath, false)
+	require.NoError(t, err)
+	require.Equal(t, "#ff79c6", cfg.SyntaxOverrides["Nord"]["keyword"].Foreground)
+
+	resolved, err := resolveSyntaxOverrides(cfg.SyntaxOverrides)
+	require.NoError(t, err)
+	overrides := resolved["nord"]
+	keywordColor, err := parseThemeColor("#ff79c6")
+	require.NoError(t, err)
+	require.Equal(t, keywordColor, overrides[TokenRoleSyntaxKeyword].Foreground)
+	require.True(t, *overrides[TokenRoleSyntaxComment].Italic)
+	require.False(t, overrides[TokenRoleSyntaxString].Foreground.IsSet())
+	require.True(t, *overrides[TokenRoleSyntaxString].Bold)
+}
+
+func TestLoadStartupConfig_ParsesAutoTheme(t *testing.T) {
+	configHome := t.TempDir()
+	configPath := filepath.Join(configHome, "custom.yaml")
+	writeTestConfig(t, configPath, `
+theme: auto
+theme-light: solarized-light
+theme-dark: nord
+`)
+
+	cfg, err := loadStartupConfig(configHome, configPath, false)
+	require.NoError(t, err)
+	require.Equal(t, "auto", *cfg.Theme)
+
+	got := applyStartupConfig(startupFlagValues{ThemeLight: defaultThemeLight, ThemeDark: defaultThemeDark}, cfg, map[string]bool{})
+	require.Equal(t, "auto", got.ThemeName)
+	require.Equal(t, "solarized-light", got.ThemeLight)
+	require.Equal(t, "nord", got.ThemeDark)
+}
+
 func TestLoadStartupConfig_UnknownKeyErrors(t *testing.T) {
 	configHome := t.TempDir()
 	configPath := filepath.Join(configHome, "custom.yaml")
@@ -139,11 +230,71 @@ func TestLoadStartupConfig_InvalidValuesIncludeKeyContext(t *testing.T) {
 			yaml:        "theme: missing-theme\n",
 			wantKeyName: flagNameTheme,
 		},
+		{
+			name:        "themeLight",
+			yaml:        "theme-light: missing-theme\n",
+			wantKeyName: configKeyThemeLight,
+		},
+		{
+			name:        "themeDarkCannotBeAuto",
+			yaml:        "theme-dark: auto\n",
+			wantKeyName: configKeyThemeDark,
+		},
 		{
 			name:        "intralineStyle",
 			yaml:        "intraline-style: outline\n",
 			wantKeyName: flagNameIntralineStyle,
 		},
+		{
+			name:        "palette",
+			yaml:        "palette: sepia\n",
+			wantKeyName: flagNamePalette,
+		},
+		{
+			name:        "color",
+			yaml:        "color: 8\n",
+			wantKeyName: flagNameColor,
+		},
+		{
+			name:        "diffDriverName",
+			yaml:        "diff-drivers:\n  \"a=b\":\n    textconv: cat\n",
+			wantKeyName: configKeyDiffDrivers,
+		},
+		{
+			name:        "unknownKeyAction",
+			yaml:        "keys:\n  launch-rockets: z\n",
+			wantKeyName: configKeyKeys,
+		},
+		{
+			name:        "conflictingKeys",
+			yaml:        "keys:\n  toggle-split: n\n",
+			wantKeyName: configKeyKeys,
+		},
+		{
+			name:        "keyMapping",
+			yaml:        "keys:\n  next-file: {key: j}\n",
+			wantKeyName: "expected a key or a list of keys",
+		},
+		{
+			name:        "invalidTheme",
+			yaml:        "themes:\n  test-invalid:\n    colors:\n      text: nope\n",
+			wantKeyName: configKeyThemes,
+		},
+		{
+			name:        "syntaxOverrideUnknownRole",
+			yaml:        "syntax-overrides:\n  nord:\n    macro: \"#fff\"\n",
+			wantKeyName: configKeySyntaxOverrides,
+		},
+		{
+			name:        "syntaxOverrideUnknownTheme",
+			yaml:        "syntax-overrides:\n  missing-theme:\n    keyword: \"#fff\"\n",
+			wantKeyName: configKeySyntaxOverrides,
+		},
+		{
+			name:        "syntaxOverrideUnknownField",
+			yaml:        "syntax-overrides:\n  nord:\n    keyword: {colour: \"#fff\"}\n",
+			wantKeyName: "unknown syntax style field",
+		},
 		{
 			name:        "stagedScopeIsRejected",
 			yaml:        "staged: true\n",
@@ -165,6 +316,58 @@ func TestLoadStartupConfig_InvalidValuesIncludeKeyContext(t *testing.T) {
 	}
 }
 
+func TestLoadStartupConfigLayers_ValidatesMergedKeys(t *testing.T) {
+	configHome := t.TempDir()
+	repoRoot := t.TempDir()
+	repoPath := filepath.Join(repoRoot, defaultRepoConfigName)
+	userPath := filepath.Join(configHome, defaultConfigRelPath)
+
+	// The repository takes next-file's default key, which the user config
+	// moves elsewhere, so the merged bindings are fine.
+	writeTestConfig(t, repoPath, "keys:\n  toggle-split: n\n")
+	writeTestConfig(t, userPath, "keys:\n  next-file: ctrl+f\n")
+	_, _, err := loadStartupConfigLayers(configHome, "", false, repoRoot)
+	require.NoError(t, err)
+
+	// Each side of a conflict between layers is reported with its file.
+	writeTestConfig(t, userPath, "keys:\n  comment: \"|\"\n")
+	writeTestConfig(t, repoPath, "keys:\n  toggle-split: \"|\"\n")
+	_, _, err = loadStartupConfigLayers(configHome, "", false, repoRoot)
+	require.ErrorContains(t, err, fmt.Sprintf(`key "|" is bound to both "toggle-split" (in %s) and "comment" (in %s)`, repoPath, userPath))
+
+	writeTestConfig(t, userPath, "")
+	writeTestConfig(t, repoPath, "keys:\n  toggle-split: n\n")
+	_, _, err = loadStartupConfigLayers(configHome, "", false, repoRoot)
+	require.ErrorContains(t, err, fmt.Sprintf(`key "n" is bound to both "next-file" (default) and "toggle-split" (in %s)`, repoPath))
+}
+
+func TestLoadStartupConfigLayers_ValidatesMergedThemeNames(t *testing.T) {
+	configHome := t.TempDir()
+	repoRoot := t.TempDir()
+	repoPath := filepath.Join(repoRoot, defaultRepoConfigName)
+	userPath := filepath.Join(configHome, defaultConfigRelPath)
+
+	// The repository config is read first, so it names a theme the user
+	// config has not registered yet.
+	writeTestConfig(t, repoPath, `
+theme: test-layered-theme
+theme-dark: test-layered-theme
+syntax-overrides:
+  test-layered-theme:
+    keyword: "#ff0000"
+`)
+	writeTestConfig(t, userPath, "themes:\n  test-layered-theme:\n    extends: nord\n")
+	layers, _, err := loadStartupConfigLayers(configHome, "", false, repoRoot)
+	require.NoError(t, err)
+	cfg, _ := mergeStartupConfigLayers(layers)
+	require.Equal(t, "test-layered-theme", *cfg.Theme)
+
+	writeTestConfig(t, userPath, "")
+	writeTestConfig(t, repoPath, "theme-light: missing-theme\n")
+	_, _, err = loadStartupConfigLayers(configHome, "", false, repoRoot)
+	require.ErrorContains(t, err, fmt.Sprintf(`invalid config value for key %q in %q`, configKeyThemeLight, repoPath))
+}
+
 func TestApplyStartupConfig_AppliesWhenFlagNotSet(t *testing.T) {
 	view := "split"
 	sidebar := false
@@ -275,8 +478,182 @@ func TestApplyStartupConfig_ExplicitFalseBooleanFlagsWinOverConfig(t *testing.T)
 	require.False(t, got.IgnoreWhitespace)
 }
 
+func TestApplyStartupConfig_PersistUIState(t *testing.T) {
+	persist := true
+	cfg := startupConfig{PersistUIState: &persist}
+
+	got := applyStartupConfig(startupFlagValues{}, cfg, map[string]bool{})
+	require.True(t, got.PersistUIState)
+
+	got = applyStartupConfig(startupFlagValues{}, cfg, map[string]bool{flagNamePersistUIState: true})
+	require.False(t, got.PersistUIState)
+}
+
+func TestThemeSetExplicitly(t *testing.T) {
+	auto, dark := "auto", "nord"
+	require.False(t, themeSetExplicitly(startupConfig{}, map[string]bool{}))
+	require.True(t, themeSetExplicitly(startupConfig{}, map[string]bool{flagNameTheme: true}))
+	require.True(t, themeSetExplicitly(startupConfig{Theme: &auto}, map[string]bool{}))
+	require.True(t, themeSetExplicitly(startupConfig{ThemeDark: &dark}, map[string]bool{}))
+}
+
+func TestLoadStartupConfigLayers_UserConfigWinsOverRepoConfig(t *testing.T) {
+	configHome := t.TempDir()
+	repoRoot := t.TempDir()
+	repoPath := filepath.Join(repoRoot, defaultRepoConfigName)
+	userPath := filepath.Join(configHome, defaultConfigRelPath)
+	writeTestConfig(t, repoPath, `
+view: split
+ignore-whitespace: true
+keys:
+  next-file: N
+  prev-file: ctrl+b
+syntax-overrides:
+  Nord:
+    keyword: "#111111"
+    comment: "#222222"
+`)
+	writeTestConfig(t, userPath, `
+view: unified
+keys:
+  prev-file: P
+syntax-overrides:
+  nord:
+    comment: "#333333"
+`)
+
+	layers, _, err := loadStartupConfigLayers(configHome, "", false, repoRoot)
+	require.NoError(t, err)
+	require.Len(t, layers, 2)
+	require.Equal(t, repoPath, layers[0].Path)
+	require.Equal(t, userPath, layers[1].Path)
+
+	cfg, sources := mergeStartupConfigLayers(layers)
+	require.Equal(t, "unified", *cfg.View)
+	require.True(t, *cfg.IgnoreWhitespace)
+	require.Equal(t, keyList{"N"}, cfg.Keys["next-file"])
+	require.Equal(t, keyList{"P"}, cfg.Keys["prev-file"])
+	require.Equal(t, userPath, sources[flagNameView])
+	require.Equal(t, repoPath, sources[flagNameIgnoreWhitespace])
+	require.Equal(t, repoPath, sources["keys.next-file"])
+	require.Equal(t, userPath, sources["keys.prev-file"])
+	require.Equal(t, repoPath, sources["syntax-overrides.nord.keyword"])
+	require.Equal(t, userPath, sources["syntax-overrides.nord.comment"])
+
+	resolved, err := resolveSyntaxOverrides(cfg.SyntaxOverrides)
+	require.NoError(t, err)
+	overrides := resolved["nord"]
+	keyword, err := parseThemeColor("#111111")
+	require.NoError(t, err)
+	require.Equal(t, keyword, overrides[TokenRoleSyntaxKeyword].Foreground)
+	comment, err := parseThemeColor("#333333")
+	require.NoError(t, err)
+	require.Equal(t, comment, overrides[TokenRoleSyntaxComment].Foreground)
+
+	got := applyStartupConfig(startupFlagValues{ViewMode: "split"}, cfg, map[string]bool{flagNameView: true})
+	require.Equal(t, "split", got.ViewMode)
+	require.True(t, got.IgnoreWhitespace)
+}
+
+func TestLoadStartupConfigLayers_RepoConfigIsOptionalAndDisabledByNoConfig(t *testing.T) {
+	configHome := t.TempDir()
+	repoRoot := t.TempDir()
+
+	layers, _, err := loadStartupConfigLayers(configHome, "", false, repoRoot)
+	require.NoError(t, err)
+	cfg, _ := mergeStartupConfigLayers(layers)
+	require.Equal(t, startupConfig{}, cfg)
+
+	writeTestConfig(t, filepath.Join(repoRoot, defaultRepoConfigName), "view: [")
+	_, _, err = loadStartupConfigLayers(configHome, "", false, repoRoot)
+	require.ErrorContains(t, err, defaultRepoConfigName)
+
+	layers, _, err = loadStartupConfigLayers(configHome, "", true, repoRoot)
+	require.NoError(t, err)
+	require.Empty(t, layers)
+}
+
+func TestLoadStartupConfigLayers_DiffDriversOnlyFromUserConfig(t *testing.T) {
+	configHome := t.TempDir()
+	repoRoot := t.TempDir()
+	repoPath := filepath.Join(repoRoot, defaultRepoConfigName)
+	userPath := filepath.Join(configHome, defaultConfigRelPath)
+	writeTestConfig(t, repoPath, "textconv: true\n")
+	writeTestConfig(t, userPath, `
+diff-drivers:
+  pdf:
+    textconv: pdftotext -layout - -
+  sqlite:
+    textconv: sqlite3 -readonly
+    xfuncname: "^CREATE TABLE .*$"
+`)
+
+	layers, _, err := loadStartupConfigLayers(configHome, "", false, repoRoot)
+	require.NoError(t, err)
+	cfg, sources := mergeStartupConfigLayers(layers)
+	require.True(t, *cfg.Textconv)
+	require.Equal(t, map[string]diffDriverConfig{
+		"pdf":    {Textconv: "pdftotext -layout - -"},
+		"sqlite": {Textconv: "sqlite3 -readonly", Xfuncname: "^CREATE TABLE .*$"},
+	}, cfg.DiffDrivers)
+	require.Equal(t, repoPath, sources[flagNameTextconv])
+	require.Equal(t, userPath, sources["diff-drivers.pdf"])
+
+	writeTestConfig(t, repoPath, "diff-drivers:\n  pdf:\n    textconv: ./run-me\n")
+	_, _, err = loadStartupConfigLayers(configHome, "", false, repoRoot)
+	require.ErrorContains(t, err, configKeyDiffDrivers)
+	require.ErrorContains(t, err, repoPath)
+}
+
 func writeTestConfig(t *testing.T, path string, content string) {
 	t.Helper()
 	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
 	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
 }
+
+func TestApplyStartupConfig_WordDiff(t *testing.T) {
+	wordDiff := true
+	cfg := startupConfig{WordDiff: &wordDiff}
+
+	got := applyStartupConfig(startupFlagValues{}, cfg, map[string]bool{})
+	require.True(t, got.WordDiff)
+
+	got = applyStartupConfig(startupFlagValues{}, cfg, map[string]bool{flagNameWordDiff: true})
+	require.False(t, got.WordDiff)
+}
+
+func TestApplyStartupConfig_ShowWhitespace(t *testing.T) {
+	showWhitespace := true
+	cfg := startupConfig{ShowWhitespace: &showWhitespace}
+
+	got := applyStartupConfig(startupFlagValues{}, cfg, map[string]bool{})
+	require.True(t, got.ShowWhitespace)
+
+	got = applyStartupConfig(startupFlagValues{}, cfg, map[string]bool{flagNameShowWhitespace: true})
+	require.False(t, got.ShowWhitespace)
+}
+
+func TestApplyStartupConfig_LargeFileLimits(t *testing.T) {
+	maxFileLines := 100
+	maxLineBytes := 0
+	cfg := startupConfig{MaxFileLines: &maxFileLines, MaxLineBytes: &maxLineBytes}
+
+	got := applyStartupConfig(startupFlagValues{MaxFileLines: 5000, MaxLineBytes: 4096, MaxDiffBytes: 10}, cfg, map[string]bool{})
+	require.Equal(t, 100, got.MaxFileLines)
+	require.Equal(t, 0, got.MaxLineBytes)
+	require.Equal(t, 10, got.MaxDiffBytes)
+
+	got = applyStartupConfig(startupFlagValues{MaxFileLines: 5000}, cfg, map[string]bool{flagNameMaxFileLines: true})
+	require.Equal(t, 5000, got.MaxFileLines)
+}
+
+func TestApplyStartupConfig_Textconv(t *testing.T) {
+	textconv := true
+	cfg := startupConfig{Textconv: &textconv}
+
+	got := applyStartupConfig(startupFlagValues{}, cfg, map[string]bool{})
+	require.True(t, got.Textconv)
+
+	got = applyStartupConfig(startupFlagValues{}, cfg, map[string]bool{flagNameTextconv: true})
+	require.False(t, got.Textconv)
+}
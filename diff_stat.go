@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	t "github.com/darrenburns/terma"
+)
+
+// diffStatMinBarWidth is the narrowest the --stat bars get before long paths
+// are truncated to make room.
+const diffStatMinBarWidth = 10
+
+// diffStatHistogramWidth is the width of the histogram column in the section
+// info card.
+const diffStatHistogramWidth = 20
+
+// diffStatRow is one line of a diffstat: a file, or a directory with the
+// totals of everything below it.
+type diffStatRow struct {
+	Path      string
+	Label     string
+	Depth     int
+	IsDir     bool
+	Binary    bool
+	Additions int
+	Deletions int
+}
+
+func (r diffStatRow) Changed() int {
+	return r.Additions + r.Deletions
+}
+
+// diffStatSortMode is the order of the file table in the section info card.
+type diffStatSortMode int
+
+const (
+	diffStatSortChanged diffStatSortMode = iota
+	diffStatSortAdditions
+	diffStatSortDeletions
+	diffStatSortPath
+)
+
+func (m diffStatSortMode) Next() diffStatSortMode {
+	return (m + 1) % (diffStatSortPath + 1)
+}
+
+func (m diffStatSortMode) DisplayName() string {
+	switch m {
+	case diffStatSortAdditions:
+		return "additions"
+	case diffStatSortDeletions:
+		return "deletions"
+	case diffStatSortPath:
+		return "path"
+	default:
+		return "lines changed"
+	}
+}
+
+// diffStatTreeRows flattens the sidebar tree into diffstat rows, directories
+// first, using the totals aggregateTreeStats stored on each node.
+func diffStatTreeRows(roots []t.TreeNode[DiffTreeNodeData]) []diffStatRow {
+	rows := []diffStatRow{}
+	var walk func(nodes []t.TreeNode[DiffTreeNodeData], depth int)
+	walk = func(nodes []t.TreeNode[DiffTreeNodeData], depth int) {
+		for _, node := range nodes {
+			row := diffStatRow{
+				Path:      node.Data.Path,
+				Label:     node.Data.Name,
+				Depth:     depth,
+				IsDir:     node.Data.IsDir,
+				Additions: node.Data.Additions,
+				Deletions: node.Data.Deletions,
+			}
+			if node.Data.IsDir {
+				row.Label += "/"
+			} else if node.Data.File != nil {
+				row.Binary = node.Data.File.IsBinary
+			}
+			rows = append(rows, row)
+			if node.Data.IsDir {
+				walk(node.Children, depth+1)
+			}
+		}
+	}
+	walk(roots, 0)
+	return rows
+}
+
+// sortedDiffStatFileRows returns one row per file, ordered for the section
+// info card. Ties fall back to path order so the table is stable.
+func sortedDiffStatFileRows(files []*DiffFile, mode diffStatSortMode) []diffStatRow {
+	rows := make([]diffStatRow, 0, len(files))
+	for _, file := range files {
+		if file == nil {
+			continue
+		}
+		rows = append(rows, diffStatRow{
+			Path:      file.DisplayPath,
+			Label:     file.DisplayPath,
+			Binary:    file.IsBinary,
+			Additions: file.Additions,
+			Deletions: file.Deletions,
+		})
+	}
+	key := func(row diffStatRow) int {
+		switch mode {
+		case diffStatSortAdditions:
+			return row.Additions
+		case diffStatSortDeletions:
+			return row.Deletions
+		case diffStatSortPath:
+			return 0
+		default:
+			return row.Changed()
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if left, right := key(rows[i]), key(rows[j]); left != right {
+			return left > right
+		}
+		return rows[i].Path < rows[j].Path
+	})
+	return rows
+}
+
+// diffStatBarCounts scales a row's additions and deletions to at most width
+// characters, the way git does: rows with changes always get at least one
+// character per non-zero side.
+func diffStatBarCounts(additions int, deletions int, maxChanged int, width int) (plus int, minus int) {
+	if width <= 0 || maxChanged <= 0 {
+		return 0, 0
+	}
+	if maxChanged <= width {
+		return additions, deletions
+	}
+	scale := func(value int) int {
+		if value <= 0 {
+			return 0
+		}
+		return 1 + value*(width-1)/maxChanged
+	}
+	total := scale(additions + deletions)
+	if total < 2 && additions > 0 && deletions > 0 {
+		total = 2
+	}
+	if additions < deletions {
+		plus = scale(additions)
+		return plus, total - plus
+	}
+	minus = scale(deletions)
+	return total - minus, minus
+}
+
+// printDiffStat writes a git-style diffstat of the section dv would open on,
+// grouped by directory like the sidebar. Bars use the theme's success and
+// error colours when color is set.
+func printDiffStat(w io.Writer, provider DiffProvider, staged bool, initialState DvInitialState, width int, color bool) error {
+	initialState = normalizeDvInitialState(initialState)
+	t.SetTheme(initialState.ThemeName)
+	theme, _ := t.GetTheme(t.CurrentThemeName())
+
+	section, doc, err := loadStartupSectionDiff(provider, staged, initialState.IgnoreWhitespace)
+	if err != nil {
+		return err
+	}
+	roots, _, _ := buildDiffTreeForSection(section, doc.Files)
+	_, err = io.WriteString(w, formatDiffStat(diffStatTreeRows(roots), width, theme, color))
+	return err
+}
+
+func formatDiffStat(rows []diffStatRow, width int, theme t.ThemeData, color bool) string {
+	if len(rows) == 0 {
+		return ""
+	}
+
+	labelWidth := 0
+	maxChanged := 0
+	countWidth := 1
+	files, additions, deletions := 0, 0, 0
+	for _, row := range rows {
+		labelWidth = max(labelWidth, diffStatIndentWidth(row)+ansi.StringWidth(row.Label))
+		maxChanged = max(maxChanged, row.Changed())
+		if row.Binary && row.Changed() == 0 {
+			countWidth = max(countWidth, len("Bin"))
+		}
+		if !row.IsDir {
+			files++
+			additions += row.Additions
+			deletions += row.Deletions
+		}
+	}
+	countWidth = max(countWidth, len(strconv.Itoa(maxChanged)))
+
+	// " <label> | <count> <bar>"
+	fixed := 1 + 3 + countWidth + 1
+	barWidth := min(maxChanged, max(0, width-fixed-labelWidth))
+	if minBar := min(maxChanged, diffStatMinBarWidth); barWidth < minBar {
+		barWidth = minBar
+		labelWidth = max(1, width-fixed-barWidth)
+	}
+
+	var builder strings.Builder
+	for _, row := range rows {
+		count := strconv.Itoa(row.Changed())
+		if row.Binary && row.Changed() == 0 {
+			count = "Bin"
+		}
+		plus, minus := diffStatBarCounts(row.Additions, row.Deletions, maxChanged, barWidth)
+		builder.WriteString(" ")
+		builder.WriteString(diffStatLabel(row, labelWidth))
+		builder.WriteString(" | ")
+		builder.WriteString(fmt.Sprintf("%*s", countWidth, count))
+		if plus+minus > 0 {
+			builder.WriteString(" ")
+			builder.WriteString(diffStatColorize(strings.Repeat("+", plus), theme.Success, color))
+			builder.WriteString(diffStatColorize(strings.Repeat("-", minus), theme.Error, color))
+		}
+		builder.WriteString("\n")
+	}
+	builder.WriteString(diffStatSummaryLine(files, additions, deletions))
+	builder.WriteString("\n")
+	return builder.String()
+}
+
+func diffStatIndentWidth(row diffStatRow) int {
+	return row.Depth * 2
+}
+
+// diffStatLabel pads the indented row label to width, cutting long names
+// from the left so the file name itself stays visible.
+func diffStatLabel(row diffStatRow, width int) string {
+	indent := strings.Repeat(" ", diffStatIndentWidth(row))
+	label := indent + row.Label
+	if labelWidth := ansi.StringWidth(label); labelWidth > width {
+		label = ansi.TruncateLeft(label, labelWidth-width+1, "…")
+	}
+	return label + strings.Repeat(" ", max(0, width-ansi.StringWidth(label)))
+}
+
+func diffStatColorize(text string, color t.Color, enabled bool) string {
+	if !enabled || text == "" || !color.IsSet() {
+		return text
+	}
+	r, g, b := color.RGB()
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm%s%s", r, g, b, text, ansiResetSequence)
+}
+
+func diffStatSummaryLine(files int, additions int, deletions int) string {
+	plural := func(count int, singular string, pluralForm string) string {
+		if count == 1 {
+			return singular
+		}
+		return pluralForm
+	}
+	parts := []string{fmt.Sprintf(" %d %s changed", files, plural(files, "file", "files"))}
+	if additions > 0 || deletions == 0 {
+		parts = append(parts, fmt.Sprintf("%d %s(+)", additions, plural(additions, "insertion", "insertions")))
+	}
+	if deletions > 0 || additions == 0 {
+		parts = append(parts, fmt.Sprintf("%d %s(-)", deletions, plural(deletions, "deletion", "deletions")))
+	}
+	return strings.Join(parts, ", ")
+}
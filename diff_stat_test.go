@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	t "github.com/darrenburns/terma"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffStatBarCounts(tt *testing.T) {
+	plus, minus := diffStatBarCounts(3, 2, 10, 20)
+	require.Equal(tt, []int{3, 2}, []int{plus, minus})
+
+	plus, minus = diffStatBarCounts(300, 100, 400, 10)
+	require.Equal(tt, []int{7, 3}, []int{plus, minus})
+
+	plus, minus = diffStatBarCounts(1, 1, 1000, 10)
+	require.Equal(tt, []int{1, 1}, []int{plus, minus})
+
+	plus, minus = diffStatBarCounts(0, 0, 10, 10)
+	require.Equal(tt, []int{0, 0}, []int{plus, minus})
+}
+
+func TestPrintDiffStat_GroupsByDirectory(tt *testing.T) {
+	provider := &scriptedDiffProvider{diffs: []string{diffForPaths("pkg/a.go", "pkg/b.go", "README.md")}}
+
+	var out strings.Builder
+	require.NoError(tt, printDiffStat(&out, provider, false, DefaultDvInitialState(), 80, false))
+	require.Equal(tt, strings.Join([]string{
+		" pkg/      | 4 ++--",
+		"   a.go    | 2 +-",
+		"   b.go    | 2 +-",
+		" README.md | 2 +-",
+		" 3 files changed, 3 insertions(+), 3 deletions(-)",
+		"",
+	}, "\n"), out.String())
+}
+
+func TestPrintDiffStat_ColorsBarsWithTheme(tt *testing.T) {
+	provider := &scriptedDiffProvider{diffs: []string{diffForPaths("a.go")}}
+
+	var out strings.Builder
+	require.NoError(tt, printDiffStat(&out, provider, false, DefaultDvInitialState(), 80, true))
+	theme, ok := t.GetTheme(t.CurrentThemeName())
+	require.True(tt, ok)
+	r, g, b := theme.Success.RGB()
+	require.Contains(tt, out.String(), fmt.Sprintf("\x1b[38;2;%d;%d;%dm+", r, g, b))
+	require.Equal(tt, " a.go | 2 +-\n 1 file changed, 1 insertion(+), 1 deletion(-)\n", ansi.Strip(out.String()))
+}
+
+func TestFormatDiffStat_ScalesBarsAndTruncatesLongPaths(tt *testing.T) {
+	rows := []diffStatRow{
+		{Label: "a/very/long/path/to/some/file.go", Additions: 90, Deletions: 10},
+		{Label: "small.go", Additions: 1},
+		{Label: "logo.png", Binary: true},
+	}
+	lines := strings.Split(strings.TrimSuffix(formatDiffStat(rows, 40, t.ThemeData{}, false), "\n"), "\n")
+	require.Len(tt, lines, 4)
+	for _, line := range lines[:3] {
+		require.LessOrEqual(tt, ansi.StringWidth(line), 40)
+	}
+	require.Equal(tt, " …/path/to/some/file.go | 100 +++++++++-", lines[0])
+	require.Equal(tt, " small.go               |   1 +", lines[1])
+	require.Equal(tt, " logo.png               | Bin", lines[2])
+	require.Equal(tt, " 3 files changed, 91 insertions(+), 10 deletions(-)", lines[3])
+}
+
+func TestSortedDiffStatFileRows(tt *testing.T) {
+	files := []*DiffFile{
+		{DisplayPath: "b.go", Additions: 1, Deletions: 5},
+		{DisplayPath: "a.go", Additions: 4, Deletions: 0},
+		{DisplayPath: "c.go", Additions: 2, Deletions: 2},
+	}
+	paths := func(rows []diffStatRow) []string {
+		out := []string{}
+		for _, row := range rows {
+			out = append(out, row.Path)
+		}
+		return out
+	}
+	require.Equal(tt, []string{"b.go", "a.go", "c.go"}, paths(sortedDiffStatFileRows(files, diffStatSortChanged)))
+	require.Equal(tt, []string{"a.go", "c.go", "b.go"}, paths(sortedDiffStatFileRows(files, diffStatSortAdditions)))
+	require.Equal(tt, []string{"b.go", "c.go", "a.go"}, paths(sortedDiffStatFileRows(files, diffStatSortDeletions)))
+	require.Equal(tt, []string{"a.go", "b.go", "c.go"}, paths(sortedDiffStatFileRows(files, diffStatSortPath)))
+	require.Equal(tt, diffStatSortChanged, diffStatSortPath.Next())
+}
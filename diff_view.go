@@ -21,14 +21,22 @@ type DiffView struct {
 	HardWrap        bool
 	HideChangeSigns bool
 	IntralineStyle  IntralineStyleMode
-	Palette         ThemePalette
-	Width           t.Dimension
-	Height          t.Dimension
-	Style           t.Style
+	PaletteVariant  PaletteVariant
+	PaletteOptions  paletteOptions
+	SeenHunks       map[int]bool
+	SelectedLines   reviewLineSpan
+	OnLineClick     func(side ReviewCommentSide, line int, extend bool)
+	// OnMovedLineClick is called with the counterpart of a moved line whose
+	// gutter was clicked.
+	OnMovedLineClick func(move RenderedLineMove)
+	Palette          ThemePalette
+	Width            t.Dimension
+	Height           t.Dimension
+	Style            t.Style
 }
 
 func (d DiffView) Build(ctx t.BuildContext) t.Widget {
-	d.Palette = NewThemePalette(ctx.Theme())
+	d.Palette = newThemePalette(ctx.Theme(), d.PaletteVariant, d.PaletteOptions)
 	return d
 }
 
@@ -57,29 +65,149 @@ func (d DiffView) GetStyle() t.Style {
 }
 
 func (d DiffView) OnMouseDown(event t.MouseEvent) {
-	if d.State == nil || d.LayoutMode != DiffLayoutSideBySide || event.Button != uv.MouseLeft {
+	if d.State == nil || event.Button != uv.MouseLeft {
 		return
 	}
+	if d.startSideDividerDrag(event) {
+		return
+	}
+	if d.OnMovedLineClick != nil {
+		if move, ok := d.movedLineAt(event.LocalX, event.LocalY); ok {
+			d.OnMovedLineClick(move)
+			return
+		}
+	}
+	if d.OnLineClick == nil {
+		return
+	}
+	if side, line, ok := d.lineTargetAt(event.LocalX, event.LocalY); ok {
+		d.OnLineClick(side, line, event.Mod&uv.ModShift != 0)
+	}
+}
+
+func (d DiffView) startSideDividerDrag(event t.MouseEvent) bool {
+	if d.LayoutMode != DiffLayoutSideBySide {
+		return false
+	}
 
 	sideBySide := d.currentSideBySide()
 	if sideBySide == nil {
-		return
+		return false
 	}
 
 	viewportWidth := d.State.ViewportWidth()
 	if viewportWidth <= 0 {
-		return
+		return false
 	}
 
 	panes := sideBySidePaneLayout(viewportWidth, sideBySide, d.HideChangeSigns, d.sideBySideSplitRatio())
 	if panes.DividerWidth <= 0 {
-		return
+		return false
 	}
 	if event.LocalX < panes.DividerX || event.LocalX >= panes.DividerX+panes.DividerWidth {
-		return
+		return false
 	}
 
 	d.State.StartSideDividerDrag(event.LocalX, panes.DividerX)
+	return true
+}
+
+// diffLineHit is the line drawn at a point in the view: a unified line, or
+// one cell of a side-by-side row.
+type diffLineHit struct {
+	Line       RenderedDiffLine
+	Cell       *RenderedSideCell
+	SideBySide bool
+	IsLeft     bool
+	InGutter   bool
+}
+
+// lineTargetAt returns the file line drawn at a point in the view. In
+// side-by-side mode the left pane addresses the old file and the right pane
+// the new file.
+func (d DiffView) lineTargetAt(x int, y int) (ReviewCommentSide, int, bool) {
+	hit, ok := d.lineHitAt(x, y)
+	if !ok {
+		return "", 0, false
+	}
+	if hit.SideBySide {
+		return sideCellLineTarget(hit.Cell, hit.IsLeft)
+	}
+	return unifiedLineTarget(hit.Line)
+}
+
+// movedLineAt returns the counterpart of the moved line whose gutter is at a
+// point in the view.
+func (d DiffView) movedLineAt(x int, y int) (RenderedLineMove, bool) {
+	hit, ok := d.lineHitAt(x, y)
+	if !ok || !hit.InGutter {
+		return RenderedLineMove{}, false
+	}
+	move := hit.Line.Move
+	if hit.SideBySide {
+		if hit.Cell == nil {
+			return RenderedLineMove{}, false
+		}
+		move = hit.Cell.Move
+	}
+	if move == nil {
+		return RenderedLineMove{}, false
+	}
+	return *move, true
+}
+
+func (d DiffView) lineHitAt(x int, y int) (diffLineHit, bool) {
+	contentRow := y
+	if d.VerticalScroll == nil {
+		contentRow = d.State.ScrollY.Peek() + y
+	}
+	viewportWidth := d.State.ViewportWidth()
+
+	if d.LayoutMode == DiffLayoutSideBySide {
+		sideBySide := d.currentSideBySide()
+		if sideBySide == nil || viewportWidth <= 0 {
+			return diffLineHit{}, false
+		}
+		panes := sideBySidePaneLayout(viewportWidth, sideBySide, d.HideChangeSigns, d.sideBySideSplitRatio())
+		var row SideBySideRenderedRow
+		if d.HardWrap {
+			var ok bool
+			if row, _, ok = wrappedSideRowAtRow(sideBySide.Rows, panes, viewportWidth, contentRow); !ok {
+				return diffLineHit{}, false
+			}
+		} else {
+			if contentRow < 0 || contentRow >= len(sideBySide.Rows) {
+				return diffLineHit{}, false
+			}
+			row = sideBySide.Rows[contentRow]
+		}
+		if x < panes.DividerX {
+			inGutter := x >= panes.LeftPaneX && x < panes.LeftPaneX+panes.LeftGutterWidth
+			return diffLineHit{Cell: row.Left, SideBySide: true, IsLeft: true, InGutter: inGutter}, true
+		}
+		inGutter := x >= panes.RightPaneX && x < panes.RightPaneX+panes.RightGutterWidth
+		return diffLineHit{Cell: row.Right, SideBySide: true, InGutter: inGutter}, true
+	}
+
+	rendered := d.currentRendered()
+	if rendered == nil {
+		return diffLineHit{}, false
+	}
+	gutterWidth := renderedGutterWidth(rendered, d.HideChangeSigns)
+	var line RenderedDiffLine
+	if d.HardWrap && viewportWidth > 0 {
+		wrapWidth := max(1, viewportWidth-gutterWidth)
+		var ok bool
+		if line, _, ok = wrappedLineAtRow(rendered.Lines, wrapWidth, contentRow); !ok {
+			return diffLineHit{}, false
+		}
+	} else {
+		if contentRow < 0 || contentRow >= len(rendered.Lines) {
+			return diffLineHit{}, false
+		}
+		line = rendered.Lines[contentRow]
+	}
+	return diffLineHit{Line: line, InGutter: x < gutterWidth}, true
 }
 
 func (d DiffView) OnMouseMove(event t.MouseEvent) {
@@ -582,11 +710,11 @@ func (d DiffView) Render(ctx *t.RenderContext) {
 			contentScrollX = horizontalScrollXForLine(line.Kind, contentScrollX)
 		}
 
-		if lineStyle, ok := d.Palette.LineStyleForKind(line.Kind); ok && lineStyle.BackgroundColor != nil && lineStyle.BackgroundColor.IsSet() {
+		if lineStyle, ok := d.Palette.LineStyleForLine(line.Kind, line.Move); ok && lineStyle.BackgroundColor != nil && lineStyle.BackgroundColor.IsSet() {
 			bg := lineStyle.BackgroundColor.ColorAt(ctx.Width, 1, 0, 0)
 			ctx.FillRect(0, row, ctx.Width, 1, bg)
 		}
-		if gutterStyle, ok := d.Palette.GutterStyleForKind(line.Kind); ok && gutterStyle.BackgroundColor != nil && gutterStyle.BackgroundColor.IsSet() {
+		if gutterStyle, ok := d.Palette.GutterStyleForLine(line.Kind, line.Move); ok && gutterStyle.BackgroundColor != nil && gutterStyle.BackgroundColor.IsSet() {
 			gutterBg := gutterStyle.BackgroundColor.ColorAt(gutterWidth, 1, 0, 0)
 			gutterCols := gutterWidth
 			if gutterCols > ctx.Width {
@@ -604,9 +732,34 @@ func (d DiffView) Render(ctx *t.RenderContext) {
 		}
 		d.renderGutterLine(ctx, rendered, row, gutterLine)
 		d.renderContentLine(ctx, row, gutterWidth, line, contentScrollX)
+		if unifiedLineSelected(d.SelectedLines, line) {
+			d.highlightSelectedLines(ctx, 0, row, ctx.Width)
+		}
+		d.veilSeenHunkRow(ctx, row, line.Hunk)
 	}
 }
 
+// highlightSelectedLines tints cells on a row selected for a review comment.
+func (d DiffView) highlightSelectedLines(ctx *t.RenderContext, x int, row int, width int) {
+	selection, ok := d.Palette.LineSelection()
+	if !ok || width <= 0 {
+		return
+	}
+	ctx.DrawBackdrop(x, row, width, 1, selection)
+}
+
+// veilSeenHunkRow dims a row belonging to a hunk that has been marked seen.
+func (d DiffView) veilSeenHunkRow(ctx *t.RenderContext, row int, hunk int) {
+	if hunk <= 0 || !d.SeenHunks[hunk] {
+		return
+	}
+	veil, ok := d.Palette.SeenHunkVeil()
+	if !ok {
+		return
+	}
+	ctx.DrawBackdrop(0, row, ctx.Width, 1, veil)
+}
+
 func (d DiffView) renderSideBySide(ctx *t.RenderContext, sideBySide *SideBySideRenderedFile, visibleStart int, visibleEnd int, scrollY int, scrollX int) {
 	if sideBySide == nil {
 		return
@@ -634,10 +787,16 @@ func (d DiffView) renderSideBySide(ctx *t.RenderContext, sideBySide *SideBySideR
 
 		if line.Shared != nil {
 			d.renderSideSharedRow(ctx, row, *line.Shared, wrapRow, scrollX)
-			continue
+		} else {
+			d.renderSidePairedRow(ctx, row, panes, sideBySide, line, wrapRow, scrollX)
+			if side, number, ok := sideCellLineTarget(line.Left, true); ok && d.SelectedLines.contains(side, number) {
+				d.highlightSelectedLines(ctx, panes.LeftPaneX, row, panes.LeftPaneWidth)
+			}
+			if side, number, ok := sideCellLineTarget(line.Right, false); ok && d.SelectedLines.contains(side, number) {
+				d.highlightSelectedLines(ctx, panes.RightPaneX, row, panes.RightPaneWidth)
+			}
 		}
-
-		d.renderSidePairedRow(ctx, row, panes, sideBySide, line, wrapRow, scrollX)
+		d.veilSeenHunkRow(ctx, row, sideRowHunk(line))
 	}
 
 	if d.State != nil && d.State.SideDividerOverlayVisible() {
@@ -848,7 +1007,7 @@ func (d DiffView) sideDividerStyle(line SideBySideRenderedRow) (t.Style, bool) {
 	fg = fg.WithAlpha(fg.Alpha() * alphaFactor)
 	style := t.Style{ForegroundColor: fg}
 
-	if gutterStyle, ok := d.Palette.GutterStyleForKind(kind); ok && gutterStyle.BackgroundColor != nil && gutterStyle.BackgroundColor.IsSet() {
+	if gutterStyle, ok := d.Palette.GutterStyleForLine(kind, line.Right.Move); ok && gutterStyle.BackgroundColor != nil && gutterStyle.BackgroundColor.IsSet() {
 		style.BackgroundColor = gutterStyle.BackgroundColor
 	}
 	return style, true
@@ -870,7 +1029,7 @@ func (d DiffView) renderSideCell(ctx *t.RenderContext, row int, paneX int, paneW
 	}
 
 	if cell != nil {
-		if lineStyle, ok := d.Palette.LineStyleForKind(cell.Kind); ok && lineStyle.BackgroundColor != nil && lineStyle.BackgroundColor.IsSet() {
+		if lineStyle, ok := d.Palette.LineStyleForLine(cell.Kind, cell.Move); ok && lineStyle.BackgroundColor != nil && lineStyle.BackgroundColor.IsSet() {
 			bg := lineStyle.BackgroundColor.ColorAt(paneWidth, 1, 0, 0)
 			ctx.FillRect(paneX, row, paneWidth, 1, bg)
 		}
@@ -881,7 +1040,7 @@ func (d DiffView) renderSideCell(ctx *t.RenderContext, row int, paneX int, paneW
 		gutterCols = paneWidth
 	}
 	if gutterCols > 0 && cell != nil {
-		if gutterStyle, ok := d.Palette.GutterStyleForKind(cell.Kind); ok && gutterStyle.BackgroundColor != nil && gutterStyle.BackgroundColor.IsSet() {
+		if gutterStyle, ok := d.Palette.GutterStyleForLine(cell.Kind, cell.Move); ok && gutterStyle.BackgroundColor != nil && gutterStyle.BackgroundColor.IsSet() {
 			gutterBg := gutterStyle.BackgroundColor.ColorAt(gutterCols, 1, 0, 0)
 			ctx.FillRect(paneX, row, gutterCols, 1, gutterBg)
 		}
@@ -958,9 +1117,9 @@ func (d DiffView) renderSideEmptyCellHatch(ctx *t.RenderContext, row int, startX
 
 func sideLineNumberRole(kind RenderedLineKind, isLeft bool) TokenRole {
 	switch kind {
-	case RenderedLineAdd:
+	case RenderedLineAdd, RenderedLineMovedAdd, RenderedLineWordDiff:
 		return TokenRoleLineNumberAdd
-	case RenderedLineRemove:
+	case RenderedLineRemove, RenderedLineMovedRemove:
 		return TokenRoleLineNumberRemove
 	default:
 		if isLeft {
@@ -1023,9 +1182,9 @@ func lineNumberRolesForLine(kind RenderedLineKind) (oldRole TokenRole, newRole T
 	oldRole = TokenRoleOldLineNumber
 	newRole = TokenRoleNewLineNumber
 	switch kind {
-	case RenderedLineAdd:
+	case RenderedLineAdd, RenderedLineMovedAdd, RenderedLineWordDiff:
 		return TokenRoleLineNumberAdd, TokenRoleLineNumberAdd
-	case RenderedLineRemove:
+	case RenderedLineRemove, RenderedLineMovedRemove:
 		return TokenRoleLineNumberRemove, TokenRoleLineNumberRemove
 	default:
 		return oldRole, newRole
@@ -1033,7 +1192,7 @@ func lineNumberRolesForLine(kind RenderedLineKind) (oldRole TokenRole, newRole T
 }
 
 func horizontalScrollXForLine(kind RenderedLineKind, scrollX int) int {
-	if kind == RenderedLineHunkHeader {
+	if kind == RenderedLineHunkHeader || kind == RenderedLineComment {
 		return 0
 	}
 	return scrollX
@@ -1042,7 +1201,7 @@ func horizontalScrollXForLine(kind RenderedLineKind, scrollX int) int {
 func displayLinePrefix(line RenderedDiffLine, hideChangeSigns bool) string {
 	if hideChangeSigns {
 		switch line.Kind {
-		case RenderedLineAdd, RenderedLineRemove:
+		case RenderedLineAdd, RenderedLineRemove, RenderedLineMovedAdd, RenderedLineMovedRemove, RenderedLineWordDiff:
 			return " "
 		}
 	}
@@ -1116,10 +1275,13 @@ func (d DiffView) renderSegments(ctx *t.RenderContext, row int, startX int, visi
 func (d DiffView) styleForSegment(segment RenderedSegment) t.Style {
 	style := d.styleForRole(segment.Role)
 	overlay, ok := d.Palette.IntralineOverlayStyle(segment.Intraline, d.IntralineStyle)
-	if !ok {
+	// Suspicious characters keep their warning style over intraline marks.
+	if !ok || segment.Role == TokenRoleDiffSuspicious {
 		return style
 	}
-	return applyIntralineOverlay(style, overlay)
+	style = applyIntralineOverlay(style, overlay)
+	// Palette variants such as high-contrast raise the readability floor.
+	return applyIntralineReadabilityFilter(style, overlay, d.Palette.readabilityFloor)
 }
 
 func (d DiffView) drawText(ctx *t.RenderContext, x int, y int, value string, role TokenRole) {
@@ -1171,7 +1333,7 @@ func applyIntralineOverlay(base t.Style, overlay t.SpanStyle) t.Style {
 	if overlay.Strikethrough {
 		base.Strikethrough = true
 	}
-	base = applyIntralineReadabilityFilter(base, overlay)
+	base = applyIntralineReadabilityFilter(base, overlay, intralineForegroundReadabilityFloor)
 	return base
 }
 
@@ -1180,7 +1342,7 @@ const (
 	intralineForegroundReadabilityIterations = 10
 )
 
-func applyIntralineReadabilityFilter(style t.Style, overlay t.SpanStyle) t.Style {
+func applyIntralineReadabilityFilter(style t.Style, overlay t.SpanStyle, minContrast float64) t.Style {
 	if !overlay.Background.IsSet() {
 		return style
 	}
@@ -1193,7 +1355,7 @@ func applyIntralineReadabilityFilter(style t.Style, overlay t.SpanStyle) t.Style
 
 	fg := style.ForegroundColor.ColorAt(1, 1, 0, 0)
 	bg := style.BackgroundColor.ColorAt(1, 1, 0, 0)
-	style.ForegroundColor = blendForegroundTowardReadability(fg, bg, intralineForegroundReadabilityFloor)
+	style.ForegroundColor = blendForegroundTowardReadability(fg, bg, minContrast)
 	return style
 }
 
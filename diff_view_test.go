@@ -20,6 +20,10 @@ func TestLineNumberRolesForLine(tt *testing.T) {
 	oldRole, newRole = lineNumberRolesForLine(RenderedLineRemove)
 	require.Equal(tt, TokenRoleLineNumberRemove, oldRole)
 	require.Equal(tt, TokenRoleLineNumberRemove, newRole)
+
+	oldRole, newRole = lineNumberRolesForLine(RenderedLineWordDiff)
+	require.Equal(tt, TokenRoleLineNumberAdd, oldRole)
+	require.Equal(tt, TokenRoleLineNumberAdd, newRole)
 }
 
 func TestHorizontalScrollXForLine(tt *testing.T) {
@@ -222,7 +226,7 @@ func TestSideDividerStyle_UsesHatchStyleWhenRightIsEmpty(tt *testing.T) {
 	require.True(tt, ok)
 
 	view := DiffView{
-		Palette: NewThemePalette(theme),
+		Palette: newThemePalette(theme, PaletteVariantDefault, paletteOptions{}),
 	}
 	line := SideBySideRenderedRow{
 		Left: &RenderedSideCell{Kind: RenderedLineRemove},
@@ -251,7 +255,7 @@ func TestSideDividerStyle_DraggingEmphasizesDividerColumn(tt *testing.T) {
 
 	view := DiffView{
 		State:   state,
-		Palette: NewThemePalette(theme),
+		Palette: newThemePalette(theme, PaletteVariantDefault, paletteOptions{}),
 	}
 	line := SideBySideRenderedRow{
 		Right: &RenderedSideCell{Kind: RenderedLineAdd},
@@ -381,6 +385,52 @@ func TestDiffView_DraggingIsNoopOutsideSideBySideMode(tt *testing.T) {
 	require.Equal(tt, initialSplit, state.SideBySideSplitRatio())
 }
 
+func TestDiffView_OnMouseDownReportsClickedLineSide(tt *testing.T) {
+	view, state, _, sideBySide := newSideBySideDragTestView(80)
+	panes := sideBySidePaneLayout(80, sideBySide, view.HideChangeSigns, state.SideBySideSplitRatio())
+	type click struct {
+		side   ReviewCommentSide
+		line   int
+		extend bool
+	}
+	var clicks []click
+	view.OnLineClick = func(side ReviewCommentSide, line int, extend bool) {
+		clicks = append(clicks, click{side, line, extend})
+	}
+
+	view.OnMouseDown(t.MouseEvent{LocalX: panes.DividerX - 1, Button: uv.MouseLeft})
+	view.OnMouseDown(t.MouseEvent{LocalX: panes.DividerX + 2, Button: uv.MouseLeft, Mod: uv.ModShift})
+	view.OnMouseDown(t.MouseEvent{LocalX: panes.DividerX, Button: uv.MouseLeft})
+	view.OnMouseDown(t.MouseEvent{LocalX: 1, LocalY: 5, Button: uv.MouseLeft})
+	require.Equal(tt, []click{
+		{ReviewCommentSideOld, 1, false},
+		{ReviewCommentSideNew, 1, true},
+	}, clicks)
+}
+
+func TestDiffView_OnMouseDownOnMovedLineGutterJumps(tt *testing.T) {
+	view, state, _, sideBySide := newSideBySideDragTestView(80)
+	panes := sideBySidePaneLayout(80, sideBySide, view.HideChangeSigns, state.SideBySideSplitRatio())
+	move := RenderedLineMove{Path: "other.go", Side: ReviewCommentSideNew, Line: 7}
+	sideBySide.Rows[0].Left.Kind = RenderedLineMovedRemove
+	sideBySide.Rows[0].Left.Move = &move
+
+	var jumps []RenderedLineMove
+	var clicks []int
+	view.OnMovedLineClick = func(move RenderedLineMove) {
+		jumps = append(jumps, move)
+	}
+	view.OnLineClick = func(side ReviewCommentSide, line int, extend bool) {
+		clicks = append(clicks, line)
+	}
+
+	view.OnMouseDown(t.MouseEvent{LocalX: panes.LeftPaneX, Button: uv.MouseLeft})
+	view.OnMouseDown(t.MouseEvent{LocalX: panes.LeftPaneX + panes.LeftGutterWidth, Button: uv.MouseLeft})
+	view.OnMouseDown(t.MouseEvent{LocalX: panes.RightPaneX, Button: uv.MouseLeft})
+	require.Equal(tt, []RenderedLineMove{move}, jumps)
+	require.Equal(tt, []int{1, 1}, clicks)
+}
+
 func newSideBySideDragTestView(width int) (DiffView, *DiffViewState, *RenderedFile, *SideBySideRenderedFile) {
 	rendered := buildTestRenderedFile(20, 120)
 	sideBySide := &SideBySideRenderedFile{
@@ -464,7 +514,7 @@ func TestStyleForSegment_AppliesBackgroundIntralineOverlay(tt *testing.T) {
 	require.True(tt, ok)
 
 	view := DiffView{
-		Palette:        NewThemePalette(theme),
+		Palette:        newThemePalette(theme, PaletteVariantDefault, paletteOptions{}),
 		IntralineStyle: IntralineStyleModeBackground,
 	}
 	segment := RenderedSegment{Text: "x", Role: TokenRoleSyntaxString, Intraline: IntralineMarkAdd}
@@ -495,7 +545,7 @@ func TestStyleForSegment_AppliesUnderlineIntralineOverlayWithoutChangingForegrou
 	require.True(tt, ok)
 
 	view := DiffView{
-		Palette:        NewThemePalette(theme),
+		Palette:        newThemePalette(theme, PaletteVariantDefault, paletteOptions{}),
 		IntralineStyle: IntralineStyleModeUnderline,
 	}
 	segment := RenderedSegment{Text: "x", Role: TokenRoleSyntaxKeyword, Intraline: IntralineMarkRemove}
@@ -519,7 +569,7 @@ func TestStyleForSegment_LeavesBaseStyleWhenIntralineStyleIsOff(tt *testing.T) {
 	require.True(tt, ok)
 
 	view := DiffView{
-		Palette:        NewThemePalette(theme),
+		Palette:        newThemePalette(theme, PaletteVariantDefault, paletteOptions{}),
 		IntralineStyle: IntralineStyleModeOff,
 	}
 	segment := RenderedSegment{Text: "x", Role: TokenRoleSyntaxKeyword, Intraline: IntralineMarkRemove}
@@ -534,7 +584,7 @@ func TestStyleForSegment_LeavesBaseStyleWhenNoIntralineMark(tt *testing.T) {
 	require.True(tt, ok)
 
 	view := DiffView{
-		Palette:        NewThemePalette(theme),
+		Palette:        newThemePalette(theme, PaletteVariantDefault, paletteOptions{}),
 		IntralineStyle: IntralineStyleModeUnderline,
 	}
 	segment := RenderedSegment{Text: "x", Role: TokenRoleSyntaxPlain, Intraline: IntralineMarkNone}
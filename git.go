@@ -24,13 +24,49 @@ type ManualRefreshCapable interface {
 	ManualRefreshEnabled() bool
 }
 
+// IgnoreWhitespaceCapable optionally controls whether whitespace-insensitive diffs are available.
+type IgnoreWhitespaceCapable interface {
+	IgnoreWhitespaceEnabled() bool
+}
+
+// FileHeadersCapable optionally shows the header lines of files without
+// hunks, for diffs whose headers say how a file changed.
+type FileHeadersCapable interface {
+	FileHeadersEnabled() bool
+}
+
+func fileHeadersEnabled(provider DiffProvider) bool {
+	capable, ok := provider.(FileHeadersCapable)
+	return ok && capable.FileHeadersEnabled()
+}
+
+// EmptyStateDescriber optionally customizes the message shown when there is nothing to diff.
+// keyPrompt phrases a hint for a key action using the user's bindings, such as
+// "press r to refresh".
+type EmptyStateDescriber interface {
+	EmptyMessageParts(ignoreWhitespace bool, keyPrompt func(action string, purpose string) string) (heading string, details string)
+}
+
+// SeenScopeDescriber optionally names what is being compared so persisted seen
+// marks from different comparisons in the same repository stay apart.
+type SeenScopeDescriber interface {
+	SeenScope() string
+}
+
+// PathAttributesProvider optionally looks up the .gitattributes of diffed
+// paths, keyed by path.
+type PathAttributesProvider interface {
+	PathAttributes(paths []string) (map[string]pathAttributes, error)
+}
+
 // GitDiffProvider loads diff data by shelling out to git.
 type GitDiffProvider struct {
-	WorkDir string
+	WorkDir  string
+	Textconv textconvOptions
 }
 
 func (p GitDiffProvider) LoadDiff(staged bool, ignoreWhitespace bool) (string, error) {
-	args := buildDiffArgs(staged, ignoreWhitespace)
+	args := buildDiffArgs(staged, ignoreWhitespace, p.Textconv)
 	stdout, stderr, err := runGit(p.WorkDir, args)
 	if err != nil {
 		return "", fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr))
@@ -54,9 +90,50 @@ func (p GitDiffProvider) CurrentBranch() (string, error) {
 	return strings.TrimSpace(stdout), nil
 }
 
+// PathAttributes reads linguist-generated and diff for paths, which are
+// relative to the repository root.
+func (p GitDiffProvider) PathAttributes(paths []string) (map[string]pathAttributes, error) {
+	root, err := p.RepoRoot()
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"check-attr", "-z", "--stdin", "linguist-generated", "diff"}
+	stdout, stderr, err := runGitWithInput(root, args, strings.Join(paths, "\x00")+"\x00")
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr))
+	}
+	return parseCheckAttrOutput(stdout), nil
+}
+
+// parseCheckAttrOutput reads the NUL-separated path, attribute and value
+// triples printed by `git check-attr -z`.
+func parseCheckAttrOutput(output string) map[string]pathAttributes {
+	fields := strings.Split(output, "\x00")
+	attributes := map[string]pathAttributes{}
+	for idx := 0; idx+2 < len(fields); idx += 3 {
+		path, name, value := fields[idx], fields[idx+1], fields[idx+2]
+		attrs := attributes[path]
+		switch {
+		case name == "linguist-generated" && (value == "set" || value == "true"):
+			attrs.Generated = true
+		case name == "diff" && value == "unset":
+			attrs.NoDiff = true
+		}
+		attributes[path] = attrs
+	}
+	return attributes
+}
+
 func runGit(workDir string, args []string) (stdout string, stderr string, err error) {
+	return runGitWithInput(workDir, args, "")
+}
+
+func runGitWithInput(workDir string, args []string, input string) (stdout string, stderr string, err error) {
 	cmd := exec.Command("git", args...)
 	cmd.Dir = workDir
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
 
 	var outBuf bytes.Buffer
 	var errBuf bytes.Buffer
@@ -67,15 +144,17 @@ func runGit(workDir string, args []string) (stdout string, stderr string, err er
 	return outBuf.String(), errBuf.String(), err
 }
 
-func buildDiffArgs(staged bool, ignoreWhitespace bool) []string {
-	args := []string{
-		"-c", "color.ui=never",
+func buildDiffArgs(staged bool, ignoreWhitespace bool, textconv textconvOptions) []string {
+	args := []string{"-c", "color.ui=never"}
+	args = append(args, textconv.gitConfigArgs()...)
+	args = append(args,
 		"diff",
 		"--no-color",
 		"--no-ext-diff",
+		textconv.diffFlag(),
 		"--patch",
 		"--find-renames",
-	}
+	)
 	if ignoreWhitespace {
 		args = append(args, "--ignore-all-space")
 	}
@@ -1,30 +1,36 @@
 package main
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
 func TestBuildDiffArgsUnstaged(t *testing.T) {
-	args := buildDiffArgs(false, false)
+	args := buildDiffArgs(false, false, textconvOptions{})
 	require.Equal(t, []string{
 		"-c", "color.ui=never",
 		"diff",
 		"--no-color",
 		"--no-ext-diff",
+		"--no-textconv",
 		"--patch",
 		"--find-renames",
 	}, args)
 }
 
 func TestBuildDiffArgsStaged(t *testing.T) {
-	args := buildDiffArgs(true, false)
+	args := buildDiffArgs(true, false, textconvOptions{})
 	require.Equal(t, []string{
 		"-c", "color.ui=never",
 		"diff",
 		"--no-color",
 		"--no-ext-diff",
+		"--no-textconv",
 		"--patch",
 		"--find-renames",
 		"--staged",
@@ -32,12 +38,13 @@ func TestBuildDiffArgsStaged(t *testing.T) {
 }
 
 func TestBuildDiffArgsUnstagedIgnoreWhitespace(t *testing.T) {
-	args := buildDiffArgs(false, true)
+	args := buildDiffArgs(false, true, textconvOptions{})
 	require.Equal(t, []string{
 		"-c", "color.ui=never",
 		"diff",
 		"--no-color",
 		"--no-ext-diff",
+		"--no-textconv",
 		"--patch",
 		"--find-renames",
 		"--ignore-all-space",
@@ -45,15 +52,119 @@ func TestBuildDiffArgsUnstagedIgnoreWhitespace(t *testing.T) {
 }
 
 func TestBuildDiffArgsStagedIgnoreWhitespace(t *testing.T) {
-	args := buildDiffArgs(true, true)
+	args := buildDiffArgs(true, true, textconvOptions{})
 	require.Equal(t, []string{
 		"-c", "color.ui=never",
 		"diff",
 		"--no-color",
 		"--no-ext-diff",
+		"--no-textconv",
 		"--patch",
 		"--find-renames",
 		"--ignore-all-space",
 		"--staged",
 	}, args)
 }
+
+func TestParseCheckAttrOutput(t *testing.T) {
+	output := strings.Join([]string{
+		"dist/app.js", "linguist-generated", "set",
+		"dist/app.js", "diff", "unspecified",
+		"assets/logo.bin", "linguist-generated", "unspecified",
+		"assets/logo.bin", "diff", "unset",
+		"main.go", "linguist-generated", "false",
+		"main.go", "diff", "set",
+		"",
+	}, "\x00")
+
+	require.Equal(t, map[string]pathAttributes{
+		"dist/app.js":     {Generated: true},
+		"assets/logo.bin": {NoDiff: true},
+		"main.go":         {},
+	}, parseCheckAttrOutput(output))
+}
+
+func TestGitDiffProvider_PathAttributesReadsGitattributes(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte("dist/** linguist-generated\n*.bin -diff\n"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+
+	attributes, err := GitDiffProvider{WorkDir: filepath.Join(dir, "sub")}.PathAttributes([]string{"dist/app.js", "logo.bin", "main.go"})
+	require.NoError(t, err)
+	require.Equal(t, pathAttributes{Generated: true}, attributes["dist/app.js"])
+	require.Equal(t, pathAttributes{NoDiff: true}, attributes["logo.bin"])
+	require.Equal(t, pathAttributes{}, attributes["main.go"])
+}
+
+func TestBuildDiffArgsTextconv(t *testing.T) {
+	textconv := textconvOptions{
+		Enabled: true,
+		Drivers: map[string]diffDriverConfig{
+			"pdf":    {Textconv: "pdftotext -layout - -"},
+			"sqlite": {Textconv: "sqlite3 -readonly", Xfuncname: "^CREATE TABLE .*$"},
+		},
+	}
+
+	require.Equal(t, []string{
+		"-c", "color.ui=never",
+		"-c", "diff.pdf.textconv=pdftotext -layout - -",
+		"-c", "diff.sqlite.textconv=sqlite3 -readonly",
+		"-c", "diff.sqlite.xfuncname=^CREATE TABLE .*$",
+		"diff",
+		"--no-color",
+		"--no-ext-diff",
+		"--textconv",
+		"--patch",
+		"--find-renames",
+	}, buildDiffArgs(false, false, textconv))
+}
+
+func TestGitDiffProvider_TextconvUsesConfiguredDrivers(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	runTestGit := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	sections := "# intro\n1\n2\n3\n4\n5\n# usage\n6\n7\n8\n9\n10\n"
+
+	runTestGit("init", "-q")
+	writeTestFile(t, filepath.Join(dir, ".gitattributes"), "*.dat diff=od\n*.md diff=sections\n")
+	writeTestFile(t, filepath.Join(dir, "data.dat"), "one\x00two\n")
+	writeTestFile(t, filepath.Join(dir, "notes.md"), sections)
+	runTestGit("add", ".")
+	runTestGit("commit", "-q", "-m", "base")
+	writeTestFile(t, filepath.Join(dir, "data.dat"), "one\x00three\n")
+	writeTestFile(t, filepath.Join(dir, "notes.md"), strings.Replace(sections, "10\n", "ten\n", 1))
+
+	provider := GitDiffProvider{WorkDir: dir}
+	raw, err := provider.LoadDiff(false, false)
+	require.NoError(t, err)
+	require.Contains(t, raw, "Binary files a/data.dat and b/data.dat differ")
+	require.NotContains(t, raw, "@@ usage")
+
+	provider.Textconv = textconvOptions{
+		Enabled: true,
+		Drivers: map[string]diffDriverConfig{
+			"od":       {Textconv: "od -An -c"},
+			"sections": {Xfuncname: "^# (.*)$"},
+		},
+	}
+	raw, err = provider.LoadDiff(false, false)
+	require.NoError(t, err)
+	require.NotContains(t, raw, "Binary files")
+	require.Contains(t, raw, "t   h   r   e   e")
+	require.Contains(t, raw, "@@ usage\n")
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/colorprofile"
+	t "github.com/darrenburns/terma"
+)
+
+const defaultDiffHTMLExportName = "dv-diff.html"
+
+// diffHTMLExport is everything needed to write a diff as a standalone HTML
+// page: the files of each section, how to lay them out, and the theme to
+// take colors from.
+type diffHTMLExport struct {
+	Title           string
+	Sections        []diffHTMLSection
+	LayoutMode      DiffLayoutMode
+	HideChangeSigns bool
+	IntralineStyle  IntralineStyleMode
+	PaletteVariant  PaletteVariant
+	PaletteOptions  paletteOptions
+	Theme           t.ThemeData
+}
+
+type diffHTMLSection struct {
+	Section DiffSection
+	Roots   []t.TreeNode[DiffTreeNodeData]
+	// Files are listed in tree order.
+	Files []diffHTMLFile
+}
+
+// diffHTMLFile is one file of an export along with its review state.
+type diffHTMLFile struct {
+	File       *DiffFile
+	Rendered   *RenderedFile
+	SideBySide *SideBySideRenderedFile
+	// SeenHunks holds the 1-based numbers of hunks marked as seen.
+	SeenHunks map[int]bool
+}
+
+func (f diffHTMLFile) seenLabel() string {
+	total := len(diffFileHunkHashes(f.File))
+	seen := 0
+	for hunk := 1; hunk <= total; hunk++ {
+		if f.SeenHunks[hunk] {
+			seen++
+		}
+	}
+	switch {
+	case seen == 0:
+		return ""
+	case seen == total:
+		return "Seen"
+	default:
+		return fmt.Sprintf("%d/%d hunks seen", seen, total)
+	}
+}
+
+func diffHTMLFileAnchor(section DiffSection, index int) string {
+	return fmt.Sprintf("%s-file-%d", section, index+1)
+}
+
+// diffHTMLStyles turns the styles the diff view would draw with into CSS
+// classes, emitting rules only for the classes a page actually uses.
+type diffHTMLStyles struct {
+	view  DiffView
+	rules map[string]string
+}
+
+func newDiffHTMLStyles(export diffHTMLExport) *diffHTMLStyles {
+	// Browsers show every colour, whatever the terminal supports.
+	options := export.PaletteOptions
+	options.Profile = colorprofile.TrueColor
+	return &diffHTMLStyles{
+		view: DiffView{
+			Palette:         newThemePalette(export.Theme, export.PaletteVariant, options),
+			IntralineStyle:  export.IntralineStyle,
+			HideChangeSigns: export.HideChangeSigns,
+		},
+		rules: map[string]string{},
+	}
+}
+
+func (s *diffHTMLStyles) segmentClass(segment RenderedSegment) string {
+	name := "t" + strconv.Itoa(int(segment.Role))
+	switch segment.Intraline {
+	case IntralineMarkAdd:
+		name += "a"
+	case IntralineMarkRemove:
+		name += "r"
+	}
+	if _, ok := s.rules[name]; !ok {
+		s.rules[name] = cssForStyle(s.view.styleForSegment(segment))
+	}
+	return name
+}
+
+func (s *diffHTMLStyles) roleClass(role TokenRole) string {
+	return s.segmentClass(RenderedSegment{Role: role})
+}
+
+func (s *diffHTMLStyles) lineClass(kind RenderedLineKind, move *RenderedLineMove) string {
+	name := "k" + strconv.Itoa(int(kind)) + zebraClassSuffix(move)
+	if _, ok := s.rules[name]; !ok {
+		style, _ := s.view.Palette.LineStyleForLine(kind, move)
+		s.rules[name] = cssForStyle(style)
+	}
+	return name
+}
+
+func (s *diffHTMLStyles) gutterClass(kind RenderedLineKind, move *RenderedLineMove) string {
+	name := "g" + strconv.Itoa(int(kind)) + zebraClassSuffix(move)
+	if _, ok := s.rules[name]; !ok {
+		style, ok := s.view.Palette.GutterStyleForLine(kind, move)
+		if !ok {
+			style, _ = s.view.Palette.LineStyleForLine(kind, move)
+		}
+		s.rules[name] = cssForStyle(style)
+	}
+	return name
+}
+
+// zebraClassSuffix tells apart the classes of alternate moved blocks.
+func zebraClassSuffix(move *RenderedLineMove) string {
+	if move != nil && move.Block%2 == 1 {
+		return "z"
+	}
+	return ""
+}
+
+func (s *diffHTMLStyles) css() string {
+	names := make([]string, 0, len(s.rules))
+	for name := range s.rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var builder strings.Builder
+	for _, name := range names {
+		if s.rules[name] == "" {
+			continue
+		}
+		fmt.Fprintf(&builder, ".%s{%s}\n", name, s.rules[name])
+	}
+	return builder.String()
+}
+
+func cssForStyle(style t.Style) string {
+	decls := []string{}
+	if style.ForegroundColor != nil && style.ForegroundColor.IsSet() {
+		decls = append(decls, "color:"+cssColor(style.ForegroundColor.ColorAt(1, 1, 0, 0)))
+	}
+	if style.BackgroundColor != nil && style.BackgroundColor.IsSet() {
+		decls = append(decls, "background-color:"+cssColor(style.BackgroundColor.ColorAt(1, 1, 0, 0)))
+	}
+	if style.Bold {
+		decls = append(decls, "font-weight:bold")
+	}
+	if style.Italic {
+		decls = append(decls, "font-style:italic")
+	}
+	if style.Faint {
+		decls = append(decls, "opacity:0.7")
+	}
+	decorations := []string{}
+	if style.Underline != t.UnderlineNone {
+		decorations = append(decorations, "underline")
+	}
+	if style.Strikethrough {
+		decorations = append(decorations, "line-through")
+	}
+	if len(decorations) > 0 {
+		decls = append(decls, "text-decoration-line:"+strings.Join(decorations, " "))
+	}
+	if style.UnderlineColor.IsSet() {
+		decls = append(decls, "text-decoration-color:"+cssColor(style.UnderlineColor))
+	}
+	return strings.Join(decls, ";")
+}
+
+func cssColor(color t.Color) string {
+	if !color.IsSet() {
+		return "inherit"
+	}
+	if color.IsOpaque() {
+		return color.Hex()
+	}
+	r, g, b := color.RGB()
+	return fmt.Sprintf("rgba(%d,%d,%d,%.3f)", r, g, b, color.Alpha())
+}
+
+// formatDiffHTML renders export as a single HTML document with inline CSS
+// and no external assets.
+func formatDiffHTML(export diffHTMLExport) string {
+	styles := newDiffHTMLStyles(export)
+
+	var body strings.Builder
+	body.WriteString("<nav class=\"tree\">\n")
+	fmt.Fprintf(&body, "<h1>%s</h1>\n", html.EscapeString(export.Title))
+	for _, section := range export.Sections {
+		writeDiffHTMLTree(&body, section, styles)
+	}
+	body.WriteString("</nav>\n<main>\n")
+	for _, section := range export.Sections {
+		for idx, file := range section.Files {
+			writeDiffHTMLFile(&body, export, section.Section, idx, file, styles)
+		}
+	}
+	body.WriteString("</main>\n")
+
+	theme := export.Theme
+	seenOpacity := 1.0
+	if veil, ok := styles.view.Palette.SeenHunkVeil(); ok {
+		seenOpacity = 1 - veil.Alpha()
+	}
+
+	var page strings.Builder
+	page.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n")
+	page.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(&page, "<title>%s</title>\n<style>\n", html.EscapeString(export.Title))
+	fmt.Fprintf(&page, "body{margin:0;display:flex;background:%s;color:%s;font:13px/1.45 ui-monospace,SFMono-Regular,Menlo,Consolas,monospace}\n",
+		cssColor(theme.Background), cssColor(theme.Text))
+	fmt.Fprintf(&page, "nav.tree{position:sticky;top:0;align-self:flex-start;flex:0 0 18rem;max-height:100vh;overflow:auto;padding:0.5rem;box-sizing:border-box;background:%s;border-right:1px solid %s}\n",
+		cssColor(theme.Surface), cssColor(theme.Border))
+	fmt.Fprintf(&page, "nav.tree h1{font-size:1em;margin:0.25rem 0 0.75rem;color:%s}\n", cssColor(theme.PrimaryText))
+	page.WriteString("nav.tree ul{list-style:none;margin:0;padding-left:1rem}\nnav.tree summary{cursor:pointer;font-weight:bold}\n")
+	fmt.Fprintf(&page, "nav.tree a{color:inherit;text-decoration:none}\nnav.tree a:hover{color:%s}\n", cssColor(theme.PrimaryText))
+	fmt.Fprintf(&page, "nav.tree .dir{color:%s}\nnav.tree .seen>a{opacity:%.2f}\n", cssColor(theme.TextMuted), seenOpacity)
+	page.WriteString("main{flex:1;min-width:0;padding:0.5rem}\nsection.file{margin-bottom:1.5rem}\n")
+	page.WriteString("section.file h2{font-size:1em;margin:0;padding:0.25rem 0.5rem;position:sticky;top:0;z-index:1}\n")
+	fmt.Fprintf(&page, ".badge{margin-left:0.75rem;font-weight:normal;color:%s}\n", cssColor(theme.TextMuted))
+	page.WriteString("table.diff{width:100%;border-collapse:collapse;table-layout:fixed}\ntable.diff td{padding:0 0.5ch;vertical-align:top;white-space:pre-wrap;overflow-wrap:anywhere}\n")
+	page.WriteString("table.diff td.n{width:5ch;text-align:right;user-select:none}\ntable.diff td.p{width:1ch;user-select:none}\n")
+	fmt.Fprintf(&page, "table.diff td.split{border-left:1px solid %s}\n", cssColor(theme.Border))
+	if hatch, ok := styles.view.Palette.StyleForRole(TokenRoleDiffHatch); ok {
+		fmt.Fprintf(&page, "table.diff td.hatch{background:repeating-linear-gradient(-45deg,transparent 0 4px,%s 4px 5px)}\n", cssColor(hatch.Foreground))
+	}
+	fmt.Fprintf(&page, "table.diff tr.seen{opacity:%.2f}\n", seenOpacity)
+	page.WriteString(styles.css())
+	page.WriteString("</style>\n</head>\n<body>\n")
+	page.WriteString(body.String())
+	page.WriteString("</body>\n</html>\n")
+	return page.String()
+}
+
+func writeDiffHTMLTree(builder *strings.Builder, section diffHTMLSection, styles *diffHTMLStyles) {
+	anchors := make(map[string]string, len(section.Files))
+	seen := make(map[string]bool, len(section.Files))
+	additions, deletions := 0, 0
+	for idx, file := range section.Files {
+		anchors[file.File.DisplayPath] = diffHTMLFileAnchor(section.Section, idx)
+		seen[file.File.DisplayPath] = file.seenLabel() == "Seen"
+		additions += file.File.Additions
+		deletions += file.File.Deletions
+	}
+	fmt.Fprintf(builder, "<details open><summary>%s%s</summary>\n", html.EscapeString(section.Section.DisplayName()), diffHTMLStats(additions, deletions, styles))
+	writeDiffHTMLTreeNodes(builder, section.Roots, anchors, seen, styles)
+	builder.WriteString("</details>\n")
+}
+
+func writeDiffHTMLTreeNodes(builder *strings.Builder, nodes []t.TreeNode[DiffTreeNodeData], anchors map[string]string, seen map[string]bool, styles *diffHTMLStyles) {
+	if len(nodes) == 0 {
+		return
+	}
+	builder.WriteString("<ul>\n")
+	for _, node := range nodes {
+		data := node.Data
+		if data.IsDir {
+			fmt.Fprintf(builder, "<li><span class=\"dir\">%s/</span>\n", html.EscapeString(data.Name))
+			writeDiffHTMLTreeNodes(builder, node.Children, anchors, seen, styles)
+			builder.WriteString("</li>\n")
+			continue
+		}
+		class := ""
+		if seen[data.Path] {
+			class = " class=\"seen\""
+		}
+		fmt.Fprintf(builder, "<li%s><a href=\"#%s\">%s</a>%s</li>\n",
+			class, anchors[data.Path], html.EscapeString(data.Name), diffHTMLStats(data.Additions, data.Deletions, styles))
+	}
+	builder.WriteString("</ul>\n")
+}
+
+func diffHTMLStats(additions int, deletions int, styles *diffHTMLStyles) string {
+	var builder strings.Builder
+	if additions > 0 {
+		fmt.Fprintf(&builder, " <span class=\"%s\">+%d</span>", styles.roleClass(TokenRoleDiffPrefixAdd), additions)
+	}
+	if deletions > 0 {
+		fmt.Fprintf(&builder, " <span class=\"%s\">-%d</span>", styles.roleClass(TokenRoleDiffPrefixRemove), deletions)
+	}
+	return builder.String()
+}
+
+func writeDiffHTMLFile(builder *strings.Builder, export diffHTMLExport, section DiffSection, index int, file diffHTMLFile, styles *diffHTMLStyles) {
+	fmt.Fprintf(builder, "<section class=\"file\" id=\"%s\">\n", diffHTMLFileAnchor(section, index))
+	fmt.Fprintf(builder, "<h2 class=\"%s\"><span class=\"%s\">%s</span>%s",
+		styles.lineClass(RenderedLineFileHeader, nil),
+		styles.roleClass(TokenRoleDiffFileHeader),
+		html.EscapeString(file.File.DisplayPath),
+		diffHTMLStats(file.File.Additions, file.File.Deletions, styles))
+	if label := file.seenLabel(); label != "" {
+		fmt.Fprintf(builder, "<span class=\"badge\">%s</span>", label)
+	}
+	builder.WriteString("</h2>\n")
+
+	if export.LayoutMode == DiffLayoutSideBySide {
+		builder.WriteString("<table class=\"diff split\">\n")
+		writeDiffHTMLSideBySideRows(builder, file, styles)
+	} else {
+		builder.WriteString("<table class=\"diff unified\">\n")
+		writeDiffHTMLUnifiedRows(builder, file, styles)
+	}
+	builder.WriteString("</table>\n</section>\n")
+}
+
+func writeDiffHTMLUnifiedRows(builder *strings.Builder, file diffHTMLFile, styles *diffHTMLStyles) {
+	if file.Rendered == nil {
+		return
+	}
+	hideSigns := styles.view.HideChangeSigns
+	for _, line := range file.Rendered.Lines {
+		writeDiffHTMLRowOpen(builder, styles.lineClass(line.Kind, line.Move), file.SeenHunks[line.Hunk])
+		gutter := styles.gutterClass(line.Kind, line.Move)
+		oldRole, newRole := lineNumberRolesForLine(line.Kind)
+		writeDiffHTMLCell(builder, "n "+gutter, diffHTMLLineNumber(line.OldLine, oldRole, styles))
+		writeDiffHTMLCell(builder, "n "+gutter, diffHTMLLineNumber(line.NewLine, newRole, styles))
+		if !hideSigns {
+			writeDiffHTMLCell(builder, "p "+gutter, diffHTMLPrefix(line.Kind, line.Prefix, styles))
+		}
+		writeDiffHTMLCell(builder, "c", diffHTMLSegments(line.Segments, styles))
+		builder.WriteString("</tr>\n")
+	}
+}
+
+func writeDiffHTMLSideBySideRows(builder *strings.Builder, file diffHTMLFile, styles *diffHTMLStyles) {
+	if file.SideBySide == nil {
+		return
+	}
+	paneColumns := 3
+	if styles.view.HideChangeSigns {
+		paneColumns = 2
+	}
+	for _, row := range file.SideBySide.Rows {
+		seen := file.SeenHunks[sideRowHunk(row)]
+		if row.Shared != nil {
+			writeDiffHTMLRowOpen(builder, styles.lineClass(row.Shared.Kind, nil), seen)
+			fmt.Fprintf(builder, "<td colspan=\"%d\" class=\"c\">%s</td></tr>\n", paneColumns*2, diffHTMLSegments(row.Shared.Segments, styles))
+			continue
+		}
+		writeDiffHTMLRowOpen(builder, "", seen)
+		writeDiffHTMLSideCell(builder, row.Left, true, paneColumns, styles)
+		writeDiffHTMLSideCell(builder, row.Right, false, paneColumns, styles)
+		builder.WriteString("</tr>\n")
+	}
+}
+
+func writeDiffHTMLSideCell(builder *strings.Builder, cell *RenderedSideCell, isLeft bool, paneColumns int, styles *diffHTMLStyles) {
+	edge := ""
+	if !isLeft {
+		edge = " split"
+	}
+	if cell == nil {
+		fmt.Fprintf(builder, "<td colspan=\"%d\" class=\"hatch%s\"></td>", paneColumns, edge)
+		return
+	}
+	gutter := styles.gutterClass(cell.Kind, cell.Move)
+	writeDiffHTMLCell(builder, "n "+gutter+edge, diffHTMLLineNumber(cell.LineNumber, sideLineNumberRole(cell.Kind, isLeft), styles))
+	if !styles.view.HideChangeSigns {
+		writeDiffHTMLCell(builder, "p "+gutter, diffHTMLPrefix(cell.Kind, cell.Prefix, styles))
+	}
+	writeDiffHTMLCell(builder, "c "+styles.lineClass(cell.Kind, cell.Move), diffHTMLSegments(cell.Segments, styles))
+}
+
+func writeDiffHTMLRowOpen(builder *strings.Builder, class string, seen bool) {
+	if seen {
+		class = strings.TrimSpace(class + " seen")
+	}
+	if class == "" {
+		builder.WriteString("<tr>")
+		return
+	}
+	fmt.Fprintf(builder, "<tr class=\"%s\">", class)
+}
+
+func writeDiffHTMLCell(builder *strings.Builder, class string, content string) {
+	fmt.Fprintf(builder, "<td class=\"%s\">%s</td>", class, content)
+}
+
+func diffHTMLLineNumber(number int, role TokenRole, styles *diffHTMLStyles) string {
+	if number <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("<span class=\"%s\">%d</span>", styles.roleClass(role), number)
+}
+
+func diffHTMLPrefix(kind RenderedLineKind, prefix string, styles *diffHTMLStyles) string {
+	role := TokenRoleDiffPrefixContext
+	if prefixRole, ok := prefixRoleForLine(kind); ok {
+		role = prefixRole
+	}
+	text := displayLinePrefix(RenderedDiffLine{Kind: kind, Prefix: prefix}, false)
+	return fmt.Sprintf("<span class=\"%s\">%s</span>", styles.roleClass(role), html.EscapeString(text))
+}
+
+func diffHTMLSegments(segments []RenderedSegment, styles *diffHTMLStyles) string {
+	var builder strings.Builder
+	for _, segment := range segments {
+		if segment.Text == "" {
+			continue
+		}
+		fmt.Fprintf(&builder, "<span class=\"%s\">%s</span>", styles.segmentClass(segment), html.EscapeString(segment.Text))
+	}
+	return builder.String()
+}
+
+func writeDiffHTML(path string, export diffHTMLExport) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("create directory for %q: %w", path, err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(formatDiffHTML(export)), 0o644); err != nil {
+		return fmt.Errorf("write HTML export %q: %w", path, err)
+	}
+	return nil
+}
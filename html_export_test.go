@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/colorprofile"
+	t "github.com/darrenburns/terma"
+	"github.com/stretchr/testify/require"
+)
+
+func htmlExportTestTheme(tt *testing.T) t.ThemeData {
+	theme, ok := t.GetTheme(t.ThemeNameObsidianTide)
+	require.True(tt, ok)
+	return theme
+}
+
+func htmlExportTestSection(file *DiffFile, seenHunks map[int]bool) diffHTMLSection {
+	roots, _, _ := buildDiffTreeForSection(DiffSectionUnstaged, []*DiffFile{file})
+	return diffHTMLSection{
+		Section: DiffSectionUnstaged,
+		Roots:   roots,
+		Files: []diffHTMLFile{{
+			File:       file,
+			Rendered:   buildRenderedFile(file),
+			SideBySide: buildSideBySideRenderedFile(file),
+			SeenHunks:  seenHunks,
+		}},
+	}
+}
+
+func TestFormatDiffHTML_UnifiedIsSelfContained(tt *testing.T) {
+	doc, err := parseUnifiedDiff("diff --git a/cmd/<main>.go b/cmd/<main>.go\n--- a/cmd/<main>.go\n+++ b/cmd/<main>.go\n@@ -1 +1 @@\n-value := 1\n+value := 2\n")
+	require.NoError(tt, err)
+	file := doc.Files[0]
+	page := formatDiffHTML(diffHTMLExport{
+		Title:          "repo (main)",
+		Sections:       []diffHTMLSection{htmlExportTestSection(file, nil)},
+		IntralineStyle: IntralineStyleModeBackground,
+		Theme:          htmlExportTestTheme(tt),
+	})
+
+	require.True(tt, strings.HasPrefix(page, "<!DOCTYPE html>"))
+	require.NotContains(tt, page, "<script")
+	require.NotContains(tt, page, "<link")
+	require.NotContains(tt, page, "http")
+	require.Contains(tt, page, "<title>repo (main)</title>")
+	require.Contains(tt, page, `<span class="dir">cmd/</span>`)
+	require.Contains(tt, page, `<a href="#unstaged-file-1">&lt;main&gt;.go</a>`)
+	require.Contains(tt, page, `<section class="file" id="unstaged-file-1">`)
+	require.Contains(tt, page, `<table class="diff unified">`)
+	require.Contains(tt, page, `<span class="t4">+1</span>`)
+	require.NotContains(tt, page, "tr class=\"k3 seen\"")
+
+	palette := newThemePalette(htmlExportTestTheme(tt), PaletteVariantDefault, paletteOptions{})
+	addLine, ok := palette.LineStyleForKind(RenderedLineAdd)
+	require.True(tt, ok)
+	require.Contains(tt, page, ".k3{background-color:"+addLine.BackgroundColor.ColorAt(1, 1, 0, 0).Hex()+"}")
+	addMark, ok := palette.IntralineOverlayStyle(IntralineMarkAdd, IntralineStyleModeBackground)
+	require.True(tt, ok)
+	require.Contains(tt, page, "background-color:"+addMark.Background.Hex())
+}
+
+func TestFormatDiffHTML_KeepsConfigStylesInFullColour(tt *testing.T) {
+	doc, err := parseUnifiedDiff("diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-func a() {}\n+func b() {}\n")
+	require.NoError(tt, err)
+	theme := htmlExportTestTheme(tt)
+	page := formatDiffHTML(diffHTMLExport{
+		Title:          "repo (main)",
+		Sections:       []diffHTMLSection{htmlExportTestSection(doc.Files[0], nil)},
+		IntralineStyle: IntralineStyleModeBackground,
+		PaletteOptions: paletteOptions{
+			Profile: colorprofile.ANSI,
+			SyntaxOverrides: map[string]map[TokenRole]syntaxStyleOverride{
+				theme.Name: {TokenRoleSyntaxKeyword: {Foreground: t.Hex("#ff79c6")}},
+			},
+		},
+		Theme: theme,
+	})
+
+	require.Contains(tt, page, "color:"+t.Hex("#ff79c6").Hex())
+	addLine, ok := newThemePalette(theme, PaletteVariantDefault, paletteOptions{}).LineStyleForKind(RenderedLineAdd)
+	require.True(tt, ok)
+	require.Contains(tt, page, ".k3{background-color:"+addLine.BackgroundColor.ColorAt(1, 1, 0, 0).Hex()+"}")
+}
+
+func TestFormatDiffHTML_SplitMarksSeenHunksAndEmptyCells(tt *testing.T) {
+	file := reviewCommentTestFile()
+	page := formatDiffHTML(diffHTMLExport{
+		Title:           "repo",
+		Sections:        []diffHTMLSection{htmlExportTestSection(file, map[int]bool{1: true})},
+		LayoutMode:      DiffLayoutSideBySide,
+		HideChangeSigns: true,
+		Theme:           htmlExportTestTheme(tt),
+	})
+
+	require.Contains(tt, page, `<table class="diff split">`)
+	require.Contains(tt, page, `<tr class="seen"><td class="n g2">`)
+	require.Contains(tt, page, `<span class="badge">Seen</span>`)
+	require.Contains(tt, page, `<li class="seen"><a href="#unstaged-file-1">main.go</a>`)
+	require.Contains(tt, page, `colspan="4"`)
+	require.NotContains(tt, page, `class="p `)
+}
+
+func TestDiffHTMLFile_SeenLabel(tt *testing.T) {
+	file := &DiffFile{Hunks: []DiffHunk{{}, {}, {}}}
+	require.Empty(tt, diffHTMLFile{File: file}.seenLabel())
+	require.Equal(tt, "1/3 hunks seen", diffHTMLFile{File: file, SeenHunks: map[int]bool{2: true}}.seenLabel())
+	require.Equal(tt, "Seen", diffHTMLFile{File: file, SeenHunks: map[int]bool{1: true, 2: true, 3: true}}.seenLabel())
+}
+
+func TestWriteDiffHTML_CreatesParentDirectories(tt *testing.T) {
+	path := filepath.Join(tt.TempDir(), "out", "diff.html")
+	export := diffHTMLExport{Title: "repo", Theme: htmlExportTestTheme(tt)}
+
+	require.NoError(tt, writeDiffHTML(path, export))
+	data, err := os.ReadFile(path)
+	require.NoError(tt, err)
+	require.Equal(tt, formatDiffHTML(export), string(data))
+}
@@ -0,0 +1,752 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const interdiffMinHunkSimilarity = 0.5
+
+// InterdiffHunkStatus describes how a hunk changed between two patch versions.
+type InterdiffHunkStatus string
+
+const (
+	InterdiffHunkNew      InterdiffHunkStatus = "new"
+	InterdiffHunkDropped  InterdiffHunkStatus = "dropped"
+	InterdiffHunkModified InterdiffHunkStatus = "modified"
+)
+
+// patchCommit is one commit of a patch version. Patch files that aren't a
+// format-patch series are a single commit without a subject.
+type patchCommit struct {
+	Hash    string
+	Subject string
+	Patch   string
+}
+
+// patchVersionLoader returns the commits of one version of a change.
+type patchVersionLoader func(ignoreWhitespace bool) ([]patchCommit, error)
+
+// InterdiffProvider compares two versions of a patch and serves only the
+// hunks that are new, dropped or modified between them.
+type InterdiffProvider struct {
+	WorkDir  string
+	OldLabel string
+	NewLabel string
+	LoadOld  patchVersionLoader
+	LoadNew  patchVersionLoader
+	// IgnoreWhitespace is set when the loaders can ignore whitespace, which
+	// patch files can't.
+	IgnoreWhitespace bool
+}
+
+func newRangeDiffProvider(workDir string, oldRange string, newRange string, textconv textconvOptions) InterdiffProvider {
+	return InterdiffProvider{
+		WorkDir:          workDir,
+		OldLabel:         oldRange,
+		NewLabel:         newRange,
+		LoadOld:          gitRangeLoader(workDir, oldRange, textconv),
+		LoadNew:          gitRangeLoader(workDir, newRange, textconv),
+		IgnoreWhitespace: true,
+	}
+}
+
+func newPatchFilesInterdiffProvider(workDir string, oldPatch string, newPatch string) (InterdiffProvider, error) {
+	for _, path := range []string{oldPatch, newPatch} {
+		if _, err := os.Stat(resolvePathArg(workDir, path)); err != nil {
+			return InterdiffProvider{}, fmt.Errorf("interdiff %q: %w", path, err)
+		}
+	}
+	return InterdiffProvider{
+		WorkDir:  workDir,
+		OldLabel: oldPatch,
+		NewLabel: newPatch,
+		LoadOld:  patchFileLoader(workDir, oldPatch),
+		LoadNew:  patchFileLoader(workDir, newPatch),
+	}, nil
+}
+
+// gitRangeLoader loads the non-merge commits of rangeSpec, oldest first, each
+// with its own patch.
+func gitRangeLoader(workDir string, rangeSpec string, textconv textconvOptions) patchVersionLoader {
+	return func(ignoreWhitespace bool) ([]patchCommit, error) {
+		args := []string{"log", "--reverse", "--no-merges", "--format=%H%x00%s", rangeSpec, "--"}
+		stdout, stderr, err := runGit(workDir, args)
+		if err != nil {
+			return nil, fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr))
+		}
+		var commits []patchCommit
+		for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+			hash, subject, ok := strings.Cut(line, "\x00")
+			if !ok {
+				continue
+			}
+			args := buildCommitPatchArgs(hash, ignoreWhitespace, textconv)
+			patch, stderr, err := runGit(workDir, args)
+			if err != nil {
+				return nil, fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr))
+			}
+			commits = append(commits, patchCommit{Hash: hash, Subject: subject, Patch: patch})
+		}
+		return commits, nil
+	}
+}
+
+func patchFileLoader(workDir string, path string) patchVersionLoader {
+	return func(bool) ([]patchCommit, error) {
+		data, err := os.ReadFile(resolvePathArg(workDir, path))
+		if err != nil {
+			return nil, fmt.Errorf("read patch %q: %w", path, err)
+		}
+		return splitPatchSeries(string(data)), nil
+	}
+}
+
+// buildCommitPatchArgs shows the patch of one commit like buildDiffArgs
+// shows the working tree.
+func buildCommitPatchArgs(commit string, ignoreWhitespace bool, textconv textconvOptions) []string {
+	args := []string{"-c", "color.ui=never"}
+	args = append(args, textconv.gitConfigArgs()...)
+	args = append(args,
+		"show",
+		"--format=",
+		"--no-color",
+		"--no-ext-diff",
+		textconv.diffFlag(),
+		"--patch",
+		"--find-renames",
+	)
+	if ignoreWhitespace {
+		args = append(args, "--ignore-all-space")
+	}
+	return append(args, commit, "--")
+}
+
+// mboxFromLine matches the first line of each patch in `git format-patch`
+// output.
+var mboxFromLine = regexp.MustCompile(`(?m)^From ([0-9a-f]{40}) Mon Sep 17 00:00:00 2001$`)
+
+// splitPatchSeries splits `git format-patch` output into its commits. Any
+// other patch is returned as one commit without a subject.
+func splitPatchSeries(raw string) []patchCommit {
+	starts := mboxFromLine.FindAllStringSubmatchIndex(raw, -1)
+	if len(starts) == 0 {
+		return []patchCommit{{Patch: raw}}
+	}
+	commits := make([]patchCommit, 0, len(starts))
+	for idx, start := range starts {
+		end := len(raw)
+		if idx+1 < len(starts) {
+			end = starts[idx+1][0]
+		}
+		message := raw[start[0]:end]
+		// The signature after "-- " would otherwise read as a removed line.
+		if cut := strings.LastIndex(message, "\n-- \n"); cut >= 0 {
+			message = message[:cut+1]
+		}
+		commits = append(commits, patchCommit{
+			Hash:    raw[start[2]:start[3]],
+			Subject: mboxSubject(message),
+			Patch:   message,
+		})
+	}
+	return commits
+}
+
+// mboxSubject returns the Subject header of a format-patch message without
+// its "[PATCH n/m]" tag.
+func mboxSubject(message string) string {
+	for _, line := range strings.Split(message, "\n") {
+		if line == "" {
+			break
+		}
+		if subject, ok := strings.CutPrefix(line, "Subject: "); ok {
+			if strings.HasPrefix(subject, "[") {
+				if _, rest, ok := strings.Cut(subject, "] "); ok {
+					subject = rest
+				}
+			}
+			return strings.TrimSpace(subject)
+		}
+	}
+	return ""
+}
+
+func (p InterdiffProvider) LoadDiff(staged bool, ignoreWhitespace bool) (string, error) {
+	if staged {
+		return "", nil
+	}
+	if p.LoadOld == nil || p.LoadNew == nil {
+		return "", fmt.Errorf("interdiff is missing a patch version")
+	}
+	oldCommits, err := p.LoadOld(ignoreWhitespace)
+	if err != nil {
+		return "", fmt.Errorf("load %s: %w", p.OldLabel, err)
+	}
+	newCommits, err := p.LoadNew(ignoreWhitespace)
+	if err != nil {
+		return "", fmt.Errorf("load %s: %w", p.NewLabel, err)
+	}
+	return computeSeriesInterdiff(oldCommits, newCommits)
+}
+
+func (p InterdiffProvider) RepoRoot() (string, error) {
+	return PathsDiffProvider{WorkDir: p.WorkDir}.RepoRoot()
+}
+
+func (p InterdiffProvider) CurrentBranch() (string, error) {
+	return GitDiffProvider{WorkDir: p.WorkDir}.CurrentBranch()
+}
+
+func (p InterdiffProvider) Sections() []DiffSection {
+	return []DiffSection{DiffSectionFiles}
+}
+
+func (p InterdiffProvider) ManualRefreshEnabled() bool {
+	return true
+}
+
+func (p InterdiffProvider) IgnoreWhitespaceEnabled() bool {
+	return p.IgnoreWhitespace
+}
+
+// FileHeadersEnabled shows the metadata lines of binary and mode-only
+// files, which say how they changed between the versions.
+func (p InterdiffProvider) FileHeadersEnabled() bool {
+	return true
+}
+
+func (p InterdiffProvider) EmptyMessageParts(bool, func(string, string) string) (heading string, details string) {
+	return fmt.Sprintf("No differences between %s and %s.", p.OldLabel, p.NewLabel),
+		"Both versions of the change touch the same hunks in the same way."
+}
+
+func (p InterdiffProvider) SeenScope() string {
+	return "interdiff " + p.OldLabel + " " + p.NewLabel
+}
+
+// patchCommitPair is a commit of both versions of a series, or of only one.
+// An index is -1 when the commit isn't in that version.
+type patchCommitPair struct {
+	OldIndex int
+	NewIndex int
+}
+
+// computeSeriesInterdiff pairs up the commits of two versions of a series
+// and renders what changed in each pair, in the order of the new version with
+// dropped commits last. When the commits have subjects, each pair's files are
+// put in a directory named after the commit.
+func computeSeriesInterdiff(oldCommits []patchCommit, newCommits []patchCommit) (string, error) {
+	oldDocs, err := parsePatchCommits(oldCommits)
+	if err != nil {
+		return "", fmt.Errorf("parse old patch: %w", err)
+	}
+	newDocs, err := parsePatchCommits(newCommits)
+	if err != nil {
+		return "", fmt.Errorf("parse new patch: %w", err)
+	}
+
+	named := false
+	for _, commit := range append(append([]patchCommit{}, oldCommits...), newCommits...) {
+		named = named || commit.Subject != ""
+	}
+
+	var builder strings.Builder
+	for _, pair := range pairPatchCommits(oldDocs, newDocs, oldCommits, newCommits) {
+		oldDoc, newDoc := &DiffDocument{}, &DiffDocument{}
+		if pair.OldIndex >= 0 {
+			oldDoc = oldDocs[pair.OldIndex]
+		}
+		if pair.NewIndex >= 0 {
+			newDoc = newDocs[pair.NewIndex]
+		}
+		dir := ""
+		if named {
+			dir = patchCommitPairDir(pair, oldCommits, newCommits)
+		}
+		writeInterdiff(&builder, oldDoc, newDoc, dir)
+	}
+	return builder.String(), nil
+}
+
+func parsePatchCommits(commits []patchCommit) ([]*DiffDocument, error) {
+	docs := make([]*DiffDocument, 0, len(commits))
+	for _, commit := range commits {
+		doc, err := parseUnifiedDiff(commit.Patch)
+		if err != nil {
+			if commit.Subject != "" {
+				return nil, fmt.Errorf("%s: %w", commit.Subject, err)
+			}
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// pairPatchCommits pairs commits with the same patch, then commits with the
+// same subject, then commits that change the same file, each in order.
+func pairPatchCommits(oldDocs []*DiffDocument, newDocs []*DiffDocument, oldCommits []patchCommit, newCommits []patchCommit) []patchCommitPair {
+	oldIDs := make([]string, len(oldDocs))
+	for idx, doc := range oldDocs {
+		oldIDs[idx] = patchIdentity(doc)
+	}
+	newIDs := make([]string, len(newDocs))
+	for idx, doc := range newDocs {
+		newIDs[idx] = patchIdentity(doc)
+	}
+
+	matchers := []func(oldIdx int, newIdx int) bool{
+		func(oldIdx int, newIdx int) bool {
+			return oldIDs[oldIdx] == newIDs[newIdx]
+		},
+		func(oldIdx int, newIdx int) bool {
+			return oldCommits[oldIdx].Subject != "" && oldCommits[oldIdx].Subject == newCommits[newIdx].Subject
+		},
+		func(oldIdx int, newIdx int) bool {
+			return patchesShareFile(oldDocs[oldIdx], newDocs[newIdx])
+		},
+	}
+
+	oldPaired := make([]bool, len(oldDocs))
+	newPairs := make([]int, len(newDocs))
+	for idx := range newPairs {
+		newPairs[idx] = -1
+	}
+	for _, match := range matchers {
+		for newIdx := range newDocs {
+			if newPairs[newIdx] >= 0 {
+				continue
+			}
+			for oldIdx := range oldDocs {
+				if oldPaired[oldIdx] || !match(oldIdx, newIdx) {
+					continue
+				}
+				oldPaired[oldIdx] = true
+				newPairs[newIdx] = oldIdx
+				break
+			}
+		}
+	}
+
+	pairs := make([]patchCommitPair, 0, len(oldDocs)+len(newDocs))
+	for newIdx, oldIdx := range newPairs {
+		pairs = append(pairs, patchCommitPair{OldIndex: oldIdx, NewIndex: newIdx})
+	}
+	for oldIdx, paired := range oldPaired {
+		if !paired {
+			pairs = append(pairs, patchCommitPair{OldIndex: oldIdx, NewIndex: -1})
+		}
+	}
+	return pairs
+}
+
+// patchIdentity hashes the paths, metadata and hunk lines of doc, leaving
+// out line numbers and blob hashes like `git patch-id`.
+func patchIdentity(doc *DiffDocument) string {
+	hash := sha256.New()
+	for _, file := range doc.Files {
+		fmt.Fprintf(hash, "file %s\x00", file.DisplayPath)
+		for _, header := range interdiffFileMetadata(file) {
+			fmt.Fprintf(hash, "%s\x00", header)
+		}
+		for _, hunk := range file.Hunks {
+			for _, line := range hunk.Lines {
+				fmt.Fprintf(hash, "%s%s\x00", diffLinePrefix(line), line.Content)
+			}
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func patchesShareFile(left *DiffDocument, right *DiffDocument) bool {
+	rightPaths := diffFilesByDisplayPath(right.Files)
+	for _, file := range left.Files {
+		if _, ok := rightPaths[file.DisplayPath]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// patchCommitPairDir names the directory of a commit pair after its position
+// in the new version, like `git range-diff`, and its subject.
+func patchCommitPairDir(pair patchCommitPair, oldCommits []patchCommit, newCommits []patchCommit) string {
+	var name string
+	switch {
+	case pair.OldIndex < 0:
+		name = fmt.Sprintf("%d: %s [%s]", pair.NewIndex+1, patchCommitName(newCommits[pair.NewIndex]), InterdiffHunkNew)
+	case pair.NewIndex < 0:
+		name = fmt.Sprintf("-: %s [%s]", patchCommitName(oldCommits[pair.OldIndex]), InterdiffHunkDropped)
+	default:
+		name = fmt.Sprintf("%d: %s", pair.NewIndex+1, patchCommitName(newCommits[pair.NewIndex]))
+	}
+	return strings.ReplaceAll(name, "/", "-")
+}
+
+func patchCommitName(commit patchCommit) string {
+	if commit.Subject != "" {
+		return commit.Subject
+	}
+	if len(commit.Hash) > 7 {
+		return commit.Hash[:7]
+	}
+	return commit.Hash
+}
+
+// writeInterdiff writes the files that differ between oldDoc and newDoc,
+// with their paths under dir.
+func writeInterdiff(builder *strings.Builder, oldDoc *DiffDocument, newDoc *DiffDocument, dir string) {
+	oldByPath := diffFilesByDisplayPath(oldDoc.Files)
+	newByPath := diffFilesByDisplayPath(newDoc.Files)
+	paths := make([]string, 0, len(oldByPath)+len(newByPath))
+	for path := range oldByPath {
+		paths = append(paths, path)
+	}
+	for path := range newByPath {
+		if _, ok := oldByPath[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		oldFile := oldByPath[path]
+		newFile := newByPath[path]
+		hunks := interdiffFileHunks(oldFile, newFile)
+		metadata := interdiffMetadataLines(oldFile, newFile)
+		if len(hunks) == 0 && len(metadata) == 0 {
+			continue
+		}
+		writeInterdiffFile(builder, dir, oldFile, newFile, metadata, hunks)
+	}
+}
+
+type interdiffHunk struct {
+	hunk   DiffHunk
+	status InterdiffHunkStatus
+	// order is the line the hunk starts at in the new version, or in the
+	// old version for dropped hunks.
+	order int
+}
+
+func diffFilesByDisplayPath(files []*DiffFile) map[string]*DiffFile {
+	byPath := make(map[string]*DiffFile, len(files))
+	for _, file := range files {
+		if file == nil {
+			continue
+		}
+		byPath[file.DisplayPath] = file
+	}
+	return byPath
+}
+
+// interdiffFileMetadata returns the header lines of file that say how it
+// changed, apart from its paths. Files without hunks keep their blob hashes,
+// which are all that tell two binary versions apart.
+func interdiffFileMetadata(file *DiffFile) []string {
+	if file == nil {
+		return nil
+	}
+	var metadata []string
+	for _, header := range file.Headers {
+		switch {
+		case strings.HasPrefix(header, "diff --git "), strings.HasPrefix(header, "--- "), strings.HasPrefix(header, "+++ "),
+			strings.HasPrefix(header, "similarity index "), strings.HasPrefix(header, "dissimilarity index "):
+			continue
+		case strings.HasPrefix(header, "index "), strings.HasPrefix(header, "Binary files "):
+			if len(file.Hunks) > 0 {
+				continue
+			}
+		}
+		metadata = append(metadata, header)
+	}
+	return metadata
+}
+
+// interdiffMetadataLines describes a change to the metadata of a file, such
+// as its mode or binary contents, as header lines tagged with the
+// InterdiffHunkStatus. New and dropped files with hunks are described by
+// their hunks instead.
+func interdiffMetadataLines(oldFile *DiffFile, newFile *DiffFile) []string {
+	oldMetadata := interdiffFileMetadata(oldFile)
+	newMetadata := interdiffFileMetadata(newFile)
+	if slices.Equal(oldMetadata, newMetadata) {
+		return nil
+	}
+	status := InterdiffHunkModified
+	switch {
+	case oldFile == nil:
+		if len(newFile.Hunks) > 0 {
+			return nil
+		}
+		status = InterdiffHunkNew
+	case newFile == nil:
+		if len(oldFile.Hunks) > 0 {
+			return nil
+		}
+		status = InterdiffHunkDropped
+	}
+
+	lines := []string{fmt.Sprintf("[%s]", status)}
+	for _, line := range oldMetadata {
+		lines = append(lines, "v1: "+line)
+	}
+	for _, line := range newMetadata {
+		lines = append(lines, "v2: "+line)
+	}
+	return lines
+}
+
+func interdiffFileHunks(oldFile *DiffFile, newFile *DiffFile) []interdiffHunk {
+	var oldHunks, newHunks []DiffHunk
+	if oldFile != nil {
+		oldHunks = oldFile.Hunks
+	}
+	if newFile != nil {
+		newHunks = newFile.Hunks
+	}
+
+	oldMatched := make([]bool, len(oldHunks))
+	newMatched := make([]bool, len(newHunks))
+	for newIdx, newHunk := range newHunks {
+		for oldIdx, oldHunk := range oldHunks {
+			if oldMatched[oldIdx] || !hunkBodiesEqual(oldHunk, newHunk) {
+				continue
+			}
+			oldMatched[oldIdx] = true
+			newMatched[newIdx] = true
+			break
+		}
+	}
+
+	pairedOld := make([]int, len(newHunks))
+	for newIdx := range pairedOld {
+		pairedOld[newIdx] = -1
+		if newMatched[newIdx] {
+			continue
+		}
+		best := -1
+		bestScore := 0.0
+		for oldIdx, oldHunk := range oldHunks {
+			if oldMatched[oldIdx] {
+				continue
+			}
+			score := hunkSimilarity(oldHunk, newHunks[newIdx])
+			if hunkOldRangesOverlap(oldHunk, newHunks[newIdx]) {
+				score = max(score, interdiffMinHunkSimilarity)
+			}
+			if score >= interdiffMinHunkSimilarity && score > bestScore {
+				best = oldIdx
+				bestScore = score
+			}
+		}
+		if best >= 0 {
+			oldMatched[best] = true
+			pairedOld[newIdx] = best
+		}
+	}
+
+	result := make([]interdiffHunk, 0)
+	for newIdx, newHunk := range newHunks {
+		if newMatched[newIdx] {
+			continue
+		}
+		if oldIdx := pairedOld[newIdx]; oldIdx >= 0 {
+			if hunk, ok := diffHunkPair(oldHunks[oldIdx], newHunk); ok {
+				result = append(result, interdiffHunk{
+					hunk:   hunk,
+					status: InterdiffHunkModified,
+					order:  newHunk.NewStart,
+				})
+				continue
+			}
+			// Too big to diff line by line, so both versions are shown
+			// whole.
+			oldMatched[oldIdx] = false
+		}
+		result = append(result, interdiffHunk{hunk: newHunk, status: InterdiffHunkNew, order: newHunk.NewStart})
+	}
+
+	for oldIdx, oldHunk := range oldHunks {
+		if !oldMatched[oldIdx] {
+			result = append(result, interdiffHunk{hunk: oldHunk, status: InterdiffHunkDropped, order: oldHunk.NewStart})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].order < result[j].order
+	})
+	return result
+}
+
+// diffHunkPair diffs the lines of two versions of a hunk, markers included,
+// like `git range-diff`: a line only in the old version is removed, a line
+// only in the new version is added, and "-+b" means the old version added b
+// and the new version doesn't. Line numbers count from where each version
+// starts in the base file. It returns false when the hunks are too big to
+// match.
+func diffHunkPair(oldHunk DiffHunk, newHunk DiffHunk) (DiffHunk, bool) {
+	oldKeys := hunkLineKeys(oldHunk)
+	newKeys := hunkLineKeys(newHunk)
+	matches, ok := longestCommonSubsequence(oldKeys, newKeys)
+	if !ok {
+		return DiffHunk{}, false
+	}
+
+	var lines []DiffLine
+	oldIdx, newIdx := 0, 0
+	for _, match := range matches {
+		for ; oldIdx < match[0]; oldIdx++ {
+			lines = append(lines, DiffLine{Kind: DiffLineRemove, Content: oldKeys[oldIdx]})
+		}
+		for ; newIdx < match[1]; newIdx++ {
+			lines = append(lines, DiffLine{Kind: DiffLineAdd, Content: newKeys[newIdx]})
+		}
+		lines = append(lines, DiffLine{Kind: DiffLineContext, Content: newKeys[newIdx]})
+		oldIdx++
+		newIdx++
+	}
+	for ; oldIdx < len(oldKeys); oldIdx++ {
+		lines = append(lines, DiffLine{Kind: DiffLineRemove, Content: oldKeys[oldIdx]})
+	}
+	for ; newIdx < len(newKeys); newIdx++ {
+		lines = append(lines, DiffLine{Kind: DiffLineAdd, Content: newKeys[newIdx]})
+	}
+
+	hunk := DiffHunk{OldStart: oldHunk.OldStart, NewStart: newHunk.OldStart, Lines: lines}
+	for _, line := range lines {
+		if line.Kind != DiffLineAdd {
+			hunk.OldCount++
+		}
+		if line.Kind != DiffLineRemove {
+			hunk.NewCount++
+		}
+	}
+	section := ""
+	if loc := hunkHeaderPattern.FindStringIndex(newHunk.Header); loc != nil {
+		section = newHunk.Header[loc[1]:]
+	}
+	hunk.Header = fmt.Sprintf("@@ -%d,%d +%d,%d @@%s", hunk.OldStart, hunk.OldCount, hunk.NewStart, hunk.NewCount, section)
+	return hunk, true
+}
+
+func hunkLineKeys(hunk DiffHunk) []string {
+	keys := make([]string, 0, len(hunk.Lines))
+	for _, line := range hunk.Lines {
+		keys = append(keys, diffLinePrefix(line)+line.Content)
+	}
+	return keys
+}
+
+func hunkBodiesEqual(left DiffHunk, right DiffHunk) bool {
+	if len(left.Lines) != len(right.Lines) {
+		return false
+	}
+	for idx := range left.Lines {
+		if left.Lines[idx].Kind != right.Lines[idx].Kind || left.Lines[idx].Content != right.Lines[idx].Content {
+			return false
+		}
+	}
+	return true
+}
+
+// hunkSimilarity is the share of changed lines both hunks have in common.
+func hunkSimilarity(left DiffHunk, right DiffHunk) float64 {
+	leftLines := changedHunkLineCounts(left)
+	rightLines := changedHunkLineCounts(right)
+	leftTotal, rightTotal := 0, 0
+	for _, count := range leftLines {
+		leftTotal += count
+	}
+	for _, count := range rightLines {
+		rightTotal += count
+	}
+	if leftTotal+rightTotal == 0 {
+		return 0
+	}
+	shared := 0
+	for key, count := range leftLines {
+		shared += min(count, rightLines[key])
+	}
+	return (2 * float64(shared)) / float64(leftTotal+rightTotal)
+}
+
+func changedHunkLineCounts(hunk DiffHunk) map[string]int {
+	counts := map[string]int{}
+	for _, line := range hunk.Lines {
+		switch line.Kind {
+		case DiffLineAdd:
+			counts["+"+line.Content]++
+		case DiffLineRemove:
+			counts["-"+line.Content]++
+		}
+	}
+	return counts
+}
+
+func hunkOldRangesOverlap(left DiffHunk, right DiffHunk) bool {
+	leftEnd := left.OldStart + max(left.OldCount, 1)
+	rightEnd := right.OldStart + max(right.OldCount, 1)
+	return left.OldStart < rightEnd && right.OldStart < leftEnd
+}
+
+func writeInterdiffFile(builder *strings.Builder, dir string, oldFile *DiffFile, newFile *DiffFile, metadata []string, hunks []interdiffHunk) {
+	file := newFile
+	if file == nil {
+		file = oldFile
+	}
+	oldHeader, newHeader := "/dev/null", "/dev/null"
+	if file.OldPath != "" {
+		oldHeader = "a/" + path.Join(dir, file.OldPath)
+	}
+	if file.NewPath != "" {
+		newHeader = "b/" + path.Join(dir, file.NewPath)
+	}
+
+	displayPath := path.Join(dir, file.DisplayPath)
+	fmt.Fprintf(builder, "diff --git a/%s b/%s\n", displayPath, displayPath)
+	for _, line := range metadata {
+		builder.WriteString(line)
+		builder.WriteString("\n")
+	}
+	fmt.Fprintf(builder, "--- %s\n", oldHeader)
+	fmt.Fprintf(builder, "+++ %s\n", newHeader)
+	for _, item := range hunks {
+		builder.WriteString(interdiffHunkHeader(item))
+		builder.WriteString("\n")
+		for _, line := range item.hunk.Lines {
+			builder.WriteString(diffLinePrefix(line))
+			builder.WriteString(line.Content)
+			builder.WriteString("\n")
+		}
+	}
+}
+
+func interdiffHunkHeader(item interdiffHunk) string {
+	header := strings.TrimRight(item.hunk.Header, " ")
+	if header == "" {
+		header = fmt.Sprintf("@@ -%d,%d +%d,%d @@", item.hunk.OldStart, item.hunk.OldCount, item.hunk.NewStart, item.hunk.NewCount)
+	}
+	return fmt.Sprintf("%s [%s]", header, item.status)
+}
+
+func diffLinePrefix(line DiffLine) string {
+	switch line.Kind {
+	case DiffLineAdd:
+		return "+"
+	case DiffLineRemove:
+		return "-"
+	case DiffLineContext:
+		return " "
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const interdiffTestV1 = `diff --git a/app.go b/app.go
+--- a/app.go
++++ b/app.go
+@@ -1,3 +1,3 @@ func main() {
+ a
+-b
++b1
+ c
+@@ -10,3 +10,3 @@ func helper() {
+ x
+-y
++y1
+ z
+@@ -20,2 +20,3 @@ func gone() {
+ p
++q
+ r
+diff --git a/removed.go b/removed.go
+--- a/removed.go
++++ b/removed.go
+@@ -1 +1 @@
+-old
++new
+`
+
+const interdiffTestV2 = `diff --git a/app.go b/app.go
+--- a/app.go
++++ b/app.go
+@@ -3,3 +3,3 @@ func main() {
+ a
+-b
++b1
+ c
+@@ -12,3 +12,3 @@ func helper() {
+ x
+-y
++y2
+ z
+@@ -40,2 +40,3 @@ func added() {
+ m
++n
+ o
+diff --git a/fresh.go b/fresh.go
+new file mode 100644
+--- /dev/null
++++ b/fresh.go
+@@ -0,0 +1 @@
++hello
+`
+
+func TestComputeSeriesInterdiff_MarksNewDroppedAndModifiedHunks(t *testing.T) {
+	raw, err := computeSeriesInterdiff([]patchCommit{{Patch: interdiffTestV1}}, []patchCommit{{Patch: interdiffTestV2}})
+	require.NoError(t, err)
+
+	doc, err := parseUnifiedDiff(raw)
+	require.NoError(t, err)
+	require.Len(t, doc.Files, 3)
+
+	byPath := diffFilesByDisplayPath(doc.Files)
+	app := byPath["app.go"]
+	require.NotNil(t, app)
+	headers := make([]string, 0, len(app.Hunks))
+	for _, hunk := range app.Hunks {
+		headers = append(headers, hunk.Header)
+	}
+	require.Equal(t, []string{
+		"@@ -10,4 +12,4 @@ func helper() { [modified]",
+		"@@ -20,2 +20,3 @@ func gone() { [dropped]",
+		"@@ -40,2 +40,3 @@ func added() { [new]",
+	}, headers)
+	require.Equal(t, []DiffLine{
+		{Kind: DiffLineContext, Content: " x", OldLine: 10, NewLine: 12},
+		{Kind: DiffLineContext, Content: "-y", OldLine: 11, NewLine: 13},
+		{Kind: DiffLineRemove, Content: "+y1", OldLine: 12},
+		{Kind: DiffLineAdd, Content: "+y2", NewLine: 14},
+		{Kind: DiffLineContext, Content: " z", OldLine: 13, NewLine: 15},
+	}, app.Hunks[0].Lines)
+
+	fresh := byPath["fresh.go"]
+	require.NotNil(t, fresh)
+	require.Empty(t, fresh.OldPath)
+	require.Len(t, fresh.Hunks, 1)
+	require.True(t, strings.HasSuffix(fresh.Hunks[0].Header, "[new]"))
+
+	removed := byPath["removed.go"]
+	require.NotNil(t, removed)
+	require.Len(t, removed.Hunks, 1)
+	require.True(t, strings.HasSuffix(removed.Hunks[0].Header, "[dropped]"))
+}
+
+func TestComputeSeriesInterdiff_IdenticalVersionsAreEmpty(t *testing.T) {
+	raw, err := computeSeriesInterdiff([]patchCommit{{Patch: interdiffTestV1}}, []patchCommit{{Patch: interdiffTestV1}})
+	require.NoError(t, err)
+	require.Empty(t, raw)
+}
+
+func TestInterdiffFileHunks_TooBigToDiffShowsBothVersions(t *testing.T) {
+	oldHunk := DiffHunk{OldStart: 1, NewStart: 1}
+	newHunk := DiffHunk{OldStart: 1, NewStart: 1}
+	for idx := range 600 {
+		oldHunk.Lines = append(oldHunk.Lines, DiffLine{Kind: DiffLineAdd, Content: fmt.Sprintf("old %d", idx)})
+		newHunk.Lines = append(newHunk.Lines, DiffLine{Kind: DiffLineAdd, Content: fmt.Sprintf("new %d", idx)})
+	}
+
+	hunks := interdiffFileHunks(&DiffFile{Hunks: []DiffHunk{oldHunk}}, &DiffFile{Hunks: []DiffHunk{newHunk}})
+	require.Len(t, hunks, 2)
+	require.Equal(t, InterdiffHunkNew, hunks[0].status)
+	require.Equal(t, newHunk, hunks[0].hunk)
+	require.Equal(t, InterdiffHunkDropped, hunks[1].status)
+	require.Equal(t, oldHunk, hunks[1].hunk)
+}
+
+func TestHunkSimilarity(t *testing.T) {
+	left := DiffHunk{Lines: []DiffLine{
+		{Kind: DiffLineRemove, Content: "a"},
+		{Kind: DiffLineAdd, Content: "b"},
+	}}
+	right := DiffHunk{Lines: []DiffLine{
+		{Kind: DiffLineContext, Content: "ctx"},
+		{Kind: DiffLineRemove, Content: "a"},
+		{Kind: DiffLineAdd, Content: "c"},
+	}}
+	require.InDelta(t, 0.5, hunkSimilarity(left, right), 0.0001)
+	require.InDelta(t, 1.0, hunkSimilarity(left, left), 0.0001)
+	require.Zero(t, hunkSimilarity(DiffHunk{}, DiffHunk{}))
+}
+
+func TestInterdiffProvider_LoadDiffFromPatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "v1.patch"), interdiffTestV1)
+	writeTestFile(t, filepath.Join(dir, "v2.patch"), interdiffTestV2)
+
+	provider, err := newPatchFilesInterdiffProvider(dir, "v1.patch", "v2.patch")
+	require.NoError(t, err)
+	require.Equal(t, []DiffSection{DiffSectionFiles}, provider.Sections())
+	require.True(t, provider.ManualRefreshEnabled())
+	require.False(t, provider.IgnoreWhitespaceEnabled())
+
+	raw, err := provider.LoadDiff(false, false)
+	require.NoError(t, err)
+	require.Contains(t, raw, "[modified]")
+
+	staged, err := provider.LoadDiff(true, false)
+	require.NoError(t, err)
+	require.Empty(t, staged)
+
+	heading, _ := provider.EmptyMessageParts(false, nil)
+	require.Equal(t, "No differences between v1.patch and v2.patch.", heading)
+}
+
+func TestInterdiffProvider_RangeDiffComparesBranches(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	runTestGit := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	runTestGit("init", "-q", "-b", "main")
+	writeTestFile(t, filepath.Join(dir, "file.txt"), "one\ntwo\nthree\n")
+	runTestGit("add", ".")
+	runTestGit("commit", "-q", "-m", "base")
+	runTestGit("checkout", "-q", "-b", "v1")
+	writeTestFile(t, filepath.Join(dir, "file.txt"), "one\nTWO\nthree\n")
+	runTestGit("commit", "-q", "-am", "v1")
+	runTestGit("checkout", "-q", "-b", "v2", "main")
+	writeTestFile(t, filepath.Join(dir, "file.txt"), "one\n2\nthree\n")
+	runTestGit("commit", "-q", "-am", "v2")
+
+	provider := newRangeDiffProvider(dir, "main..v1", "main..v2", textconvOptions{})
+	raw, err := provider.LoadDiff(false, false)
+	require.NoError(t, err)
+
+	doc, err := parseUnifiedDiff(raw)
+	require.NoError(t, err)
+	require.Len(t, doc.Files, 1)
+	require.Equal(t, "1: v2/file.txt", doc.Files[0].DisplayPath)
+	require.Len(t, doc.Files[0].Hunks, 1)
+	require.True(t, strings.HasSuffix(doc.Files[0].Hunks[0].Header, "[modified]"))
+}
+
+func TestInterdiffProvider_RangeDiffPairsCommits(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	runTestGit := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	commit := func(path string, content string, subject string) {
+		t.Helper()
+		writeTestFile(t, filepath.Join(dir, path), content)
+		runTestGit("add", ".")
+		runTestGit("commit", "-q", "-m", subject)
+	}
+
+	runTestGit("init", "-q", "-b", "main")
+	commit("base.txt", "base\n", "base")
+	runTestGit("checkout", "-q", "-b", "v1")
+	commit("docs.txt", "docs\n", "Add docs")
+	commit("parser.go", "package parser\n", "Add parser")
+	commit("old.txt", "old\n", "Add old file")
+	runTestGit("checkout", "-q", "-b", "v2", "main")
+	commit("docs.txt", "docs\n", "Add docs")
+	commit("parser.go", "package parser // v2\n", "Add parser")
+	commit("new.txt", "new\n", "Add new file")
+
+	provider := newRangeDiffProvider(dir, "main..v1", "main..v2", textconvOptions{})
+	raw, err := provider.LoadDiff(false, false)
+	require.NoError(t, err)
+
+	doc, err := parseUnifiedDiff(raw)
+	require.NoError(t, err)
+	paths := make([]string, 0, len(doc.Files))
+	for _, file := range doc.Files {
+		paths = append(paths, file.DisplayPath)
+	}
+	require.Equal(t, []string{
+		"2: Add parser/parser.go",
+		"3: Add new file [new]/new.txt",
+		"-: Add old file [dropped]/old.txt",
+	}, paths)
+	require.True(t, strings.HasSuffix(doc.Files[0].Hunks[0].Header, "[modified]"))
+	require.True(t, strings.HasSuffix(doc.Files[1].Hunks[0].Header, "[new]"))
+	require.True(t, strings.HasSuffix(doc.Files[2].Hunks[0].Header, "[dropped]"))
+}
+
+func TestComputeSeriesInterdiff_KeepsFilesWithoutHunks(t *testing.T) {
+	v1 := "diff --git a/logo.png b/logo.png\n" +
+		"index 1111111..2222222 100644\n" +
+		"Binary files a/logo.png and b/logo.png differ\n" +
+		"diff --git a/run.sh b/run.sh\n" +
+		"old mode 100644\n" +
+		"new mode 100755\n"
+	v2 := "diff --git a/logo.png b/logo.png\n" +
+		"index 1111111..3333333 100644\n" +
+		"Binary files a/logo.png and b/logo.png differ\n" +
+		"diff --git a/icon.png b/icon.png\n" +
+		"new file mode 100644\n" +
+		"index 0000000..4444444\n" +
+		"Binary files /dev/null and b/icon.png differ\n"
+
+	raw, err := computeSeriesInterdiff([]patchCommit{{Patch: v1}}, []patchCommit{{Patch: v2}})
+	require.NoError(t, err)
+	doc, err := parseUnifiedDiff(raw)
+	require.NoError(t, err)
+
+	byPath := diffFilesByDisplayPath(doc.Files)
+	require.Len(t, byPath, 3)
+	require.Contains(t, byPath["logo.png"].Headers, "[modified]")
+	require.Contains(t, byPath["logo.png"].Headers, "v1: index 1111111..2222222 100644")
+	require.Contains(t, byPath["logo.png"].Headers, "v2: index 1111111..3333333 100644")
+	require.Contains(t, byPath["icon.png"].Headers, "[new]")
+	require.Contains(t, byPath["run.sh"].Headers, "[dropped]")
+	require.Contains(t, byPath["run.sh"].Headers, "v1: new mode 100755")
+
+	renderedTexts := func(options fileRenderOptions) []string {
+		rendered := buildRenderedFileWithOptions(byPath["run.sh"], options)
+		texts := make([]string, 0, len(rendered.Lines))
+		for _, line := range rendered.Lines {
+			texts = append(texts, lineText(line))
+		}
+		return texts
+	}
+	require.Equal(t, []string{"[dropped]", "v1: old mode 100644", "v1: new mode 100755"}, renderedTexts(fileRenderOptions{FileHeaders: true}))
+	// Outside interdiffs a file without hunks keeps the short message.
+	require.Equal(t, []string{"No displayable content"}, renderedTexts(fileRenderOptions{}))
+	require.True(t, InterdiffProvider{}.FileHeadersEnabled())
+
+	same, err := computeSeriesInterdiff([]patchCommit{{Patch: v1}}, []patchCommit{{Patch: v1}})
+	require.NoError(t, err)
+	require.Empty(t, same)
+}
+
+func TestSplitPatchSeries(t *testing.T) {
+	series := "From 1111111111111111111111111111111111111111 Mon Sep 17 00:00:00 2001\n" +
+		"From: Someone <someone@example.com>\n" +
+		"Subject: [PATCH 1/2] Add docs\n" +
+		"\n" +
+		"---\n" +
+		"diff --git a/docs.txt b/docs.txt\n" +
+		"--- a/docs.txt\n" +
+		"+++ b/docs.txt\n" +
+		"@@ -1 +1 @@\n" +
+		"-a\n" +
+		"+b\n" +
+		"-- \n" +
+		"2.40.0\n" +
+		"\n" +
+		"From 2222222222222222222222222222222222222222 Mon Sep 17 00:00:00 2001\n" +
+		"Subject: [PATCH 2/2] Fix parser\n" +
+		"\n" +
+		"diff --git a/parser.go b/parser.go\n"
+
+	commits := splitPatchSeries(series)
+	require.Len(t, commits, 2)
+	require.Equal(t, "1111111111111111111111111111111111111111", commits[0].Hash)
+	require.Equal(t, "Add docs", commits[0].Subject)
+	require.NotContains(t, commits[0].Patch, "2.40.0")
+	require.Equal(t, "Fix parser", commits[1].Subject)
+
+	doc, err := parseUnifiedDiff(commits[0].Patch)
+	require.NoError(t, err)
+	require.Len(t, doc.Files[0].Hunks[0].Lines, 2)
+
+	require.Equal(t, []patchCommit{{Patch: interdiffTestV1}}, splitPatchSeries(interdiffTestV1))
+}
+
+func TestPairPatchCommits(t *testing.T) {
+	patch := func(path string, line string) string {
+		return "diff --git a/" + path + " b/" + path + "\n--- a/" + path + "\n+++ b/" + path + "\n@@ -1 +1 @@\n-x\n+" + line + "\n"
+	}
+	oldCommits := []patchCommit{
+		{Subject: "Same", Patch: patch("a.txt", "a")},
+		{Subject: "Reworded", Patch: patch("b.txt", "b")},
+		{Subject: "Renamed subject", Patch: patch("c.txt", "c")},
+		{Subject: "Dropped", Patch: patch("d.txt", "d")},
+	}
+	newCommits := []patchCommit{
+		{Subject: "Renamed subject, v2", Patch: patch("c.txt", "c2")},
+		{Subject: "Same, but retitled", Patch: patch("a.txt", "a")},
+		{Subject: "Reworded", Patch: patch("b.txt", "b2")},
+		{Subject: "Added", Patch: patch("e.txt", "e")},
+	}
+	oldDocs, err := parsePatchCommits(oldCommits)
+	require.NoError(t, err)
+	newDocs, err := parsePatchCommits(newCommits)
+	require.NoError(t, err)
+
+	require.Equal(t, []patchCommitPair{
+		{OldIndex: 2, NewIndex: 0},
+		{OldIndex: 0, NewIndex: 1},
+		{OldIndex: 1, NewIndex: 2},
+		{OldIndex: -1, NewIndex: 3},
+		{OldIndex: 3, NewIndex: -1},
+	}, pairPatchCommits(oldDocs, newDocs, oldCommits, newCommits))
+}
@@ -9,6 +9,44 @@ import (
 
 const intralineMaxDPMatrixCells = 250000
 
+// longestCommonSubsequence returns the index pairs of the longest common
+// subsequence of oldKeys and newKeys. It returns false when the table would
+// have more than intralineMaxDPMatrixCells cells.
+func longestCommonSubsequence(oldKeys []string, newKeys []string) ([][2]int, bool) {
+	if len(oldKeys)*len(newKeys) > intralineMaxDPMatrixCells {
+		return nil, false
+	}
+	dp := make([][]int, len(oldKeys)+1)
+	for row := range dp {
+		dp[row] = make([]int, len(newKeys)+1)
+	}
+	for oldIdx := len(oldKeys) - 1; oldIdx >= 0; oldIdx-- {
+		for newIdx := len(newKeys) - 1; newIdx >= 0; newIdx-- {
+			if oldKeys[oldIdx] == newKeys[newIdx] {
+				dp[oldIdx][newIdx] = dp[oldIdx+1][newIdx+1] + 1
+				continue
+			}
+			dp[oldIdx][newIdx] = max(dp[oldIdx+1][newIdx], dp[oldIdx][newIdx+1])
+		}
+	}
+
+	var matches [][2]int
+	oldIdx, newIdx := 0, 0
+	for oldIdx < len(oldKeys) && newIdx < len(newKeys) {
+		switch {
+		case oldKeys[oldIdx] == newKeys[newIdx]:
+			matches = append(matches, [2]int{oldIdx, newIdx})
+			oldIdx++
+			newIdx++
+		case dp[oldIdx+1][newIdx] >= dp[oldIdx][newIdx+1]:
+			oldIdx++
+		default:
+			newIdx++
+		}
+	}
+	return matches, true
+}
+
 // intralineChangeMasks returns per-grapheme change masks for old/new text.
 // A value of true indicates that grapheme should receive intraline emphasis.
 // The bool return is false when matching is skipped (for example due to size cutoff).
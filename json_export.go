@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// diffJSONSchemaVersion is bumped whenever a field of the --json output is
+// removed or changes meaning. Adding fields does not bump it.
+const (
+	diffJSONSchemaName    = "dv.diff"
+	diffJSONSchemaVersion = 1
+)
+
+// diffJSONDocument is the top-level object written by --json. The README
+// documents every field.
+type diffJSONDocument struct {
+	Schema  string         `json:"schema"`
+	Version int            `json:"version"`
+	Section DiffSection    `json:"section"`
+	Files   []diffJSONFile `json:"files"`
+}
+
+type diffJSONFile struct {
+	Path      string         `json:"path"`
+	OldPath   string         `json:"old_path"`
+	NewPath   string         `json:"new_path"`
+	Change    string         `json:"change"`
+	Binary    bool           `json:"binary"`
+	Additions int            `json:"additions"`
+	Deletions int            `json:"deletions"`
+	Headers   []string       `json:"headers"`
+	Hunks     []diffJSONHunk `json:"hunks"`
+}
+
+type diffJSONHunk struct {
+	Header   string         `json:"header"`
+	OldStart int            `json:"old_start"`
+	OldCount int            `json:"old_count"`
+	NewStart int            `json:"new_start"`
+	NewCount int            `json:"new_count"`
+	Lines    []diffJSONLine `json:"lines"`
+}
+
+type diffJSONLine struct {
+	Kind    string `json:"kind"`
+	Content string `json:"content"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+	// Intraline holds [start, end) byte offsets into Content of the changed
+	// parts of the line. It is only present with --json-intraline.
+	Intraline [][2]int `json:"intraline,omitempty"`
+}
+
+// writeDiffJSON writes the section dv would open on as a diffJSONDocument.
+func writeDiffJSON(w io.Writer, provider DiffProvider, staged bool, ignoreWhitespace bool, includeIntraline bool) error {
+	section, doc, err := loadStartupSectionDiff(provider, staged, ignoreWhitespace)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(buildDiffJSONDocument(section, doc, includeIntraline)); err != nil {
+		return fmt.Errorf("encode diff JSON: %w", err)
+	}
+	return nil
+}
+
+func buildDiffJSONDocument(section DiffSection, doc *DiffDocument, includeIntraline bool) diffJSONDocument {
+	out := diffJSONDocument{
+		Schema:  diffJSONSchemaName,
+		Version: diffJSONSchemaVersion,
+		Section: section,
+		Files:   []diffJSONFile{},
+	}
+	if doc == nil {
+		return out
+	}
+	for _, file := range doc.Files {
+		if file == nil {
+			continue
+		}
+		out.Files = append(out.Files, buildDiffJSONFile(file, includeIntraline))
+	}
+	return out
+}
+
+func buildDiffJSONFile(file *DiffFile, includeIntraline bool) diffJSONFile {
+	out := diffJSONFile{
+		Path:      file.DisplayPath,
+		OldPath:   file.OldPath,
+		NewPath:   file.NewPath,
+		Change:    diffFileChangeType(file),
+		Binary:    file.IsBinary,
+		Additions: file.Additions,
+		Deletions: file.Deletions,
+		Headers:   append([]string{}, file.Headers...),
+		Hunks:     make([]diffJSONHunk, 0, len(file.Hunks)),
+	}
+	for _, hunk := range file.Hunks {
+		var intraline map[int][][2]int
+		if includeIntraline {
+			intraline = diffHunkIntralineRanges(hunk)
+		}
+		lines := make([]diffJSONLine, 0, len(hunk.Lines))
+		for idx, line := range hunk.Lines {
+			lines = append(lines, diffJSONLine{
+				Kind:      diffLineKindName(line.Kind),
+				Content:   line.Content,
+				OldLine:   line.OldLine,
+				NewLine:   line.NewLine,
+				Intraline: intraline[idx],
+			})
+		}
+		out.Hunks = append(out.Hunks, diffJSONHunk{
+			Header:   hunk.Header,
+			OldStart: hunk.OldStart,
+			OldCount: hunk.OldCount,
+			NewStart: hunk.NewStart,
+			NewCount: hunk.NewCount,
+			Lines:    lines,
+		})
+	}
+	return out
+}
+
+// diffFileChangeType classifies a file diff as "added", "deleted", "renamed",
+// "copied" or "modified" from its git headers and paths.
+func diffFileChangeType(file *DiffFile) string {
+	for _, header := range file.Headers {
+		switch {
+		case strings.HasPrefix(header, "new file mode"):
+			return "added"
+		case strings.HasPrefix(header, "deleted file mode"):
+			return "deleted"
+		case strings.HasPrefix(header, "copy from "):
+			return "copied"
+		case strings.HasPrefix(header, "rename from "):
+			return "renamed"
+		}
+	}
+	switch {
+	case file.OldPath == "" && file.NewPath != "":
+		return "added"
+	case file.NewPath == "" && file.OldPath != "":
+		return "deleted"
+	case file.OldPath != file.NewPath:
+		return "renamed"
+	}
+	return "modified"
+}
+
+func diffLineKindName(kind DiffLineKind) string {
+	switch kind {
+	case DiffLineAdd:
+		return "add"
+	case DiffLineRemove:
+		return "remove"
+	case DiffLineMeta:
+		return "meta"
+	default:
+		return "context"
+	}
+}
+
+// diffHunkIntralineRanges pairs removed and added lines the way the diff view
+// does and returns the changed byte ranges of each paired line, keyed by its
+// index in the hunk.
+func diffHunkIntralineRanges(hunk DiffHunk) map[int][][2]int {
+	ranges := map[int][][2]int{}
+	for idx := 0; idx < len(hunk.Lines); {
+		if hunk.Lines[idx].Kind != DiffLineRemove {
+			idx++
+			continue
+		}
+		removeStart := idx
+		for idx < len(hunk.Lines) && hunk.Lines[idx].Kind == DiffLineRemove {
+			idx++
+		}
+		addStart := idx
+		for idx < len(hunk.Lines) && hunk.Lines[idx].Kind == DiffLineAdd {
+			idx++
+		}
+		removes := make([]RenderedDiffLine, 0, addStart-removeStart)
+		for _, line := range hunk.Lines[removeStart:addStart] {
+			removes = append(removes, plainRenderedLine(line.Content))
+		}
+		adds := make([]RenderedDiffLine, 0, idx-addStart)
+		for _, line := range hunk.Lines[addStart:idx] {
+			adds = append(adds, plainRenderedLine(line.Content))
+		}
+		for _, pair := range pairChangeBlock(removes, adds) {
+			if changed := intralineMaskByteRanges(hunk.Lines[removeStart+pair.Remove].Content, pair.RemoveMask); len(changed) > 0 {
+				ranges[removeStart+pair.Remove] = changed
+			}
+			if changed := intralineMaskByteRanges(hunk.Lines[addStart+pair.Add].Content, pair.AddMask); len(changed) > 0 {
+				ranges[addStart+pair.Add] = changed
+			}
+		}
+	}
+	return ranges
+}
+
+// plainRenderedLine wraps raw content without tab expansion, so intraline
+// masks line up with the graphemes of the original text.
+func plainRenderedLine(content string) RenderedDiffLine {
+	return RenderedDiffLine{Segments: []RenderedSegment{{Text: content}}}
+}
+
+// intralineMaskByteRanges converts a per-grapheme mask of text into merged
+// [start, end) byte ranges.
+func intralineMaskByteRanges(text string, mask []bool) [][2]int {
+	var ranges [][2]int
+	offset := 0
+	for idx, grapheme := range splitGraphemes(text) {
+		end := offset + len(grapheme)
+		if idx < len(mask) && mask[idx] {
+			if last := len(ranges) - 1; last >= 0 && ranges[last][1] == offset {
+				ranges[last][1] = end
+			} else {
+				ranges = append(ranges, [2]int{offset, end})
+			}
+		}
+		offset = end
+	}
+	return ranges
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDiffJSON_EmitsVersionedDocument(t *testing.T) {
+	diff := strings.Join([]string{
+		"diff --git a/main.go b/main.go",
+		"index 1111111..2222222 100644",
+		"--- a/main.go",
+		"+++ b/main.go",
+		"@@ -1,3 +1,3 @@ func main() {",
+		" package main",
+		"-value := 1",
+		"+value := 2",
+		" done",
+		"diff --git a/new.txt b/new.txt",
+		"new file mode 100644",
+		"--- /dev/null",
+		"+++ b/new.txt",
+		"@@ -0,0 +1 @@",
+		"+hello",
+		"",
+	}, "\n")
+	provider := &scriptedDiffProvider{diffs: []string{diff}}
+
+	var out strings.Builder
+	require.NoError(t, writeDiffJSON(&out, provider, false, false, false))
+
+	var doc diffJSONDocument
+	require.NoError(t, json.Unmarshal([]byte(out.String()), &doc))
+	require.Equal(t, "dv.diff", doc.Schema)
+	require.Equal(t, diffJSONSchemaVersion, doc.Version)
+	require.Equal(t, DiffSectionUnstaged, doc.Section)
+	require.Len(t, doc.Files, 2)
+
+	modified := doc.Files[0]
+	require.Equal(t, "main.go", modified.Path)
+	require.Equal(t, "modified", modified.Change)
+	require.Equal(t, 1, modified.Additions)
+	require.Equal(t, 1, modified.Deletions)
+	require.Len(t, modified.Hunks, 1)
+	hunk := modified.Hunks[0]
+	require.Equal(t, "@@ -1,3 +1,3 @@ func main() {", hunk.Header)
+	require.Equal(t, []int{1, 3, 1, 3}, []int{hunk.OldStart, hunk.OldCount, hunk.NewStart, hunk.NewCount})
+	require.Equal(t, []diffJSONLine{
+		{Kind: "context", Content: "package main", OldLine: 1, NewLine: 1},
+		{Kind: "remove", Content: "value := 1", OldLine: 2},
+		{Kind: "add", Content: "value := 2", NewLine: 2},
+		{Kind: "context", Content: "done", OldLine: 3, NewLine: 3},
+	}, hunk.Lines)
+	require.NotContains(t, out.String(), `"intraline"`)
+
+	require.Equal(t, "added", doc.Files[1].Change)
+	require.Equal(t, "new.txt", doc.Files[1].Path)
+}
+
+func TestWriteDiffJSON_IncludesIntralineByteRanges(t *testing.T) {
+	diff := "diff --git a/a.md b/a.md\n--- a/a.md\n+++ b/a.md\n@@ -1 +1 @@\n-café = 1\n+café = 22\n"
+	provider := &scriptedDiffProvider{diffs: []string{diff}}
+
+	var out strings.Builder
+	require.NoError(t, writeDiffJSON(&out, provider, false, false, true))
+
+	var doc diffJSONDocument
+	require.NoError(t, json.Unmarshal([]byte(out.String()), &doc))
+	lines := doc.Files[0].Hunks[0].Lines
+	require.Equal(t, [][2]int{{8, 9}}, lines[0].Intraline)
+	require.Equal(t, [][2]int{{8, 10}}, lines[1].Intraline)
+	require.Equal(t, "22", lines[1].Content[8:10])
+}
+
+func TestWriteDiffJSON_UsesStagedSectionWhenRequested(t *testing.T) {
+	provider := &scriptedDiffProvider{
+		unstagedDiffs: []string{diffForPaths("unstaged.go")},
+		stagedDiffs:   []string{diffForPaths("staged.go")},
+	}
+
+	var out strings.Builder
+	require.NoError(t, writeDiffJSON(&out, provider, true, false, false))
+
+	var doc diffJSONDocument
+	require.NoError(t, json.Unmarshal([]byte(out.String()), &doc))
+	require.Equal(t, DiffSectionStaged, doc.Section)
+	require.Len(t, doc.Files, 1)
+	require.Equal(t, "staged.go", doc.Files[0].Path)
+}
+
+func TestDiffFileChangeType(t *testing.T) {
+	require.Equal(t, "deleted", diffFileChangeType(&DiffFile{OldPath: "a", Headers: []string{"deleted file mode 100644"}}))
+	require.Equal(t, "renamed", diffFileChangeType(&DiffFile{OldPath: "a", NewPath: "b", Headers: []string{"rename from a", "rename to b"}}))
+	require.Equal(t, "copied", diffFileChangeType(&DiffFile{OldPath: "a", NewPath: "b", Headers: []string{"copy from a", "copy to b"}}))
+	require.Equal(t, "modified", diffFileChangeType(&DiffFile{OldPath: "a", NewPath: "a"}))
+}
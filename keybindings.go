@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Action IDs are the names used in the `keys:` config section.
+const (
+	keyActionNextFile               = "next-file"
+	keyActionPrevFile               = "prev-file"
+	keyActionJumpDown               = "jump-down"
+	keyActionJumpUp                 = "jump-up"
+	keyActionFilterFiles            = "filter-files"
+	keyActionToggleSidebar          = "toggle-sidebar"
+	keyActionClearFilter            = "clear-filter"
+	keyActionRefresh                = "refresh"
+	keyActionSwitchSection          = "switch-section"
+	keyActionCopyPath               = "copy-path"
+	keyActionToggleWrap             = "toggle-wrap"
+	keyActionToggleSplit            = "toggle-split"
+	keyActionShiftSplitLeft         = "shift-split-left"
+	keyActionShiftSplitRight        = "shift-split-right"
+	keyActionToggleIntralineStyle   = "toggle-intraline-style"
+	keyActionToggleWordDiff         = "toggle-word-diff"
+	keyActionToggleWhitespace       = "toggle-whitespace"
+	keyActionRenderAnyway           = "render-anyway"
+	keyActionToggleSeen             = "toggle-seen"
+	keyActionToggleHunkSeen         = "toggle-hunk-seen"
+	keyActionClearSeen              = "clear-seen"
+	keyActionComment                = "comment"
+	keyActionFocusDivider           = "focus-divider"
+	keyActionCommandPalette         = "command-palette"
+	keyActionThemeMenu              = "theme-menu"
+	keyActionQuit                   = "quit"
+	keyActionToggleIgnoreWhitespace = "toggle-ignore-whitespace"
+	keyActionSortFiles              = "sort-files"
+)
+
+// keyAction is an app-level action that can be rebound. Name is what the
+// keybind bar and actionHint show. HiddenKeys are default alternatives that
+// work but are left out of the keybind bar.
+type keyAction struct {
+	ID         string
+	Name       string
+	Keys       []string
+	HiddenKeys []string
+}
+
+var keyActions = []keyAction{
+	{ID: keyActionNextFile, Name: "Next file", Keys: []string{"n", "]"}, HiddenKeys: []string{"ctrl+j"}},
+	{ID: keyActionPrevFile, Name: "Prev file", Keys: []string{"p", "["}, HiddenKeys: []string{"ctrl+k"}},
+	{ID: keyActionJumpDown, Name: "Jump down 10", Keys: []string{"J"}},
+	{ID: keyActionJumpUp, Name: "Jump up 10", Keys: []string{"K"}},
+	{ID: keyActionFilterFiles, Name: "Filter files", Keys: []string{"/"}},
+	{ID: keyActionToggleSidebar, Name: "Toggle sidebar", Keys: []string{"b"}},
+	{ID: keyActionClearFilter, Name: "Clear filter", Keys: []string{"escape"}},
+	{ID: keyActionRefresh, Name: "Refresh", Keys: []string{"r"}},
+	{ID: keyActionSwitchSection, Name: "Switch section", Keys: []string{"s"}},
+	{ID: keyActionCopyPath, Name: "Copy path", Keys: []string{"y"}},
+	{ID: keyActionToggleWrap, Name: "Toggle line wrap", Keys: []string{"w"}},
+	{ID: keyActionToggleSplit, Name: "Toggle split", Keys: []string{"v"}},
+	{ID: keyActionShiftSplitLeft, Name: "Shift split left", Keys: []string{"ctrl+h"}},
+	{ID: keyActionShiftSplitRight, Name: "Shift split right", Keys: []string{"ctrl+l"}},
+	{ID: keyActionToggleIntralineStyle, Name: "Toggle intraline style", Keys: []string{"i"}},
+	{ID: keyActionToggleWordDiff, Name: "Toggle word diff", Keys: []string{"u"}},
+	{ID: keyActionToggleWhitespace, Name: "Toggle whitespace", Keys: []string{"W"}},
+	{ID: keyActionToggleSeen, Name: "Toggle seen", Keys: []string{"m"}},
+	{ID: keyActionToggleHunkSeen, Name: "Toggle hunk seen", Keys: []string{"H"}},
+	{ID: keyActionClearSeen, Name: "Clear all seen", Keys: []string{"M"}},
+	{ID: keyActionComment, Name: "Comment", Keys: []string{"c"}},
+	{ID: keyActionFocusDivider, Name: "Focus divider", Keys: []string{"d"}},
+	{ID: keyActionCommandPalette, Name: "Command palette", Keys: []string{"ctrl+p"}},
+	{ID: keyActionThemeMenu, Name: "Theme menu", Keys: []string{"t"}},
+	{ID: keyActionQuit, Name: "Quit", Keys: []string{"q"}},
+	{ID: keyActionToggleIgnoreWhitespace, Name: "Toggle ignore whitespace", Keys: []string{"x"}},
+	{ID: keyActionSortFiles, Name: "Sort files", Keys: []string{"o"}},
+	{ID: keyActionRenderAnyway, Name: "Render anyway", Keys: []string{"R"}},
+}
+
+// widgetKeymap is the keys a widget handles itself while it has focus, before
+// app-level bindings see them.
+type widgetKeymap struct {
+	Name string
+	Keys []string
+}
+
+// widgetKeymaps are the built-in keys of the widgets dv is made of. A key
+// bound to an action here would never reach it while that widget has focus.
+var widgetKeymaps = []widgetKeymap{
+	{Name: "file tree", Keys: []string{"enter", "up", "k", "down", "j", "home", "g", "end", "G", "left", "h", "right", "l", "space", " "}},
+	{Name: "diff view", Keys: []string{"up", "k", "down", "j", "pgup", "pageup", "ctrl+u", "pgdown", "pagedown", "ctrl+d", "home", "g", "end", "G", "left", "h", "right", "l"}},
+	{Name: "command palette", Keys: []string{"up", "down", "ctrl+p", "ctrl+n", "home", "end", "enter", "escape", "backspace"}},
+}
+
+// widgetKeymapUsing returns the first widget keymap that handles key.
+func widgetKeymapUsing(key string) (widgetKeymap, bool) {
+	for _, keymap := range widgetKeymaps {
+		if slices.Contains(keymap.Keys, key) {
+			return keymap, true
+		}
+	}
+	return widgetKeymap{}, false
+}
+
+// keyList is one or more keys. In YAML it may be a single string or a list.
+type keyList []string
+
+func (l *keyList) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		var key string
+		if err := node.Decode(&key); err != nil {
+			return err
+		}
+		*l = keyList{key}
+		return nil
+	case yaml.SequenceNode:
+		var keys []string
+		if err := node.Decode(&keys); err != nil {
+			return err
+		}
+		*l = keyList(keys)
+		return nil
+	default:
+		return fmt.Errorf("line %d: expected a key or a list of keys", node.Line)
+	}
+}
+
+// keyBinding is the resolved set of keys for one action.
+type keyBinding struct {
+	Keys       []string
+	HiddenKeys []string
+}
+
+// KeyBindings maps action IDs to their keys.
+type KeyBindings map[string]keyBinding
+
+func defaultKeyBindings() KeyBindings {
+	bindings := make(KeyBindings, len(keyActions))
+	for _, action := range keyActions {
+		bindings[action.ID] = keyBinding{Keys: action.Keys, HiddenKeys: action.HiddenKeys}
+	}
+	return bindings
+}
+
+// resolveKeyBindings applies config overrides to the defaults. An override
+// replaces every default key of its action, and an empty list unbinds it.
+// Unknown actions, keys bound to more than one action and keys that a widget
+// already handles are errors. A default key kept by its own action is allowed
+// to overlap a widget, as command-palette's ctrl+p does.
+//
+// sources maps "keys.<action>" to the config file that set it, as returned by
+// mergeStartupConfigLayers, so that errors can say where each action involved
+// was bound. It may be nil.
+func resolveKeyBindings(overrides map[string]keyList, sources map[string]string) (KeyBindings, error) {
+	bindings := defaultKeyBindings()
+	describe := func(id string) string {
+		if sources == nil {
+			return strconv.Quote(id)
+		}
+		if source := sources[configKeyKeys+"."+id]; source != "" {
+			return fmt.Sprintf("%q (in %s)", id, source)
+		}
+		return fmt.Sprintf("%q (default)", id)
+	}
+
+	ids := make([]string, 0, len(overrides))
+	for id := range overrides {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if _, ok := bindings[id]; !ok {
+			return nil, fmt.Errorf("unknown action %s (available actions: %s)", describe(id), strings.Join(keyActionIDs(), ", "))
+		}
+		keys := make([]string, 0, len(overrides[id]))
+		for _, key := range overrides[id] {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				return nil, fmt.Errorf("empty key for action %s", describe(id))
+			}
+			if keymap, ok := widgetKeymapUsing(key); ok && !isDefaultKey(id, key) {
+				return nil, fmt.Errorf("key %q for %s is already used by the %s", key, describe(id), keymap.Name)
+			}
+			keys = append(keys, key)
+		}
+		bindings[id] = keyBinding{Keys: keys}
+	}
+
+	owners := map[string]string{}
+	for _, action := range keyActions {
+		binding := bindings[action.ID]
+		for _, key := range append(append([]string{}, binding.Keys...), binding.HiddenKeys...) {
+			if owner, ok := owners[key]; ok && owner != action.ID {
+				return nil, fmt.Errorf("key %q is bound to both %s and %s", key, describe(owner), describe(action.ID))
+			}
+			owners[key] = action.ID
+		}
+	}
+	return bindings, nil
+}
+
+func isDefaultKey(id string, key string) bool {
+	action, ok := keyActionByID(id)
+	return ok && (slices.Contains(action.Keys, key) || slices.Contains(action.HiddenKeys, key))
+}
+
+func keyActionIDs() []string {
+	ids := make([]string, 0, len(keyActions))
+	for _, action := range keyActions {
+		ids = append(ids, action.ID)
+	}
+	return ids
+}
+
+func keyActionByID(id string) (keyAction, bool) {
+	for _, action := range keyActions {
+		if action.ID == id {
+			return action, true
+		}
+	}
+	return keyAction{}, false
+}
+
+// firstKey is the key used in hints such as "[n]", or "" when the action is
+// unbound.
+func (b keyBinding) firstKey() string {
+	if len(b.Keys) > 0 {
+		return b.Keys[0]
+	}
+	if len(b.HiddenKeys) > 0 {
+		return b.HiddenKeys[0]
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveKeyBindings_DefaultsHaveNoConflicts(t *testing.T) {
+	bindings, err := resolveKeyBindings(nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, defaultKeyBindings(), bindings)
+	require.Len(t, bindings, len(keyActions))
+}
+
+func TestResolveKeyBindings_OverrideReplacesDefaults(t *testing.T) {
+	bindings, err := resolveKeyBindings(map[string]keyList{
+		keyActionNextFile: {"N", " ctrl+f "},
+		keyActionJumpDown: {},
+	}, nil)
+	require.NoError(t, err)
+	require.Equal(t, keyBinding{Keys: []string{"N", "ctrl+f"}}, bindings[keyActionNextFile])
+	require.Equal(t, keyBinding{Keys: []string{}}, bindings[keyActionJumpDown])
+	require.Equal(t, "", bindings[keyActionJumpDown].firstKey())
+	require.Equal(t, keyBinding{Keys: []string{"p", "["}, HiddenKeys: []string{"ctrl+k"}}, bindings[keyActionPrevFile])
+}
+
+func TestResolveKeyBindings_RejectsUnknownActionsAndConflicts(t *testing.T) {
+	_, err := resolveKeyBindings(map[string]keyList{"launch-rockets": {"z"}}, nil)
+	require.ErrorContains(t, err, `unknown action "launch-rockets"`)
+	require.ErrorContains(t, err, keyActionNextFile)
+
+	_, err = resolveKeyBindings(map[string]keyList{keyActionToggleSplit: {"n"}}, nil)
+	require.ErrorContains(t, err, `key "n" is bound to both "next-file" and "toggle-split"`)
+
+	_, err = resolveKeyBindings(map[string]keyList{keyActionNextFile: {"J"}, keyActionJumpDown: {"ctrl+f"}}, nil)
+	require.NoError(t, err)
+
+	_, err = resolveKeyBindings(map[string]keyList{keyActionQuit: {""}}, nil)
+	require.ErrorContains(t, err, `empty key for action "quit"`)
+}
+
+func TestResolveKeyBindings_RejectsKeysHandledByWidgets(t *testing.T) {
+	_, err := resolveKeyBindings(map[string]keyList{keyActionNextFile: {"j"}}, nil)
+	require.ErrorContains(t, err, `key "j" for "next-file" is already used by the file tree`)
+
+	_, err = resolveKeyBindings(map[string]keyList{keyActionJumpDown: {"ctrl+d"}}, nil)
+	require.ErrorContains(t, err, `key "ctrl+d" for "jump-down" is already used by the diff view`)
+
+	_, err = resolveKeyBindings(map[string]keyList{keyActionPrevFile: {"ctrl+n"}}, nil)
+	require.ErrorContains(t, err, `key "ctrl+n" for "prev-file" is already used by the command palette`)
+
+	// Defaults that overlap the palette on purpose can be kept.
+	_, err = resolveKeyBindings(map[string]keyList{keyActionCommandPalette: {"ctrl+p", "ctrl+o"}}, nil)
+	require.NoError(t, err)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Defaults for largeFileLimits.
+const (
+	defaultMaxFileLines = 5000
+	defaultMaxLineBytes = 4096
+	defaultMaxDiffBytes = 16 << 20
+)
+
+// largeFileLimits are the sizes above which a file is collapsed instead of
+// rendered. A zero limit is off.
+type largeFileLimits struct {
+	// MaxFileLines caps the hunk lines of one file.
+	MaxFileLines int
+	// MaxLineBytes caps the length of any one hunk line.
+	MaxLineBytes int
+	// MaxDiffBytes caps the hunk content rendered across the whole diff.
+	// Files past it are collapsed.
+	MaxDiffBytes int
+}
+
+// lockfileNames are dependency lockfiles, which are generated and rarely
+// worth reading line by line.
+var lockfileNames = map[string]bool{
+	"Cargo.lock":          true,
+	"Gemfile.lock":        true,
+	"Package.resolved":    true,
+	"Pipfile.lock":        true,
+	"Podfile.lock":        true,
+	"bun.lock":            true,
+	"composer.lock":       true,
+	"deno.lock":           true,
+	"flake.lock":          true,
+	"go.sum":              true,
+	"gradle.lockfile":     true,
+	"mix.lock":            true,
+	"npm-shrinkwrap.json": true,
+	"package-lock.json":   true,
+	"packages.lock.json":  true,
+	"pdm.lock":            true,
+	"pnpm-lock.yaml":      true,
+	"poetry.lock":         true,
+	"pubspec.lock":        true,
+	"uv.lock":             true,
+	"yarn.lock":           true,
+}
+
+// pathAttributes are the .gitattributes of a path that collapse it.
+type pathAttributes struct {
+	// Generated is set by linguist-generated.
+	Generated bool
+	// NoDiff is set by -diff.
+	NoDiff bool
+}
+
+// diffFileSize returns the number of hunk lines in file, their total length
+// in bytes and the length of the longest.
+func diffFileSize(file *DiffFile) (lines int, bytes int, longest int) {
+	for _, hunk := range file.Hunks {
+		lines += len(hunk.Lines)
+		for _, line := range hunk.Lines {
+			bytes += len(line.Content)
+			longest = max(longest, len(line.Content))
+		}
+	}
+	return lines, bytes, longest
+}
+
+// largeFileCollapseReason returns why file should be collapsed, or "" when it
+// can be rendered. diffBytes is the hunk content of the files rendered before
+// it, counted against limits.MaxDiffBytes.
+func largeFileCollapseReason(file *DiffFile, attrs pathAttributes, limits largeFileLimits, diffBytes int) string {
+	switch {
+	case attrs.Generated:
+		return "generated file (linguist-generated in .gitattributes)"
+	case attrs.NoDiff:
+		return "diff turned off by -diff in .gitattributes"
+	case isLockfilePath(file.DisplayPath):
+		return "lockfile"
+	}
+
+	lines, bytes, longest := diffFileSize(file)
+	switch {
+	case limits.MaxFileLines > 0 && lines > limits.MaxFileLines:
+		return fmt.Sprintf("%d lines, over the --%s limit of %d", lines, flagNameMaxFileLines, limits.MaxFileLines)
+	case limits.MaxLineBytes > 0 && longest > limits.MaxLineBytes:
+		return fmt.Sprintf("a %d-byte line, over the --%s limit of %d", longest, flagNameMaxLineBytes, limits.MaxLineBytes)
+	case limits.MaxDiffBytes > 0 && diffBytes+bytes > limits.MaxDiffBytes:
+		return fmt.Sprintf("the diff is over the --%s limit of %d", flagNameMaxDiffBytes, limits.MaxDiffBytes)
+	}
+	return ""
+}
+
+func isLockfilePath(filePath string) bool {
+	return lockfileNames[path.Base(filePath)]
+}
+
+// buildCollapsedRenderedFile stands in for a file that is too large or too
+// generated to render, saying why and how to render it anyway.
+func buildCollapsedRenderedFile(file *DiffFile, reason string, renderHint string) *RenderedFile {
+	summary := fmt.Sprintf("%d hunks not shown.", len(file.Hunks))
+	if len(file.Hunks) == 1 {
+		summary = "1 hunk not shown."
+	}
+	if addText, delText := nonZeroChangeTexts(file.Additions, file.Deletions); addText != "" || delText != "" {
+		summary = strings.TrimSpace(addText+" "+delText) + " in " + summary
+	}
+	return buildMetaRenderedFile(file.DisplayPath, []string{
+		"Collapsed: " + reason + ".",
+		summary,
+		renderHint,
+	})
+}
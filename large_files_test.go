@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func largeTestFile(path string, lines int, lineLength int) *DiffFile {
+	hunk := DiffHunk{Header: "@@ -0,0 +1 @@"}
+	for idx := range lines {
+		hunk.Lines = append(hunk.Lines, DiffLine{Kind: DiffLineAdd, Content: strings.Repeat("x", lineLength), NewLine: idx + 1})
+	}
+	return &DiffFile{DisplayPath: path, NewPath: path, Additions: lines, Hunks: []DiffHunk{hunk}}
+}
+
+func TestDiffFileSize(t *testing.T) {
+	file := largeTestFile("a.txt", 3, 10)
+	file.Hunks[0].Lines[1].Content = "short"
+
+	lines, bytes, longest := diffFileSize(file)
+	require.Equal(t, 3, lines)
+	require.Equal(t, 25, bytes)
+	require.Equal(t, 10, longest)
+}
+
+func TestLargeFileCollapseReason(t *testing.T) {
+	limits := largeFileLimits{MaxFileLines: 10, MaxLineBytes: 100, MaxDiffBytes: 1000}
+
+	require.Empty(t, largeFileCollapseReason(largeTestFile("main.go", 10, 100), pathAttributes{}, limits, 0))
+	require.Equal(t, "11 lines, over the --max-file-lines limit of 10", largeFileCollapseReason(largeTestFile("main.go", 11, 1), pathAttributes{}, limits, 0))
+	require.Equal(t, "a 101-byte line, over the --max-line-bytes limit of 100", largeFileCollapseReason(largeTestFile("app.min.js", 1, 101), pathAttributes{}, limits, 0))
+	require.Equal(t, "the diff is over the --max-diff-bytes limit of 1000", largeFileCollapseReason(largeTestFile("main.go", 2, 10), pathAttributes{}, limits, 990))
+	require.Equal(t, "lockfile", largeFileCollapseReason(largeTestFile("web/package-lock.json", 1, 1), pathAttributes{}, limits, 0))
+	require.Equal(t, "generated file (linguist-generated in .gitattributes)", largeFileCollapseReason(largeTestFile("gen.go", 1, 1), pathAttributes{Generated: true}, limits, 0))
+	require.Equal(t, "diff turned off by -diff in .gitattributes", largeFileCollapseReason(largeTestFile("logo.svg", 1, 1), pathAttributes{NoDiff: true}, limits, 0))
+}
+
+func TestLargeFileCollapseReason_ZeroLimitsAreOff(t *testing.T) {
+	require.Empty(t, largeFileCollapseReason(largeTestFile("main.go", 50, 5000), pathAttributes{}, largeFileLimits{}, 1<<30))
+}
+
+func TestBuildCollapsedRenderedFile(t *testing.T) {
+	file := largeTestFile("yarn.lock", 3, 1)
+	file.Deletions = 2
+
+	rendered := buildCollapsedRenderedFile(file, "lockfile", "Press R to render it anyway.")
+	texts := make([]string, 0, len(rendered.Lines))
+	for _, line := range rendered.Lines {
+		texts = append(texts, lineText(line))
+	}
+	require.Contains(t, texts, "Collapsed: lockfile.")
+	require.Contains(t, texts, "+3 -2 in 1 hunk not shown.")
+	require.Contains(t, texts, "Press R to render it anyway.")
+}
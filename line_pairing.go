@@ -0,0 +1,166 @@
+package main
+
+// changeBlockMaxAlignedPairs caps how many removed/added line combinations a
+// change block compares when pairing by similarity. Bigger blocks pair lines
+// by position instead.
+const changeBlockMaxAlignedPairs = 4096
+
+// changeLinePair is a removed and an added line of a change block that are
+// edits of each other, with the graphemes that changed in each.
+type changeLinePair struct {
+	Remove     int
+	Add        int
+	RemoveMask []bool
+	AddMask    []bool
+	Similarity float64
+}
+
+// changeBlockRow is one side-by-side row of a change block, holding indexes
+// into its removed and added lines. A side is -1 when it has no line.
+type changeBlockRow struct {
+	Remove int
+	Add    int
+}
+
+// pairChangeBlock pairs the removed and added lines of a change block. Pairs
+// keep the order of both sides and are chosen to keep as much text unchanged
+// as possible, so a line inserted above an edited line doesn't shift every
+// later line onto the wrong partner. Moved lines never pair.
+func pairChangeBlock(removes []RenderedDiffLine, adds []RenderedDiffLine) []changeLinePair {
+	if len(removes) == 0 || len(adds) == 0 {
+		return nil
+	}
+	if len(removes)*len(adds) > changeBlockMaxAlignedPairs {
+		return pairChangeBlockByPosition(removes, adds)
+	}
+
+	candidates := make([][]*changeLinePair, len(removes))
+	for removeIdx, removeLine := range removes {
+		candidates[removeIdx] = make([]*changeLinePair, len(adds))
+		if removeLine.Move != nil {
+			continue
+		}
+		for addIdx, addLine := range adds {
+			if addLine.Move != nil {
+				continue
+			}
+			if pair, ok := newChangeLinePair(removes, adds, removeIdx, addIdx); ok {
+				candidates[removeIdx][addIdx] = &pair
+			}
+		}
+	}
+
+	// score[r][a] is the best total similarity of removes[r:] paired with
+	// adds[a:].
+	score := make([][]float64, len(removes)+1)
+	for row := range score {
+		score[row] = make([]float64, len(adds)+1)
+	}
+	for removeIdx := len(removes) - 1; removeIdx >= 0; removeIdx-- {
+		for addIdx := len(adds) - 1; addIdx >= 0; addIdx-- {
+			best := max(score[removeIdx+1][addIdx], score[removeIdx][addIdx+1])
+			if pair := candidates[removeIdx][addIdx]; pair != nil {
+				best = max(best, score[removeIdx+1][addIdx+1]+pair.Similarity)
+			}
+			score[removeIdx][addIdx] = best
+		}
+	}
+
+	var pairs []changeLinePair
+	for removeIdx, addIdx := 0, 0; removeIdx < len(removes) && addIdx < len(adds); {
+		pair := candidates[removeIdx][addIdx]
+		switch {
+		case pair != nil && score[removeIdx][addIdx] == score[removeIdx+1][addIdx+1]+pair.Similarity:
+			pairs = append(pairs, *pair)
+			removeIdx++
+			addIdx++
+		case score[removeIdx+1][addIdx] >= score[removeIdx][addIdx+1]:
+			removeIdx++
+		default:
+			addIdx++
+		}
+	}
+	return pairs
+}
+
+// pairChangeBlockByPosition pairs the unmoved lines of a change block in
+// order, stopping at the first pair that isn't an edit.
+func pairChangeBlockByPosition(removes []RenderedDiffLine, adds []RenderedDiffLine) []changeLinePair {
+	unmoved := func(lines []RenderedDiffLine) []int {
+		indexes := make([]int, 0, len(lines))
+		for idx, line := range lines {
+			if line.Move == nil {
+				indexes = append(indexes, idx)
+			}
+		}
+		return indexes
+	}
+	removeIndexes, addIndexes := unmoved(removes), unmoved(adds)
+
+	var pairs []changeLinePair
+	for idx := range min(len(removeIndexes), len(addIndexes)) {
+		pair, ok := newChangeLinePair(removes, adds, removeIndexes[idx], addIndexes[idx])
+		if !ok {
+			break
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
+
+func newChangeLinePair(removes []RenderedDiffLine, adds []RenderedDiffLine, removeIdx int, addIdx int) (changeLinePair, bool) {
+	removeMask, addMask, ok := intralinePairMasks(removes[removeIdx], adds[addIdx])
+	if !ok {
+		return changeLinePair{}, false
+	}
+	removeChanged, removeTotal := maskStats(removeMask)
+	addChanged, addTotal := maskStats(addMask)
+	similarity := 1.0
+	if total := removeTotal + addTotal; total > 0 {
+		similarity = float64(total-removeChanged-addChanged) / float64(total)
+	}
+	return changeLinePair{
+		Remove:     removeIdx,
+		Add:        addIdx,
+		RemoveMask: removeMask,
+		AddMask:    addMask,
+		Similarity: similarity,
+	}, true
+}
+
+// markChangeLinePairs applies the intraline masks of pairs to their lines.
+func markChangeLinePairs(removes []RenderedDiffLine, adds []RenderedDiffLine, pairs []changeLinePair) {
+	for _, pair := range pairs {
+		removes[pair.Remove] = markLineIntraline(removes[pair.Remove], pair.RemoveMask, IntralineMarkRemove)
+		adds[pair.Add] = markLineIntraline(adds[pair.Add], pair.AddMask, IntralineMarkAdd)
+	}
+}
+
+// changeBlockRows lays out a change block side by side. Paired lines sit
+// opposite each other; the unpaired lines between pairs fill rows in order,
+// with filler cells on the shorter side.
+func changeBlockRows(removeCount int, addCount int, pairs []changeLinePair) []changeBlockRow {
+	rows := make([]changeBlockRow, 0, max(removeCount, addCount))
+	removeIdx, addIdx := 0, 0
+	fill := func(removeEnd int, addEnd int) {
+		for removeIdx < removeEnd || addIdx < addEnd {
+			row := changeBlockRow{Remove: -1, Add: -1}
+			if removeIdx < removeEnd {
+				row.Remove = removeIdx
+				removeIdx++
+			}
+			if addIdx < addEnd {
+				row.Add = addIdx
+				addIdx++
+			}
+			rows = append(rows, row)
+		}
+	}
+	for _, pair := range pairs {
+		fill(pair.Remove, pair.Add)
+		rows = append(rows, changeBlockRow{Remove: pair.Remove, Add: pair.Add})
+		removeIdx, addIdx = pair.Remove+1, pair.Add+1
+	}
+	fill(removeCount, addCount)
+	return rows
+}
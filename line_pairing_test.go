@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeBlockRows_FillsBetweenPairs(t *testing.T) {
+	pairs := []changeLinePair{{Remove: 0, Add: 2}, {Remove: 3, Add: 3}}
+
+	require.Equal(t, []changeBlockRow{
+		{Remove: -1, Add: 0},
+		{Remove: -1, Add: 1},
+		{Remove: 0, Add: 2},
+		{Remove: 1, Add: -1},
+		{Remove: 2, Add: -1},
+		{Remove: 3, Add: 3},
+		{Remove: 4, Add: 4},
+	}, changeBlockRows(5, 5, pairs))
+}
+
+func TestChangeBlockRows_ZipsUnpairedLines(t *testing.T) {
+	require.Equal(t, []changeBlockRow{
+		{Remove: 0, Add: 0},
+		{Remove: 1, Add: -1},
+	}, changeBlockRows(2, 1, nil))
+}
+
+func TestPairChangeBlock_SkipsMovedLines(t *testing.T) {
+	removes := []RenderedDiffLine{
+		{Kind: RenderedLineMovedRemove, Segments: []RenderedSegment{{Text: "return valueA"}}, Move: &RenderedLineMove{}},
+		{Kind: RenderedLineRemove, Segments: []RenderedSegment{{Text: "return valueA"}}},
+	}
+	adds := []RenderedDiffLine{
+		{Kind: RenderedLineAdd, Segments: []RenderedSegment{{Text: "return valueB"}}},
+	}
+
+	pairs := pairChangeBlock(removes, adds)
+	require.Len(t, pairs, 1)
+	require.Equal(t, 1, pairs[0].Remove)
+	require.Equal(t, 0, pairs[0].Add)
+}
+
+func TestPairChangeBlock_LargeBlocksPairByPosition(t *testing.T) {
+	line := func(text string) RenderedDiffLine {
+		return RenderedDiffLine{Segments: []RenderedSegment{{Text: text}}}
+	}
+	removes := make([]RenderedDiffLine, 0, 65)
+	adds := make([]RenderedDiffLine, 0, 65)
+	removes = append(removes, line("return valueA"))
+	adds = append(adds, line("completely unrelated text"))
+	for range 64 {
+		removes = append(removes, line("return valueA"))
+		adds = append(adds, line("return valueB"))
+	}
+
+	require.Empty(t, pairChangeBlock(removes, adds))
+}
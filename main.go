@@ -9,6 +9,7 @@ import (
 
 	"github.com/adrg/xdg"
 	uv "github.com/charmbracelet/ultraviolet"
+	"github.com/charmbracelet/x/term"
 	t "github.com/darrenburns/terma"
 )
 
@@ -29,21 +30,51 @@ func main() {
 	var sidebarVisible bool
 	var themeName string
 	var intralineStyle string
+	var palette string
+	var colorMode string
 	var showSymbols bool
 	var ignoreWhitespace bool
+	var wordDiff bool
+	var showWhitespace bool
+	var maxFileLines int
+	var maxLineBytes int
+	var maxDiffBytes int
+	var textconv bool
+	var persistUIState bool
 	var configPath string
 	var noConfig bool
+	var printMode bool
+	var printWidth int
+	var exportHTMLPath string
+	var jsonMode bool
+	var jsonIntraline bool
+	var statMode bool
 
 	flag.BoolVar(&staged, "staged", false, "start focused on staged changes")
 	flag.BoolVar(&showVersion, "version", false, "print version and exit")
 	flag.StringVar(&viewMode, "view", "unified", "default view mode: unified or split")
 	flag.BoolVar(&sidebarVisible, "sidebar", true, "show sidebar on startup")
-	flag.StringVar(&themeName, "theme", t.ThemeNameObsidianTide, "default theme")
+	flag.StringVar(&themeName, "theme", t.ThemeNameObsidianTide, "default theme, or auto to pick theme-light or theme-dark from the terminal background")
 	flag.StringVar(&intralineStyle, "intraline-style", "background", "default intraline style: background, underline, or off")
+	flag.StringVar(&palette, "palette", "default", "add/remove colours: default, deuteranopia, protanopia, tritanopia, or high-contrast")
+	flag.StringVar(&colorMode, "color", "auto", "colour depth: auto, truecolor, 256, or 16")
 	flag.BoolVar(&showSymbols, "show-symbols", false, "show +/- symbols by default")
 	flag.BoolVar(&ignoreWhitespace, "ignore-whitespace", false, "ignore whitespace-only changes by default")
+	flag.BoolVar(&wordDiff, "word-diff", false, "show changed lines as one line with [-removed-]{+added+} words")
+	flag.BoolVar(&showWhitespace, "show-whitespace", false, "draw tabs, trailing spaces and CRLF line endings as visible glyphs")
+	flag.IntVar(&maxFileLines, "max-file-lines", defaultMaxFileLines, "collapse files with more diff lines than this (0 for no limit)")
+	flag.IntVar(&maxLineBytes, "max-line-bytes", defaultMaxLineBytes, "collapse files with a diff line longer than this many bytes (0 for no limit)")
+	flag.IntVar(&maxDiffBytes, "max-diff-bytes", defaultMaxDiffBytes, "collapse files once the diff content rendered passes this many bytes (0 for no limit)")
+	flag.BoolVar(&textconv, "textconv", false, "run .gitattributes textconv filters, with the diff-drivers from the config, so converted binary files show as text")
+	flag.BoolVar(&persistUIState, "persist-ui-state", false, "remember the layout and picked theme between runs in this repo")
 	flag.StringVar(&configPath, "config", "", "path to YAML config file")
 	flag.BoolVar(&noConfig, "no-config", false, "disable config file loading")
+	flag.BoolVar(&printMode, "print", false, "print the diff to stdout as ANSI instead of opening the viewer (default when stdout is not a terminal or dv is git's pager)")
+	flag.IntVar(&printWidth, "width", 0, "layout width for --print and --stat (default: terminal width, then $COLUMNS, then 120)")
+	flag.StringVar(&exportHTMLPath, "export-html", "", "write the diff as a standalone HTML file and exit")
+	flag.BoolVar(&jsonMode, "json", false, "print the parsed diff as JSON and exit")
+	flag.BoolVar(&jsonIntraline, "json-intraline", false, "include intraline change ranges in --json output")
+	flag.BoolVar(&statMode, "stat", false, "print a diffstat grouped by directory and exit")
 	flag.Parse()
 
 	explicitlySetFlags := map[string]bool{}
@@ -55,22 +86,67 @@ func main() {
 		fmt.Printf("dv %s (%s)\n", version, commit)
 		os.Exit(0)
 	}
+	if printWidth < 0 {
+		log.Fatalf("invalid --width value %d (expected a positive number of columns)", printWidth)
+	}
+	for name, limit := range map[string]int{flagNameMaxFileLines: maxFileLines, flagNameMaxLineBytes: maxLineBytes, flagNameMaxDiffBytes: maxDiffBytes} {
+		if limit < 0 {
+			log.Fatalf("invalid --%s value %d (expected 0 for no limit or a positive number)", name, limit)
+		}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	cfg, err := loadStartupConfig(xdg.ConfigHome, configPath, noConfig)
+	// Outside a repository there is no .dv.yaml to layer in.
+	repoRoot, _ := GitDiffProvider{WorkDir: cwd}.RepoRoot()
+	configLayers, themeStyles, err := loadStartupConfigLayers(xdg.ConfigHome, configPath, noConfig, repoRoot)
 	if err != nil {
 		log.Fatal(err)
 	}
+	cfg, configSources := mergeStartupConfigLayers(configLayers)
 
 	flagValues := startupFlagValues{
 		ViewMode:         viewMode,
 		SidebarVisible:   sidebarVisible,
 		ThemeName:        themeName,
+		ThemeLight:       defaultThemeLight,
+		ThemeDark:        defaultThemeDark,
 		IntralineStyle:   intralineStyle,
+		Palette:          palette,
+		Color:            colorMode,
 		ShowSymbols:      showSymbols,
 		IgnoreWhitespace: ignoreWhitespace,
+		PersistUIState:   persistUIState,
+		WordDiff:         wordDiff,
+		ShowWhitespace:   showWhitespace,
+		MaxFileLines:     maxFileLines,
+		MaxLineBytes:     maxLineBytes,
+		MaxDiffBytes:     maxDiffBytes,
+		Textconv:         textconv,
 	}
 	flagValues = applyStartupConfig(flagValues, cfg, explicitlySetFlags)
 
+	if args := flag.Args(); len(args) > 0 && args[0] == "config" {
+		if err := runConfigCommand(os.Stdout, args[1:], flagValues, cfg, configSources, explicitlySetFlags); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	stdoutTerminal := term.IsTerminal(os.Stdout.Fd())
+	if isAutoThemeName(flagValues.ThemeName) {
+		// Only ask the terminal when dv owns it; a pager reading the same
+		// terminal would swallow the reply.
+		var detect backgroundDetector
+		if stdoutTerminal {
+			detect = func() (bool, bool) { return queryTerminalBackground(terminalBackgroundTimeout) }
+		}
+		flagValues.ThemeName = resolveAutoTheme(detect, os.Getenv("COLORFGBG"), flagValues.ThemeLight, flagValues.ThemeDark)
+	}
+
 	initialState, err := startupInitialStateFromFlags(
 		flagValues.ViewMode,
 		flagValues.SidebarVisible,
@@ -82,32 +158,151 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	cwd, err := os.Getwd()
+	initialState.SeenStatePath = defaultSeenStatePath(xdg.StateHome)
+	initialState.WordDiff = flagValues.WordDiff
+	initialState.ShowWhitespace = flagValues.ShowWhitespace
+	initialState.LargeFileLimits = largeFileLimits{
+		MaxFileLines: flagValues.MaxFileLines,
+		MaxLineBytes: flagValues.MaxLineBytes,
+		MaxDiffBytes: flagValues.MaxDiffBytes,
+	}
+	if flagValues.PersistUIState {
+		initialState.UIStatePath = defaultUIStatePath(xdg.StateHome)
+	}
+	initialState.ThemeExplicit = themeSetExplicitly(cfg, explicitlySetFlags)
+	initialState.KeyBindings, err = resolveKeyBindings(cfg.Keys, configSources)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	stdinPiped, err := stdinIsPiped(os.Stdin)
+	initialState.PaletteVariant, err = parsePaletteVariant(flagValues.Palette)
 	if err != nil {
 		log.Fatal(err)
 	}
-	provider, closeTTY, err := startupDiffProvider(
-		cwd,
-		os.Stdin,
-		stdinPiped,
-		uv.OpenTTY,
-		func(file *os.File) { os.Stdin = file },
-	)
+	requestedColorProfile, err := parseColorProfile(flagValues.Color)
+	if err != nil {
+		log.Fatal(err)
+	}
+	colorProfile := resolveColorProfile(requestedColorProfile, os.Stdout, os.Environ())
+	syntaxOverrides, err := resolveSyntaxOverrides(cfg.SyntaxOverrides)
 	if err != nil {
 		log.Fatal(err)
 	}
+	initialState.PaletteOptions = paletteOptions{
+		Profile:         colorProfile,
+		ThemeStyles:     themeStyles,
+		SyntaxOverrides: syntaxOverrides,
+	}
+	diffTextconv := textconvOptions{Enabled: flagValues.Textconv, Drivers: cfg.DiffDrivers}
+
+	provider, handled, err := startupArgsDiffProvider(cwd, flag.Args(), diffTextconv)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stdinPiped, stdinErr := stdinIsPiped(os.Stdin)
+	printOutput := shouldPrintDiff(printMode, explicitlySetFlags["print"], stdoutTerminal, runningAsGitPager(stdinPiped, os.Getenv("GIT_PAGER_IN_USE")))
+
+	exportHTML := exportHTMLPath != ""
+	if (exportHTML || jsonMode || statMode || printOutput) && !handled {
+		if stdinErr != nil {
+			log.Fatal(stdinErr)
+		}
+		provider, err = printDiffProvider(cwd, os.Stdin, stdinPiped, diffTextconv)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handled = true
+	}
+	if exportHTML {
+		if err := NewDv(provider, staged, initialState).writeDiffHTML(exportHTMLPath); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if jsonMode {
+		if err := writeDiffJSON(os.Stdout, provider, staged, initialState.IgnoreWhitespace, jsonIntraline); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	terminalWidth := 0
+	if stdoutTerminal {
+		terminalWidth, _, _ = term.GetSize(os.Stdout.Fd())
+	}
+	if statMode {
+		width := resolvePrintWidth(printWidth, terminalWidth, os.Getenv("COLUMNS"))
+		if err := printDiffStat(colorProfileWriter(os.Stdout, colorProfile), provider, staged, initialState, width, stdoutTerminal); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if printOutput {
+		width := resolvePrintWidth(printWidth, terminalWidth, os.Getenv("COLUMNS"))
+		if err := printDiff(colorProfileWriter(os.Stdout, colorProfile), provider, staged, initialState, width); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	closeTTY := func() {}
+	if !handled {
+		if stdinErr != nil {
+			log.Fatal(stdinErr)
+		}
+		provider, closeTTY, err = startupDiffProvider(
+			cwd,
+			os.Stdin,
+			stdinPiped,
+			uv.OpenTTY,
+			func(file *os.File) { os.Stdin = file },
+			diffTextconv,
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 	defer closeTTY()
 
+	initialState.PaletteOptions.Profile = rendererColorProfile(colorProfile, os.Stdout, os.Environ())
 	app := NewDv(provider, staged, initialState)
 	if err := t.Run(app); err != nil {
 		log.Fatal(err)
 	}
+	if err := app.saveUIState(); err != nil {
+		log.Print(err)
+	}
+}
+
+// startupArgsDiffProvider picks a provider from positional arguments. It
+// reports false when no arguments were given so git or stdin can be used.
+func startupArgsDiffProvider(workDir string, args []string, textconv textconvOptions) (DiffProvider, bool, error) {
+	if len(args) == 0 {
+		return nil, false, nil
+	}
+	switch args[0] {
+	case "range-diff":
+		if len(args) != 3 {
+			return nil, true, fmt.Errorf("expected two ranges (usage: dv [flags] range-diff <old-range> <new-range>), got %d argument(s)", len(args)-1)
+		}
+		return newRangeDiffProvider(workDir, args[1], args[2], textconv), true, nil
+	case "interdiff":
+		if len(args) != 3 {
+			return nil, true, fmt.Errorf("expected two patch files (usage: dv [flags] interdiff <old.patch> <new.patch>), got %d argument(s)", len(args)-1)
+		}
+		provider, err := newPatchFilesInterdiffProvider(workDir, args[1], args[2])
+		if err != nil {
+			return nil, true, err
+		}
+		return provider, true, nil
+	}
+	if len(args) != 2 {
+		return nil, true, fmt.Errorf("expected two paths to compare (usage: dv [flags] <path> <path>), got %d argument(s)", len(args))
+	}
+	provider, err := newPathsDiffProvider(workDir, args[0], args[1], textconv)
+	if err != nil {
+		return nil, true, err
+	}
+	return provider, true, nil
 }
 
 func stdinIsPiped(stdin *os.File) (bool, error) {
@@ -121,7 +316,7 @@ func stdinIsPiped(stdin *os.File) (bool, error) {
 	return info.Mode()&os.ModeCharDevice == 0, nil
 }
 
-func startupDiffProvider(workDir string, stdin io.Reader, piped bool, openTTY ttyOpener, setStdin stdinSetter) (DiffProvider, func(), error) {
+func startupDiffProvider(workDir string, stdin io.Reader, piped bool, openTTY ttyOpener, setStdin stdinSetter, textconv textconvOptions) (DiffProvider, func(), error) {
 	if openTTY == nil {
 		openTTY = uv.OpenTTY
 	}
@@ -132,7 +327,7 @@ func startupDiffProvider(workDir string, stdin io.Reader, piped bool, openTTY tt
 	}
 
 	if !piped {
-		return GitDiffProvider{WorkDir: workDir}, func() {}, nil
+		return GitDiffProvider{WorkDir: workDir, Textconv: textconv}, func() {}, nil
 	}
 
 	rawDiff, err := io.ReadAll(stdin)
@@ -10,13 +10,15 @@ import (
 )
 
 func TestStartupDiffProvider_UsesGitProviderWhenStdinNotPiped(t *testing.T) {
-	provider, cleanup, err := startupDiffProvider("/tmp/repo", strings.NewReader("ignored"), false, nil, nil)
+	textconv := textconvOptions{Enabled: true}
+	provider, cleanup, err := startupDiffProvider("/tmp/repo", strings.NewReader("ignored"), false, nil, nil, textconv)
 	require.NoError(t, err)
 	defer cleanup()
 
 	gitProvider, ok := provider.(GitDiffProvider)
 	require.True(t, ok)
 	require.Equal(t, "/tmp/repo", gitProvider.WorkDir)
+	require.Equal(t, textconv, gitProvider.Textconv)
 }
 
 func TestStartupDiffProvider_UsesStdinProviderWhenPiped(t *testing.T) {
@@ -39,6 +41,7 @@ func TestStartupDiffProvider_UsesStdinProviderWhenPiped(t *testing.T) {
 		func(file *os.File) {
 			assignedStdin = file
 		},
+		textconvOptions{},
 	)
 	require.NoError(t, err)
 	defer cleanup()
@@ -63,6 +66,7 @@ func TestStartupDiffProvider_ReturnsClearErrorWhenTTYRebindFails(t *testing.T) {
 		func(file *os.File) {
 			setCalled = true
 		},
+		textconvOptions{},
 	)
 	cleanup()
 	require.Error(t, err)
@@ -70,3 +74,63 @@ func TestStartupDiffProvider_ReturnsClearErrorWhenTTYRebindFails(t *testing.T) {
 	require.ErrorContains(t, err, "reopen terminal input after reading piped stdin")
 	require.False(t, setCalled)
 }
+
+func TestStartupArgsDiffProvider_NoArgsFallsBack(t *testing.T) {
+	provider, handled, err := startupArgsDiffProvider("/tmp/repo", nil, textconvOptions{})
+	require.NoError(t, err)
+	require.False(t, handled)
+	require.Nil(t, provider)
+}
+
+func TestStartupArgsDiffProvider_TwoPathsComparePaths(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(dir+"/a.txt", []byte("a\n"), 0o644))
+	require.NoError(t, os.WriteFile(dir+"/b.txt", []byte("b\n"), 0o644))
+
+	provider, handled, err := startupArgsDiffProvider(dir, []string{"a.txt", "b.txt"}, textconvOptions{})
+	require.NoError(t, err)
+	require.True(t, handled)
+
+	pathsProvider, ok := provider.(PathsDiffProvider)
+	require.True(t, ok)
+	require.Equal(t, "a.txt", pathsProvider.OldPath)
+	require.Equal(t, "b.txt", pathsProvider.NewPath)
+}
+
+func TestStartupArgsDiffProvider_RejectsWrongArgumentCount(t *testing.T) {
+	_, handled, err := startupArgsDiffProvider("/tmp/repo", []string{"only.txt"}, textconvOptions{})
+	require.True(t, handled)
+	require.Error(t, err)
+	require.ErrorContains(t, err, "usage: dv [flags] <path> <path>")
+}
+
+func TestStartupArgsDiffProvider_RangeDiffSubcommand(t *testing.T) {
+	provider, handled, err := startupArgsDiffProvider("/tmp/repo", []string{"range-diff", "main..v1", "main..v2"}, textconvOptions{})
+	require.NoError(t, err)
+	require.True(t, handled)
+
+	interdiff, ok := provider.(InterdiffProvider)
+	require.True(t, ok)
+	require.Equal(t, "main..v1", interdiff.OldLabel)
+	require.Equal(t, "main..v2", interdiff.NewLabel)
+	require.True(t, interdiff.IgnoreWhitespaceEnabled())
+
+	_, handled, err = startupArgsDiffProvider("/tmp/repo", []string{"range-diff", "main..v1"}, textconvOptions{})
+	require.True(t, handled)
+	require.ErrorContains(t, err, "usage: dv [flags] range-diff <old-range> <new-range>")
+}
+
+func TestStartupArgsDiffProvider_InterdiffSubcommand(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir+"/v1.patch", "")
+	writeTestFile(t, dir+"/v2.patch", "")
+
+	provider, handled, err := startupArgsDiffProvider(dir, []string{"interdiff", "v1.patch", "v2.patch"}, textconvOptions{})
+	require.NoError(t, err)
+	require.True(t, handled)
+	_, ok := provider.(InterdiffProvider)
+	require.True(t, ok)
+
+	_, _, err = startupArgsDiffProvider(dir, []string{"interdiff", "v1.patch", "missing.patch"}, textconvOptions{})
+	require.ErrorContains(t, err, "missing.patch")
+}
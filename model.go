@@ -38,6 +38,9 @@ type DiffFile struct {
 	IsBinary    bool
 	Additions   int
 	Deletions   int
+	// SuspiciousChars counts the bidi controls, invisible characters,
+	// confusables and control codes in added lines.
+	SuspiciousChars int
 }
 
 // DiffDocument is the parsed representation of a full git diff output.
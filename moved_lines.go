@@ -0,0 +1,188 @@
+package main
+
+import "unicode"
+
+// movedBlockMinAlnum is how many alphanumeric characters a block of lines
+// needs before it counts as moved, as in git's --color-moved. Shorter blocks,
+// such as a lone closing brace, match by accident too often.
+const movedBlockMinAlnum = 20
+
+// RenderedLineMove points a moved line at its counterpart: the added line a
+// removed line moved to, or the removed line an added line came from.
+type RenderedLineMove struct {
+	// Path is the DisplayPath of the file the counterpart is in.
+	Path string
+	// Side and Line address the counterpart: the new file for removed lines
+	// and the old file for added lines.
+	Side ReviewCommentSide
+	Line int
+	// Block numbers the moved blocks of a run of changed lines from 0, so
+	// that adjacent blocks can alternate styles like git's
+	// --color-moved=zebra.
+	Block int
+}
+
+// lineMoveKey identifies a changed line of a file by its side and number.
+type lineMoveKey struct {
+	Kind DiffLineKind
+	Line int
+}
+
+// fileLineMoves holds the moved lines of one file.
+type fileLineMoves map[lineMoveKey]RenderedLineMove
+
+// sectionLineMoves holds the moved lines of a section by DisplayPath.
+type sectionLineMoves map[string]fileLineMoves
+
+// changedLineRun is a run of consecutive removed or added lines in a hunk.
+type changedLineRun struct {
+	Path  string
+	Lines []DiffLine
+}
+
+type changedLinePos struct {
+	Run    int
+	Offset int
+}
+
+// detectMovedLines matches blocks of removed lines to identical blocks of
+// added lines anywhere in files, across hunks and files. Each removed line
+// takes the longest block of unmatched added lines that starts with it, so
+// a function moved as a whole is matched as one block.
+func detectMovedLines(files []*DiffFile) sectionLineMoves {
+	removeRuns, addRuns := collectChangedLineRuns(files)
+	if len(removeRuns) == 0 || len(addRuns) == 0 {
+		return nil
+	}
+
+	addsByContent := map[string][]changedLinePos{}
+	for runIdx, run := range addRuns {
+		for offset, line := range run.Lines {
+			if alnumCount(line.Content) == 0 {
+				continue
+			}
+			addsByContent[line.Content] = append(addsByContent[line.Content], changedLinePos{Run: runIdx, Offset: offset})
+		}
+	}
+	usedAdds := make([][]bool, len(addRuns))
+	for runIdx, run := range addRuns {
+		usedAdds[runIdx] = make([]bool, len(run.Lines))
+	}
+
+	moves := sectionLineMoves{}
+	for _, removeRun := range removeRuns {
+		for idx := 0; idx < len(removeRun.Lines); {
+			best, bestLen := changedLinePos{}, 0
+			for _, candidate := range addsByContent[removeRun.Lines[idx].Content] {
+				addLines := addRuns[candidate.Run].Lines
+				length := 0
+				for idx+length < len(removeRun.Lines) &&
+					candidate.Offset+length < len(addLines) &&
+					!usedAdds[candidate.Run][candidate.Offset+length] &&
+					removeRun.Lines[idx+length].Content == addLines[candidate.Offset+length].Content {
+					length++
+				}
+				if length > bestLen {
+					best, bestLen = candidate, length
+				}
+			}
+
+			alnum := 0
+			for _, line := range removeRun.Lines[idx : idx+bestLen] {
+				alnum += alnumCount(line.Content)
+			}
+			if bestLen == 0 || alnum < movedBlockMinAlnum {
+				idx++
+				continue
+			}
+
+			addRun := addRuns[best.Run]
+			for offset := range bestLen {
+				removed := removeRun.Lines[idx+offset]
+				added := addRun.Lines[best.Offset+offset]
+				usedAdds[best.Run][best.Offset+offset] = true
+				moves.add(removeRun.Path, lineMoveKey{Kind: DiffLineRemove, Line: removed.OldLine}, RenderedLineMove{Path: addRun.Path, Side: ReviewCommentSideNew, Line: added.NewLine})
+				moves.add(addRun.Path, lineMoveKey{Kind: DiffLineAdd, Line: added.NewLine}, RenderedLineMove{Path: removeRun.Path, Side: ReviewCommentSideOld, Line: removed.OldLine})
+			}
+			idx += bestLen
+		}
+	}
+	if len(moves) == 0 {
+		return nil
+	}
+	moves.numberBlocks(removeRuns, DiffLineRemove)
+	moves.numberBlocks(addRuns, DiffLineAdd)
+	return moves
+}
+
+// numberBlocks sets Block on the moved lines of runs. A block ends where the
+// next line is not moved or its counterpart does not follow on.
+func (m sectionLineMoves) numberBlocks(runs []changedLineRun, kind DiffLineKind) {
+	for _, run := range runs {
+		moves := m[run.Path]
+		block := -1
+		var prev *RenderedLineMove
+		for _, line := range run.Lines {
+			key := lineMoveKey{Kind: kind, Line: line.NewLine}
+			if kind == DiffLineRemove {
+				key.Line = line.OldLine
+			}
+			move, ok := moves[key]
+			if !ok {
+				prev = nil
+				continue
+			}
+			if prev == nil || move.Path != prev.Path || move.Line != prev.Line+1 {
+				block++
+			}
+			move.Block = block
+			moves[key] = move
+			prev = &move
+		}
+	}
+}
+
+func (m sectionLineMoves) add(path string, key lineMoveKey, move RenderedLineMove) {
+	if m[path] == nil {
+		m[path] = fileLineMoves{}
+	}
+	m[path][key] = move
+}
+
+func collectChangedLineRuns(files []*DiffFile) (removeRuns []changedLineRun, addRuns []changedLineRun) {
+	for _, file := range files {
+		if file == nil || file.IsBinary {
+			continue
+		}
+		for _, hunk := range file.Hunks {
+			for idx := 0; idx < len(hunk.Lines); {
+				kind := hunk.Lines[idx].Kind
+				if kind != DiffLineRemove && kind != DiffLineAdd {
+					idx++
+					continue
+				}
+				start := idx
+				for idx < len(hunk.Lines) && hunk.Lines[idx].Kind == kind {
+					idx++
+				}
+				run := changedLineRun{Path: file.DisplayPath, Lines: hunk.Lines[start:idx]}
+				if kind == DiffLineRemove {
+					removeRuns = append(removeRuns, run)
+				} else {
+					addRuns = append(addRuns, run)
+				}
+			}
+		}
+	}
+	return removeRuns, addRuns
+}
+
+func alnumCount(text string) int {
+	count := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			count++
+		}
+	}
+	return count
+}
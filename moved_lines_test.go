@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func movedTestFile(path string, lines ...DiffLine) *DiffFile {
+	return &DiffFile{
+		NewPath:     path,
+		DisplayPath: path,
+		Hunks:       []DiffHunk{{Header: "@@ -1 +1 @@", Lines: lines}},
+	}
+}
+
+func TestDetectMovedLines_MatchesBlockAcrossFiles(t *testing.T) {
+	files := []*DiffFile{
+		movedTestFile("a.go",
+			DiffLine{Kind: DiffLineContext, Content: "package a", OldLine: 1, NewLine: 1},
+			DiffLine{Kind: DiffLineRemove, Content: "func helper() int {", OldLine: 2},
+			DiffLine{Kind: DiffLineRemove, Content: "\treturn computeAnswer()", OldLine: 3},
+			DiffLine{Kind: DiffLineRemove, Content: "}", OldLine: 4},
+		),
+		movedTestFile("b.go",
+			DiffLine{Kind: DiffLineContext, Content: "package b", OldLine: 1, NewLine: 1},
+			DiffLine{Kind: DiffLineAdd, Content: "func helper() int {", NewLine: 5},
+			DiffLine{Kind: DiffLineAdd, Content: "\treturn computeAnswer()", NewLine: 6},
+			DiffLine{Kind: DiffLineAdd, Content: "}", NewLine: 7},
+		),
+	}
+
+	moves := detectMovedLines(files)
+	require.Equal(t, fileLineMoves{
+		{Kind: DiffLineRemove, Line: 2}: {Path: "b.go", Side: ReviewCommentSideNew, Line: 5},
+		{Kind: DiffLineRemove, Line: 3}: {Path: "b.go", Side: ReviewCommentSideNew, Line: 6},
+		{Kind: DiffLineRemove, Line: 4}: {Path: "b.go", Side: ReviewCommentSideNew, Line: 7},
+	}, moves["a.go"])
+	require.Equal(t, fileLineMoves{
+		{Kind: DiffLineAdd, Line: 5}: {Path: "a.go", Side: ReviewCommentSideOld, Line: 2},
+		{Kind: DiffLineAdd, Line: 6}: {Path: "a.go", Side: ReviewCommentSideOld, Line: 3},
+		{Kind: DiffLineAdd, Line: 7}: {Path: "a.go", Side: ReviewCommentSideOld, Line: 4},
+	}, moves["b.go"])
+}
+
+func TestDetectMovedLines_IgnoresShortBlocks(t *testing.T) {
+	files := []*DiffFile{
+		movedTestFile("a.go",
+			DiffLine{Kind: DiffLineRemove, Content: "}", OldLine: 1},
+			DiffLine{Kind: DiffLineRemove, Content: "return nil", OldLine: 2},
+			DiffLine{Kind: DiffLineContext, Content: "x", OldLine: 3, NewLine: 1},
+			DiffLine{Kind: DiffLineAdd, Content: "}", NewLine: 2},
+			DiffLine{Kind: DiffLineAdd, Content: "return nil", NewLine: 3},
+		),
+	}
+
+	require.Nil(t, detectMovedLines(files))
+}
+
+func TestDetectMovedLines_UsesEachAddedLineOnce(t *testing.T) {
+	line := "const movedConstantValue = 42"
+	files := []*DiffFile{
+		{
+			NewPath:     "a.go",
+			DisplayPath: "a.go",
+			Hunks: []DiffHunk{
+				{Header: "@@ -1 +1 @@", Lines: []DiffLine{
+					{Kind: DiffLineRemove, Content: line, OldLine: 1},
+					{Kind: DiffLineContext, Content: "x", OldLine: 2, NewLine: 1},
+				}},
+				{Header: "@@ -10 +10 @@", Lines: []DiffLine{
+					{Kind: DiffLineRemove, Content: line, OldLine: 10},
+					{Kind: DiffLineContext, Content: "y", OldLine: 11, NewLine: 10},
+				}},
+				{Header: "@@ -20 +20 @@", Lines: []DiffLine{
+					{Kind: DiffLineContext, Content: "z", OldLine: 20, NewLine: 20},
+					{Kind: DiffLineAdd, Content: line, NewLine: 21},
+				}},
+			},
+		},
+	}
+
+	moves := detectMovedLines(files)["a.go"]
+	require.Equal(t, RenderedLineMove{Path: "a.go", Side: ReviewCommentSideNew, Line: 21}, moves[lineMoveKey{Kind: DiffLineRemove, Line: 1}])
+	require.NotContains(t, moves, lineMoveKey{Kind: DiffLineRemove, Line: 10})
+	require.Equal(t, RenderedLineMove{Path: "a.go", Side: ReviewCommentSideOld, Line: 1}, moves[lineMoveKey{Kind: DiffLineAdd, Line: 21}])
+}
+
+func TestDetectMovedLines_NumbersAdjacentBlocksFromDifferentPlaces(t *testing.T) {
+	files := []*DiffFile{
+		movedTestFile("a.go",
+			DiffLine{Kind: DiffLineRemove, Content: "func first() int {", OldLine: 1},
+			DiffLine{Kind: DiffLineRemove, Content: "\treturn firstAnswer()", OldLine: 2},
+			DiffLine{Kind: DiffLineRemove, Content: "}", OldLine: 3},
+			DiffLine{Kind: DiffLineContext, Content: "var separator = true", OldLine: 4, NewLine: 1},
+			DiffLine{Kind: DiffLineRemove, Content: "func second() int {", OldLine: 5},
+			DiffLine{Kind: DiffLineRemove, Content: "\treturn secondAnswer()", OldLine: 6},
+			DiffLine{Kind: DiffLineRemove, Content: "}", OldLine: 7},
+		),
+		movedTestFile("b.go",
+			DiffLine{Kind: DiffLineAdd, Content: "func second() int {", NewLine: 1},
+			DiffLine{Kind: DiffLineAdd, Content: "\treturn secondAnswer()", NewLine: 2},
+			DiffLine{Kind: DiffLineAdd, Content: "}", NewLine: 3},
+			DiffLine{Kind: DiffLineAdd, Content: "func first() int {", NewLine: 4},
+			DiffLine{Kind: DiffLineAdd, Content: "\treturn firstAnswer()", NewLine: 5},
+			DiffLine{Kind: DiffLineAdd, Content: "}", NewLine: 6},
+		),
+	}
+
+	moves := detectMovedLines(files)["b.go"]
+	for line := 1; line <= 3; line++ {
+		require.Equal(t, 0, moves[lineMoveKey{Kind: DiffLineAdd, Line: line}].Block, "line %d", line)
+	}
+	for line := 4; line <= 6; line++ {
+		require.Equal(t, 1, moves[lineMoveKey{Kind: DiffLineAdd, Line: line}].Block, "line %d", line)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+
+	t "github.com/darrenburns/terma"
+)
+
+// PaletteVariant remaps the add and remove colours of any theme, for readers
+// who can't tell the theme's red and green apart or want stronger contrast.
+type PaletteVariant int
+
+const (
+	PaletteVariantDefault PaletteVariant = iota
+	PaletteVariantDeuteranopia
+	PaletteVariantProtanopia
+	PaletteVariantTritanopia
+	PaletteVariantHighContrast
+)
+
+// highContrastReadabilityFloor is the WCAG AA contrast ratio for body text.
+const highContrastReadabilityFloor = 4.5
+
+// paletteVariantSpec describes how a variant builds the diff colours. Unset
+// hues keep the theme's success and error colours.
+type paletteVariantSpec struct {
+	AddHue           t.Color
+	RemoveHue        t.Color
+	LineBlend        float64
+	IntralineBlend   float64
+	ReadabilityFloor float64
+}
+
+// The colour-blind variants use pairs that stay apart for that kind of colour
+// vision: blue/orange when red and green merge, teal/red when blue and
+// yellow do.
+var paletteVariantSpecs = map[PaletteVariant]paletteVariantSpec{
+	PaletteVariantDefault: {
+		LineBlend:        0.14,
+		IntralineBlend:   0.28,
+		ReadabilityFloor: intralineForegroundReadabilityFloor,
+	},
+	PaletteVariantDeuteranopia: {
+		AddHue:           t.Hex("#3a8fd9"),
+		RemoveHue:        t.Hex("#e08a1e"),
+		LineBlend:        0.16,
+		IntralineBlend:   0.34,
+		ReadabilityFloor: intralineForegroundReadabilityFloor,
+	},
+	PaletteVariantProtanopia: {
+		AddHue:           t.Hex("#2f7fd0"),
+		RemoveHue:        t.Hex("#d9b21e"),
+		LineBlend:        0.16,
+		IntralineBlend:   0.34,
+		ReadabilityFloor: intralineForegroundReadabilityFloor,
+	},
+	PaletteVariantTritanopia: {
+		AddHue:           t.Hex("#11a6a6"),
+		RemoveHue:        t.Hex("#d6336c"),
+		LineBlend:        0.16,
+		IntralineBlend:   0.34,
+		ReadabilityFloor: intralineForegroundReadabilityFloor,
+	},
+	PaletteVariantHighContrast: {
+		LineBlend:        0.24,
+		IntralineBlend:   0.4,
+		ReadabilityFloor: highContrastReadabilityFloor,
+	},
+}
+
+func (v PaletteVariant) spec() paletteVariantSpec {
+	if spec, ok := paletteVariantSpecs[v]; ok {
+		return spec
+	}
+	return paletteVariantSpecs[PaletteVariantDefault]
+}
+
+func (v PaletteVariant) DisplayName() string {
+	switch v {
+	case PaletteVariantDeuteranopia:
+		return "deuteranopia"
+	case PaletteVariantProtanopia:
+		return "protanopia"
+	case PaletteVariantTritanopia:
+		return "tritanopia"
+	case PaletteVariantHighContrast:
+		return "high-contrast"
+	default:
+		return "default"
+	}
+}
+
+// diffHues returns the add and remove colours the variant uses for theme.
+func (v PaletteVariant) diffHues(theme t.ThemeData) (add t.Color, remove t.Color) {
+	spec := v.spec()
+	return firstSetColor(spec.AddHue, theme.Success), firstSetColor(spec.RemoveHue, theme.Error)
+}
+
+func parsePaletteVariant(value string) (PaletteVariant, error) {
+	switch normalizeCLIValue(value) {
+	case "", "default":
+		return PaletteVariantDefault, nil
+	case "deuteranopia":
+		return PaletteVariantDeuteranopia, nil
+	case "protanopia":
+		return PaletteVariantProtanopia, nil
+	case "tritanopia":
+		return PaletteVariantTritanopia, nil
+	case "high-contrast", "highcontrast":
+		return PaletteVariantHighContrast, nil
+	default:
+		return PaletteVariantDefault, fmt.Errorf("invalid --palette value %q (expected \"default\", \"deuteranopia\", \"protanopia\", \"tritanopia\", or \"high-contrast\")", value)
+	}
+}
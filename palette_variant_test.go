@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+
+	t "github.com/darrenburns/terma"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePaletteVariant(tt *testing.T) {
+	tests := map[string]PaletteVariant{
+		"default":       PaletteVariantDefault,
+		"":              PaletteVariantDefault,
+		"Deuteranopia":  PaletteVariantDeuteranopia,
+		"protanopia":    PaletteVariantProtanopia,
+		"tritanopia":    PaletteVariantTritanopia,
+		"high_contrast": PaletteVariantHighContrast,
+	}
+	for value, want := range tests {
+		got, err := parsePaletteVariant(value)
+		require.NoError(tt, err, value)
+		require.Equal(tt, want, got, value)
+		if value != "" {
+			require.Equal(tt, want, mustParsePaletteVariant(tt, got.DisplayName()))
+		}
+	}
+
+	_, err := parsePaletteVariant("sepia")
+	require.ErrorContains(tt, err, `invalid --palette value "sepia"`)
+}
+
+func mustParsePaletteVariant(tt *testing.T, value string) PaletteVariant {
+	variant, err := parsePaletteVariant(value)
+	require.NoError(tt, err)
+	return variant
+}
+
+func TestThemePalette_ColorBlindVariantsReplaceRedAndGreen(tt *testing.T) {
+	variants := []PaletteVariant{PaletteVariantDeuteranopia, PaletteVariantProtanopia, PaletteVariantTritanopia}
+	for _, name := range t.ThemeNames() {
+		theme, ok := t.GetTheme(name)
+		require.True(tt, ok)
+		base := newThemePalette(theme, PaletteVariantDefault, paletteOptions{})
+		baseAdd, _ := base.LineStyleForKind(RenderedLineAdd)
+		for _, variant := range variants {
+			palette := newThemePalette(theme, variant, paletteOptions{})
+			add, _ := palette.LineStyleForKind(RenderedLineAdd)
+			remove, _ := palette.LineStyleForKind(RenderedLineRemove)
+			require.NotEqual(tt, baseAdd.BackgroundColor, add.BackgroundColor, "%s/%s", name, variant.DisplayName())
+			require.NotEqual(tt, add.BackgroundColor, remove.BackgroundColor, "%s/%s", name, variant.DisplayName())
+
+			addIntraline, _ := palette.IntralineOverlayStyle(IntralineMarkAdd, IntralineStyleModeBackground)
+			removeIntraline, _ := palette.IntralineOverlayStyle(IntralineMarkRemove, IntralineStyleModeBackground)
+			require.NotEqual(tt, addIntraline.Background, removeIntraline.Background)
+		}
+	}
+}
+
+func TestThemePalette_VariantSignsMeetReadabilityFloor(tt *testing.T) {
+	variants := []PaletteVariant{PaletteVariantDeuteranopia, PaletteVariantProtanopia, PaletteVariantTritanopia, PaletteVariantHighContrast}
+	for _, name := range t.ThemeNames() {
+		theme, ok := t.GetTheme(name)
+		require.True(tt, ok)
+		for _, variant := range variants {
+			palette := newThemePalette(theme, variant, paletteOptions{})
+			for kind, role := range map[RenderedLineKind]TokenRole{
+				RenderedLineAdd:    TokenRoleDiffPrefixAdd,
+				RenderedLineRemove: TokenRoleDiffPrefixRemove,
+			} {
+				gutter, _ := palette.GutterStyleForKind(kind)
+				bg := gutter.BackgroundColor.ColorAt(1, 1, 0, 0)
+				sign, _ := palette.StyleForRole(role)
+				require.GreaterOrEqual(tt, sign.Foreground.ContrastRatio(bg), variant.spec().ReadabilityFloor, "%s/%s", name, variant.DisplayName())
+			}
+		}
+	}
+}
+
+func TestThemePalette_VariantsReplaceUserThemeDiffColors(tt *testing.T) {
+	styles := newUserThemeStyles()
+	require.NoError(tt, registerUserThemes(map[string]userThemeConfig{
+		"test-variant-theme": {Diff: map[string]string{"add-background": "#00ff00"}},
+	}, styles))
+	theme, ok := t.GetTheme("test-variant-theme")
+	require.True(tt, ok)
+	options := paletteOptions{ThemeStyles: styles}
+
+	add, _ := newThemePalette(theme, PaletteVariantDefault, options).LineStyleForKind(RenderedLineAdd)
+	require.Equal(tt, t.Hex("#00ff00"), add.BackgroundColor)
+	add, _ = newThemePalette(theme, PaletteVariantDeuteranopia, options).LineStyleForKind(RenderedLineAdd)
+	require.NotEqual(tt, t.Hex("#00ff00"), add.BackgroundColor)
+}
+
+func TestDiffView_HighContrastRaisesIntralineReadabilityFloor(tt *testing.T) {
+	for _, name := range t.ThemeNames() {
+		theme, ok := t.GetTheme(name)
+		require.True(tt, ok)
+		view := DiffView{
+			Palette:        newThemePalette(theme, PaletteVariantHighContrast, paletteOptions{}),
+			IntralineStyle: IntralineStyleModeBackground,
+		}
+
+		for _, mark := range []IntralineMarkKind{IntralineMarkAdd, IntralineMarkRemove} {
+			for _, role := range []TokenRole{TokenRoleSyntaxComment, TokenRoleSyntaxKeyword, TokenRoleSyntaxString} {
+				style := view.styleForSegment(RenderedSegment{Text: "x", Role: role, Intraline: mark})
+				fg := style.ForegroundColor.ColorAt(1, 1, 0, 0)
+				bg := style.BackgroundColor.ColorAt(1, 1, 0, 0)
+				require.GreaterOrEqual(tt, fg.ContrastRatio(bg), highContrastReadabilityFloor, name)
+			}
+		}
+	}
+}
@@ -46,7 +46,9 @@ func parseUnifiedDiff(raw string) (*DiffDocument, error) {
 		currentFile = nil
 	}
 
-	for _, line := range lines {
+	for _, rawLine := range lines {
+		// Only hunk lines keep a trailing CR, where it belongs to the file.
+		line := strings.TrimSuffix(rawLine, "\r")
 		if strings.HasPrefix(line, "diff --git ") {
 			flushFile()
 			oldPath, newPath := parseDiffGitPaths(line)
@@ -75,10 +77,11 @@ func parseUnifiedDiff(raw string) (*DiffDocument, error) {
 		}
 
 		if currentHunk != nil {
-			diffLine := parseHunkLine(line, &oldLineCursor, &newLineCursor)
+			diffLine := parseHunkLine(rawLine, &oldLineCursor, &newLineCursor)
 			switch diffLine.Kind {
 			case DiffLineAdd:
 				currentFile.Additions++
+				currentFile.SuspiciousChars += countSuspiciousChars(diffLine.Content)
 			case DiffLineRemove:
 				currentFile.Deletions++
 			}
@@ -94,8 +97,14 @@ func parseUnifiedDiff(raw string) (*DiffDocument, error) {
 	return doc, nil
 }
 
+// normalizeDiffInput converts a diff saved with CRLF line endings to LF. In
+// any other diff a CR at the end of a hunk line is part of the file's content,
+// so it is kept for line-ending changes to show up.
 func normalizeDiffInput(raw string) string {
-	normalized := strings.ReplaceAll(raw, "\r\n", "\n")
+	normalized := raw
+	if firstLine, _, ok := strings.Cut(raw, "\n"); ok && strings.HasSuffix(firstLine, "\r") {
+		normalized = strings.ReplaceAll(raw, "\r\n", "\n")
+	}
 	if strings.IndexByte(normalized, 0x1b) == -1 {
 		return normalized
 	}
@@ -175,9 +184,9 @@ func parseHunkLine(line string, oldCursor *int, newCursor *int) DiffLine {
 		*oldCursor = *oldCursor + 1
 		return DiffLine{Kind: DiffLineRemove, Content: content, OldLine: old}
 	case '\\':
-		return DiffLine{Kind: DiffLineMeta, Content: line}
+		return DiffLine{Kind: DiffLineMeta, Content: strings.TrimSuffix(line, "\r")}
 	default:
-		return DiffLine{Kind: DiffLineMeta, Content: line}
+		return DiffLine{Kind: DiffLineMeta, Content: strings.TrimSuffix(line, "\r")}
 	}
 }
 
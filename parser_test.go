@@ -143,3 +143,58 @@ func TestParseUnifiedDiff_StripsANSIFromInlineHunkContent(t *testing.T) {
 	require.Equal(t, `fmt.Println("new")`, add.Content)
 	require.NotContains(t, add.Content, esc)
 }
+
+func TestParseUnifiedDiff_KeepsCRAtEndOfHunkLines(t *testing.T) {
+	raw := strings.Join([]string{
+		"diff --git a/main.go b/main.go",
+		"--- a/main.go",
+		"+++ b/main.go",
+		"@@ -1 +1 @@ func main()\r",
+		"-fmt.Println()\r",
+		"+fmt.Println()",
+	}, "\n") + "\n"
+
+	doc, err := parseUnifiedDiff(raw)
+	require.NoError(t, err)
+	require.Len(t, doc.Files, 1)
+	require.Equal(t, "main.go", doc.Files[0].NewPath)
+	require.Len(t, doc.Files[0].Hunks, 1)
+	require.Equal(t, "@@ -1 +1 @@ func main()", doc.Files[0].Hunks[0].Header)
+	require.Equal(t, "fmt.Println()\r", doc.Files[0].Hunks[0].Lines[0].Content)
+	require.Equal(t, "fmt.Println()", doc.Files[0].Hunks[0].Lines[1].Content)
+}
+
+func TestParseUnifiedDiff_NormalizesCRLFDiff(t *testing.T) {
+	raw := strings.Join([]string{
+		"diff --git a/main.go b/main.go",
+		"--- a/main.go",
+		"+++ b/main.go",
+		"@@ -1 +1 @@",
+		"-old",
+		"+new",
+	}, "\r\n") + "\r\n"
+
+	doc, err := parseUnifiedDiff(raw)
+	require.NoError(t, err)
+	require.Len(t, doc.Files, 1)
+	require.Len(t, doc.Files[0].Hunks, 1)
+	require.Equal(t, "old", doc.Files[0].Hunks[0].Lines[0].Content)
+	require.Equal(t, "new", doc.Files[0].Hunks[0].Lines[1].Content)
+}
+
+func TestParseUnifiedDiff_CountsSuspiciousCharsInAddedLines(t *testing.T) {
+	raw := strings.Join([]string{
+		"diff --git a/auth.go b/auth.go",
+		"--- a/auth.go",
+		"+++ b/auth.go",
+		"@@ -1,2 +1,2 @@",
+		" // \u202e context is not counted",
+		"-isAdmin := false\u200b",
+		"+is\u0430dmin := false // \u2066 \u2069",
+	}, "\n") + "\n"
+
+	doc, err := parseUnifiedDiff(raw)
+	require.NoError(t, err)
+	require.Len(t, doc.Files, 1)
+	require.Equal(t, 3, doc.Files[0].SuspiciousChars)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// PathsDiffProvider compares two arbitrary files or directories with
+// `git diff --no-index`, so it works outside of a git repository.
+type PathsDiffProvider struct {
+	WorkDir  string
+	OldPath  string
+	NewPath  string
+	Textconv textconvOptions
+}
+
+func newPathsDiffProvider(workDir string, oldPath string, newPath string, textconv textconvOptions) (PathsDiffProvider, error) {
+	oldInfo, err := os.Stat(resolvePathArg(workDir, oldPath))
+	if err != nil {
+		return PathsDiffProvider{}, fmt.Errorf("compare %q: %w", oldPath, err)
+	}
+	newInfo, err := os.Stat(resolvePathArg(workDir, newPath))
+	if err != nil {
+		return PathsDiffProvider{}, fmt.Errorf("compare %q: %w", newPath, err)
+	}
+	if oldInfo.IsDir() != newInfo.IsDir() {
+		return PathsDiffProvider{}, fmt.Errorf("cannot compare %q with %q: both paths must be files or both must be directories", oldPath, newPath)
+	}
+	return PathsDiffProvider{
+		WorkDir:  workDir,
+		OldPath:  filepath.Clean(oldPath),
+		NewPath:  filepath.Clean(newPath),
+		Textconv: textconv,
+	}, nil
+}
+
+func (p PathsDiffProvider) LoadDiff(staged bool, ignoreWhitespace bool) (string, error) {
+	if staged {
+		return "", nil
+	}
+	args := buildNoIndexDiffArgs(p.OldPath, p.NewPath, ignoreWhitespace, p.Textconv)
+	stdout, stderr, err := runGit(p.WorkDir, args)
+	if err != nil && !isNoIndexDifferenceExit(err) {
+		return "", fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr))
+	}
+	if p.comparesDirectories() {
+		stdout = relativizeNoIndexDiff(stdout, p.OldPath, p.NewPath)
+	}
+	return stdout, nil
+}
+
+func (p PathsDiffProvider) RepoRoot() (string, error) {
+	if root, err := (GitDiffProvider{WorkDir: p.WorkDir}).RepoRoot(); err == nil {
+		return root, nil
+	}
+	return p.WorkDir, nil
+}
+
+func (p PathsDiffProvider) CurrentBranch() (string, error) {
+	return GitDiffProvider{WorkDir: p.WorkDir}.CurrentBranch()
+}
+
+func (p PathsDiffProvider) Sections() []DiffSection {
+	return []DiffSection{DiffSectionFiles}
+}
+
+func (p PathsDiffProvider) ManualRefreshEnabled() bool {
+	return true
+}
+
+func (p PathsDiffProvider) IgnoreWhitespaceEnabled() bool {
+	return true
+}
+
+func (p PathsDiffProvider) EmptyMessageParts(ignoreWhitespace bool, keyPrompt func(action string, purpose string) string) (heading string, details string) {
+	heading = fmt.Sprintf("No differences between %s and %s.", p.OldPath, p.NewPath)
+	if ignoreWhitespace {
+		heading = fmt.Sprintf("No differences between %s and %s (ignoring whitespace).", p.OldPath, p.NewPath)
+	}
+	return heading, "Edit either side, then " + keyPrompt(keyActionRefresh, "refresh") + "."
+}
+
+func (p PathsDiffProvider) SeenScope() string {
+	return "paths " + p.OldPath + " " + p.NewPath
+}
+
+func (p PathsDiffProvider) comparesDirectories() bool {
+	info, err := os.Stat(resolvePathArg(p.WorkDir, p.OldPath))
+	return err == nil && info.IsDir()
+}
+
+func buildNoIndexDiffArgs(oldPath string, newPath string, ignoreWhitespace bool, textconv textconvOptions) []string {
+	args := []string{"-c", "color.ui=never"}
+	args = append(args, textconv.gitConfigArgs()...)
+	args = append(args,
+		"diff",
+		"--no-color",
+		"--no-ext-diff",
+		textconv.diffFlag(),
+		"--patch",
+		"--find-renames",
+		"--no-index",
+	)
+	if ignoreWhitespace {
+		args = append(args, "--ignore-all-space")
+	}
+	return append(args, "--", oldPath, newPath)
+}
+
+// git diff --no-index exits with status 1 when the inputs differ.
+func isNoIndexDifferenceExit(err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == 1
+}
+
+func resolvePathArg(workDir string, path string) string {
+	if filepath.IsAbs(path) || workDir == "" {
+		return path
+	}
+	return filepath.Join(workDir, path)
+}
+
+// relativizeNoIndexDiff strips the compared directory roots from file headers
+// so that both sides of a directory comparison share one tree.
+func relativizeNoIndexDiff(raw string, oldRoot string, newRoot string) string {
+	roots := []string{noIndexDisplayRoot(oldRoot), noIndexDisplayRoot(newRoot)}
+	if len(roots[1]) > len(roots[0]) {
+		roots[0], roots[1] = roots[1], roots[0]
+	}
+
+	lines := strings.SplitAfter(raw, "\n")
+	inFileHeader := false
+	for idx, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			inFileHeader = true
+		case strings.HasPrefix(line, "@@ "):
+			inFileHeader = false
+		}
+		if inFileHeader {
+			lines[idx] = relativizeNoIndexHeader(line, roots)
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// relativizeNoIndexHeader strips a leading root from each path in a file
+// header line. Lines that don't name paths are returned unchanged.
+func relativizeNoIndexHeader(line string, roots []string) string {
+	body, newline := strings.CutSuffix(line, "\n")
+	suffix := ""
+	if newline {
+		suffix = "\n"
+	}
+
+	if operands, ok := strings.CutPrefix(body, "diff --git "); ok {
+		operands = stripNoIndexRoot(operands, "a/", roots)
+		for _, root := range roots {
+			if root == "" {
+				continue
+			}
+			for _, side := range []string{" b/", ` "b/`} {
+				if idx := strings.Index(operands, side+root+"/"); idx >= 0 {
+					return "diff --git " + operands[:idx+1] + stripNoIndexRoot(operands[idx+1:], "b/", roots) + suffix
+				}
+			}
+		}
+		return "diff --git " + operands + suffix
+	}
+
+	prefixes := []struct {
+		header string
+		side   string
+	}{
+		{"--- ", "a/"},
+		{"+++ ", "b/"},
+		{"rename from ", ""},
+		{"rename to ", ""},
+	}
+	for _, prefix := range prefixes {
+		if path, ok := strings.CutPrefix(body, prefix.header); ok {
+			return prefix.header + stripNoIndexRoot(path, prefix.side, roots) + suffix
+		}
+	}
+	return line
+}
+
+// stripNoIndexRoot removes root from the start of path, keeping its side
+// prefix ("a/" or "b/") and any quote git put around it.
+func stripNoIndexRoot(path string, side string, roots []string) string {
+	quote := ""
+	if strings.HasPrefix(path, `"`) {
+		quote = `"`
+	}
+	for _, root := range roots {
+		if root == "" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(path, quote+side+root+"/"); ok {
+			return quote + side + rest
+		}
+	}
+	return path
+}
+
+func noIndexDisplayRoot(root string) string {
+	root = filepath.ToSlash(filepath.Clean(root))
+	root = strings.TrimPrefix(root, "/")
+	if root == "." {
+		return ""
+	}
+	return root
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildNoIndexDiffArgs(t *testing.T) {
+	args := buildNoIndexDiffArgs("a.txt", "b.txt", true, textconvOptions{})
+	require.Equal(t, []string{
+		"-c", "color.ui=never",
+		"diff",
+		"--no-color",
+		"--no-ext-diff",
+		"--no-textconv",
+		"--patch",
+		"--find-renames",
+		"--no-index",
+		"--ignore-all-space",
+		"--", "a.txt", "b.txt",
+	}, args)
+}
+
+func TestNewPathsDiffProvider_RejectsFileAgainstDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+
+	_, err := newPathsDiffProvider(dir, "a.txt", "sub", textconvOptions{})
+	require.Error(t, err)
+	require.ErrorContains(t, err, "both paths must be files or both must be directories")
+
+	_, err = newPathsDiffProvider(dir, "a.txt", "missing.txt", textconvOptions{})
+	require.Error(t, err)
+	require.ErrorContains(t, err, "missing.txt")
+}
+
+func TestPathsDiffProvider_LoadDiffComparesFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.txt"), []byte("one\ntwo\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("one\nthree\n"), 0o644))
+
+	provider, err := newPathsDiffProvider(dir, "old.txt", "new.txt", textconvOptions{})
+	require.NoError(t, err)
+
+	raw, err := provider.LoadDiff(false, false)
+	require.NoError(t, err)
+	doc, err := parseUnifiedDiff(raw)
+	require.NoError(t, err)
+	require.Len(t, doc.Files, 1)
+	require.Equal(t, "new.txt", doc.Files[0].DisplayPath)
+	require.Equal(t, 1, doc.Files[0].Additions)
+	require.Equal(t, 1, doc.Files[0].Deletions)
+
+	staged, err := provider.LoadDiff(true, false)
+	require.NoError(t, err)
+	require.Empty(t, staged)
+}
+
+func TestPathsDiffProvider_LoadDiffIdenticalFilesIsEmpty(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.txt"), []byte("same\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("same\n"), 0o644))
+
+	provider, err := newPathsDiffProvider(dir, "old.txt", "new.txt", textconvOptions{})
+	require.NoError(t, err)
+
+	raw, err := provider.LoadDiff(false, false)
+	require.NoError(t, err)
+	require.Empty(t, raw)
+}
+
+func TestPathsDiffProvider_LoadDiffComparesDirectoriesRelativeToRoots(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "left", "shared.txt"), "a\n")
+	writeTestFile(t, filepath.Join(dir, "left", "nested", "gone.txt"), "bye\n")
+	writeTestFile(t, filepath.Join(dir, "right", "shared.txt"), "b\n")
+	writeTestFile(t, filepath.Join(dir, "right", "nested", "added.txt"), "hi\n")
+
+	provider, err := newPathsDiffProvider(dir, "left", "right/", textconvOptions{})
+	require.NoError(t, err)
+
+	raw, err := provider.LoadDiff(false, false)
+	require.NoError(t, err)
+	doc, err := parseUnifiedDiff(raw)
+	require.NoError(t, err)
+
+	paths := make([]string, 0, len(doc.Files))
+	for _, file := range doc.Files {
+		paths = append(paths, file.DisplayPath)
+	}
+	require.ElementsMatch(t, []string{"shared.txt", "nested/gone.txt", "nested/added.txt"}, paths)
+}
+
+func TestRelativizeNoIndexDiff_LeavesHunkContentUntouched(t *testing.T) {
+	raw := "diff --git a/left/x b/right/x\n" +
+		"--- a/left/x\n" +
+		"+++ b/right/x\n" +
+		"@@ -1 +1 @@\n" +
+		"--- a/left/y\n" +
+		"+++ b/right/y\n"
+
+	got := relativizeNoIndexDiff(raw, "left", "right")
+	require.Equal(t, "diff --git a/x b/x\n"+
+		"--- a/x\n"+
+		"+++ b/x\n"+
+		"@@ -1 +1 @@\n"+
+		"--- a/left/y\n"+
+		"+++ b/right/y\n", got)
+}
+
+func TestPathsDiffProvider_SectionsAndCapabilities(t *testing.T) {
+	provider := PathsDiffProvider{OldPath: "a.txt", NewPath: "b.txt"}
+	require.Equal(t, []DiffSection{DiffSectionFiles}, provider.Sections())
+	require.True(t, provider.ManualRefreshEnabled())
+	require.True(t, provider.IgnoreWhitespaceEnabled())
+
+	keyPrompt := func(action string, purpose string) string { return action + " to " + purpose }
+	heading, details := provider.EmptyMessageParts(false, keyPrompt)
+	require.Equal(t, "No differences between a.txt and b.txt.", heading)
+	require.Equal(t, "Edit either side, then "+keyActionRefresh+" to refresh.", details)
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestRelativizeNoIndexDiff_OnlyStripsLeadingRoots(t *testing.T) {
+	raw := "diff --git a/old/data/old/x b/new/data/old/x\n" +
+		"similarity index 90%\n" +
+		"rename from old/data/old/x\n" +
+		"rename to new/data/new/y\n" +
+		"--- a/old/data/old/x\n" +
+		"+++ b/new/data/old/x\n" +
+		"@@ -1 +1 @@\n" +
+		"-a/old/z\n"
+
+	got := relativizeNoIndexDiff(raw, "old", "new")
+	require.Equal(t, "diff --git a/data/old/x b/data/old/x\n"+
+		"similarity index 90%\n"+
+		"rename from data/old/x\n"+
+		"rename to data/new/y\n"+
+		"--- a/data/old/x\n"+
+		"+++ b/data/old/x\n"+
+		"@@ -1 +1 @@\n"+
+		"-a/old/z\n", got)
+}
+
+func TestRelativizeNoIndexDiff_KeepsQuotedPathsQuoted(t *testing.T) {
+	raw := "diff --git \"a/left/sp\\tace\" \"b/right/sp\\tace\"\n" +
+		"--- \"a/left/sp\\tace\"\n" +
+		"+++ \"b/right/sp\\tace\"\n"
+
+	got := relativizeNoIndexDiff(raw, "left", "right")
+	require.Equal(t, "diff --git \"a/sp\\tace\" \"b/sp\\tace\"\n"+
+		"--- \"a/sp\\tace\"\n"+
+		"+++ \"b/sp\\tace\"\n", got)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	t "github.com/darrenburns/terma"
+)
+
+// defaultPrintWidth is the layout width used by --print when neither --width,
+// the terminal nor $COLUMNS provide one.
+const defaultPrintWidth = 120
+
+const ansiResetSequence = "\x1b[0m"
+
+// shouldPrintDiff reports whether dv should write the diff to stdout instead
+// of starting the viewer. Printing is automatic when stdout is not a terminal
+// or dv is running as git's pager, unless --print=false was passed explicitly.
+func shouldPrintDiff(printFlag bool, printFlagSet bool, stdoutIsTerminal bool, gitPager bool) bool {
+	if printFlagSet {
+		return printFlag
+	}
+	return printFlag || !stdoutIsTerminal || gitPager
+}
+
+// runningAsGitPager reports whether dv was started as git's pager (core.pager
+// or pager.<cmd>): git pipes its output into the pager and sets
+// GIT_PAGER_IN_USE for it.
+func runningAsGitPager(stdinPiped bool, pagerInUseEnv string) bool {
+	return stdinPiped && pagerInUseEnv != ""
+}
+
+// resolvePrintWidth picks the layout width for printed output: the --width
+// flag, then the terminal width, then $COLUMNS (which git exports to pagers).
+func resolvePrintWidth(requested int, terminalWidth int, columnsEnv string) int {
+	if requested > 0 {
+		return requested
+	}
+	if terminalWidth > 0 {
+		return terminalWidth
+	}
+	if columns, err := strconv.Atoi(strings.TrimSpace(columnsEnv)); err == nil && columns > 0 {
+		return columns
+	}
+	return defaultPrintWidth
+}
+
+// printDiffProvider picks a provider for print mode. Unlike
+// startupDiffProvider it never reopens the terminal, since nothing is read
+// from it.
+func printDiffProvider(workDir string, stdin io.Reader, piped bool, textconv textconvOptions) (DiffProvider, error) {
+	if !piped {
+		return GitDiffProvider{WorkDir: workDir, Textconv: textconv}, nil
+	}
+	rawDiff, err := io.ReadAll(stdin)
+	if err != nil {
+		return nil, fmt.Errorf("read piped diff from stdin: %w", err)
+	}
+	return StdinDiffProvider{WorkDir: workDir, Diff: string(rawDiff)}, nil
+}
+
+// printDiff renders every file to w as ANSI text, using the same diff view
+// and theme palette as the interactive viewer. Each section that has changes
+// is printed, under a heading when there is more than one; --staged prints
+// only the staged section.
+func printDiff(w io.Writer, provider DiffProvider, staged bool, initialState DvInitialState, width int) error {
+	initialState = normalizeDvInitialState(initialState)
+	t.SetTheme(initialState.ThemeName)
+
+	sections := []DiffSection{}
+	docs := map[DiffSection]*DiffDocument{}
+	for _, section := range printDiffSections(provider, staged) {
+		doc, err := loadSectionDiff(provider, section, initialState.IgnoreWhitespace)
+		if err != nil {
+			return err
+		}
+		if len(doc.Files) > 0 {
+			sections = append(sections, section)
+			docs[section] = doc
+		}
+	}
+
+	printed := 0
+	for _, section := range sections {
+		if len(sections) > 1 {
+			if printed > 0 {
+				if _, err := io.WriteString(w, "\n"); err != nil {
+					return err
+				}
+			}
+			if _, err := io.WriteString(w, printedSectionHeading(section)); err != nil {
+				return err
+			}
+			printed = 0
+		}
+		for _, file := range docs[section].Files {
+			if file == nil {
+				continue
+			}
+			if printed > 0 {
+				if _, err := io.WriteString(w, "\n"); err != nil {
+					return err
+				}
+			}
+			if _, err := io.WriteString(w, renderPrintedFile(file, initialState, width, fileHeadersEnabled(provider))); err != nil {
+				return err
+			}
+			printed++
+		}
+	}
+	return nil
+}
+
+// printedSectionHeading introduces a section when more than one is printed.
+func printedSectionHeading(section DiffSection) string {
+	return "\x1b[1m" + section.DisplayName() + " changes" + ansiResetSequence + "\n\n"
+}
+
+// loadStartupSectionDiff loads and parses the section the viewer would start
+// on, for the non-interactive output modes.
+func loadStartupSectionDiff(provider DiffProvider, staged bool, ignoreWhitespace bool) (DiffSection, *DiffDocument, error) {
+	section := printDiffSection(provider, staged)
+	doc, err := loadSectionDiff(provider, section, ignoreWhitespace)
+	return section, doc, err
+}
+
+func loadSectionDiff(provider DiffProvider, section DiffSection, ignoreWhitespace bool) (*DiffDocument, error) {
+	if capable, ok := provider.(IgnoreWhitespaceCapable); ok && !capable.IgnoreWhitespaceEnabled() {
+		ignoreWhitespace = false
+	}
+	raw, err := provider.LoadDiff(section == DiffSectionStaged, ignoreWhitespace)
+	if err != nil {
+		return nil, fmt.Errorf("%s diff: %w", strings.ToLower(section.DisplayName()), err)
+	}
+	doc, err := parseUnifiedDiff(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s parse error: %w", strings.ToLower(section.DisplayName()), err)
+	}
+	return doc, nil
+}
+
+// printDiffSections returns the sections --print writes, in viewer order.
+func printDiffSections(provider DiffProvider, staged bool) []DiffSection {
+	sections := providerDiffSections(provider)
+	if staged && containsSection(sections, DiffSectionStaged) {
+		return []DiffSection{DiffSectionStaged}
+	}
+	return sections
+}
+
+func providerDiffSections(provider DiffProvider) []DiffSection {
+	sections := defaultDiffSections()
+	if customSectionProvider, ok := provider.(DiffSectionsProvider); ok {
+		sections = normalizeDiffSections(customSectionProvider.Sections())
+	}
+	return sections
+}
+
+func printDiffSection(provider DiffProvider, staged bool) DiffSection {
+	sections := providerDiffSections(provider)
+	if staged && containsSection(sections, DiffSectionStaged) {
+		return DiffSectionStaged
+	}
+	return sections[0]
+}
+
+// renderPrintedFile lays one file out with a header row at the given width.
+// Long lines are wrapped rather than cut off, since there is no horizontal
+// scrolling on stdout.
+func renderPrintedFile(file *DiffFile, initialState DvInitialState, width int, fileHeaders bool) string {
+	width = max(1, width)
+	hideSigns := !initialState.ShowChangeSigns
+	header := printedFileHeaderLine(file)
+
+	options := fileRenderOptions{
+		Intraline:      true,
+		WordDiff:       initialState.WordDiff,
+		ShowWhitespace: initialState.ShowWhitespace,
+		FileHeaders:    fileHeaders,
+	}
+	rendered := buildRenderedFileWithOptions(file, options)
+	rendered.Lines = append([]RenderedDiffLine{header}, rendered.Lines...)
+	sideBySide := buildSideBySideRenderedFileWithOptions(file, options)
+	sideBySide.Rows = append([]SideBySideRenderedRow{{Shared: &header}}, sideBySide.Rows...)
+
+	state := NewDiffViewState(rendered)
+	state.SetRenderedPair(rendered, sideBySide)
+
+	height := 0
+	if initialState.LayoutMode == DiffLayoutSideBySide {
+		panes := sideBySidePaneLayout(width, sideBySide, hideSigns, state.SideBySideSplitRatio())
+		height = wrappedSideContentHeight(sideBySide.Rows, panes, width)
+	} else {
+		wrapWidth := max(1, width-renderedGutterWidth(rendered, hideSigns))
+		height = wrappedContentHeight(rendered.Lines, wrapWidth)
+	}
+	height = max(1, height)
+
+	view := DiffView{
+		DisableFocus:    true,
+		State:           state,
+		LayoutMode:      initialState.LayoutMode,
+		HardWrap:        true,
+		HideChangeSigns: hideSigns,
+		IntralineStyle:  initialState.IntralineStyle,
+		PaletteVariant:  initialState.PaletteVariant,
+		PaletteOptions:  initialState.PaletteOptions,
+		Width:           t.Cells(width),
+		Height:          t.Cells(height),
+	}
+	buf, _, _ := t.RenderToBufferWithSize(view, width, height)
+
+	// Every styled run ends in its own reset, so the trailing reset is
+	// redundant and unstyled padding at the end of each line can be dropped.
+	output := strings.TrimSuffix(t.BufferToANSI(buf, width, height), ansiResetSequence)
+	var builder strings.Builder
+	for _, line := range strings.Split(output, "\n") {
+		builder.WriteString(strings.TrimRight(line, " "))
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+func printedFileHeaderLine(file *DiffFile) RenderedDiffLine {
+	segments := []RenderedSegment{{Text: file.DisplayPath, Role: TokenRoleDiffFileHeader}}
+	if file.Additions > 0 {
+		segments = append(segments, RenderedSegment{Text: " +" + strconv.Itoa(file.Additions), Role: TokenRoleDiffPrefixAdd})
+	}
+	if file.Deletions > 0 {
+		segments = append(segments, RenderedSegment{Text: " -" + strconv.Itoa(file.Deletions), Role: TokenRoleDiffPrefixRemove})
+	}
+	return newRenderedLine(RenderedLineFileHeader, 0, 0, " ", segments)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldPrintDiff(t *testing.T) {
+	require.False(t, shouldPrintDiff(false, false, true, false))
+	require.True(t, shouldPrintDiff(true, true, true, false))
+	require.True(t, shouldPrintDiff(false, false, false, false))
+	require.False(t, shouldPrintDiff(false, true, false, false))
+	require.True(t, shouldPrintDiff(false, false, true, true))
+	require.False(t, shouldPrintDiff(false, true, true, true))
+}
+
+func TestRunningAsGitPager(t *testing.T) {
+	require.True(t, runningAsGitPager(true, "true"))
+	require.False(t, runningAsGitPager(true, ""))
+	require.False(t, runningAsGitPager(false, "true"))
+}
+
+func TestResolvePrintWidth(t *testing.T) {
+	require.Equal(t, 90, resolvePrintWidth(90, 200, "100"))
+	require.Equal(t, 200, resolvePrintWidth(0, 200, "100"))
+	require.Equal(t, 100, resolvePrintWidth(0, 0, " 100 "))
+	require.Equal(t, defaultPrintWidth, resolvePrintWidth(0, 0, "wide"))
+}
+
+func TestPrintDiffProvider_ReadsPipedDiffWithoutTerminal(t *testing.T) {
+	diff := diffForPaths("piped.txt")
+	provider, err := printDiffProvider("/tmp/repo", strings.NewReader(diff), true, textconvOptions{})
+	require.NoError(t, err)
+	require.Equal(t, StdinDiffProvider{WorkDir: "/tmp/repo", Diff: diff}, provider)
+
+	textconv := textconvOptions{Enabled: true}
+	provider, err = printDiffProvider("/tmp/repo", strings.NewReader("ignored"), false, textconv)
+	require.NoError(t, err)
+	require.Equal(t, GitDiffProvider{WorkDir: "/tmp/repo", Textconv: textconv}, provider)
+}
+
+func TestPrintDiff_RendersEachFileAsANSI(t *testing.T) {
+	provider := &scriptedDiffProvider{diffs: []string{diffForPaths("a.go", "b.go")}}
+
+	var out strings.Builder
+	require.NoError(t, printDiff(&out, provider, false, DefaultDvInitialState(), 40))
+	require.Contains(t, out.String(), "\x1b[")
+
+	lines := strings.Split(strings.TrimSuffix(ansi.Strip(out.String()), "\n"), "\n")
+	require.Equal(t, []string{
+		"    a.go +1 -1",
+		"    @@ -1 +1 @@",
+		"1   old",
+		"  1 new",
+		"",
+		"    b.go +1 -1",
+		"    @@ -1 +1 @@",
+		"1   old",
+		"  1 new",
+	}, trimRightLines(lines))
+	require.Equal(t, []bool{false, true}, provider.loadStaged)
+}
+
+func TestPrintDiff_SplitLayoutFitsWidthAndWraps(t *testing.T) {
+	long := strings.Repeat("x", 50)
+	diff := "diff --git a/a.md b/a.md\n--- a/a.md\n+++ b/a.md\n@@ -1 +1 @@\n-old\n+" + long + "\n"
+	provider := &scriptedDiffProvider{diffs: []string{diff}}
+	initial := DefaultDvInitialState()
+	initial.LayoutMode = DiffLayoutSideBySide
+
+	var out strings.Builder
+	require.NoError(t, printDiff(&out, provider, false, initial, 40))
+
+	plain := ansi.Strip(out.String())
+	require.Equal(t, long, strings.Join(strings.FieldsFunc(plain, func(r rune) bool { return r != 'x' }), ""))
+	rows := strings.Split(strings.TrimSuffix(plain, "\n"), "\n")
+	require.Greater(t, len(rows), 4)
+	require.Contains(t, rows[2], "old")
+	for _, row := range rows {
+		require.LessOrEqual(t, ansi.StringWidth(row), 40)
+	}
+}
+
+func TestPrintDiff_UsesStagedSectionWhenRequested(t *testing.T) {
+	provider := &scriptedDiffProvider{
+		unstagedDiffs: []string{diffForPaths("unstaged.go")},
+		stagedDiffs:   []string{diffForPaths("staged.go")},
+	}
+
+	var out strings.Builder
+	require.NoError(t, printDiff(&out, provider, true, DefaultDvInitialState(), 60))
+	require.Contains(t, ansi.Strip(out.String()), "staged.go")
+	require.NotContains(t, ansi.Strip(out.String()), "unstaged.go")
+	require.Equal(t, []bool{true}, provider.loadStaged)
+}
+
+func TestPrintDiff_PrintsEachSectionWithChanges(t *testing.T) {
+	provider := &scriptedDiffProvider{
+		unstagedDiffs: []string{diffForPaths("unstaged.go")},
+		stagedDiffs:   []string{diffForPaths("staged.go")},
+	}
+
+	var out strings.Builder
+	require.NoError(t, printDiff(&out, provider, false, DefaultDvInitialState(), 40))
+	lines := strings.Split(strings.TrimSuffix(ansi.Strip(out.String()), "\n"), "\n")
+	require.Equal(t, []string{
+		"Unstaged changes",
+		"",
+		"    unstaged.go +1 -1",
+		"    @@ -1 +1 @@",
+		"1   old",
+		"  1 new",
+		"",
+		"Staged changes",
+		"",
+		"    staged.go +1 -1",
+		"    @@ -1 +1 @@",
+		"1   old",
+		"  1 new",
+	}, trimRightLines(lines))
+}
+
+func trimRightLines(lines []string) []string {
+	trimmed := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed = append(trimmed, strings.TrimRight(line, " "))
+	}
+	return trimmed
+}
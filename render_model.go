@@ -20,8 +20,26 @@ const (
 	RenderedLineAdd
 	RenderedLineRemove
 	RenderedLineMeta
+	RenderedLineComment
+	// RenderedLineMovedRemove and RenderedLineMovedAdd are removed and added
+	// lines that match a block elsewhere in the section.
+	RenderedLineMovedRemove
+	RenderedLineMovedAdd
+	// RenderedLineWordDiff is an added line of a word diff with the removed
+	// words spliced in. It is numbered and addressed on the new side.
+	RenderedLineWordDiff
 )
 
+// isAddition reports whether kind is a line that only exists in the new file.
+func (k RenderedLineKind) isAddition() bool {
+	return k == RenderedLineAdd || k == RenderedLineMovedAdd || k == RenderedLineWordDiff
+}
+
+// isRemoval reports whether kind is a line that only exists in the old file.
+func (k RenderedLineKind) isRemoval() bool {
+	return k == RenderedLineRemove || k == RenderedLineMovedRemove
+}
+
 // TokenRole is a semantic token role used to map to theme styles.
 type TokenRole int
 
@@ -37,6 +55,10 @@ const (
 	TokenRoleDiffHunkHeader
 	TokenRoleDiffMeta
 	TokenRoleDiffHatch
+	TokenRoleDiffWhitespace
+	TokenRoleDiffSuspicious
+	TokenRoleDiffCommentHeader
+	TokenRoleDiffComment
 	TokenRoleSyntaxPlain
 	TokenRoleSyntaxKeyword
 	TokenRoleSyntaxType
@@ -83,6 +105,14 @@ type RenderedDiffLine struct {
 	Prefix       string
 	Segments     []RenderedSegment
 	ContentWidth int
+	// Source is the content of a code line as it appears in the diff, before
+	// tabs are expanded.
+	Source string
+	// Hunk is the 1-based number of the hunk this line belongs to, or 0 for
+	// lines outside of any hunk.
+	Hunk int
+	// Move points moved lines at their counterpart.
+	Move *RenderedLineMove
 }
 
 // RenderedFile is the display model for one file diff.
@@ -101,6 +131,8 @@ type RenderedSideCell struct {
 	Prefix       string
 	Segments     []RenderedSegment
 	ContentWidth int
+	Hunk         int
+	Move         *RenderedLineMove
 }
 
 // SideBySideRenderedRow is a row in side-by-side mode.
@@ -126,12 +158,43 @@ func buildRenderedFile(file *DiffFile) *RenderedFile {
 }
 
 func buildRenderedFileWithIntraline(file *DiffFile, intralineEnabled bool) *RenderedFile {
+	return buildRenderedFileWithOptions(file, fileRenderOptions{Intraline: intralineEnabled})
+}
+
+// fileRenderOptions controls how the changes of a file are rendered.
+type fileRenderOptions struct {
+	Intraline bool
+	// Moves holds the lines of the file to show as moved.
+	Moves fileLineMoves
+	// WordDiff merges each change block into lines with the removed and
+	// added words marked inline.
+	WordDiff bool
+	// ShowWhitespace draws tabs, trailing spaces, non-breaking spaces and
+	// CRs as visible glyphs.
+	ShowWhitespace bool
+	// Plain skips syntax highlighting and the matching of changed lines, for
+	// files too large to afford them.
+	Plain bool
+	// FileHeaders describes a file without hunks by its header lines, for
+	// diffs such as interdiffs whose headers say how the file changed.
+	FileHeaders bool
+}
+
+// lexer returns the lexer to highlight file with, or nil for none.
+func (o fileRenderOptions) lexer(file *DiffFile) chroma.Lexer {
+	if o.Plain {
+		return nil
+	}
+	return chooseLexer(file)
+}
+
+func buildRenderedFileWithOptions(file *DiffFile, options fileRenderOptions) *RenderedFile {
 	if file == nil {
 		return nil
 	}
 
-	lexer := chooseLexer(file)
-	lines := buildRenderLines(file, lexer, intralineEnabled)
+	lexer := options.lexer(file)
+	lines := buildRenderLines(file, lexer, options)
 	if len(lines) == 0 {
 		lines = []RenderedDiffLine{
 			newRenderedLine(RenderedLineMeta, 0, 0, " ", []RenderedSegment{{Text: "No changes to render", Role: TokenRoleDiffMeta}}),
@@ -185,25 +248,22 @@ func buildSideBySideRenderedFile(file *DiffFile) *SideBySideRenderedFile {
 }
 
 func buildSideBySideRenderedFileWithIntraline(file *DiffFile, intralineEnabled bool) *SideBySideRenderedFile {
+	return buildSideBySideRenderedFileWithOptions(file, fileRenderOptions{Intraline: intralineEnabled})
+}
+
+// buildSideBySideRenderedFileWithOptions is the side-by-side counterpart of
+// buildRenderedFileWithOptions. Word-diff lines span both panes.
+func buildSideBySideRenderedFileWithOptions(file *DiffFile, options fileRenderOptions) *SideBySideRenderedFile {
 	if file == nil {
 		return nil
 	}
 
-	lexer := chooseLexer(file)
-	rows := buildSideBySideRows(file, lexer, intralineEnabled)
+	lexer := options.lexer(file)
+	rows := buildSideBySideRows(file, lexer, options)
 	if len(rows) == 0 {
-		message := "No displayable content"
-		if file.IsBinary {
-			message = "Binary file changed"
+		for _, line := range hunklessFileLines(file, options.FileHeaders) {
+			rows = append(rows, SideBySideRenderedRow{Shared: &line})
 		}
-		line := newRenderedLine(
-			RenderedLineMeta,
-			0,
-			0,
-			" ",
-			[]RenderedSegment{{Text: message, Role: TokenRoleDiffMeta}},
-		)
-		rows = append(rows, SideBySideRenderedRow{Shared: &line})
 	}
 
 	leftNumWidth, rightNumWidth, leftMax, rightMax := sideBySideMetrics(rows)
@@ -255,46 +315,73 @@ func buildSideBySideFromRendered(rendered *RenderedFile) *SideBySideRenderedFile
 	}
 }
 
-func buildRenderLines(file *DiffFile, lexer chroma.Lexer, intralineEnabled bool) []RenderedDiffLine {
+func buildRenderLines(file *DiffFile, lexer chroma.Lexer, options fileRenderOptions) []RenderedDiffLine {
 	lines := make([]RenderedDiffLine, 0, len(file.Headers)+len(file.Hunks)*8)
-	for _, hunk := range file.Hunks {
-		lines = append(lines, newRenderedLine(
+	for hunkIdx, hunk := range file.Hunks {
+		header := newRenderedLine(
 			RenderedLineHunkHeader,
 			0,
 			0,
 			" ",
 			[]RenderedSegment{{Text: hunk.Header, Role: TokenRoleDiffHunkHeader}},
-		))
-		blocks := buildHunkRenderBlocks(hunk, lexer, intralineEnabled)
+		)
+		header.Hunk = hunkIdx + 1
+		lines = append(lines, header)
+		blocks := buildHunkRenderBlocks(hunk, lexer, options)
+		tagHunkRenderBlocks(blocks, hunkIdx+1)
 		for _, block := range blocks {
 			if block.Shared != nil {
 				lines = append(lines, *block.Shared)
 				continue
 			}
 			lines = append(lines, block.Removes...)
+			lines = append(lines, block.Merged...)
 			lines = append(lines, block.Adds...)
 		}
 	}
 
 	if len(lines) == 0 {
-		message := "No displayable content"
-		if file.IsBinary {
-			message = "Binary file changed"
-		}
-		lines = append(lines, newRenderedLine(
-			RenderedLineMeta,
-			0,
-			0,
-			" ",
-			[]RenderedSegment{{Text: message, Role: TokenRoleDiffMeta}},
-		))
+		lines = hunklessFileLines(file, options.FileHeaders)
 	}
 	return lines
 }
 
-func buildSideBySideRows(file *DiffFile, lexer chroma.Lexer, intralineEnabled bool) []SideBySideRenderedRow {
+// hunklessFileLines describes a file with no hunks, such as a binary or
+// mode-only change. With headers, the header lines that say what changed are
+// shown too.
+func hunklessFileLines(file *DiffFile, headers bool) []RenderedDiffLine {
+	var lines []RenderedDiffLine
+	for _, header := range file.Headers {
+		if !headers || isStructuralFileHeader(header) {
+			continue
+		}
+		lines = append(lines, newRenderedLine(RenderedLineMeta, 0, 0, " ", []RenderedSegment{{Text: header, Role: TokenRoleDiffMeta}}))
+	}
+	message := "No displayable content"
+	switch {
+	case file.IsBinary:
+		message = "Binary file changed"
+	case len(lines) > 0:
+		return lines
+	}
+	return append(lines, newRenderedLine(RenderedLineMeta, 0, 0, " ", []RenderedSegment{{Text: message, Role: TokenRoleDiffMeta}}))
+}
+
+// isStructuralFileHeader reports whether a file header line only names the
+// file, its blobs or its binary contents, which the file title and the
+// "Binary file changed" message already cover.
+func isStructuralFileHeader(header string) bool {
+	for _, prefix := range []string{"diff --git ", "index ", "--- ", "+++ ", "Binary files ", "GIT binary patch"} {
+		if strings.HasPrefix(header, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func buildSideBySideRows(file *DiffFile, lexer chroma.Lexer, options fileRenderOptions) []SideBySideRenderedRow {
 	rows := make([]SideBySideRenderedRow, 0, len(file.Headers)+len(file.Hunks)*8)
-	for _, hunk := range file.Hunks {
+	for hunkIdx, hunk := range file.Hunks {
 		header := newRenderedLine(
 			RenderedLineHunkHeader,
 			0,
@@ -302,8 +389,10 @@ func buildSideBySideRows(file *DiffFile, lexer chroma.Lexer, intralineEnabled bo
 			" ",
 			[]RenderedSegment{{Text: hunk.Header, Role: TokenRoleDiffHunkHeader}},
 		)
+		header.Hunk = hunkIdx + 1
 		rows = append(rows, SideBySideRenderedRow{Shared: &header})
-		blocks := buildHunkRenderBlocks(hunk, lexer, intralineEnabled)
+		blocks := buildHunkRenderBlocks(hunk, lexer, options)
+		tagHunkRenderBlocks(blocks, hunkIdx+1)
 		for _, block := range blocks {
 			if block.Shared != nil {
 				if block.Shared.Kind == RenderedLineContext {
@@ -318,15 +407,27 @@ func buildSideBySideRows(file *DiffFile, lexer chroma.Lexer, intralineEnabled bo
 				continue
 			}
 
+			if block.Merged != nil {
+				for _, removeLine := range block.Removes {
+					rows = append(rows, SideBySideRenderedRow{Left: leftCellFromRenderedLine(removeLine)})
+				}
+				for idx := range block.Merged {
+					rows = append(rows, SideBySideRenderedRow{Shared: &block.Merged[idx]})
+				}
+				for _, addLine := range block.Adds {
+					rows = append(rows, SideBySideRenderedRow{Right: rightCellFromRenderedLine(addLine)})
+				}
+				continue
+			}
+
 			if len(block.Removes) > 0 {
-				rowCount := max(len(block.Removes), len(block.Adds))
-				for pairIdx := 0; pairIdx < rowCount; pairIdx++ {
+				for _, blockRow := range changeBlockRows(len(block.Removes), len(block.Adds), block.Pairs) {
 					row := SideBySideRenderedRow{}
-					if pairIdx < len(block.Removes) {
-						row.Left = leftCellFromRenderedLine(block.Removes[pairIdx])
+					if blockRow.Remove >= 0 {
+						row.Left = leftCellFromRenderedLine(block.Removes[blockRow.Remove])
 					}
-					if pairIdx < len(block.Adds) {
-						row.Right = rightCellFromRenderedLine(block.Adds[pairIdx])
+					if blockRow.Add >= 0 {
+						row.Right = rightCellFromRenderedLine(block.Adds[blockRow.Add])
 					}
 					rows = append(rows, row)
 				}
@@ -345,78 +446,140 @@ type hunkRenderedBlock struct {
 	Shared  *RenderedDiffLine
 	Removes []RenderedDiffLine
 	Adds    []RenderedDiffLine
+	Pairs   []changeLinePair
+	// Merged holds the word-diff lines of the block. Removes and Adds then
+	// only hold its moved lines.
+	Merged []RenderedDiffLine
 }
 
-func buildHunkRenderBlocks(hunk DiffHunk, lexer chroma.Lexer, intralineEnabled bool) []hunkRenderedBlock {
+func buildHunkRenderBlocks(hunk DiffHunk, lexer chroma.Lexer, options fileRenderOptions) []hunkRenderedBlock {
 	blocks := make([]hunkRenderedBlock, 0, len(hunk.Lines))
 	for idx := 0; idx < len(hunk.Lines); {
 		line := hunk.Lines[idx]
 		switch line.Kind {
 		case DiffLineContext:
-			rendered := renderedLineFromDiffLine(line, lexer)
+			rendered := renderedLineFromDiffLine(line, lexer, options.ShowWhitespace)
 			blocks = append(blocks, hunkRenderedBlock{Shared: &rendered})
 			idx++
 		case DiffLineRemove:
 			removes := make([]RenderedDiffLine, 0, 4)
 			for idx < len(hunk.Lines) && hunk.Lines[idx].Kind == DiffLineRemove {
-				removes = append(removes, renderedLineFromDiffLine(hunk.Lines[idx], lexer))
+				removes = append(removes, movedRenderedLine(renderedLineFromDiffLine(hunk.Lines[idx], lexer, options.ShowWhitespace), options.Moves))
 				idx++
 			}
 
 			adds := make([]RenderedDiffLine, 0, 4)
 			for idx < len(hunk.Lines) && hunk.Lines[idx].Kind == DiffLineAdd {
-				adds = append(adds, renderedLineFromDiffLine(hunk.Lines[idx], lexer))
+				adds = append(adds, movedRenderedLine(renderedLineFromDiffLine(hunk.Lines[idx], lexer, options.ShowWhitespace), options.Moves))
 				idx++
 			}
 
-			if intralineEnabled {
-				markIntralinePairedLines(removes, adds)
+			if options.Plain {
+				blocks = append(blocks, hunkRenderedBlock{Removes: removes, Adds: adds})
+				continue
+			}
+			if options.WordDiff {
+				if block, ok := buildWordDiffBlock(removes, adds); ok {
+					blocks = append(blocks, block)
+					continue
+				}
+			}
+			pairs := pairChangeBlock(removes, adds)
+			if options.Intraline {
+				markChangeLinePairs(removes, adds, pairs)
 			}
 			blocks = append(blocks, hunkRenderedBlock{
 				Removes: removes,
 				Adds:    adds,
+				Pairs:   pairs,
 			})
 		case DiffLineAdd:
 			adds := make([]RenderedDiffLine, 0, 4)
 			for idx < len(hunk.Lines) && hunk.Lines[idx].Kind == DiffLineAdd {
-				adds = append(adds, renderedLineFromDiffLine(hunk.Lines[idx], lexer))
+				adds = append(adds, movedRenderedLine(renderedLineFromDiffLine(hunk.Lines[idx], lexer, options.ShowWhitespace), options.Moves))
 				idx++
 			}
 			blocks = append(blocks, hunkRenderedBlock{Adds: adds})
 		default:
-			rendered := renderedLineFromDiffLine(line, lexer)
+			rendered := renderedLineFromDiffLine(line, lexer, options.ShowWhitespace)
 			blocks = append(blocks, hunkRenderedBlock{Shared: &rendered})
 			idx++
 		}
 	}
+	for blockIdx := range blocks {
+		labelBlockSuspiciousChars(&blocks[blockIdx])
+	}
 	return blocks
 }
 
-func markIntralinePairedLines(removes []RenderedDiffLine, adds []RenderedDiffLine) {
-	if len(removes) == 0 || len(adds) == 0 {
-		return
+// labelBlockSuspiciousChars labels suspicious characters once a block's
+// intraline marks are in place, since the labels do not line up with the
+// lines' Source.
+func labelBlockSuspiciousChars(block *hunkRenderedBlock) {
+	if block.Shared != nil && block.Shared.Kind != RenderedLineMeta {
+		labeled := labelSuspiciousChars(*block.Shared)
+		block.Shared = &labeled
+	}
+	for _, lines := range [][]RenderedDiffLine{block.Removes, block.Adds, block.Merged} {
+		for idx := range lines {
+			lines[idx] = labelSuspiciousChars(lines[idx])
+		}
 	}
+}
 
-	pairCount := min(len(removes), len(adds))
-	for idx := 0; idx < pairCount; idx++ {
-		removeMask, addMask, ok := intralinePairMasks(removes[idx], adds[idx])
-		if !ok {
-			break
+func tagHunkRenderBlocks(blocks []hunkRenderedBlock, hunk int) {
+	for blockIdx := range blocks {
+		block := &blocks[blockIdx]
+		if block.Shared != nil {
+			block.Shared.Hunk = hunk
+		}
+		for idx := range block.Removes {
+			block.Removes[idx].Hunk = hunk
+		}
+		for idx := range block.Adds {
+			block.Adds[idx].Hunk = hunk
+		}
+		for idx := range block.Merged {
+			block.Merged[idx].Hunk = hunk
 		}
-		removes[idx] = markLineIntraline(removes[idx], removeMask, IntralineMarkRemove)
-		adds[idx] = markLineIntraline(adds[idx], addMask, IntralineMarkAdd)
 	}
 }
 
+// movedRenderedLine marks line as moved when moves has it.
+func movedRenderedLine(line RenderedDiffLine, moves fileLineMoves) RenderedDiffLine {
+	var key lineMoveKey
+	switch line.Kind {
+	case RenderedLineRemove:
+		key = lineMoveKey{Kind: DiffLineRemove, Line: line.OldLine}
+	case RenderedLineAdd:
+		key = lineMoveKey{Kind: DiffLineAdd, Line: line.NewLine}
+	default:
+		return line
+	}
+	move, ok := moves[key]
+	if !ok {
+		return line
+	}
+	if line.Kind == RenderedLineRemove {
+		line.Kind = RenderedLineMovedRemove
+	} else {
+		line.Kind = RenderedLineMovedAdd
+	}
+	line.Move = &move
+	return line
+}
+
 const (
 	intralineSuppressChangedRatio    = 0.70
 	intralineMinSharedWordSimilarity = 0.30
 	intralineMinPerSideWordCoverage  = 0.35
 )
 
+// intralinePairMasks compares the Source of code lines, so edits that only
+// change whitespace are marked even where tabs render as spaces.
 func intralinePairMasks(removeLine RenderedDiffLine, addLine RenderedDiffLine) (removeMask []bool, addMask []bool, ok bool) {
-	removeText := renderedLineText(removeLine)
-	addText := renderedLineText(addLine)
+	removeText := intralineSourceText(removeLine)
+	addText := intralineSourceText(addLine)
 	removeGraphemes := splitGraphemes(removeText)
 	addGraphemes := splitGraphemes(addText)
 	removeGraphemeCount := len(removeGraphemes)
@@ -433,9 +596,22 @@ func intralinePairMasks(removeLine RenderedDiffLine, addLine RenderedDiffLine) (
 		shouldSuppressForWeakSharedWordSimilarity(removeGraphemes, addGraphemes, removeMask, addMask) {
 		return nil, nil, false
 	}
+	if removeLine.Source != "" {
+		removeMask = expandSourceMask(removeLine.Source, removeMask, diffTabWidth)
+	}
+	if addLine.Source != "" {
+		addMask = expandSourceMask(addLine.Source, addMask, diffTabWidth)
+	}
 	return removeMask, addMask, true
 }
 
+func intralineSourceText(line RenderedDiffLine) string {
+	if line.Source != "" {
+		return line.Source
+	}
+	return renderedLineText(line)
+}
+
 func shouldSuppressIntralineMasks(oldMask []bool, newMask []bool) bool {
 	oldChanged, oldTotal := maskStats(oldMask)
 	newChanged, newTotal := maskStats(newMask)
@@ -537,32 +713,15 @@ func appendSegmentWithMark(segments *[]RenderedSegment, role TokenRole, intralin
 	})
 }
 
-func renderedLineFromDiffLine(line DiffLine, lexer chroma.Lexer) RenderedDiffLine {
+func renderedLineFromDiffLine(line DiffLine, lexer chroma.Lexer, showWhitespace bool) RenderedDiffLine {
+	rendered := RenderedDiffLine{Source: line.Content}
 	switch line.Kind {
 	case DiffLineContext:
-		return newRenderedLine(
-			RenderedLineContext,
-			line.OldLine,
-			line.NewLine,
-			" ",
-			lineSegmentsForCode(line.Content, lexer),
-		)
+		rendered.Kind, rendered.OldLine, rendered.NewLine, rendered.Prefix = RenderedLineContext, line.OldLine, line.NewLine, " "
 	case DiffLineAdd:
-		return newRenderedLine(
-			RenderedLineAdd,
-			0,
-			line.NewLine,
-			"+",
-			lineSegmentsForCode(line.Content, lexer),
-		)
+		rendered.Kind, rendered.NewLine, rendered.Prefix = RenderedLineAdd, line.NewLine, "+"
 	case DiffLineRemove:
-		return newRenderedLine(
-			RenderedLineRemove,
-			line.OldLine,
-			0,
-			"-",
-			lineSegmentsForCode(line.Content, lexer),
-		)
+		rendered.Kind, rendered.OldLine, rendered.Prefix = RenderedLineRemove, line.OldLine, "-"
 	default:
 		return newRenderedLine(
 			RenderedLineMeta,
@@ -572,6 +731,14 @@ func renderedLineFromDiffLine(line DiffLine, lexer chroma.Lexer) RenderedDiffLin
 			[]RenderedSegment{{Text: line.Content, Role: TokenRoleDiffMeta}},
 		)
 	}
+
+	content, crlf := strings.CutSuffix(line.Content, "\r")
+	segments := lineSegmentsForCode(content, lexer)
+	if crlf {
+		segments = append(segments, RenderedSegment{Text: "\r", Role: TokenRoleDiffWhitespace})
+	}
+	rendered.Segments, rendered.ContentWidth = expandTabsInSegments(segments, diffTabWidth, showWhitespace)
+	return rendered
 }
 
 func leftCellFromRenderedLine(line RenderedDiffLine) *RenderedSideCell {
@@ -581,6 +748,8 @@ func leftCellFromRenderedLine(line RenderedDiffLine) *RenderedSideCell {
 		Prefix:       line.Prefix,
 		Segments:     line.Segments,
 		ContentWidth: line.ContentWidth,
+		Hunk:         line.Hunk,
+		Move:         line.Move,
 	}
 }
 
@@ -591,7 +760,22 @@ func rightCellFromRenderedLine(line RenderedDiffLine) *RenderedSideCell {
 		Prefix:       line.Prefix,
 		Segments:     line.Segments,
 		ContentWidth: line.ContentWidth,
+		Hunk:         line.Hunk,
+		Move:         line.Move,
+	}
+}
+
+// sideRowHunk returns the 1-based hunk number of a side-by-side row.
+func sideRowHunk(row SideBySideRenderedRow) int {
+	switch {
+	case row.Shared != nil:
+		return row.Shared.Hunk
+	case row.Left != nil:
+		return row.Left.Hunk
+	case row.Right != nil:
+		return row.Right.Hunk
 	}
+	return 0
 }
 
 func sideBySideMetrics(rows []SideBySideRenderedRow) (leftNumWidth int, rightNumWidth int, leftMax int, rightMax int) {
@@ -662,7 +846,7 @@ func lineSegmentsForCode(content string, lexer chroma.Lexer) []RenderedSegment {
 }
 
 func newRenderedLine(kind RenderedLineKind, oldLine int, newLine int, prefix string, segments []RenderedSegment) RenderedDiffLine {
-	expanded, width := expandTabsInSegments(segments, diffTabWidth)
+	expanded, width := expandTabsInSegments(segments, diffTabWidth, false)
 	return RenderedDiffLine{
 		Kind:         kind,
 		OldLine:      oldLine,
@@ -696,9 +880,9 @@ func lineNumberText(number int, width int) string {
 
 func prefixRoleForLine(kind RenderedLineKind) (TokenRole, bool) {
 	switch kind {
-	case RenderedLineAdd:
+	case RenderedLineAdd, RenderedLineMovedAdd, RenderedLineWordDiff:
 		return TokenRoleDiffPrefixAdd, true
-	case RenderedLineRemove:
+	case RenderedLineRemove, RenderedLineMovedRemove:
 		return TokenRoleDiffPrefixRemove, true
 	case RenderedLineContext:
 		return TokenRoleDiffPrefixContext, true
@@ -744,13 +928,36 @@ func lexLineSegments(lexer chroma.Lexer, content string) []RenderedSegment {
 	return result
 }
 
-func expandTabsInSegments(segments []RenderedSegment, tabWidth int) ([]RenderedSegment, int) {
+// Glyphs drawn in place of whitespace when it is shown.
+const (
+	whitespaceTabGlyph   = "→"
+	whitespaceSpaceGlyph = "·"
+	whitespaceNBSPGlyph  = "␣"
+	whitespaceCRGlyph    = "␍"
+)
+
+// expandTabsInSegments expands tabs to the next tab stop and drops a line's
+// trailing CR. With showWhitespace, tabs start with an arrow, and trailing
+// spaces, non-breaking spaces and the CR are drawn as glyphs.
+func expandTabsInSegments(segments []RenderedSegment, tabWidth int, showWhitespace bool) ([]RenderedSegment, int) {
 	if tabWidth <= 0 {
 		tabWidth = diffTabWidth
 	}
 
+	trailingFrom := 0
+	graphemeIdx := 0
+	for _, segment := range segments {
+		for _, grapheme := range splitGraphemes(segment.Text) {
+			graphemeIdx++
+			if !isBlankGrapheme(grapheme) {
+				trailingFrom = graphemeIdx
+			}
+		}
+	}
+
 	expanded := make([]RenderedSegment, 0, len(segments))
 	column := 0
+	graphemeIdx = 0
 	for _, segment := range segments {
 		remaining := segment.Text
 		for len(remaining) > 0 {
@@ -758,14 +965,30 @@ func expandTabsInSegments(segments []RenderedSegment, tabWidth int) ([]RenderedS
 			if grapheme == "" {
 				break
 			}
-			if grapheme == "\t" {
+			switch {
+			case grapheme == "\t":
 				spaces := tabWidth - (column % tabWidth)
 				if spaces <= 0 {
 					spaces = tabWidth
 				}
-				appendRoleText(&expanded, segment.Role, strings.Repeat(" ", spaces))
+				if showWhitespace {
+					appendRoleText(&expanded, TokenRoleDiffWhitespace, whitespaceTabGlyph+strings.Repeat(" ", spaces-1))
+				} else {
+					appendRoleText(&expanded, segment.Role, strings.Repeat(" ", spaces))
+				}
 				column += spaces
-			} else {
+			case grapheme == "\r" && segment.Role == TokenRoleDiffWhitespace:
+				if showWhitespace {
+					appendRoleText(&expanded, TokenRoleDiffWhitespace, whitespaceCRGlyph)
+					column++
+				}
+			case showWhitespace && grapheme == "\u00a0":
+				appendRoleText(&expanded, TokenRoleDiffWhitespace, whitespaceNBSPGlyph)
+				column++
+			case showWhitespace && grapheme == " " && graphemeIdx >= trailingFrom:
+				appendRoleText(&expanded, TokenRoleDiffWhitespace, whitespaceSpaceGlyph)
+				column++
+			default:
 				appendRoleText(&expanded, segment.Role, grapheme)
 				if width <= 0 {
 					width = ansi.StringWidth(grapheme)
@@ -775,6 +998,7 @@ func expandTabsInSegments(segments []RenderedSegment, tabWidth int) ([]RenderedS
 				}
 				column += width
 			}
+			graphemeIdx++
 			remaining = remaining[len(grapheme):]
 		}
 	}
@@ -782,6 +1006,39 @@ func expandTabsInSegments(segments []RenderedSegment, tabWidth int) ([]RenderedS
 	return expanded, column
 }
 
+// isBlankGrapheme reports whether grapheme is whitespace that can trail a
+// line: a space, tab, non-breaking space or CR.
+func isBlankGrapheme(grapheme string) bool {
+	switch grapheme {
+	case " ", "\t", "\u00a0", "\r":
+		return true
+	default:
+		return false
+	}
+}
+
+// expandSourceMask maps a mask over the graphemes of a line's Source onto
+// the graphemes it renders as, where each tab covers the spaces up to the
+// next tab stop.
+func expandSourceMask(source string, mask []bool, tabWidth int) []bool {
+	expanded := make([]bool, 0, len(mask))
+	column := 0
+	for idx, grapheme := range splitGraphemes(source) {
+		value := idx < len(mask) && mask[idx]
+		if grapheme != "\t" {
+			expanded = append(expanded, value)
+			column += max(ansi.StringWidth(grapheme), 1)
+			continue
+		}
+		spaces := tabWidth - (column % tabWidth)
+		for range spaces {
+			expanded = append(expanded, value)
+		}
+		column += spaces
+	}
+	return expanded
+}
+
 func appendRoleText(segments *[]RenderedSegment, role TokenRole, text string) {
 	if text == "" {
 		return
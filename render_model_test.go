@@ -216,6 +216,27 @@ func TestBuildRenderedFile_IntralineMarksChangedWordChunks(t *testing.T) {
 	require.Equal(t, indexRange(7, 12), markedIndicesForLine(add, IntralineMarkAdd))
 }
 
+func TestBuildRenderedFile_IntralineMarksTabsReplacedBySpaces(t *testing.T) {
+	file := &DiffFile{
+		DisplayPath: "main.go",
+		Hunks: []DiffHunk{
+			{
+				Header: "@@ -1,1 +1,1 @@",
+				Lines: []DiffLine{
+					{Kind: DiffLineRemove, Content: "    x := 1", OldLine: 1},
+					{Kind: DiffLineAdd, Content: "\tx := 1", NewLine: 1},
+				},
+			},
+		},
+	}
+
+	rendered := buildRenderedFile(file)
+	require.Len(t, rendered.Lines, 3)
+	require.Equal(t, lineText(rendered.Lines[1]), lineText(rendered.Lines[2]))
+	require.Equal(t, indexRange(0, 4), markedIndicesForLine(rendered.Lines[1], IntralineMarkRemove))
+	require.Equal(t, indexRange(0, 4), markedIndicesForLine(rendered.Lines[2], IntralineMarkAdd))
+}
+
 func TestBuildRenderedFile_WithIntralineDisabledDoesNotMarkSegments(t *testing.T) {
 	file := &DiffFile{
 		DisplayPath: "main.go",
@@ -503,7 +524,7 @@ func TestBuildRenderedFile_IntralineSuppressesWhenOnlyFewWordsMatchOnLongLine(t
 	require.Empty(t, markedIndicesForLine(rendered.Lines[2], IntralineMarkAdd))
 }
 
-func TestBuildRenderedFile_IntralineStopsAfterWeakSimilarityPair(t *testing.T) {
+func TestBuildRenderedFile_IntralinePairsPastWeakSimilarityPair(t *testing.T) {
 	file := &DiffFile{
 		DisplayPath: "main.go",
 		Hunks: []DiffHunk{
@@ -523,12 +544,12 @@ func TestBuildRenderedFile_IntralineStopsAfterWeakSimilarityPair(t *testing.T) {
 	require.NotNil(t, rendered)
 	require.Len(t, rendered.Lines, 5)
 	require.Empty(t, markedIndicesForLine(rendered.Lines[1], IntralineMarkRemove))
-	require.Empty(t, markedIndicesForLine(rendered.Lines[2], IntralineMarkRemove))
+	require.Equal(t, indexRange(7, 13), markedIndicesForLine(rendered.Lines[2], IntralineMarkRemove))
 	require.Empty(t, markedIndicesForLine(rendered.Lines[3], IntralineMarkAdd))
-	require.Empty(t, markedIndicesForLine(rendered.Lines[4], IntralineMarkAdd))
+	require.Equal(t, indexRange(7, 13), markedIndicesForLine(rendered.Lines[4], IntralineMarkAdd))
 }
 
-func TestBuildRenderedFile_IntralineStopsOnEmptyVsNonEmptyPair(t *testing.T) {
+func TestBuildRenderedFile_IntralinePairsPastEmptyVsNonEmptyPair(t *testing.T) {
 	file := &DiffFile{
 		DisplayPath: "main.go",
 		Hunks: []DiffHunk{
@@ -550,9 +571,9 @@ func TestBuildRenderedFile_IntralineStopsOnEmptyVsNonEmptyPair(t *testing.T) {
 
 	// First pair should be treated as divergent, so no intraline on the addition.
 	require.Empty(t, markedIndicesForLine(rendered.Lines[3], IntralineMarkAdd))
-	// Matching should stop for the rest of the run.
-	require.Empty(t, markedIndicesForLine(rendered.Lines[2], IntralineMarkRemove))
-	require.Empty(t, markedIndicesForLine(rendered.Lines[4], IntralineMarkAdd))
+	// The similar lines after it still pair up.
+	require.NotEmpty(t, markedIndicesForLine(rendered.Lines[2], IntralineMarkRemove))
+	require.NotEmpty(t, markedIndicesForLine(rendered.Lines[4], IntralineMarkAdd))
 
 	side := buildSideBySideRenderedFile(file)
 	require.NotNil(t, side)
@@ -563,8 +584,8 @@ func TestBuildRenderedFile_IntralineStopsOnEmptyVsNonEmptyPair(t *testing.T) {
 	require.Empty(t, markedIndicesForSideCell(side.Rows[1].Right, IntralineMarkAdd))
 	require.NotNil(t, side.Rows[2].Left)
 	require.NotNil(t, side.Rows[2].Right)
-	require.Empty(t, markedIndicesForSideCell(side.Rows[2].Left, IntralineMarkRemove))
-	require.Empty(t, markedIndicesForSideCell(side.Rows[2].Right, IntralineMarkAdd))
+	require.NotEmpty(t, markedIndicesForSideCell(side.Rows[2].Left, IntralineMarkRemove))
+	require.NotEmpty(t, markedIndicesForSideCell(side.Rows[2].Right, IntralineMarkAdd))
 }
 
 func TestTokenRoleFromChroma_SeparatesOperatorsFromPunctuation(t *testing.T) {
@@ -648,3 +669,197 @@ func indexRange(start int, endExclusive int) []int {
 	}
 	return indices
 }
+
+func TestBuildRenderedFiles_TagLinesWithHunkNumbers(t *testing.T) {
+	file := &DiffFile{
+		DisplayPath: "a.txt",
+		Hunks: []DiffHunk{
+			{Header: "@@ -1 +1 @@", Lines: []DiffLine{
+				{Kind: DiffLineRemove, Content: "a", OldLine: 1},
+				{Kind: DiffLineAdd, Content: "b", NewLine: 1},
+			}},
+			{Header: "@@ -9 +9 @@", Lines: []DiffLine{
+				{Kind: DiffLineContext, Content: "c", OldLine: 9, NewLine: 9},
+			}},
+		},
+	}
+
+	rendered := buildRenderedFile(file)
+	hunks := make([]int, 0, len(rendered.Lines))
+	for _, line := range rendered.Lines {
+		hunks = append(hunks, line.Hunk)
+	}
+	require.Equal(t, []int{1, 1, 1, 2, 2}, hunks)
+
+	side := buildSideBySideRenderedFile(file)
+	rowHunks := make([]int, 0, len(side.Rows))
+	for _, row := range side.Rows {
+		rowHunks = append(rowHunks, sideRowHunk(row))
+	}
+	require.Equal(t, []int{1, 1, 2, 2}, rowHunks)
+	require.Equal(t, 1, side.Rows[1].Left.Hunk)
+	require.Equal(t, 1, side.Rows[1].Right.Hunk)
+}
+
+func TestBuildRenderedFileWithMoves_MarksMovedLinesAndPairsTheRest(t *testing.T) {
+	file := &DiffFile{
+		DisplayPath: "main.go",
+		Hunks: []DiffHunk{
+			{
+				Header: "@@ -1,2 +1,1 @@",
+				Lines: []DiffLine{
+					{Kind: DiffLineRemove, Content: "return computeMovedAnswer()", OldLine: 1},
+					{Kind: DiffLineRemove, Content: "prefix value suffix", OldLine: 2},
+					{Kind: DiffLineAdd, Content: "prefix valve suffix", NewLine: 1},
+				},
+			},
+		},
+	}
+	move := RenderedLineMove{Path: "other.go", Side: ReviewCommentSideNew, Line: 9}
+	moves := fileLineMoves{{Kind: DiffLineRemove, Line: 1}: move}
+
+	rendered := buildRenderedFileWithOptions(file, fileRenderOptions{Intraline: true, Moves: moves})
+	require.Len(t, rendered.Lines, 4)
+	require.Equal(t, RenderedLineMovedRemove, rendered.Lines[1].Kind)
+	require.Equal(t, &move, rendered.Lines[1].Move)
+	require.Empty(t, markedIndicesForLine(rendered.Lines[1], IntralineMarkRemove))
+	require.Equal(t, RenderedLineRemove, rendered.Lines[2].Kind)
+	require.Nil(t, rendered.Lines[2].Move)
+	require.Equal(t, indexRange(7, 12), markedIndicesForLine(rendered.Lines[2], IntralineMarkRemove))
+	require.Equal(t, indexRange(7, 12), markedIndicesForLine(rendered.Lines[3], IntralineMarkAdd))
+
+	side := buildSideBySideRenderedFileWithOptions(file, fileRenderOptions{Intraline: true, Moves: moves})
+	require.NotNil(t, side.Rows[1].Left)
+	require.Equal(t, RenderedLineMovedRemove, side.Rows[1].Left.Kind)
+	require.Equal(t, &move, side.Rows[1].Left.Move)
+}
+
+func TestBuildRenderedFile_IntralinePairsLinesBySimilarityAroundInsertions(t *testing.T) {
+	file := &DiffFile{
+		DisplayPath: "main.go",
+		Hunks: []DiffHunk{
+			{
+				Header: "@@ -1,2 +1,3 @@",
+				Lines: []DiffLine{
+					{Kind: DiffLineRemove, Content: "total := sum(values)", OldLine: 1},
+					{Kind: DiffLineRemove, Content: "return total", OldLine: 2},
+					{Kind: DiffLineAdd, Content: "log.Println(\"summing\")", NewLine: 1},
+					{Kind: DiffLineAdd, Content: "total := sum(items)", NewLine: 2},
+					{Kind: DiffLineAdd, Content: "return total", NewLine: 3},
+				},
+			},
+		},
+	}
+
+	rendered := buildRenderedFile(file)
+	require.Len(t, rendered.Lines, 6)
+	require.Equal(t, indexRange(13, 19), markedIndicesForLine(rendered.Lines[1], IntralineMarkRemove))
+	require.Empty(t, markedIndicesForLine(rendered.Lines[2], IntralineMarkRemove))
+	require.Empty(t, markedIndicesForLine(rendered.Lines[3], IntralineMarkAdd))
+	require.Equal(t, indexRange(13, 18), markedIndicesForLine(rendered.Lines[4], IntralineMarkAdd))
+	require.Empty(t, markedIndicesForLine(rendered.Lines[5], IntralineMarkAdd))
+
+	side := buildSideBySideRenderedFile(file)
+	require.Len(t, side.Rows, 4)
+	require.Nil(t, side.Rows[1].Left)
+	require.Equal(t, "log.Println(\"summing\")", sideCellText(side.Rows[1].Right))
+	require.Equal(t, "total := sum(values)", sideCellText(side.Rows[2].Left))
+	require.Equal(t, "total := sum(items)", sideCellText(side.Rows[2].Right))
+	require.Equal(t, "return total", sideCellText(side.Rows[3].Left))
+	require.Equal(t, "return total", sideCellText(side.Rows[3].Right))
+}
+
+func TestBuildRenderedFileWithOptions_ShowWhitespaceDrawsGlyphs(t *testing.T) {
+	file := &DiffFile{
+		DisplayPath: "notes.txt",
+		Hunks: []DiffHunk{
+			{
+				Header: "@@ -1,2 +1,2 @@",
+				Lines: []DiffLine{
+					{Kind: DiffLineContext, Content: "a\u00a0b", OldLine: 1, NewLine: 1},
+					{Kind: DiffLineRemove, Content: "\tfoo bar\r", OldLine: 2},
+					{Kind: DiffLineAdd, Content: "\tfoo bar  ", NewLine: 2},
+				},
+			},
+		},
+	}
+
+	plain := buildRenderedFileWithOptions(file, fileRenderOptions{Intraline: true})
+	require.Equal(t, "a\u00a0b", lineText(plain.Lines[1]))
+	require.Equal(t, "    foo bar", lineText(plain.Lines[2]))
+	require.Equal(t, 11, plain.Lines[2].ContentWidth)
+	require.Equal(t, "    foo bar  ", lineText(plain.Lines[3]))
+
+	rendered := buildRenderedFileWithOptions(file, fileRenderOptions{Intraline: true, ShowWhitespace: true})
+	require.Len(t, rendered.Lines, 4)
+	require.Equal(t, "a␣b", lineText(rendered.Lines[1]))
+	require.Equal(t, "→   foo bar␍", lineText(rendered.Lines[2]))
+	require.Equal(t, 12, rendered.Lines[2].ContentWidth)
+	require.Equal(t, "→   foo bar··", lineText(rendered.Lines[3]))
+	require.Equal(t, RenderedSegment{Text: "→   ", Role: TokenRoleDiffWhitespace}, rendered.Lines[3].Segments[0])
+	require.Equal(t, []int{11}, markedIndicesForLine(rendered.Lines[2], IntralineMarkRemove))
+	require.Equal(t, indexRange(11, 13), markedIndicesForLine(rendered.Lines[3], IntralineMarkAdd))
+}
+
+func TestBuildRenderedFileWithOptions_WordDiffMergesChangeBlocks(t *testing.T) {
+	file := &DiffFile{
+		DisplayPath: "README.md",
+		Hunks: []DiffHunk{
+			{
+				Header: "@@ -1,2 +1,2 @@",
+				Lines: []DiffLine{
+					{Kind: DiffLineContext, Content: "# Title", OldLine: 1, NewLine: 1},
+					{Kind: DiffLineRemove, Content: "Some old words here.", OldLine: 2},
+					{Kind: DiffLineAdd, Content: "Some new words here.", NewLine: 2},
+				},
+			},
+		},
+	}
+	options := fileRenderOptions{Intraline: true, WordDiff: true}
+
+	rendered := buildRenderedFileWithOptions(file, options)
+	require.Len(t, rendered.Lines, 3)
+	merged := rendered.Lines[2]
+	require.Equal(t, RenderedLineWordDiff, merged.Kind)
+	require.Equal(t, "Some [-old-]{+new+} words here.", lineText(merged))
+	require.Zero(t, merged.OldLine)
+	require.Equal(t, 2, merged.NewLine)
+	require.Equal(t, 1, merged.Hunk)
+	targetSide, targetLine, ok := unifiedLineTarget(merged)
+	require.True(t, ok)
+	require.Equal(t, ReviewCommentSideNew, targetSide)
+	require.Equal(t, 2, targetLine)
+
+	side := buildSideBySideRenderedFileWithOptions(file, options)
+	require.Len(t, side.Rows, 3)
+	require.NotNil(t, side.Rows[2].Shared)
+	require.Equal(t, "Some [-old-]{+new+} words here.", lineText(*side.Rows[2].Shared))
+}
+
+func TestBuildRenderedFileWithOptions_PlainSkipsHighlightingAndIntraline(t *testing.T) {
+	file := &DiffFile{
+		DisplayPath: "main.go",
+		NewPath:     "main.go",
+		Hunks: []DiffHunk{
+			{
+				Header: "@@ -1 +1 @@",
+				Lines: []DiffLine{
+					{Kind: DiffLineRemove, Content: "func oldName() {}", OldLine: 1},
+					{Kind: DiffLineAdd, Content: "func newName() {}", NewLine: 1},
+				},
+			},
+		},
+	}
+
+	highlighted := buildRenderedFileWithOptions(file, fileRenderOptions{Intraline: true})
+	require.Equal(t, TokenRoleSyntaxKeyword, highlighted.Lines[2].Segments[0].Role)
+	require.NotEmpty(t, markedIndicesForLine(highlighted.Lines[2], IntralineMarkAdd))
+
+	plain := buildRenderedFileWithOptions(file, fileRenderOptions{Intraline: true, Plain: true})
+	require.Len(t, plain.Lines, 3)
+	require.Equal(t, "func newName() {}", lineText(plain.Lines[2]))
+	for _, segment := range plain.Lines[2].Segments {
+		require.Equal(t, TokenRoleSyntaxPlain, segment.Role)
+	}
+	require.Empty(t, markedIndicesForLine(plain.Lines[2], IntralineMarkAdd))
+}
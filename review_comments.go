@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultReviewCommentsExportName = "dv-review.md"
+
+// ReviewCommentSide is the side of the diff a review comment is attached to.
+type ReviewCommentSide string
+
+const (
+	ReviewCommentSideOld ReviewCommentSide = "old"
+	ReviewCommentSideNew ReviewCommentSide = "new"
+)
+
+// ReviewComment is a note attached to a line or line range of a diffed file.
+type ReviewComment struct {
+	Section   DiffSection
+	Path      string
+	Side      ReviewCommentSide
+	StartLine int
+	EndLine   int
+	Body      string
+	// Excerpt holds the commented lines with their diff prefixes, captured
+	// when the comment was written.
+	Excerpt []string
+}
+
+// reviewLineSpan is an inclusive range of file lines on one side of a diff.
+// The zero value selects nothing.
+type reviewLineSpan struct {
+	side  ReviewCommentSide
+	start int
+	end   int
+}
+
+func newReviewLineSpan(side ReviewCommentSide, first int, second int) reviewLineSpan {
+	if second < first {
+		first, second = second, first
+	}
+	return reviewLineSpan{side: side, start: first, end: second}
+}
+
+func (s reviewLineSpan) isEmpty() bool {
+	return s.side == "" || s.start <= 0
+}
+
+func (s reviewLineSpan) contains(side ReviewCommentSide, line int) bool {
+	return !s.isEmpty() && side == s.side && line >= s.start && line <= s.end
+}
+
+func (s reviewLineSpan) label() string {
+	if s.start == s.end {
+		return fmt.Sprintf("%s line %d", s.side, s.start)
+	}
+	return fmt.Sprintf("%s lines %d-%d", s.side, s.start, s.end)
+}
+
+func (c ReviewComment) span() reviewLineSpan {
+	return newReviewLineSpan(c.Side, c.StartLine, c.EndLine)
+}
+
+// LineLabel formats the commented lines the way editors reference them,
+// e.g. "L12" or "L12-14".
+func (c ReviewComment) LineLabel() string {
+	if c.StartLine == c.EndLine {
+		return "L" + strconv.Itoa(c.StartLine)
+	}
+	return fmt.Sprintf("L%d-%d", c.StartLine, c.EndLine)
+}
+
+func (c ReviewComment) sameTarget(other ReviewComment) bool {
+	return c.Section == other.Section && c.Path == other.Path && c.span() == other.span()
+}
+
+func sortReviewComments(comments []ReviewComment) {
+	sort.SliceStable(comments, func(i, j int) bool {
+		left, right := comments[i], comments[j]
+		if left.Path != right.Path {
+			return left.Path < right.Path
+		}
+		if left.Section != right.Section {
+			return left.Section < right.Section
+		}
+		if left.StartLine != right.StartLine {
+			return left.StartLine < right.StartLine
+		}
+		return left.Side < right.Side
+	})
+}
+
+// unifiedLineTarget returns the file line a unified row refers to. Rows that
+// exist in the new file are addressed on the new side.
+func unifiedLineTarget(line RenderedDiffLine) (ReviewCommentSide, int, bool) {
+	switch line.Kind {
+	case RenderedLineContext, RenderedLineAdd, RenderedLineRemove, RenderedLineMovedAdd, RenderedLineMovedRemove, RenderedLineWordDiff:
+	default:
+		return "", 0, false
+	}
+	if line.NewLine > 0 {
+		return ReviewCommentSideNew, line.NewLine, true
+	}
+	if line.OldLine > 0 {
+		return ReviewCommentSideOld, line.OldLine, true
+	}
+	return "", 0, false
+}
+
+func sideCellLineTarget(cell *RenderedSideCell, isLeft bool) (ReviewCommentSide, int, bool) {
+	if cell == nil || cell.LineNumber <= 0 {
+		return "", 0, false
+	}
+	switch cell.Kind {
+	case RenderedLineContext, RenderedLineAdd, RenderedLineRemove, RenderedLineMovedAdd, RenderedLineMovedRemove, RenderedLineWordDiff:
+	default:
+		return "", 0, false
+	}
+	if isLeft {
+		return ReviewCommentSideOld, cell.LineNumber, true
+	}
+	return ReviewCommentSideNew, cell.LineNumber, true
+}
+
+func unifiedLineSelected(span reviewLineSpan, line RenderedDiffLine) bool {
+	if span.isEmpty() {
+		return false
+	}
+	switch line.Kind {
+	case RenderedLineContext, RenderedLineAdd, RenderedLineRemove, RenderedLineMovedAdd, RenderedLineMovedRemove, RenderedLineWordDiff:
+	default:
+		return false
+	}
+	if span.side == ReviewCommentSideOld {
+		return span.contains(ReviewCommentSideOld, line.OldLine)
+	}
+	return span.contains(ReviewCommentSideNew, line.NewLine)
+}
+
+// reviewCommentExcerpt collects the diff lines covered by span, prefixed with
+// their change markers.
+func reviewCommentExcerpt(file *DiffFile, span reviewLineSpan) []string {
+	if file == nil || span.isEmpty() {
+		return nil
+	}
+	excerpt := []string{}
+	for _, hunk := range file.Hunks {
+		for _, line := range hunk.Lines {
+			number := line.NewLine
+			if span.side == ReviewCommentSideOld {
+				number = line.OldLine
+			}
+			if line.Kind == DiffLineMeta || !span.contains(span.side, number) {
+				continue
+			}
+			excerpt = append(excerpt, diffLinePrefix(line)+strings.TrimSuffix(line.Content, "\r"))
+		}
+	}
+	return excerpt
+}
+
+// decorateRenderedWithReviewComments returns a copy of rendered with an
+// annotation block inserted below the last line of each comment's range.
+func decorateRenderedWithReviewComments(rendered *RenderedFile, comments []ReviewComment) *RenderedFile {
+	if rendered == nil || len(comments) == 0 {
+		return rendered
+	}
+	lines := make([]RenderedDiffLine, 0, len(rendered.Lines)+len(comments)*2)
+	for _, line := range rendered.Lines {
+		lines = append(lines, line)
+		for _, comment := range comments {
+			if !reviewCommentAnchoredAtLine(comment, line) {
+				continue
+			}
+			lines = append(lines, reviewCommentRenderedLines(comment, line.Hunk)...)
+		}
+	}
+	decorated := *rendered
+	decorated.Lines = lines
+	return &decorated
+}
+
+// decorateSideBySideWithReviewComments is the side-by-side counterpart of
+// decorateRenderedWithReviewComments. Annotations span both panes.
+func decorateSideBySideWithReviewComments(sideBySide *SideBySideRenderedFile, comments []ReviewComment) *SideBySideRenderedFile {
+	if sideBySide == nil || len(comments) == 0 {
+		return sideBySide
+	}
+	rows := make([]SideBySideRenderedRow, 0, len(sideBySide.Rows)+len(comments)*2)
+	for _, row := range sideBySide.Rows {
+		rows = append(rows, row)
+		for _, comment := range comments {
+			if !reviewCommentAnchoredAtSideRow(comment, row) {
+				continue
+			}
+			for _, line := range reviewCommentRenderedLines(comment, sideRowHunk(row)) {
+				shared := line
+				rows = append(rows, SideBySideRenderedRow{Shared: &shared})
+			}
+		}
+	}
+	decorated := *sideBySide
+	decorated.Rows = rows
+	return &decorated
+}
+
+func reviewCommentAnchoredAtLine(comment ReviewComment, line RenderedDiffLine) bool {
+	switch line.Kind {
+	case RenderedLineContext, RenderedLineAdd, RenderedLineRemove, RenderedLineMovedAdd, RenderedLineMovedRemove, RenderedLineWordDiff:
+	default:
+		return false
+	}
+	if comment.Side == ReviewCommentSideOld {
+		return line.OldLine == comment.EndLine
+	}
+	return line.NewLine == comment.EndLine
+}
+
+func reviewCommentAnchoredAtSideRow(comment ReviewComment, row SideBySideRenderedRow) bool {
+	if row.Shared != nil {
+		return false
+	}
+	cell, isLeft := row.Right, false
+	if comment.Side == ReviewCommentSideOld {
+		cell, isLeft = row.Left, true
+	}
+	side, line, ok := sideCellLineTarget(cell, isLeft)
+	return ok && side == comment.Side && line == comment.EndLine
+}
+
+func reviewCommentRenderedLines(comment ReviewComment, hunk int) []RenderedDiffLine {
+	header := newRenderedLine(RenderedLineComment, 0, 0, " ", []RenderedSegment{
+		{Text: "┃ ", Role: TokenRoleDiffCommentHeader},
+		{Text: "Comment on " + comment.span().label(), Role: TokenRoleDiffCommentHeader},
+	})
+	header.Hunk = hunk
+	lines := []RenderedDiffLine{header}
+	for _, bodyLine := range strings.Split(strings.TrimRight(comment.Body, "\n"), "\n") {
+		line := newRenderedLine(RenderedLineComment, 0, 0, " ", []RenderedSegment{
+			{Text: "┃ ", Role: TokenRoleDiffCommentHeader},
+			{Text: bodyLine, Role: TokenRoleDiffComment},
+		})
+		line.Hunk = hunk
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// formatReviewCommentsMarkdown renders comments grouped by file, each with
+// its location, a code excerpt and the comment text.
+// markdownCodeFence returns a backtick fence longer than any run of backticks
+// in lines, so that code in the excerpt can't close the block early.
+func markdownCodeFence(lines []string) string {
+	longest := 0
+	for _, line := range lines {
+		run := 0
+		for _, r := range line {
+			if r != '`' {
+				run = 0
+				continue
+			}
+			run++
+			longest = max(longest, run)
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+func formatReviewCommentsMarkdown(comments []ReviewComment) string {
+	sorted := append([]ReviewComment(nil), comments...)
+	sortReviewComments(sorted)
+
+	var builder strings.Builder
+	builder.WriteString("# Review comments\n")
+	lastFile := ""
+	for _, comment := range sorted {
+		fileHeading := comment.Path
+		if comment.Section == DiffSectionStaged {
+			fileHeading += " (staged)"
+		}
+		if fileHeading != lastFile {
+			fmt.Fprintf(&builder, "\n## %s\n", fileHeading)
+			lastFile = fileHeading
+		}
+		fmt.Fprintf(&builder, "\n### %s (%s)\n\n", comment.LineLabel(), comment.Side)
+		if len(comment.Excerpt) > 0 {
+			fence := markdownCodeFence(comment.Excerpt)
+			builder.WriteString(fence + "diff\n")
+			for _, line := range comment.Excerpt {
+				builder.WriteString(line)
+				builder.WriteString("\n")
+			}
+			builder.WriteString(fence + "\n\n")
+		}
+		builder.WriteString(strings.TrimRight(comment.Body, "\n"))
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func reviewCommentTestFile() *DiffFile {
+	return &DiffFile{
+		DisplayPath: "main.go",
+		Hunks: []DiffHunk{
+			{Header: "@@ -1,3 +1,3 @@", Lines: []DiffLine{
+				{Kind: DiffLineContext, Content: "package main", OldLine: 1, NewLine: 1},
+				{Kind: DiffLineRemove, Content: "old()", OldLine: 2},
+				{Kind: DiffLineAdd, Content: "new()", NewLine: 2},
+				{Kind: DiffLineContext, Content: "end()", OldLine: 3, NewLine: 3},
+			}},
+		},
+	}
+}
+
+func TestReviewCommentExcerpt_CollectsLinesOnOneSide(t *testing.T) {
+	file := reviewCommentTestFile()
+
+	require.Equal(t, []string{" package main", "+new()"}, reviewCommentExcerpt(file, newReviewLineSpan(ReviewCommentSideNew, 2, 1)))
+	require.Equal(t, []string{"-old()"}, reviewCommentExcerpt(file, newReviewLineSpan(ReviewCommentSideOld, 2, 2)))
+	require.Empty(t, reviewCommentExcerpt(file, reviewLineSpan{}))
+
+	crlf := &DiffFile{Hunks: []DiffHunk{{Lines: []DiffLine{{Kind: DiffLineAdd, Content: "x := 1\r", NewLine: 1}}}}}
+	require.Equal(t, []string{"+x := 1"}, reviewCommentExcerpt(crlf, newReviewLineSpan(ReviewCommentSideNew, 1, 1)))
+}
+
+func TestDecorateRenderedWithReviewComments_InsertsBlockBelowRange(t *testing.T) {
+	rendered := buildRenderedFile(reviewCommentTestFile())
+	comments := []ReviewComment{
+		{Path: "main.go", Side: ReviewCommentSideOld, StartLine: 2, EndLine: 2, Body: "why remove?"},
+		{Path: "main.go", Side: ReviewCommentSideNew, StartLine: 1, EndLine: 2, Body: "looks good\nsecond line"},
+	}
+
+	decorated := decorateRenderedWithReviewComments(rendered, comments)
+	require.Len(t, rendered.Lines, 5)
+	require.Len(t, decorated.Lines, 10)
+
+	kinds := make([]RenderedLineKind, 0, len(decorated.Lines))
+	for _, line := range decorated.Lines {
+		kinds = append(kinds, line.Kind)
+	}
+	require.Equal(t, []RenderedLineKind{
+		RenderedLineHunkHeader,
+		RenderedLineContext,
+		RenderedLineRemove,
+		RenderedLineComment,
+		RenderedLineComment,
+		RenderedLineAdd,
+		RenderedLineComment,
+		RenderedLineComment,
+		RenderedLineComment,
+		RenderedLineContext,
+	}, kinds)
+	require.Equal(t, "┃ Comment on old line 2", lineText(decorated.Lines[3]))
+	require.Equal(t, "┃ why remove?", lineText(decorated.Lines[4]))
+	require.Equal(t, "┃ Comment on new lines 1-2", lineText(decorated.Lines[6]))
+	require.Equal(t, "┃ second line", lineText(decorated.Lines[8]))
+	require.Equal(t, 1, decorated.Lines[4].Hunk)
+
+	require.Same(t, rendered, decorateRenderedWithReviewComments(rendered, nil))
+}
+
+func TestDecorateSideBySideWithReviewComments_UsesPaneForSide(t *testing.T) {
+	side := buildSideBySideRenderedFile(reviewCommentTestFile())
+	comments := []ReviewComment{
+		{Path: "main.go", Side: ReviewCommentSideNew, StartLine: 3, EndLine: 3, Body: "tail"},
+	}
+
+	decorated := decorateSideBySideWithReviewComments(side, comments)
+	require.Len(t, decorated.Rows, len(side.Rows)+2)
+	last := decorated.Rows[len(decorated.Rows)-1]
+	require.NotNil(t, last.Shared)
+	require.Equal(t, RenderedLineComment, last.Shared.Kind)
+	require.Equal(t, "┃ tail", lineText(*last.Shared))
+	require.Equal(t, 3, decorated.Rows[len(decorated.Rows)-3].Right.LineNumber)
+}
+
+func TestFormatReviewCommentsMarkdown(t *testing.T) {
+	comments := []ReviewComment{
+		{Section: DiffSectionUnstaged, Path: "b.go", Side: ReviewCommentSideNew, StartLine: 4, EndLine: 4, Body: "rename", Excerpt: []string{"+x := 1"}},
+		{Section: DiffSectionStaged, Path: "a.go", Side: ReviewCommentSideOld, StartLine: 7, EndLine: 9, Body: "keep this\n"},
+	}
+
+	require.Equal(t, "# Review comments\n"+
+		"\n## a.go (staged)\n"+
+		"\n### L7-9 (old)\n\n"+
+		"keep this\n"+
+		"\n## b.go\n"+
+		"\n### L4 (new)\n\n"+
+		"```diff\n+x := 1\n```\n\n"+
+		"rename\n", formatReviewCommentsMarkdown(comments))
+}
+
+func TestFormatReviewCommentsMarkdown_FenceOutlastsBackticksInExcerpt(t *testing.T) {
+	comments := []ReviewComment{
+		{Path: "README.md", Side: ReviewCommentSideNew, StartLine: 1, EndLine: 2, Body: "ok", Excerpt: []string{"+```go", "+run `x`"}},
+	}
+
+	require.Equal(t, "# Review comments\n"+
+		"\n## README.md\n"+
+		"\n### L1-2 (new)\n\n"+
+		"````diff\n+```go\n+run `x`\n````\n\n"+
+		"ok\n", formatReviewCommentsMarkdown(comments))
+	require.Equal(t, "```", markdownCodeFence([]string{"+plain", "+`x`"}))
+}
+
+func TestWriteReviewCommentsExport_CreatesParentDirectories(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes", "review.md")
+	comments := []ReviewComment{{Path: "a.go", Side: ReviewCommentSideNew, StartLine: 1, EndLine: 1, Body: "hi"}}
+
+	require.NoError(t, writeReviewCommentsExport(path, ReviewCommentsExportMarkdown, comments, nil))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, formatReviewCommentsMarkdown(comments), string(data))
+}
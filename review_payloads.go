@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ReviewCommentsExportFormat selects the file format review comments are
+// exported as.
+type ReviewCommentsExportFormat string
+
+const (
+	ReviewCommentsExportMarkdown ReviewCommentsExportFormat = "markdown"
+	ReviewCommentsExportGitHub   ReviewCommentsExportFormat = "github"
+	ReviewCommentsExportGitLab   ReviewCommentsExportFormat = "gitlab"
+)
+
+func (f ReviewCommentsExportFormat) defaultFileName() string {
+	switch f {
+	case ReviewCommentsExportGitHub:
+		return "dv-review.github.json"
+	case ReviewCommentsExportGitLab:
+		return "dv-review.gitlab.json"
+	default:
+		return defaultReviewCommentsExportName
+	}
+}
+
+func (f ReviewCommentsExportFormat) label() string {
+	switch f {
+	case ReviewCommentsExportGitHub:
+		return "GitHub review JSON"
+	case ReviewCommentsExportGitLab:
+		return "GitLab discussions JSON"
+	default:
+		return "Markdown"
+	}
+}
+
+// reviewCommentFileLookup returns the current diff of the file a comment was
+// written against, or nil when it is no longer part of the diff.
+type reviewCommentFileLookup func(section DiffSection, path string) *DiffFile
+
+// githubReviewPayload is the request body of GitHub's "create a review for a
+// pull request" endpoint.
+type githubReviewPayload struct {
+	Event    string                `json:"event"`
+	Comments []githubReviewComment `json:"comments"`
+}
+
+type githubReviewComment struct {
+	Path      string `json:"path"`
+	Body      string `json:"body"`
+	Line      int    `json:"line"`
+	Side      string `json:"side"`
+	StartLine int    `json:"start_line,omitempty"`
+	StartSide string `json:"start_side,omitempty"`
+}
+
+// gitlabDiscussion is the request body of GitLab's "create a new thread in the
+// merge request diff" endpoint. The SHA fields are left empty for the caller
+// to fill in with the merge request's diff refs.
+type gitlabDiscussion struct {
+	Body     string         `json:"body"`
+	Position gitlabPosition `json:"position"`
+}
+
+type gitlabPosition struct {
+	PositionType string           `json:"position_type"`
+	BaseSHA      string           `json:"base_sha"`
+	StartSHA     string           `json:"start_sha"`
+	HeadSHA      string           `json:"head_sha"`
+	OldPath      string           `json:"old_path"`
+	NewPath      string           `json:"new_path"`
+	OldLine      int              `json:"old_line,omitempty"`
+	NewLine      int              `json:"new_line,omitempty"`
+	LineRange    *gitlabLineRange `json:"line_range,omitempty"`
+}
+
+type gitlabLineRange struct {
+	Start gitlabLinePoint `json:"start"`
+	End   gitlabLinePoint `json:"end"`
+}
+
+type gitlabLinePoint struct {
+	LineCode string `json:"line_code"`
+	Type     string `json:"type,omitempty"`
+	OldLine  int    `json:"old_line,omitempty"`
+	NewLine  int    `json:"new_line,omitempty"`
+}
+
+func buildGitHubReviewPayload(comments []ReviewComment, lookup reviewCommentFileLookup) githubReviewPayload {
+	sorted := append([]ReviewComment(nil), comments...)
+	sortReviewComments(sorted)
+
+	payload := githubReviewPayload{Event: "COMMENT", Comments: make([]githubReviewComment, 0, len(sorted))}
+	for _, comment := range sorted {
+		side := "RIGHT"
+		if comment.Side == ReviewCommentSideOld {
+			side = "LEFT"
+		}
+		entry := githubReviewComment{
+			Path: reviewCommentRemotePath(comment, lookupReviewCommentFile(lookup, comment)),
+			Body: comment.Body,
+			Line: comment.EndLine,
+			Side: side,
+		}
+		if comment.StartLine != comment.EndLine {
+			entry.StartLine = comment.StartLine
+			entry.StartSide = side
+		}
+		payload.Comments = append(payload.Comments, entry)
+	}
+	return payload
+}
+
+func buildGitLabDiscussions(comments []ReviewComment, lookup reviewCommentFileLookup) []gitlabDiscussion {
+	sorted := append([]ReviewComment(nil), comments...)
+	sortReviewComments(sorted)
+
+	discussions := make([]gitlabDiscussion, 0, len(sorted))
+	for _, comment := range sorted {
+		file := lookupReviewCommentFile(lookup, comment)
+		oldPath, newPath := comment.Path, comment.Path
+		if file != nil {
+			if file.OldPath != "" {
+				oldPath = file.OldPath
+			}
+			if file.NewPath != "" {
+				newPath = file.NewPath
+			}
+		}
+
+		end := gitlabPointForLine(oldPath, newPath, comment.Side, comment.EndLine, file)
+		position := gitlabPosition{
+			PositionType: "text",
+			OldPath:      oldPath,
+			NewPath:      newPath,
+			OldLine:      end.OldLine,
+			NewLine:      end.NewLine,
+		}
+		if comment.StartLine != comment.EndLine {
+			position.LineRange = &gitlabLineRange{
+				Start: gitlabPointForLine(oldPath, newPath, comment.Side, comment.StartLine, file),
+				End:   end,
+			}
+		}
+		discussions = append(discussions, gitlabDiscussion{Body: comment.Body, Position: position})
+	}
+	return discussions
+}
+
+func lookupReviewCommentFile(lookup reviewCommentFileLookup, comment ReviewComment) *DiffFile {
+	if lookup == nil {
+		return nil
+	}
+	return lookup(comment.Section, comment.Path)
+}
+
+// reviewCommentRemotePath is the path code hosts key review comments by: the
+// new path, or the old one for deleted files.
+func reviewCommentRemotePath(comment ReviewComment, file *DiffFile) string {
+	if file == nil {
+		return comment.Path
+	}
+	if file.NewPath != "" {
+		return file.NewPath
+	}
+	if file.OldPath != "" {
+		return file.OldPath
+	}
+	return comment.Path
+}
+
+// gitlabPointForLine describes a commented line the way GitLab positions
+// address it: unchanged lines carry both line numbers, added and removed
+// lines only their own side.
+func gitlabPointForLine(oldPath string, newPath string, side ReviewCommentSide, number int, file *DiffFile) gitlabLinePoint {
+	point := gitlabLinePoint{}
+	line, ok := findDiffLine(file, side, number)
+	switch {
+	case ok && line.Kind == DiffLineContext:
+		point.OldLine = line.OldLine
+		point.NewLine = line.NewLine
+	case side == ReviewCommentSideOld:
+		point.Type = "old"
+		point.OldLine = number
+	default:
+		point.Type = "new"
+		point.NewLine = number
+	}
+	path := newPath
+	if point.Type == "old" {
+		path = oldPath
+	}
+	point.LineCode = gitlabLineCode(path, point.OldLine, point.NewLine)
+	return point
+}
+
+// gitlabLineCode builds GitLab's "<sha1 of path>_<old line>_<new line>" line
+// identifier.
+func gitlabLineCode(path string, oldLine int, newLine int) string {
+	sum := sha1.Sum([]byte(path))
+	return fmt.Sprintf("%s_%d_%d", hex.EncodeToString(sum[:]), oldLine, newLine)
+}
+
+// findDiffLine returns the diff line with the given line number on one side
+// of a file diff.
+func findDiffLine(file *DiffFile, side ReviewCommentSide, number int) (DiffLine, bool) {
+	if file == nil || number <= 0 {
+		return DiffLine{}, false
+	}
+	for _, hunk := range file.Hunks {
+		for _, line := range hunk.Lines {
+			switch {
+			case side == ReviewCommentSideOld && line.Kind != DiffLineAdd && line.OldLine == number:
+				return line, true
+			case side == ReviewCommentSideNew && line.Kind != DiffLineRemove && line.NewLine == number:
+				return line, true
+			}
+		}
+	}
+	return DiffLine{}, false
+}
+
+func formatReviewCommentsExport(format ReviewCommentsExportFormat, comments []ReviewComment, lookup reviewCommentFileLookup) ([]byte, error) {
+	var payload any
+	switch format {
+	case ReviewCommentsExportMarkdown:
+		return []byte(formatReviewCommentsMarkdown(comments)), nil
+	case ReviewCommentsExportGitHub:
+		payload = buildGitHubReviewPayload(comments, lookup)
+	case ReviewCommentsExportGitLab:
+		payload = buildGitLabDiscussions(comments, lookup)
+	default:
+		return nil, fmt.Errorf("unknown review comments export format %q", format)
+	}
+	data, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode %s: %w", format.label(), err)
+	}
+	return append(data, '\n'), nil
+}
+
+func writeReviewCommentsExport(path string, format ReviewCommentsExportFormat, comments []ReviewComment, lookup reviewCommentFileLookup) error {
+	data, err := formatReviewCommentsExport(format, comments, lookup)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("create directory for %q: %w", path, err)
+		}
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write review comments %q: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func reviewPayloadTestLookup(section DiffSection, path string) *DiffFile {
+	if path != "main.go" {
+		return nil
+	}
+	file := reviewCommentTestFile()
+	file.OldPath = "old_main.go"
+	file.NewPath = "main.go"
+	return file
+}
+
+func TestBuildGitHubReviewPayload_UsesLineAndSide(t *testing.T) {
+	comments := []ReviewComment{
+		{Path: "main.go", Side: ReviewCommentSideNew, StartLine: 1, EndLine: 2, Body: "range"},
+		{Path: "main.go", Side: ReviewCommentSideOld, StartLine: 2, EndLine: 2, Body: "removed"},
+	}
+
+	payload := buildGitHubReviewPayload(comments, reviewPayloadTestLookup)
+	require.Equal(t, "COMMENT", payload.Event)
+	require.Equal(t, []githubReviewComment{
+		{Path: "main.go", Body: "range", Line: 2, Side: "RIGHT", StartLine: 1, StartSide: "RIGHT"},
+		{Path: "main.go", Body: "removed", Line: 2, Side: "LEFT"},
+	}, payload.Comments)
+}
+
+func TestBuildGitLabDiscussions_AddressesLinesByKind(t *testing.T) {
+	comments := []ReviewComment{
+		{Path: "main.go", Side: ReviewCommentSideNew, StartLine: 1, EndLine: 2, Body: "range"},
+		{Path: "main.go", Side: ReviewCommentSideOld, StartLine: 2, EndLine: 2, Body: "removed"},
+		{Path: "gone.go", Side: ReviewCommentSideNew, StartLine: 5, EndLine: 5, Body: "outdated"},
+	}
+
+	discussions := buildGitLabDiscussions(comments, reviewPayloadTestLookup)
+	require.Len(t, discussions, 3)
+
+	outdated := discussions[0].Position
+	require.Equal(t, "gone.go", outdated.OldPath)
+	require.Equal(t, 5, outdated.NewLine)
+	require.Zero(t, outdated.OldLine)
+
+	ranged := discussions[1].Position
+	require.Equal(t, "text", ranged.PositionType)
+	require.Equal(t, "old_main.go", ranged.OldPath)
+	require.Equal(t, "main.go", ranged.NewPath)
+	require.Zero(t, ranged.OldLine)
+	require.Equal(t, 2, ranged.NewLine)
+	require.NotNil(t, ranged.LineRange)
+	require.Equal(t, gitlabLinePoint{LineCode: gitlabLineCode("main.go", 1, 1), OldLine: 1, NewLine: 1}, ranged.LineRange.Start)
+	require.Equal(t, gitlabLinePoint{LineCode: gitlabLineCode("main.go", 0, 2), Type: "new", NewLine: 2}, ranged.LineRange.End)
+
+	removed := discussions[2].Position
+	require.Equal(t, 2, removed.OldLine)
+	require.Zero(t, removed.NewLine)
+	require.Nil(t, removed.LineRange)
+}
+
+func TestGitLabLineCode(t *testing.T) {
+	require.Equal(t, "1bf4fdcca6c69b16320601b75601e06a8a68d102_3_4", gitlabLineCode("a.go", 3, 4))
+	require.NotEqual(t, gitlabLineCode("a.go", 3, 4), gitlabLineCode("b.go", 3, 4))
+}
+
+func TestFormatReviewCommentsExport_EncodesJSON(t *testing.T) {
+	comments := []ReviewComment{{Path: "main.go", Side: ReviewCommentSideNew, StartLine: 3, EndLine: 3, Body: "ok"}}
+
+	data, err := formatReviewCommentsExport(ReviewCommentsExportGitHub, comments, reviewPayloadTestLookup)
+	require.NoError(t, err)
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, "COMMENT", decoded["event"])
+	entry := decoded["comments"].([]any)[0].(map[string]any)
+	require.Equal(t, "main.go", entry["path"])
+	require.Equal(t, float64(3), entry["line"])
+	require.Equal(t, "RIGHT", entry["side"])
+	require.NotContains(t, entry, "start_line")
+
+	data, err = formatReviewCommentsExport(ReviewCommentsExportGitLab, comments, reviewPayloadTestLookup)
+	require.NoError(t, err)
+	var discussions []map[string]any
+	require.NoError(t, json.Unmarshal(data, &discussions))
+	position := discussions[0]["position"].(map[string]any)
+	require.Equal(t, float64(3), position["old_line"])
+	require.Equal(t, float64(3), position["new_line"])
+	require.Contains(t, position, "base_sha")
+
+	_, err = formatReviewCommentsExport("xml", comments, nil)
+	require.Error(t, err)
+}
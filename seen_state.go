@@ -0,0 +1,250 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const seenStateVersion = 2
+
+const (
+	// seenAllHunksMark is stored with a file's marks while every hunk of it
+	// is seen, so that a hunk added later shows the file as changed since
+	// seen rather than as partly seen.
+	seenAllHunksMark = "all"
+	// seenIgnoreWhitespacePrefix starts marks made while whitespace changes
+	// are ignored. Hunks differ between the two modes, so each keeps its own
+	// marks and toggling does not show seen files as changed.
+	seenIgnoreWhitespacePrefix = "ignore-whitespace:"
+)
+
+// seenStateFile is the on-disk layout of persisted seen marks. Marks are
+// grouped by scope (repo root plus provider scope) and map a
+// "<section>/<path>" key to the content hashes of the hunks marked as seen.
+type seenStateFile struct {
+	Version int                            `json:"version"`
+	Scopes  map[string]map[string][]string `json:"scopes"`
+}
+
+// errSeenStateUnreadable marks a seen state file that exists but cannot be
+// decoded.
+var errSeenStateUnreadable = errors.New("parse seen state")
+
+// SeenStore persists seen marks to a JSON file under the XDG state directory.
+type SeenStore struct {
+	Path string
+}
+
+func defaultSeenStatePath(stateHome string) string {
+	if stateHome == "" {
+		return ""
+	}
+	return filepath.Join(stateHome, "dv", "seen.json")
+}
+
+func seenStateScope(repoRoot string, providerScope string) string {
+	if providerScope == "" {
+		return repoRoot
+	}
+	return repoRoot + " " + providerScope
+}
+
+// Load returns the seen hunk hashes stored for scope, keyed like
+// diffFileReviewKey. A file that cannot be decoded is moved aside to
+// "<path>.corrupt" so that later saves start fresh; the returned error says
+// where it went.
+func (s SeenStore) Load(scope string) (map[string]map[string]bool, error) {
+	state, err := s.read()
+	if errors.Is(err, errSeenStateUnreadable) {
+		backupPath := s.Path + ".corrupt"
+		if renameErr := os.Rename(s.Path, backupPath); renameErr != nil {
+			return nil, fmt.Errorf("%w (moving it aside: %w)", err, renameErr)
+		}
+		return nil, fmt.Errorf("%w; moved it to %q and started fresh", err, backupPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	marks := map[string]map[string]bool{}
+	for storedKey, hashes := range state.Scopes[scope] {
+		section, filePath, ok := strings.Cut(storedKey, "/")
+		if !ok || len(hashes) == 0 {
+			continue
+		}
+		key := diffFileReviewKey(DiffSection(section), filePath)
+		if key == "" {
+			continue
+		}
+		seen := make(map[string]bool, len(hashes))
+		for _, hash := range hashes {
+			seen[hash] = true
+		}
+		marks[key] = seen
+	}
+	return marks, nil
+}
+
+// Save replaces the marks stored for scope, leaving other scopes untouched.
+func (s SeenStore) Save(scope string, marks map[string]map[string]bool) error {
+	if s.Path == "" {
+		return nil
+	}
+	state, err := s.read()
+	if err != nil {
+		return err
+	}
+	if len(marks) == 0 {
+		delete(state.Scopes, scope)
+	} else {
+		stored := make(map[string][]string, len(marks))
+		for key, seen := range marks {
+			section, filePath, ok := strings.Cut(key, "\x00")
+			if !ok || len(seen) == 0 {
+				continue
+			}
+			hashes := make([]string, 0, len(seen))
+			for hash := range seen {
+				hashes = append(hashes, hash)
+			}
+			sort.Strings(hashes)
+			stored[section+"/"+filePath] = hashes
+		}
+		state.Scopes[scope] = stored
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode seen state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return fmt.Errorf("create seen state directory: %w", err)
+	}
+	if err := writeFileAtomic(s.Path, append(data, '\n')); err != nil {
+		return fmt.Errorf("write seen state %q: %w", s.Path, err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces path with data by writing a uniquely named
+// temporary file next to it and renaming it into place, so that concurrent
+// writers never interleave and readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if err := errors.Join(writeErr, closeErr); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (s SeenStore) read() (seenStateFile, error) {
+	state := seenStateFile{Version: seenStateVersion, Scopes: map[string]map[string][]string{}}
+	if s.Path == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return state, nil
+		}
+		return state, fmt.Errorf("read seen state %q: %w", s.Path, err)
+	}
+	var stored struct {
+		Version int             `json:"version"`
+		Scopes  json.RawMessage `json:"scopes"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return state, fmt.Errorf("%w %q: %w", errSeenStateUnreadable, s.Path, err)
+	}
+	if len(stored.Scopes) == 0 {
+		return state, nil
+	}
+	if stored.Version != seenStateVersion {
+		// Leave marks written by a newer dv alone rather than overwrite them.
+		return state, fmt.Errorf("seen state %q has unsupported version %d", s.Path, stored.Version)
+	}
+	if err := json.Unmarshal(stored.Scopes, &state.Scopes); err != nil {
+		return state, fmt.Errorf("%w %q: %w", errSeenStateUnreadable, s.Path, err)
+	}
+	if state.Scopes == nil {
+		state.Scopes = map[string]map[string][]string{}
+	}
+	return state, nil
+}
+
+// diffFileContentHash fingerprints the hunks of a file. Line numbers are left
+// out so that edits elsewhere in the file which only shift a hunk do not
+// invalidate a seen mark.
+func diffFileContentHash(file *DiffFile) string {
+	if file == nil {
+		return ""
+	}
+	hash := sha256.New()
+	if file.IsBinary {
+		for _, header := range file.Headers {
+			hash.Write([]byte(header))
+			hash.Write([]byte{'\n'})
+		}
+	}
+	for _, hunk := range file.Hunks {
+		hash.Write([]byte("@@\n"))
+		writeHunkContent(hash, hunk)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// diffHunkContentHash fingerprints one hunk, ignoring its position.
+func diffHunkContentHash(hunk DiffHunk) string {
+	hash := sha256.New()
+	writeHunkContent(hash, hunk)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// diffFileHunkHashes returns one hash per hunk. Files without hunks (binary
+// or mode-only changes) are treated as a single hunk. Identical hunks get
+// "#<n>" appended from the second one on, so each can be marked on its own.
+func diffFileHunkHashes(file *DiffFile) []string {
+	if file == nil {
+		return nil
+	}
+	if len(file.Hunks) == 0 {
+		return []string{diffFileContentHash(file)}
+	}
+	hashes := make([]string, 0, len(file.Hunks))
+	occurrences := map[string]int{}
+	for _, hunk := range file.Hunks {
+		hash := diffHunkContentHash(hunk)
+		occurrences[hash]++
+		if n := occurrences[hash]; n > 1 {
+			hash += "#" + strconv.Itoa(n)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
+
+func writeHunkContent(w io.Writer, hunk DiffHunk) {
+	for _, line := range hunk.Lines {
+		io.WriteString(w, strconv.Itoa(int(line.Kind)))
+		io.WriteString(w, line.Content)
+		io.WriteString(w, "\n")
+	}
+}
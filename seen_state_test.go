@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultSeenStatePath(t *testing.T) {
+	require.Equal(t, filepath.Join("/tmp/state", "dv", "seen.json"), defaultSeenStatePath("/tmp/state"))
+	require.Empty(t, defaultSeenStatePath(""))
+}
+
+func TestSeenStore_SaveAndLoadRoundTripPerScope(t *testing.T) {
+	store := SeenStore{Path: filepath.Join(t.TempDir(), "nested", "seen.json")}
+
+	marks := map[string]map[string]bool{
+		diffFileReviewKey(DiffSectionUnstaged, "a/b.txt"): {"hash-a1": true, "hash-a2": true},
+		diffFileReviewKey(DiffSectionStaged, "c.txt"):     {"hash-c": true},
+	}
+	require.NoError(t, store.Save("/repo/one", marks))
+	require.NoError(t, store.Save("/repo/two", map[string]map[string]bool{
+		diffFileReviewKey(DiffSectionFiles, "x.txt"): {"hash-x": true},
+	}))
+
+	loaded, err := store.Load("/repo/one")
+	require.NoError(t, err)
+	require.Equal(t, marks, loaded)
+
+	require.NoError(t, store.Save("/repo/one", nil))
+	loaded, err = store.Load("/repo/one")
+	require.NoError(t, err)
+	require.Empty(t, loaded)
+
+	loaded, err = store.Load("/repo/two")
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+}
+
+func TestSeenStore_LoadMissingOrDisabledIsEmpty(t *testing.T) {
+	loaded, err := SeenStore{Path: filepath.Join(t.TempDir(), "seen.json")}.Load("/repo")
+	require.NoError(t, err)
+	require.Empty(t, loaded)
+
+	loaded, err = SeenStore{}.Load("/repo")
+	require.NoError(t, err)
+	require.Empty(t, loaded)
+	require.NoError(t, SeenStore{}.Save("/repo", map[string]map[string]bool{"k": {"v": true}}))
+}
+
+func TestSeenStore_LoadRejectsMalformedState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seen.json")
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0o644))
+
+	_, err := SeenStore{Path: path}.Load("/repo")
+	require.ErrorContains(t, err, "parse seen state")
+	require.ErrorContains(t, err, "started fresh")
+
+	backup, err := os.ReadFile(path + ".corrupt")
+	require.NoError(t, err)
+	require.Equal(t, "{not json", string(backup))
+
+	loaded, err := SeenStore{Path: path}.Load("/repo")
+	require.NoError(t, err)
+	require.Empty(t, loaded)
+	require.NoError(t, SeenStore{Path: path}.Save("/repo", map[string]map[string]bool{
+		diffFileReviewKey(DiffSectionUnstaged, "a.txt"): {"hash": true},
+	}))
+}
+
+func TestSeenStore_SaveLeavesNoTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := SeenStore{Path: filepath.Join(dir, "seen.json")}
+	for _, scope := range []string{"/repo/one", "/repo/two"} {
+		require.NoError(t, store.Save(scope, map[string]map[string]bool{
+			diffFileReviewKey(DiffSectionUnstaged, "a.txt"): {"hash": true},
+		}))
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "seen.json", entries[0].Name())
+}
+
+func TestDiffFileContentHash_IgnoresLineNumbers(t *testing.T) {
+	first := &DiffFile{Hunks: []DiffHunk{{
+		OldStart: 1, NewStart: 1,
+		Lines: []DiffLine{{Kind: DiffLineRemove, Content: "a", OldLine: 1}, {Kind: DiffLineAdd, Content: "b", NewLine: 1}},
+	}}}
+	shifted := &DiffFile{Hunks: []DiffHunk{{
+		OldStart: 9, NewStart: 9,
+		Lines: []DiffLine{{Kind: DiffLineRemove, Content: "a", OldLine: 9}, {Kind: DiffLineAdd, Content: "b", NewLine: 9}},
+	}}}
+	edited := &DiffFile{Hunks: []DiffHunk{{
+		OldStart: 1, NewStart: 1,
+		Lines: []DiffLine{{Kind: DiffLineRemove, Content: "a", OldLine: 1}, {Kind: DiffLineAdd, Content: "c", NewLine: 1}},
+	}}}
+
+	require.Equal(t, diffFileContentHash(first), diffFileContentHash(shifted))
+	require.NotEqual(t, diffFileContentHash(first), diffFileContentHash(edited))
+	require.Empty(t, diffFileContentHash(nil))
+}
+
+func TestSeenStore_KeepsNewerVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seen.json")
+	stored := `{"version":99,"scopes":{"/repo":{}}}`
+	require.NoError(t, os.WriteFile(path, []byte(stored), 0o644))
+
+	store := SeenStore{Path: path}
+	_, err := store.Load("/repo")
+	require.ErrorContains(t, err, "unsupported version 99")
+	require.Error(t, store.Save("/repo", nil))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, stored, string(data))
+}
+
+func TestDiffFileHunkHashes(t *testing.T) {
+	file := &DiffFile{Hunks: []DiffHunk{
+		{Lines: []DiffLine{{Kind: DiffLineAdd, Content: "a"}}},
+		{Lines: []DiffLine{{Kind: DiffLineAdd, Content: "b"}}},
+	}}
+	hashes := diffFileHunkHashes(file)
+	require.Len(t, hashes, 2)
+	require.NotEqual(t, hashes[0], hashes[1])
+	require.Equal(t, diffHunkContentHash(file.Hunks[1]), hashes[1])
+
+	duplicated := &DiffFile{Hunks: []DiffHunk{file.Hunks[0], file.Hunks[1], file.Hunks[0], file.Hunks[0]}}
+	require.Equal(t, []string{hashes[0], hashes[1], hashes[0] + "#2", hashes[0] + "#3"}, diffFileHunkHashes(duplicated))
+
+	binary := &DiffFile{IsBinary: true, Headers: []string{"Binary files a/x and b/x differ"}}
+	require.Equal(t, []string{diffFileContentHash(binary)}, diffFileHunkHashes(binary))
+	require.Nil(t, diffFileHunkHashes(nil))
+}
@@ -28,3 +28,7 @@ func (p StdinDiffProvider) Sections() []DiffSection {
 func (p StdinDiffProvider) ManualRefreshEnabled() bool {
 	return false
 }
+
+func (p StdinDiffProvider) SeenScope() string {
+	return "stdin"
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// suspiciousCharKind is why a character is flagged as suspicious.
+type suspiciousCharKind int
+
+const (
+	// suspiciousCharBidi reorders how text is displayed, as used by Trojan
+	// Source attacks.
+	suspiciousCharBidi suspiciousCharKind = iota
+	// suspiciousCharInvisible has no width, so it can hide in identifiers
+	// and strings.
+	suspiciousCharInvisible
+	// suspiciousCharConfusable looks like an ASCII letter or symbol in a word
+	// that is otherwise ASCII.
+	suspiciousCharConfusable
+	// suspiciousCharControl is a C0 or C1 control code other than tab.
+	suspiciousCharControl
+)
+
+// suspiciousChar is a character that can hide or disguise what a line of
+// code does. Offset is its byte offset in the line.
+type suspiciousChar struct {
+	Rune   rune
+	Kind   suspiciousCharKind
+	Offset int
+}
+
+// confusableRunes are Cyrillic and Greek letters that are hard to tell apart
+// from ASCII letters. Fullwidth ASCII forms are matched by range.
+var confusableRunes = map[rune]bool{
+	// Cyrillic а в е к м н о р с т у х ѕ і ј һ ԁ ԛ ԝ ӏ.
+	'\u0430': true, '\u0432': true, '\u0435': true, '\u043a': true, '\u043c': true,
+	'\u043d': true, '\u043e': true, '\u0440': true, '\u0441': true, '\u0442': true,
+	'\u0443': true, '\u0445': true, '\u0455': true, '\u0456': true, '\u0458': true,
+	'\u04bb': true, '\u0501': true, '\u051b': true, '\u051d': true, '\u04cf': true,
+	// Cyrillic А В Е К М Н О Р С Т Х Ѕ І Ј.
+	'\u0410': true, '\u0412': true, '\u0415': true, '\u041a': true, '\u041c': true,
+	'\u041d': true, '\u041e': true, '\u0420': true, '\u0421': true, '\u0422': true,
+	'\u0425': true, '\u0405': true, '\u0406': true, '\u0408': true,
+	// Greek α ι ν ο ρ υ.
+	'\u03b1': true, '\u03b9': true, '\u03bd': true, '\u03bf': true, '\u03c1': true,
+	'\u03c5': true,
+	// Greek Α Β Ε Ζ Η Ι Κ Μ Ν Ο Ρ Τ Υ Χ.
+	'\u0391': true, '\u0392': true, '\u0395': true, '\u0396': true, '\u0397': true,
+	'\u0399': true, '\u039a': true, '\u039c': true, '\u039d': true, '\u039f': true,
+	'\u03a1': true, '\u03a4': true, '\u03a5': true, '\u03a7': true,
+}
+
+// scanSuspiciousChars returns the bidi controls, invisible characters,
+// confusables and control codes in text. Confusables are only reported in
+// words that also contain ASCII letters, so text written in Cyrillic or Greek
+// is left alone.
+func scanSuspiciousChars(text string) []suspiciousChar {
+	if isPlainASCII(text) {
+		return nil
+	}
+
+	type textRune struct {
+		r      rune
+		offset int
+	}
+	runes := make([]textRune, 0, len(text))
+	for offset, r := range text {
+		runes = append(runes, textRune{r: r, offset: offset})
+	}
+
+	var found []suspiciousChar
+	emojiTags := false
+	for idx := 0; idx < len(runes); {
+		if isWordRune(runes[idx].r) {
+			end := idx
+			mixed := false
+			for end < len(runes) && isWordRune(runes[end].r) {
+				mixed = mixed || isASCIILetter(runes[end].r)
+				end++
+			}
+			for ; idx < end; idx++ {
+				r := runes[idx].r
+				if kind, ok := classifySuspiciousRune(r); ok {
+					found = append(found, suspiciousChar{Rune: r, Kind: kind, Offset: runes[idx].offset})
+				} else if mixed && isConfusableRune(r) {
+					found = append(found, suspiciousChar{Rune: r, Kind: suspiciousCharConfusable, Offset: runes[idx].offset})
+				}
+			}
+			continue
+		}
+
+		r := runes[idx].r
+		var prev rune
+		if idx > 0 {
+			prev = runes[idx-1].r
+		}
+		kind, ok := classifySuspiciousRune(r)
+		switch {
+		case !ok:
+		case r == '\u200d':
+			// Joiners also build emoji sequences.
+			ok = !(isEmojiRune(prev) && idx+1 < len(runes) && isEmojiRune(runes[idx+1].r))
+		case isTagRune(r):
+			// Tags also spell out subdivision flags after a flag emoji.
+			if !isTagRune(prev) {
+				emojiTags = isEmojiRune(prev)
+			}
+			ok = !emojiTags
+		}
+		if ok {
+			found = append(found, suspiciousChar{Rune: r, Kind: kind, Offset: runes[idx].offset})
+		}
+		idx++
+	}
+	return found
+}
+
+func classifySuspiciousRune(r rune) (suspiciousCharKind, bool) {
+	switch {
+	case r >= '\u202a' && r <= '\u202e', r >= '\u2066' && r <= '\u2069',
+		r == '\u200e', r == '\u200f', r == '\u061c':
+		return suspiciousCharBidi, true
+	case r >= '\u200b' && r <= '\u200d', r >= '\u2060' && r <= '\u2064',
+		r == '\ufeff', r == '\u00ad', r == '\u180e', r == '\u034f',
+		r == '\u115f', r == '\u1160', r == '\u3164', r == '\uffa0', isTagRune(r):
+		return suspiciousCharInvisible, true
+	case r < 0x20 && r != '\t', r >= 0x7f && r <= 0x9f:
+		return suspiciousCharControl, true
+	default:
+		return 0, false
+	}
+}
+
+func isConfusableRune(r rune) bool {
+	return confusableRunes[r] || (r >= '\uff01' && r <= '\uff5e')
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isASCIILetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// isTagRune reports whether r is a Unicode tag character, which can smuggle
+// invisible ASCII text.
+func isTagRune(r rune) bool {
+	return r >= 0xe0000 && r <= 0xe007f
+}
+
+func isEmojiRune(r rune) bool {
+	return r >= 0x1f000 || r == '\ufe0f' || unicode.Is(unicode.So, r)
+}
+
+func isPlainASCII(text string) bool {
+	for idx := 0; idx < len(text); idx++ {
+		if b := text[idx]; b >= utf8.RuneSelf || (b < 0x20 && b != '\t') || b == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// suspiciousCharLabel is shown in place of a suspicious character.
+func suspiciousCharLabel(r rune) string {
+	return fmt.Sprintf("<U+%04X>", r)
+}
+
+// countSuspiciousChars counts the suspicious characters in content, ignoring
+// a CR line ending.
+func countSuspiciousChars(content string) int {
+	if len(content) > 0 && content[len(content)-1] == '\r' {
+		content = content[:len(content)-1]
+	}
+	return len(scanSuspiciousChars(content))
+}
+
+// labelSuspiciousChars replaces the suspicious characters of a code line with
+// their codepoints, drawn in their own style. Confusables keep their glyph in
+// front of the label. Intraline marks carry over to the labels.
+func labelSuspiciousChars(line RenderedDiffLine) RenderedDiffLine {
+	found := scanSuspiciousChars(renderedLineText(line))
+	if len(found) == 0 {
+		return line
+	}
+
+	labeled := make([]RenderedSegment, 0, len(line.Segments)+2*len(found))
+	next := 0
+	offset := 0
+	for _, segment := range line.Segments {
+		start := 0
+		for next < len(found) && found[next].Offset < offset+len(segment.Text) {
+			char := found[next]
+			local := char.Offset - offset
+			appendSegmentWithMark(&labeled, segment.Role, segment.Intraline, segment.Text[start:local])
+			label := suspiciousCharLabel(char.Rune)
+			if char.Kind == suspiciousCharConfusable {
+				label = string(char.Rune) + label
+			}
+			appendSegmentWithMark(&labeled, TokenRoleDiffSuspicious, segment.Intraline, label)
+			start = local + utf8.RuneLen(char.Rune)
+			next++
+		}
+		appendSegmentWithMark(&labeled, segment.Role, segment.Intraline, segment.Text[start:])
+		offset += len(segment.Text)
+	}
+	line.Segments = labeled
+	line.ContentWidth = renderedSegmentsWidth(labeled)
+	return line
+}
+
+// renderedSegmentsWidth is the number of cells segments take up, counting
+// zero-width graphemes as one cell like DiffView does.
+func renderedSegmentsWidth(segments []RenderedSegment) int {
+	width := 0
+	for _, segment := range segments {
+		remaining := segment.Text
+		for len(remaining) > 0 {
+			grapheme, graphemeWidth := ansi.FirstGraphemeCluster(remaining, ansi.GraphemeWidth)
+			if grapheme == "" {
+				break
+			}
+			width += max(graphemeWidth, 1)
+			remaining = remaining[len(grapheme):]
+		}
+	}
+	return width
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanSuspiciousChars_FindsBidiInvisibleAndControlChars(t *testing.T) {
+	text := "if isAdmin \u202e{ \u2066// check\u2069 x\u200by\x07"
+
+	found := scanSuspiciousChars(text)
+	require.Equal(t, []suspiciousChar{
+		{Rune: '\u202e', Kind: suspiciousCharBidi, Offset: 11},
+		{Rune: '\u2066', Kind: suspiciousCharBidi, Offset: 16},
+		{Rune: '\u2069', Kind: suspiciousCharBidi, Offset: 27},
+		{Rune: '\u200b', Kind: suspiciousCharInvisible, Offset: 32},
+		{Rune: '\x07', Kind: suspiciousCharControl, Offset: 36},
+	}, found)
+}
+
+func TestScanSuspiciousChars_FlagsConfusablesOnlyInMixedScriptWords(t *testing.T) {
+	found := scanSuspiciousChars("p\u0430ypal := привет")
+	require.Equal(t, []suspiciousChar{{Rune: '\u0430', Kind: suspiciousCharConfusable, Offset: 1}}, found)
+
+	require.Equal(t, []suspiciousChar{{Rune: '\uff41', Kind: suspiciousCharConfusable, Offset: 0}}, scanSuspiciousChars("\uff41dmin"))
+}
+
+func TestScanSuspiciousChars_IgnoresPlainTextAndEmojiSequences(t *testing.T) {
+	require.Empty(t, scanSuspiciousChars("\tfmt.Println(\"hello\")"))
+	require.Empty(t, scanSuspiciousChars("café naïve"))
+	require.Empty(t, scanSuspiciousChars("family: \U0001f468\u200d\U0001f469\u200d\U0001f467"))
+	require.Empty(t, scanSuspiciousChars("flag: \U0001f3f4\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f"))
+
+	found := scanSuspiciousChars("key\U000e0061\U000e0062")
+	require.Len(t, found, 2)
+	require.Equal(t, suspiciousCharInvisible, found[0].Kind)
+}
+
+func TestCountSuspiciousChars_IgnoresCRLineEnding(t *testing.T) {
+	require.Equal(t, 0, countSuspiciousChars("return nil\r"))
+	require.Equal(t, 1, countSuspiciousChars("return\r nil\r"))
+}
+
+func TestLabelSuspiciousChars_ReplacesCharsWithCodepoints(t *testing.T) {
+	line := newRenderedLine(RenderedLineAdd, 0, 1, "+", []RenderedSegment{
+		{Text: "x\u202e", Role: TokenRoleSyntaxIdentifier},
+		{Text: " = p\u0430th", Role: TokenRoleSyntaxPlain},
+	})
+	line.Segments[0].Intraline = IntralineMarkAdd
+
+	labeled := labelSuspiciousChars(line)
+	require.Equal(t, []RenderedSegment{
+		{Text: "x", Role: TokenRoleSyntaxIdentifier, Intraline: IntralineMarkAdd},
+		{Text: "<U+202E>", Role: TokenRoleDiffSuspicious, Intraline: IntralineMarkAdd},
+		{Text: " = p", Role: TokenRoleSyntaxPlain},
+		{Text: "\u0430<U+0430>", Role: TokenRoleDiffSuspicious},
+		{Text: "th", Role: TokenRoleSyntaxPlain},
+	}, labeled.Segments)
+	require.Equal(t, 24, labeled.ContentWidth)
+}
@@ -1,6 +1,12 @@
 package main
 
-import t "github.com/darrenburns/terma"
+import (
+	"fmt"
+	"strings"
+
+	t "github.com/darrenburns/terma"
+	"gopkg.in/yaml.v3"
+)
 
 type syntaxColorResolver func(theme t.ThemeData) t.Color
 
@@ -100,15 +106,48 @@ var syntaxThemeOverrides = map[string]map[TokenRole]syntaxColorResolver{
 	},
 }
 
-func applySyntaxThemeOverrides(theme t.ThemeData, roleStyles map[TokenRole]t.SpanStyle) {
+// syntaxRoleNames are the config names of the roles in
+// isSyntaxOverrideableRole.
+var syntaxRoleNames = map[string]TokenRole{
+	"plain":         TokenRoleSyntaxPlain,
+	"keyword":       TokenRoleSyntaxKeyword,
+	"type":          TokenRoleSyntaxType,
+	"function":      TokenRoleSyntaxFunction,
+	"identifier":    TokenRoleSyntaxIdentifier,
+	"constant":      TokenRoleSyntaxConstant,
+	"builtin":       TokenRoleSyntaxBuiltin,
+	"preprocessor":  TokenRoleSyntaxPreprocessor,
+	"attribute":     TokenRoleSyntaxAttribute,
+	"parameter":     TokenRoleSyntaxParameter,
+	"string":        TokenRoleSyntaxString,
+	"number":        TokenRoleSyntaxNumber,
+	"regex":         TokenRoleSyntaxRegex,
+	"string-escape": TokenRoleSyntaxStringEscape,
+	"tag":           TokenRoleSyntaxTag,
+	"comment":       TokenRoleSyntaxComment,
+	"operator":      TokenRoleSyntaxOperator,
+	"punctuation":   TokenRoleSyntaxPunctuation,
+}
+
+func parseSyntaxRoleName(name string) (TokenRole, error) {
+	role, ok := syntaxRoleNames[normalizeCLIValue(name)]
+	if !ok || !isSyntaxOverrideableRole(role) {
+		return 0, fmt.Errorf("unknown syntax role %q (available roles: %s)", name, strings.Join(sortedKeys(syntaxRoleNames), ", "))
+	}
+	return role, nil
+}
+
+func applySyntaxThemeOverrides(theme t.ThemeData, roleStyles map[TokenRole]t.SpanStyle, options paletteOptions) {
 	if !theme.IsLight {
 		applySyntaxResolvers(theme, roleStyles, darkThemeStructuralSyntaxResolvers)
 	}
 
-	overrides, ok := syntaxThemeOverrides[theme.Name]
-	if ok {
+	if overrides, ok := options.ThemeStyles.syntaxResolvers(theme.Name); ok {
 		applySyntaxResolvers(theme, roleStyles, overrides)
 	}
+
+	applySyntaxStyleOverrides(roleStyles, options.ThemeStyles.Syntax[theme.Name])
+	applySyntaxStyleOverrides(roleStyles, options.SyntaxOverrides[theme.Name])
 }
 
 func isSyntaxOverrideableRole(role TokenRole) bool {
@@ -152,3 +191,120 @@ func applySyntaxResolvers(theme t.ThemeData, roleStyles map[TokenRole]t.SpanStyl
 		roleStyles[role] = style
 	}
 }
+
+func applySyntaxStyleOverrides(roleStyles map[TokenRole]t.SpanStyle, overrides map[TokenRole]syntaxStyleOverride) {
+	for role, override := range overrides {
+		if !isSyntaxOverrideableRole(role) {
+			continue
+		}
+		style, ok := roleStyles[role]
+		if !ok {
+			continue
+		}
+		roleStyles[role] = override.apply(style)
+	}
+}
+
+// syntaxStyleConfig is a syntax role style in config: either a colour, or a
+// mapping with fg, bold, italic and underline.
+type syntaxStyleConfig struct {
+	Foreground string `yaml:"fg,omitempty"`
+	Bold       *bool  `yaml:"bold,omitempty"`
+	Italic     *bool  `yaml:"italic,omitempty"`
+	Underline  *bool  `yaml:"underline,omitempty"`
+}
+
+func (c *syntaxStyleConfig) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*c = syntaxStyleConfig{}
+		return node.Decode(&c.Foreground)
+	case yaml.MappingNode:
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			switch field := node.Content[idx]; field.Value {
+			case "fg", "bold", "italic", "underline":
+			default:
+				return fmt.Errorf("line %d: unknown syntax style field %q (expected fg, bold, italic or underline)", field.Line, field.Value)
+			}
+		}
+		type plain syntaxStyleConfig
+		return node.Decode((*plain)(c))
+	default:
+		return fmt.Errorf("line %d: expected a colour or a style mapping", node.Line)
+	}
+}
+
+// syntaxStyleOverride is a parsed syntaxStyleConfig. Unset fields keep the
+// theme's style for the role.
+type syntaxStyleOverride struct {
+	Foreground t.Color
+	Bold       *bool
+	Italic     *bool
+	Underline  *bool
+}
+
+func (o syntaxStyleOverride) apply(style t.SpanStyle) t.SpanStyle {
+	if o.Foreground.IsSet() {
+		style.Foreground = o.Foreground
+	}
+	if o.Bold != nil {
+		style.Bold = *o.Bold
+	}
+	if o.Italic != nil {
+		style.Italic = *o.Italic
+	}
+	if o.Underline != nil {
+		style.Underline = t.UnderlineNone
+		if *o.Underline {
+			style.Underline = t.UnderlineSingle
+		}
+	}
+	return style
+}
+
+// parseSyntaxStyleOverrides validates role names against
+// isSyntaxOverrideableRole and parses each style.
+func parseSyntaxStyleOverrides(styles map[string]syntaxStyleConfig) (map[TokenRole]syntaxStyleOverride, error) {
+	overrides := make(map[TokenRole]syntaxStyleOverride, len(styles))
+	for _, name := range sortedKeys(styles) {
+		role, err := parseSyntaxRoleName(name)
+		if err != nil {
+			return nil, err
+		}
+		style := styles[name]
+		override := syntaxStyleOverride{Bold: style.Bold, Italic: style.Italic, Underline: style.Underline}
+		if strings.TrimSpace(style.Foreground) != "" {
+			override.Foreground, err = parseThemeColor(style.Foreground)
+			if err != nil {
+				return nil, fmt.Errorf("syntax role %q: %w", name, err)
+			}
+		} else if style.Bold == nil && style.Italic == nil && style.Underline == nil {
+			return nil, fmt.Errorf("syntax role %q: expected a colour or at least one of fg, bold, italic or underline", name)
+		}
+		overrides[role] = override
+	}
+	return overrides, nil
+}
+
+// resolveSyntaxOverrides parses the `syntax-overrides:` config section. Theme
+// names go through parseThemeName, so user themes can be overridden too.
+func resolveSyntaxOverrides(themes map[string]map[string]syntaxStyleConfig) (map[string]map[TokenRole]syntaxStyleOverride, error) {
+	resolved := make(map[string]map[TokenRole]syntaxStyleOverride, len(themes))
+	for _, name := range sortedKeys(themes) {
+		themeName, err := parseThemeName(name)
+		if err != nil {
+			return nil, fmt.Errorf("unknown theme %q", name)
+		}
+		overrides, err := parseSyntaxStyleOverrides(themes[name])
+		if err != nil {
+			return nil, fmt.Errorf("theme %q: %w", name, err)
+		}
+		if resolved[themeName] == nil {
+			resolved[themeName] = map[TokenRole]syntaxStyleOverride{}
+		}
+		for role, override := range overrides {
+			resolved[themeName][role] = override
+		}
+	}
+	return resolved, nil
+}
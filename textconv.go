@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffDriverConfig sets up a git diff driver, as named by `diff=<driver>` in
+// .gitattributes.
+type diffDriverConfig struct {
+	// Textconv is diff.<driver>.textconv: a command that prints a file as
+	// text, given its path as the last argument.
+	Textconv string `yaml:"textconv,omitempty"`
+	// Xfuncname is diff.<driver>.xfuncname: a regular expression for the lines
+	// shown as function context in hunk headers.
+	Xfuncname string `yaml:"xfuncname,omitempty"`
+}
+
+// textconvOptions controls how git diffs treat textconv filters. The zero
+// value turns them off, so binary files with a textconv driver show as binary
+// and the diff is the patch git would apply.
+type textconvOptions struct {
+	Enabled bool
+	// Drivers are set up on top of git's own config, by driver name.
+	Drivers map[string]diffDriverConfig
+}
+
+// diffFlag turns textconv filters on or off for `git diff`.
+func (o textconvOptions) diffFlag() string {
+	if o.Enabled {
+		return "--textconv"
+	}
+	return "--no-textconv"
+}
+
+// gitConfigArgs returns the `-c` options that set up o's drivers, or nothing
+// when textconv filters are off.
+func (o textconvOptions) gitConfigArgs() []string {
+	if !o.Enabled {
+		return nil
+	}
+	var args []string
+	for _, name := range sortedKeys(o.Drivers) {
+		driver := o.Drivers[name]
+		if driver.Textconv != "" {
+			args = append(args, "-c", "diff."+name+".textconv="+driver.Textconv)
+		}
+		if driver.Xfuncname != "" {
+			args = append(args, "-c", "diff."+name+".xfuncname="+driver.Xfuncname)
+		}
+	}
+	return args
+}
+
+// validateDiffDriverName rejects names that can't be passed to git as
+// diff.<driver>.textconv.
+func validateDiffDriverName(name string) error {
+	if name == "" {
+		return fmt.Errorf("driver name is empty")
+	}
+	if strings.ContainsAny(name, "= \t\r\n") {
+		return fmt.Errorf("driver name %q contains whitespace or '='", name)
+	}
+	return nil
+}
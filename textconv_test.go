@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextconvOptions_OffIgnoresDrivers(t *testing.T) {
+	options := textconvOptions{Drivers: map[string]diffDriverConfig{"pdf": {Textconv: "pdftotext - -"}}}
+	require.Equal(t, "--no-textconv", options.diffFlag())
+	require.Empty(t, options.gitConfigArgs())
+}
+
+func TestTextconvOptions_GitConfigArgsSkipsEmptySettings(t *testing.T) {
+	options := textconvOptions{
+		Enabled: true,
+		Drivers: map[string]diffDriverConfig{
+			"exif": {Textconv: "exiftool"},
+			"ini":  {Xfuncname: "^\\[.*\\]$"},
+		},
+	}
+	require.Equal(t, "--textconv", options.diffFlag())
+	require.Equal(t, []string{
+		"-c", "diff.exif.textconv=exiftool",
+		"-c", "diff.ini.xfuncname=^\\[.*\\]$",
+	}, options.gitConfigArgs())
+}
+
+func TestValidateDiffDriverName(t *testing.T) {
+	require.NoError(t, validateDiffDriverName("pdf"))
+	require.NoError(t, validateDiffDriverName("my.driver"))
+	require.Error(t, validateDiffDriverName(""))
+	require.Error(t, validateDiffDriverName("a=b"))
+	require.Error(t, validateDiffDriverName("two words"))
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	t "github.com/darrenburns/terma"
+)
+
+// themeNameAuto picks theme-light or theme-dark from the terminal background.
+const themeNameAuto = "auto"
+
+const (
+	defaultThemeLight = t.ThemeNameCatppuccinLatte
+	defaultThemeDark  = t.ThemeNameObsidianTide
+)
+
+// terminalBackgroundTimeout bounds how long startup waits for the terminal to
+// answer the background colour query.
+const terminalBackgroundTimeout = 500 * time.Millisecond
+
+// backgroundDetector reports whether the terminal background is light. ok is
+// false when the terminal did not say.
+type backgroundDetector func() (light bool, ok bool)
+
+func isAutoThemeName(value string) bool {
+	return normalizeCLIValue(value) == themeNameAuto
+}
+
+// resolveAutoTheme picks lightTheme or darkTheme, asking detect first, then
+// $COLORFGBG, and falling back to darkTheme when neither knows.
+func resolveAutoTheme(detect backgroundDetector, colorFGBG string, lightTheme string, darkTheme string) string {
+	light, ok := false, false
+	if detect != nil {
+		light, ok = detect()
+	}
+	if !ok {
+		light, ok = colorFGBGIsLight(colorFGBG)
+	}
+	if ok && light {
+		return lightTheme
+	}
+	return darkTheme
+}
+
+// colorFGBGIsLight reads the background from $COLORFGBG ("fg;bg" or
+// "fg;default;bg"). Colours 7 and 9-15 are the light ANSI colours.
+func colorFGBGIsLight(value string) (light bool, ok bool) {
+	fields := strings.Split(strings.TrimSpace(value), ";")
+	if len(fields) < 2 {
+		return false, false
+	}
+	bg, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || bg < 0 || bg > 15 {
+		return false, false
+	}
+	return bg == 7 || bg >= 9, true
+}
+
+// queryTerminalBackground asks the controlling terminal for its background
+// colour with OSC 11. A primary device attributes query follows it, which
+// every terminal answers, so terminals without OSC 11 support don't cost the
+// full timeout.
+func queryTerminalBackground(timeout time.Duration) (light bool, ok bool) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false, false
+	}
+	defer tty.Close()
+	// Without read deadlines a silent terminal would block startup.
+	if err := tty.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return false, false
+	}
+
+	state, err := term.MakeRaw(tty.Fd())
+	if err != nil {
+		return false, false
+	}
+	defer func() { _ = term.Restore(tty.Fd(), state) }()
+
+	if _, err := tty.WriteString("\x1b]11;?\x1b\\\x1b[c"); err != nil {
+		return false, false
+	}
+	response, err := readTerminalReplies(tty)
+	if err != nil {
+		return false, false
+	}
+	color, ok := parseOSC11Response(response)
+	if !ok {
+		return false, false
+	}
+	return color.IsLight(), true
+}
+
+// readTerminalReplies reads until the device attributes reply ("ESC [ ? ... c")
+// arrives or the read deadline passes.
+func readTerminalReplies(tty *os.File) (string, error) {
+	var replies []byte
+	buf := make([]byte, 256)
+	for {
+		n, err := tty.Read(buf)
+		replies = append(replies, buf[:n]...)
+		if idx := bytes.Index(replies, []byte("\x1b[?")); idx >= 0 && bytes.IndexByte(replies[idx:], 'c') >= 0 {
+			return string(replies), nil
+		}
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) && len(replies) > 0 {
+				return string(replies), nil
+			}
+			return "", err
+		}
+	}
+}
+
+// parseOSC11Response extracts the colour from an OSC 11 reply such as
+// "ESC ] 11 ; rgb:ffff/ffff/dddd BEL". Each channel has one to four hex
+// digits.
+func parseOSC11Response(response string) (t.Color, bool) {
+	start := strings.Index(response, "\x1b]11;")
+	if start < 0 {
+		return t.Color{}, false
+	}
+	body := response[start+len("\x1b]11;"):]
+	end := strings.IndexAny(body, "\x07\x1b")
+	if end < 0 {
+		return t.Color{}, false
+	}
+	body = body[:end]
+	spec, ok := strings.CutPrefix(body, "rgb:")
+	if !ok {
+		spec, ok = strings.CutPrefix(body, "rgba:")
+	}
+	if !ok {
+		return t.Color{}, false
+	}
+	channels := strings.Split(spec, "/")
+	if len(channels) < 3 {
+		return t.Color{}, false
+	}
+	var rgb [3]uint8
+	for idx := range rgb {
+		value, err := parseOSCColorChannel(channels[idx])
+		if err != nil {
+			return t.Color{}, false
+		}
+		rgb[idx] = value
+	}
+	return t.RGB(rgb[0], rgb[1], rgb[2]), true
+}
+
+func parseOSCColorChannel(channel string) (uint8, error) {
+	if len(channel) < 1 || len(channel) > 4 {
+		return 0, fmt.Errorf("invalid colour channel %q", channel)
+	}
+	value, err := strconv.ParseUint(channel, 16, 16)
+	if err != nil {
+		return 0, err
+	}
+	maxValue := uint64(1)<<(4*len(channel)) - 1
+	return uint8(value * 255 / maxValue), nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	t "github.com/darrenburns/terma"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveAutoTheme(tt *testing.T) {
+	answers := func(light bool) backgroundDetector {
+		return func() (bool, bool) { return light, true }
+	}
+	silent := func() (bool, bool) { return false, false }
+
+	tests := map[string]struct {
+		detect    backgroundDetector
+		colorFGBG string
+		want      string
+	}{
+		"terminalSaysLight":         {answers(true), "", "light-theme"},
+		"terminalSaysDark":          {answers(false), "0;15", "dark-theme"},
+		"terminalWinsOverCOLORFGBG": {answers(true), "15;0", "light-theme"},
+		"colorFGBGLight":            {silent, "0;15", "light-theme"},
+		"colorFGBGWithDefault":      {silent, "0;default;7", "light-theme"},
+		"colorFGBGDark":             {silent, "15;0", "dark-theme"},
+		"noDetector":                {nil, "0;15", "light-theme"},
+		"nothingKnown":              {silent, "", "dark-theme"},
+		"colorFGBGUnparseable":      {silent, "default;default", "dark-theme"},
+	}
+	for name, tc := range tests {
+		tt.Run(name, func(tt *testing.T) {
+			require.Equal(tt, tc.want, resolveAutoTheme(tc.detect, tc.colorFGBG, "light-theme", "dark-theme"))
+		})
+	}
+}
+
+func TestParseOSC11Response(tt *testing.T) {
+	tests := map[string]struct {
+		response string
+		want     t.Color
+		ok       bool
+	}{
+		"fourDigitsBEL":         {"\x1b]11;rgb:ffff/ffff/dddd\x07", t.RGB(255, 255, 221), true},
+		"twoDigitsST":           {"\x1b]11;rgb:1e/1e/2e\x1b\\", t.RGB(30, 30, 46), true},
+		"oneDigit":              {"\x1b]11;rgb:f/0/8\x07", t.RGB(255, 0, 136), true},
+		"withDeviceAttributes":  {"\x1b]11;rgb:0000/0000/0000\x1b\\\x1b[?62;22c", t.RGB(0, 0, 0), true},
+		"onlyDeviceAttributes":  {"\x1b[?62;22c", t.Color{}, false},
+		"unterminated":          {"\x1b]11;rgb:ffff/ffff", t.Color{}, false},
+		"unsupportedColorSpace": {"\x1b]11;cmy:1/1/1\x07", t.Color{}, false},
+		"invalidChannel":        {"\x1b]11;rgb:zz/00/00\x07", t.Color{}, false},
+	}
+	for name, tc := range tests {
+		tt.Run(name, func(tt *testing.T) {
+			got, ok := parseOSC11Response(tc.response)
+			require.Equal(tt, tc.ok, ok)
+			require.Equal(tt, tc.want, got)
+		})
+	}
+}
+
+func TestParseOSC11Response_DecidesLightness(tt *testing.T) {
+	light, ok := parseOSC11Response("\x1b]11;rgb:fdfd/f6f6/e3e3\x07")
+	require.True(tt, ok)
+	require.True(tt, light.IsLight())
+
+	dark, ok := parseOSC11Response("\x1b]11;rgb:2828/2c2c/3434\x07")
+	require.True(tt, ok)
+	require.False(tt, dark.IsLight())
+}
@@ -1,6 +1,9 @@
 package main
 
-import t "github.com/darrenburns/terma"
+import (
+	"github.com/charmbracelet/colorprofile"
+	t "github.com/darrenburns/terma"
+)
 
 type IntralineStyleMode int
 
@@ -12,10 +15,19 @@ const (
 
 // ThemePalette stores all styles needed by the diff renderer for one theme.
 type ThemePalette struct {
-	roleStyles      map[TokenRole]t.SpanStyle
-	lineStyles      map[RenderedLineKind]t.Style
-	gutterStyles    map[RenderedLineKind]t.Style
-	intralineStyles map[intralineStyleKey]t.SpanStyle
+	roleStyles   map[TokenRole]t.SpanStyle
+	lineStyles   map[RenderedLineKind]t.Style
+	gutterStyles map[RenderedLineKind]t.Style
+	// zebraLineStyles and zebraGutterStyles replace the moved line styles
+	// for every other moved block.
+	zebraLineStyles   map[RenderedLineKind]t.Style
+	zebraGutterStyles map[RenderedLineKind]t.Style
+	intralineStyles   map[intralineStyleKey]t.SpanStyle
+	seenHunkVeil      t.Color
+	lineSelection     t.Color
+	// readabilityFloor is the minimum contrast of text on intraline
+	// backgrounds.
+	readabilityFloor float64
 }
 
 type intralineStyleKey struct {
@@ -23,32 +35,130 @@ type intralineStyleKey struct {
 	mode IntralineStyleMode
 }
 
-func NewThemePalette(theme t.ThemeData) ThemePalette {
+// paletteOptions holds what a palette is built from besides its theme and
+// variant: the terminal's colour depth and the styles set up by config.
+type paletteOptions struct {
+	// Profile is the colour depth of the terminal dv draws to. Below
+	// TrueColor every colour is snapped to the nearest colour the terminal
+	// has, so the renderer's own downsampling can't merge add and remove
+	// lines into the background. The zero value keeps full colour.
+	Profile colorprofile.Profile
+	// ThemeStyles holds the diff colours and syntax styles of user themes.
+	ThemeStyles userThemeStyles
+	// SyntaxOverrides holds the `syntax-overrides:` config section by theme
+	// name. It is applied last, so it wins over built-in and user theme
+	// styles.
+	SyntaxOverrides map[string]map[TokenRole]syntaxStyleOverride
+}
+
+// newThemePalette builds the palette with the add and remove colours of
+// variant. Variants other than the default replace a user theme's diff
+// colours too.
+func newThemePalette(theme t.ThemeData, variant PaletteVariant, options paletteOptions) ThemePalette {
 	const gutterDarkenAmount = 0.08
 
-	addBg := theme.Background.Blend(theme.Success, 0.14)
-	removeBg := theme.Background.Blend(theme.Error, 0.14)
+	profile := options.Profile
+	if profile == colorprofile.Unknown {
+		profile = colorprofile.TrueColor
+	}
+
+	spec := variant.spec()
+	addHue, removeHue := variant.diffHues(theme)
+	addBg := theme.Background.Blend(addHue, spec.LineBlend)
+	removeBg := theme.Background.Blend(removeHue, spec.LineBlend)
 	contextGutterBg := theme.Background.Darken(gutterDarkenAmount)
 	hunkBg := theme.Background.Blend(theme.Info, 0.1)
 	hunkFg := theme.TextMuted.Blend(theme.InfoText, 0.35)
 	headerBg := theme.Background.Blend(theme.Primary, 0.11)
 	lineNumberFg := theme.TextMuted.Blend(theme.TextDisabled, 0.35)
 	hatchFg := theme.Background.Blend(theme.TextDisabled, 0.26)
-	addIntralineBg := theme.Background.Blend(theme.Success, 0.28)
-	removeIntralineBg := theme.Background.Blend(theme.Error, 0.28)
+	addIntralineBg := theme.Background.Blend(addHue, spec.IntralineBlend)
+	removeIntralineBg := theme.Background.Blend(removeHue, spec.IntralineBlend)
+	seenHunkVeil := theme.Background.WithAlpha(0.55)
+	commentBg := theme.Background.Blend(theme.Accent, 0.1)
+	lineSelection := theme.Primary.WithAlpha(0.2)
+	// Moved lines get their own hues, like git's --color-moved, so a moved
+	// block stands out from the edits around it.
+	movedRemoveBg := theme.Background.Blend(theme.Secondary, spec.LineBlend)
+	movedAddBg := theme.Background.Blend(theme.Info, spec.LineBlend)
+	// Alternate blocks use a stronger tint of the same hue, so neighbouring
+	// blocks moved from different places are told apart.
+	movedRemoveZebraBg := theme.Background.Blend(theme.Secondary, spec.LineBlend*2)
+	movedAddZebraBg := theme.Background.Blend(theme.Info, spec.LineBlend*2)
+
+	addFg, removeFg := addHue, removeHue
+	if variant != PaletteVariantDefault {
+		addFg = blendForegroundTowardReadability(addHue, addBg.Darken(gutterDarkenAmount), spec.ReadabilityFloor)
+		removeFg = blendForegroundTowardReadability(removeHue, removeBg.Darken(gutterDarkenAmount), spec.ReadabilityFloor)
+	}
+
+	addUnderline := addFg
+	removeUnderline := removeFg
+	if diffColors, ok := options.ThemeStyles.DiffColors[theme.Name]; ok && variant == PaletteVariantDefault {
+		addBg = firstSetColor(diffColors.AddBackground, addBg)
+		removeBg = firstSetColor(diffColors.RemoveBackground, removeBg)
+		addIntralineBg = firstSetColor(diffColors.AddIntraline, addIntralineBg)
+		removeIntralineBg = firstSetColor(diffColors.RemoveIntraline, removeIntralineBg)
+		addUnderline = firstSetColor(diffColors.AddIntraline, addUnderline)
+		removeUnderline = firstSetColor(diffColors.RemoveIntraline, removeUnderline)
+	}
+
+	addGutterBg := addBg.Darken(gutterDarkenAmount)
+	removeGutterBg := removeBg.Darken(gutterDarkenAmount)
+	commentGutterBg := commentBg.Darken(gutterDarkenAmount)
+	movedRemoveGutterBg := movedRemoveBg.Darken(gutterDarkenAmount)
+	movedAddGutterBg := movedAddBg.Darken(gutterDarkenAmount)
+	movedRemoveZebraGutterBg := movedRemoveZebraBg.Darken(gutterDarkenAmount)
+	movedAddZebraGutterBg := movedAddZebraBg.Darken(gutterDarkenAmount)
+	quantize := func(c t.Color) t.Color { return quantizeColor(c, profile) }
+	if profile < colorprofile.TrueColor {
+		// Tints are pushed toward their hue until they survive quantizing,
+		// so changed lines and intraline changes stay visible and add and
+		// remove never share a colour.
+		addBg = quantizeTint(addBg, theme.Background, addHue, t.Color{}, profile)
+		removeBg = quantizeTint(removeBg, theme.Background, removeHue, addBg, profile)
+		addIntralineBg = quantizeTint(addIntralineBg, addBg, addHue, t.Color{}, profile)
+		removeIntralineBg = quantizeTint(removeIntralineBg, removeBg, removeHue, addIntralineBg, profile)
+		movedRemoveBg = quantizeTint(movedRemoveBg, theme.Background, theme.Secondary, removeBg, profile)
+		movedAddBg = quantizeTint(movedAddBg, theme.Background, theme.Info, addBg, profile)
+		movedRemoveZebraBg = quantizeTint(movedRemoveZebraBg, theme.Background, theme.Secondary, movedRemoveBg, profile)
+		movedAddZebraBg = quantizeTint(movedAddZebraBg, theme.Background, theme.Info, movedAddBg, profile)
+		movedRemoveGutterBg = quantize(movedRemoveGutterBg)
+		movedAddGutterBg = quantize(movedAddGutterBg)
+		movedRemoveZebraGutterBg = quantize(movedRemoveZebraGutterBg)
+		movedAddZebraGutterBg = quantize(movedAddZebraGutterBg)
+		addGutterBg = quantize(addGutterBg)
+		removeGutterBg = quantize(removeGutterBg)
+		commentGutterBg = quantize(commentGutterBg)
+		contextGutterBg = quantize(contextGutterBg)
+		hunkBg = quantize(hunkBg)
+		hunkFg = quantize(hunkFg)
+		headerBg = quantize(headerBg)
+		commentBg = quantize(commentBg)
+		lineNumberFg = quantize(lineNumberFg)
+		hatchFg = quantize(hatchFg)
+		addFg = quantize(addFg)
+		removeFg = quantize(removeFg)
+		addUnderline = quantize(addUnderline)
+		removeUnderline = quantize(removeUnderline)
+	}
 
 	roleStyles := map[TokenRole]t.SpanStyle{
 		TokenRoleOldLineNumber:      {Foreground: lineNumberFg},
 		TokenRoleNewLineNumber:      {Foreground: lineNumberFg},
-		TokenRoleLineNumberAdd:      {Foreground: theme.Success},
-		TokenRoleLineNumberRemove:   {Foreground: theme.Error},
-		TokenRoleDiffPrefixAdd:      {Foreground: theme.Success},
-		TokenRoleDiffPrefixRemove:   {Foreground: theme.Error},
+		TokenRoleLineNumberAdd:      {Foreground: addFg},
+		TokenRoleLineNumberRemove:   {Foreground: removeFg},
+		TokenRoleDiffPrefixAdd:      {Foreground: addFg},
+		TokenRoleDiffPrefixRemove:   {Foreground: removeFg},
 		TokenRoleDiffPrefixContext:  {Foreground: theme.TextMuted},
 		TokenRoleDiffFileHeader:     {Foreground: theme.PrimaryText, Bold: true},
 		TokenRoleDiffHunkHeader:     {Foreground: hunkFg},
 		TokenRoleDiffMeta:           {Foreground: theme.WarningText, Italic: true},
 		TokenRoleDiffHatch:          {Foreground: hatchFg},
+		TokenRoleDiffWhitespace:     {Foreground: theme.TextDisabled},
+		TokenRoleDiffSuspicious:     {Foreground: theme.TextOnError, Background: theme.Error, Bold: true},
+		TokenRoleDiffCommentHeader:  {Foreground: theme.AccentText, Bold: true},
+		TokenRoleDiffComment:        {Foreground: theme.Text},
 		TokenRoleSyntaxPlain:        {Foreground: theme.Text},
 		TokenRoleSyntaxKeyword:      {Foreground: theme.Accent, Bold: true},
 		TokenRoleSyntaxType:         {Foreground: theme.Primary},
@@ -68,33 +178,55 @@ func NewThemePalette(theme t.ThemeData) ThemePalette {
 		TokenRoleSyntaxOperator:     {Foreground: theme.Text},
 		TokenRoleSyntaxPunctuation:  {Foreground: theme.Text},
 	}
-	applySyntaxThemeOverrides(theme, roleStyles)
+	applySyntaxThemeOverrides(theme, roleStyles, options)
+	for role, style := range roleStyles {
+		style.Foreground = quantize(style.Foreground)
+		roleStyles[role] = style
+	}
 
 	return ThemePalette{
 		roleStyles: roleStyles,
 		lineStyles: map[RenderedLineKind]t.Style{
-			RenderedLineFileHeader: {BackgroundColor: headerBg},
-			RenderedLineHunkHeader: {BackgroundColor: hunkBg},
-			RenderedLineAdd:        {BackgroundColor: addBg},
-			RenderedLineRemove:     {BackgroundColor: removeBg},
+			RenderedLineFileHeader:  {BackgroundColor: headerBg},
+			RenderedLineHunkHeader:  {BackgroundColor: hunkBg},
+			RenderedLineAdd:         {BackgroundColor: addBg},
+			RenderedLineRemove:      {BackgroundColor: removeBg},
+			RenderedLineComment:     {BackgroundColor: commentBg},
+			RenderedLineMovedRemove: {BackgroundColor: movedRemoveBg},
+			RenderedLineMovedAdd:    {BackgroundColor: movedAddBg},
 		},
 		gutterStyles: map[RenderedLineKind]t.Style{
-			RenderedLineContext: {BackgroundColor: contextGutterBg},
-			RenderedLineAdd:     {BackgroundColor: addBg.Darken(gutterDarkenAmount)},
-			RenderedLineRemove:  {BackgroundColor: removeBg.Darken(gutterDarkenAmount)},
+			RenderedLineContext:     {BackgroundColor: contextGutterBg},
+			RenderedLineAdd:         {BackgroundColor: addGutterBg},
+			RenderedLineRemove:      {BackgroundColor: removeGutterBg},
+			RenderedLineComment:     {BackgroundColor: commentGutterBg},
+			RenderedLineMovedRemove: {BackgroundColor: movedRemoveGutterBg},
+			RenderedLineMovedAdd:    {BackgroundColor: movedAddGutterBg},
+			RenderedLineWordDiff:    {BackgroundColor: addGutterBg},
+		},
+		zebraLineStyles: map[RenderedLineKind]t.Style{
+			RenderedLineMovedRemove: {BackgroundColor: movedRemoveZebraBg},
+			RenderedLineMovedAdd:    {BackgroundColor: movedAddZebraBg},
+		},
+		zebraGutterStyles: map[RenderedLineKind]t.Style{
+			RenderedLineMovedRemove: {BackgroundColor: movedRemoveZebraGutterBg},
+			RenderedLineMovedAdd:    {BackgroundColor: movedAddZebraGutterBg},
 		},
 		intralineStyles: map[intralineStyleKey]t.SpanStyle{
 			{mark: IntralineMarkAdd, mode: IntralineStyleModeBackground}:    {Background: addIntralineBg},
 			{mark: IntralineMarkRemove, mode: IntralineStyleModeBackground}: {Background: removeIntralineBg},
 			{mark: IntralineMarkAdd, mode: IntralineStyleModeUnderline}: {
 				Underline:      t.UnderlineSingle,
-				UnderlineColor: theme.Success,
+				UnderlineColor: addUnderline,
 			},
 			{mark: IntralineMarkRemove, mode: IntralineStyleModeUnderline}: {
 				Underline:      t.UnderlineSingle,
-				UnderlineColor: theme.Error,
+				UnderlineColor: removeUnderline,
 			},
 		},
+		seenHunkVeil:     seenHunkVeil,
+		lineSelection:    lineSelection,
+		readabilityFloor: spec.ReadabilityFloor,
 	}
 }
 
@@ -113,6 +245,27 @@ func (p ThemePalette) GutterStyleForKind(kind RenderedLineKind) (t.Style, bool)
 	return style, ok
 }
 
+// LineStyleForLine is LineStyleForKind for a line that may be moved. Every
+// other moved block gets the zebra style.
+func (p ThemePalette) LineStyleForLine(kind RenderedLineKind, move *RenderedLineMove) (t.Style, bool) {
+	if move != nil && move.Block%2 == 1 {
+		if style, ok := p.zebraLineStyles[kind]; ok {
+			return style, true
+		}
+	}
+	return p.LineStyleForKind(kind)
+}
+
+// GutterStyleForLine is GutterStyleForKind for a line that may be moved.
+func (p ThemePalette) GutterStyleForLine(kind RenderedLineKind, move *RenderedLineMove) (t.Style, bool) {
+	if move != nil && move.Block%2 == 1 {
+		if style, ok := p.zebraGutterStyles[kind]; ok {
+			return style, true
+		}
+	}
+	return p.GutterStyleForKind(kind)
+}
+
 func (p ThemePalette) IntralineOverlayStyle(mark IntralineMarkKind, mode IntralineStyleMode) (t.SpanStyle, bool) {
 	if mark == IntralineMarkNone {
 		return t.SpanStyle{}, false
@@ -120,3 +273,21 @@ func (p ThemePalette) IntralineOverlayStyle(mark IntralineMarkKind, mode Intrali
 	style, ok := p.intralineStyles[intralineStyleKey{mark: mark, mode: mode}]
 	return style, ok
 }
+
+// SeenHunkVeil is a translucent backdrop drawn over hunks marked as seen.
+func (p ThemePalette) SeenHunkVeil() (t.Color, bool) {
+	return p.seenHunkVeil, p.seenHunkVeil.IsSet()
+}
+
+// LineSelection is a translucent backdrop drawn over lines selected for a
+// review comment.
+func (p ThemePalette) LineSelection() (t.Color, bool) {
+	return p.lineSelection, p.lineSelection.IsSet()
+}
+
+func firstSetColor(primary t.Color, fallback t.Color) t.Color {
+	if primary.IsSet() {
+		return primary
+	}
+	return fallback
+}
@@ -11,7 +11,7 @@ func TestThemePalette_GutterTintIsDarkerForAddAndRemove(tt *testing.T) {
 	theme, ok := t.GetTheme(t.CurrentThemeName())
 	require.True(tt, ok)
 
-	palette := NewThemePalette(theme)
+	palette := newThemePalette(theme, PaletteVariantDefault, paletteOptions{})
 
 	addLineStyle, ok := palette.LineStyleForKind(RenderedLineAdd)
 	require.True(tt, ok)
@@ -40,11 +40,54 @@ func TestThemePalette_GutterTintIsDarkerForAddAndRemove(tt *testing.T) {
 	require.Less(tt, contextGutterBg.Luminance(), theme.Background.Luminance())
 }
 
+func TestThemePalette_MovedLinesUseTheirOwnBackgrounds(tt *testing.T) {
+	theme, ok := t.GetTheme(t.CurrentThemeName())
+	require.True(tt, ok)
+
+	palette := newThemePalette(theme, PaletteVariantDefault, paletteOptions{})
+	background := func(kind RenderedLineKind) t.Color {
+		style, ok := palette.LineStyleForKind(kind)
+		require.True(tt, ok)
+		require.NotNil(tt, style.BackgroundColor)
+		return style.BackgroundColor.ColorAt(1, 1, 0, 0)
+	}
+
+	require.NotEqual(tt, background(RenderedLineRemove), background(RenderedLineMovedRemove))
+	require.NotEqual(tt, background(RenderedLineAdd), background(RenderedLineMovedAdd))
+	require.NotEqual(tt, background(RenderedLineMovedRemove), background(RenderedLineMovedAdd))
+	_, ok = palette.GutterStyleForKind(RenderedLineMovedAdd)
+	require.True(tt, ok)
+}
+
+func TestThemePalette_AlternateMovedBlocksUseZebraStyles(tt *testing.T) {
+	theme, ok := t.GetTheme(t.CurrentThemeName())
+	require.True(tt, ok)
+
+	palette := newThemePalette(theme, PaletteVariantDefault, paletteOptions{})
+	background := func(style t.Style) t.Color {
+		require.NotNil(tt, style.BackgroundColor)
+		return style.BackgroundColor.ColorAt(1, 1, 0, 0)
+	}
+
+	for _, kind := range []RenderedLineKind{RenderedLineMovedRemove, RenderedLineMovedAdd} {
+		even, ok := palette.LineStyleForLine(kind, &RenderedLineMove{Block: 0})
+		require.True(tt, ok)
+		odd, ok := palette.LineStyleForLine(kind, &RenderedLineMove{Block: 1})
+		require.True(tt, ok)
+		base, _ := palette.LineStyleForKind(kind)
+		require.Equal(tt, background(base), background(even))
+		require.NotEqual(tt, background(even), background(odd))
+	}
+	plain, _ := palette.LineStyleForLine(RenderedLineAdd, &RenderedLineMove{Block: 1})
+	base, _ := palette.LineStyleForKind(RenderedLineAdd)
+	require.Equal(tt, background(base), background(plain))
+}
+
 func TestThemePalette_IntralineBackgroundAccentsAreStrongerThanBaseLineTint(tt *testing.T) {
 	theme, ok := t.GetTheme(t.CurrentThemeName())
 	require.True(tt, ok)
 
-	palette := NewThemePalette(theme)
+	palette := newThemePalette(theme, PaletteVariantDefault, paletteOptions{})
 
 	addLineStyle, ok := palette.LineStyleForKind(RenderedLineAdd)
 	require.True(tt, ok)
@@ -79,7 +122,7 @@ func TestThemePalette_IntralineUnderlineStylesUseSemanticColors(tt *testing.T) {
 	theme, ok := t.GetTheme(t.CurrentThemeName())
 	require.True(tt, ok)
 
-	palette := NewThemePalette(theme)
+	palette := newThemePalette(theme, PaletteVariantDefault, paletteOptions{})
 
 	addUnderline, ok := palette.IntralineOverlayStyle(IntralineMarkAdd, IntralineStyleModeUnderline)
 	require.True(tt, ok)
@@ -96,7 +139,7 @@ func TestThemePalette_SyntaxOverrides_KanagawaPartialOverride(tt *testing.T) {
 	theme, ok := t.GetTheme(t.ThemeNameKanagawa)
 	require.True(tt, ok)
 
-	palette := NewThemePalette(theme)
+	palette := newThemePalette(theme, PaletteVariantDefault, paletteOptions{})
 
 	expected := map[TokenRole]t.Color{
 		TokenRoleSyntaxKeyword:      theme.Secondary,
@@ -133,6 +176,29 @@ func TestThemePalette_SyntaxOverrides_KanagawaPartialOverride(tt *testing.T) {
 	require.True(tt, commentStyle.Italic)
 }
 
+func TestThemePalette_SyntaxOverrides_ConfigStylesWinOverThemeOverrides(tt *testing.T) {
+	theme, ok := t.GetTheme(t.ThemeNameKanagawa)
+	require.True(tt, ok)
+	baseString, _ := newThemePalette(theme, PaletteVariantDefault, paletteOptions{}).StyleForRole(TokenRoleSyntaxString)
+
+	bold, italic := true, false
+	palette := newThemePalette(theme, PaletteVariantDefault, paletteOptions{
+		SyntaxOverrides: map[string]map[TokenRole]syntaxStyleOverride{
+			t.ThemeNameKanagawa: {
+				TokenRoleSyntaxKeyword: {Foreground: t.Hex("#ff79c6"), Italic: &italic},
+				TokenRoleSyntaxString:  {Bold: &bold},
+			},
+		},
+	})
+
+	keyword, _ := palette.StyleForRole(TokenRoleSyntaxKeyword)
+	require.Equal(tt, t.Hex("#ff79c6"), keyword.Foreground)
+	require.False(tt, keyword.Italic)
+	str, _ := palette.StyleForRole(TokenRoleSyntaxString)
+	require.True(tt, str.Bold)
+	require.Equal(tt, baseString.Foreground, str.Foreground)
+}
+
 func TestThemePalette_SyntaxOverrides_LightThemesUseReadableProfile(tt *testing.T) {
 	for _, themeName := range lightOverrideThemeNames() {
 		themeName := themeName
@@ -140,7 +206,7 @@ func TestThemePalette_SyntaxOverrides_LightThemesUseReadableProfile(tt *testing.
 			theme, ok := t.GetTheme(themeName)
 			require.True(tt, ok)
 
-			palette := NewThemePalette(theme)
+			palette := newThemePalette(theme, PaletteVariantDefault, paletteOptions{})
 			expected := expectedLightReadableSyntaxForegrounds(theme)
 
 			for _, role := range syntaxOverrideRoles() {
@@ -161,7 +227,7 @@ func TestThemePalette_SyntaxOverrides_DarkThemeStructuralSyntaxUsesSharedProfile
 	theme, ok := t.GetTheme(t.ThemeNameObsidianTide)
 	require.True(tt, ok)
 
-	palette := NewThemePalette(theme)
+	palette := newThemePalette(theme, PaletteVariantDefault, paletteOptions{})
 	expected := expectedDarkThemeSyntaxForegrounds(theme)
 	for _, role := range syntaxOverrideRoles() {
 		style := mustRoleStyle(tt, palette, role)
@@ -176,7 +242,7 @@ func TestThemePalette_SyntaxOverrides_AllDarkThemesUseSpecificOperatorAndPunctua
 			theme, ok := t.GetTheme(themeName)
 			require.True(tt, ok)
 
-			palette := NewThemePalette(theme)
+			palette := newThemePalette(theme, PaletteVariantDefault, paletteOptions{})
 
 			operatorStyle := mustRoleStyle(tt, palette, TokenRoleSyntaxOperator)
 			require.Equal(tt, expectedDarkThemeOperatorForeground(theme), operatorStyle.Foreground)
@@ -193,7 +259,7 @@ func TestThemePalette_SyntaxOverrides_DoNotAffectNonSyntaxRoles(tt *testing.T) {
 	theme, ok := t.GetTheme(t.ThemeNameKanagawa)
 	require.True(tt, ok)
 
-	palette := NewThemePalette(theme)
+	palette := newThemePalette(theme, PaletteVariantDefault, paletteOptions{})
 	expected := expectedDefaultNonSyntaxForegrounds(theme)
 	for role, want := range expected {
 		style := mustRoleStyle(tt, palette, role)
@@ -214,7 +280,7 @@ func TestThemePalette_SyntaxOverrides_LightThemeSyntaxReadabilityFloor(tt *testi
 			theme, ok := t.GetTheme(themeName)
 			require.True(tt, ok)
 
-			palette := NewThemePalette(theme)
+			palette := newThemePalette(theme, PaletteVariantDefault, paletteOptions{})
 			for _, role := range syntaxOverrideRoles() {
 				style := mustRoleStyle(tt, palette, role)
 				ratio := style.Foreground.ContrastRatio(theme.Background)
@@ -353,6 +419,8 @@ func expectedDefaultNonSyntaxForegrounds(theme t.ThemeData) map[TokenRole]t.Colo
 		TokenRoleDiffHunkHeader:    hunkFg,
 		TokenRoleDiffMeta:          theme.WarningText,
 		TokenRoleDiffHatch:         hatchFg,
+		TokenRoleDiffWhitespace:    theme.TextDisabled,
+		TokenRoleDiffSuspicious:    theme.TextOnError,
 	}
 }
 
@@ -360,7 +428,7 @@ func TestThemePalette_IntralineOffHasNoOverlay(tt *testing.T) {
 	theme, ok := t.GetTheme(t.CurrentThemeName())
 	require.True(tt, ok)
 
-	palette := NewThemePalette(theme)
+	palette := newThemePalette(theme, PaletteVariantDefault, paletteOptions{})
 
 	_, ok = palette.IntralineOverlayStyle(IntralineMarkAdd, IntralineStyleModeOff)
 	require.False(tt, ok)
@@ -376,3 +444,13 @@ func colorDistance(a t.Color, b t.Color) float64 {
 	db := float64(int(ab) - int(bb))
 	return dr*dr + dg*dg + db*db
 }
+
+func TestThemePalette_SeenHunkVeilIsTranslucentBackground(tt *testing.T) {
+	theme, ok := t.GetTheme(t.CurrentThemeName())
+	require.True(tt, ok)
+
+	veil, ok := newThemePalette(theme, PaletteVariantDefault, paletteOptions{}).SeenHunkVeil()
+	require.True(tt, ok)
+	require.False(tt, veil.IsOpaque())
+	require.Equal(tt, theme.Background.WithAlpha(1), veil.WithAlpha(1))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const uiStateVersion = 1
+
+// UIState is the layout restored on startup when --persist-ui-state is on.
+// Unset fields keep dv's defaults.
+type UIState struct {
+	SidebarRatio  *float64 `json:"sidebar_ratio,omitempty"`
+	SplitRatio    *float64 `json:"split_ratio,omitempty"`
+	Wrap          *bool    `json:"wrap,omitempty"`
+	Theme         string   `json:"theme,omitempty"`
+	CollapsedDirs []string `json:"collapsed_dirs,omitempty"`
+}
+
+// uiStateFile is the on-disk layout of persisted UI state, keyed by repo
+// root.
+type uiStateFile struct {
+	Version int                `json:"version"`
+	Repos   map[string]UIState `json:"repos"`
+}
+
+// errUIStateUnreadable marks a UI state file that exists but cannot be
+// decoded.
+var errUIStateUnreadable = errors.New("parse UI state")
+
+// UIStateStore persists UI state to a JSON file under the XDG state
+// directory. An empty Path disables it.
+type UIStateStore struct {
+	Path string
+}
+
+func defaultUIStatePath(stateHome string) string {
+	if stateHome == "" {
+		return ""
+	}
+	return filepath.Join(stateHome, "dv", "ui.json")
+}
+
+// Load returns the state stored for repoRoot. A file that can't be decoded is
+// moved aside, so the next Save starts fresh instead of failing.
+func (s UIStateStore) Load(repoRoot string) (UIState, error) {
+	state, err := s.read()
+	if errors.Is(err, errUIStateUnreadable) {
+		backupPath := s.Path + ".corrupt"
+		if renameErr := os.Rename(s.Path, backupPath); renameErr != nil {
+			return UIState{}, fmt.Errorf("%w (moving it aside: %w)", err, renameErr)
+		}
+		return UIState{}, fmt.Errorf("%w; moved it to %q and started fresh", err, backupPath)
+	}
+	if err != nil {
+		return UIState{}, err
+	}
+	return state.Repos[repoRoot], nil
+}
+
+// Save replaces the state stored for repoRoot, leaving other repos untouched.
+func (s UIStateStore) Save(repoRoot string, ui UIState) error {
+	if s.Path == "" {
+		return nil
+	}
+	state, err := s.read()
+	if err != nil {
+		return err
+	}
+	state.Repos[repoRoot] = ui
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode UI state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return fmt.Errorf("create UI state directory: %w", err)
+	}
+	if err := writeFileAtomic(s.Path, append(data, '\n')); err != nil {
+		return fmt.Errorf("write UI state %q: %w", s.Path, err)
+	}
+	return nil
+}
+
+func (s UIStateStore) read() (uiStateFile, error) {
+	state := uiStateFile{Version: uiStateVersion, Repos: map[string]UIState{}}
+	if s.Path == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return state, nil
+		}
+		return state, fmt.Errorf("read UI state %q: %w", s.Path, err)
+	}
+	var stored uiStateFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return state, fmt.Errorf("%w %q: %w", errUIStateUnreadable, s.Path, err)
+	}
+	if stored.Version != uiStateVersion || stored.Repos == nil {
+		return state, nil
+	}
+	return stored, nil
+}
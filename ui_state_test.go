@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultUIStatePath(t *testing.T) {
+	require.Equal(t, filepath.Join("/tmp/state", "dv", "ui.json"), defaultUIStatePath("/tmp/state"))
+	require.Empty(t, defaultUIStatePath(""))
+}
+
+func TestUIStateStore_SaveAndLoadRoundTripPerRepo(t *testing.T) {
+	store := UIStateStore{Path: filepath.Join(t.TempDir(), "nested", "ui.json")}
+	ratio := 0.4
+	wrap := true
+	one := UIState{SidebarRatio: &ratio, Wrap: &wrap, Theme: "nord", CollapsedDirs: []string{"unstaged::dir::src"}}
+	require.NoError(t, store.Save("/repo/one", one))
+	require.NoError(t, store.Save("/repo/two", UIState{Theme: "dracula"}))
+
+	loaded, err := store.Load("/repo/one")
+	require.NoError(t, err)
+	require.Equal(t, one, loaded)
+
+	loaded, err = store.Load("/repo/two")
+	require.NoError(t, err)
+	require.Equal(t, UIState{Theme: "dracula"}, loaded)
+
+	loaded, err = store.Load("/repo/three")
+	require.NoError(t, err)
+	require.Equal(t, UIState{}, loaded)
+
+	entries, err := os.ReadDir(filepath.Dir(store.Path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "ui.json", entries[0].Name())
+}
+
+func TestUIStateStore_LoadMissingDisabledOrOutdatedIsEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ui.json")
+	loaded, err := UIStateStore{Path: path}.Load("/repo")
+	require.NoError(t, err)
+	require.Equal(t, UIState{}, loaded)
+
+	loaded, err = UIStateStore{}.Load("/repo")
+	require.NoError(t, err)
+	require.Equal(t, UIState{}, loaded)
+	require.NoError(t, UIStateStore{}.Save("/repo", UIState{Theme: "nord"}))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 99, "repos": {"/repo": {"theme": "nord"}}}`), 0o644))
+	loaded, err = UIStateStore{Path: path}.Load("/repo")
+	require.NoError(t, err)
+	require.Equal(t, UIState{}, loaded)
+
+}
+
+func TestUIStateStore_LoadMovesMalformedStateAside(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ui.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+
+	_, err := UIStateStore{Path: path}.Load("/repo")
+	require.ErrorContains(t, err, "parse UI state")
+	require.ErrorContains(t, err, "started fresh")
+
+	backup, err := os.ReadFile(path + ".corrupt")
+	require.NoError(t, err)
+	require.Equal(t, "{", string(backup))
+
+	loaded, err := UIStateStore{Path: path}.Load("/repo")
+	require.NoError(t, err)
+	require.Equal(t, UIState{}, loaded)
+	require.NoError(t, UIStateStore{Path: path}.Save("/repo", UIState{Theme: "nord"}))
+}
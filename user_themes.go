@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	t "github.com/darrenburns/terma"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultThemesRelDir holds one YAML file per user theme, named after the
+// theme.
+const defaultThemesRelDir = "dv/themes"
+
+// userThemeConfig defines a theme in the `themes:` config section or in a
+// file under the themes directory. Unset colours come from Extends.
+type userThemeConfig struct {
+	Extends string                       `yaml:"extends,omitempty"`
+	Light   *bool                        `yaml:"light,omitempty"`
+	Colors  map[string]string            `yaml:"colors,omitempty"`
+	Diff    map[string]string            `yaml:"diff,omitempty"`
+	Syntax  map[string]syntaxStyleConfig `yaml:"syntax,omitempty"`
+}
+
+// themeDiffColors replaces the diff colours newThemePalette would otherwise
+// derive from the theme's success and error colours. Unset colours keep the
+// derived value.
+type themeDiffColors struct {
+	AddBackground    t.Color
+	RemoveBackground t.Color
+	AddIntraline     t.Color
+	RemoveIntraline  t.Color
+}
+
+// userThemeStyles holds what user themes set beyond terma's ThemeData, by
+// theme name. Registering a theme records its styles here, and palettes are
+// built from them.
+type userThemeStyles struct {
+	DiffColors map[string]themeDiffColors
+	// Syntax holds the `syntax:` styles of each theme, merged with those of
+	// the theme it extends.
+	Syntax map[string]map[TokenRole]syntaxStyleOverride
+	// Resolvers holds the built-in syntax resolvers of the theme a user
+	// theme extends.
+	Resolvers map[string]map[TokenRole]syntaxColorResolver
+}
+
+func newUserThemeStyles() userThemeStyles {
+	return userThemeStyles{
+		DiffColors: map[string]themeDiffColors{},
+		Syntax:     map[string]map[TokenRole]syntaxStyleOverride{},
+		Resolvers:  map[string]map[TokenRole]syntaxColorResolver{},
+	}
+}
+
+// syntaxResolvers returns the syntax resolvers of a built-in theme or of the
+// built-in theme a user theme extends.
+func (s userThemeStyles) syntaxResolvers(name string) (map[TokenRole]syntaxColorResolver, bool) {
+	if resolvers, ok := syntaxThemeOverrides[name]; ok {
+		return resolvers, true
+	}
+	resolvers, ok := s.Resolvers[name]
+	return resolvers, ok
+}
+
+// userThemeNames records which registered themes came from the user, so the
+// theme menu can list them separately.
+var userThemeNames = map[string]bool{}
+
+var themeUIColorFields = map[string]func(*t.ThemeData) *t.Color{
+	"primary":           func(d *t.ThemeData) *t.Color { return &d.Primary },
+	"secondary":         func(d *t.ThemeData) *t.Color { return &d.Secondary },
+	"accent":            func(d *t.ThemeData) *t.Color { return &d.Accent },
+	"background":        func(d *t.ThemeData) *t.Color { return &d.Background },
+	"surface":           func(d *t.ThemeData) *t.Color { return &d.Surface },
+	"surface-hover":     func(d *t.ThemeData) *t.Color { return &d.SurfaceHover },
+	"surface-2":         func(d *t.ThemeData) *t.Color { return &d.Surface2 },
+	"surface-3":         func(d *t.ThemeData) *t.Color { return &d.Surface3 },
+	"text":              func(d *t.ThemeData) *t.Color { return &d.Text },
+	"text-muted":        func(d *t.ThemeData) *t.Color { return &d.TextMuted },
+	"text-on-primary":   func(d *t.ThemeData) *t.Color { return &d.TextOnPrimary },
+	"text-on-secondary": func(d *t.ThemeData) *t.Color { return &d.TextOnSecondary },
+	"text-on-accent":    func(d *t.ThemeData) *t.Color { return &d.TextOnAccent },
+	"text-disabled":     func(d *t.ThemeData) *t.Color { return &d.TextDisabled },
+	"border":            func(d *t.ThemeData) *t.Color { return &d.Border },
+	"focus-ring":        func(d *t.ThemeData) *t.Color { return &d.FocusRing },
+	"error":             func(d *t.ThemeData) *t.Color { return &d.Error },
+	"warning":           func(d *t.ThemeData) *t.Color { return &d.Warning },
+	"success":           func(d *t.ThemeData) *t.Color { return &d.Success },
+	"info":              func(d *t.ThemeData) *t.Color { return &d.Info },
+	"text-on-error":     func(d *t.ThemeData) *t.Color { return &d.TextOnError },
+	"text-on-warning":   func(d *t.ThemeData) *t.Color { return &d.TextOnWarning },
+	"text-on-success":   func(d *t.ThemeData) *t.Color { return &d.TextOnSuccess },
+	"text-on-info":      func(d *t.ThemeData) *t.Color { return &d.TextOnInfo },
+	"active-cursor":     func(d *t.ThemeData) *t.Color { return &d.ActiveCursor },
+	"selection":         func(d *t.ThemeData) *t.Color { return &d.Selection },
+	"selection-text":    func(d *t.ThemeData) *t.Color { return &d.SelectionText },
+	"scrollbar-track":   func(d *t.ThemeData) *t.Color { return &d.ScrollbarTrack },
+	"scrollbar-thumb":   func(d *t.ThemeData) *t.Color { return &d.ScrollbarThumb },
+	"overlay":           func(d *t.ThemeData) *t.Color { return &d.Overlay },
+	"placeholder":       func(d *t.ThemeData) *t.Color { return &d.Placeholder },
+	"cursor":            func(d *t.ThemeData) *t.Color { return &d.Cursor },
+	"link":              func(d *t.ThemeData) *t.Color { return &d.Link },
+}
+
+var themeDiffColorFields = map[string]func(*themeDiffColors) *t.Color{
+	"add-background":    func(c *themeDiffColors) *t.Color { return &c.AddBackground },
+	"remove-background": func(c *themeDiffColors) *t.Color { return &c.RemoveBackground },
+	"add-intraline":     func(c *themeDiffColors) *t.Color { return &c.AddIntraline },
+	"remove-intraline":  func(c *themeDiffColors) *t.Color { return &c.RemoveIntraline },
+}
+
+// loadThemeFiles registers every *.yaml and *.yml theme in dir, recording
+// their styles in styles. A missing directory is not an error.
+func loadThemeFiles(dir string, styles userThemeStyles) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("read themes directory %q: %w", dir, err)
+	}
+
+	themes := map[string]userThemeConfig{}
+	paths := map[string]string{}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		theme, err := readThemeFile(path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(entry.Name(), ext)
+		themes[name] = theme
+		paths[normalizeCLIValue(name)] = path
+	}
+
+	if err := registerUserThemes(themes, styles); err != nil {
+		var themeErr userThemeError
+		if errors.As(err, &themeErr) {
+			return fmt.Errorf("invalid theme file %q: %w", paths[themeErr.name], themeErr.err)
+		}
+		return err
+	}
+	return nil
+}
+
+func readThemeFile(path string) (userThemeConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return userThemeConfig{}, fmt.Errorf("read theme %q: %w", path, err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var theme userThemeConfig
+	if err := decoder.Decode(&theme); err != nil && !errors.Is(err, io.EOF) {
+		return userThemeConfig{}, fmt.Errorf("parse theme %q: %w", path, err)
+	}
+	return theme, nil
+}
+
+type userThemeError struct {
+	name string
+	err  error
+}
+
+func (e userThemeError) Error() string {
+	return fmt.Sprintf("theme %q: %v", e.name, e.err)
+}
+
+func (e userThemeError) Unwrap() error {
+	return e.err
+}
+
+// registerUserThemes builds and registers themes with terma, recording their
+// styles in styles. A theme may extend a built-in theme or another user theme
+// in styles, in any order.
+func registerUserThemes(themes map[string]userThemeConfig, styles userThemeStyles) error {
+	pending := map[string]userThemeConfig{}
+	for name, theme := range themes {
+		normalized := normalizeCLIValue(name)
+		if normalized == "" {
+			return userThemeError{name: name, err: errors.New("theme names cannot be empty")}
+		}
+		if _, ok := t.GetTheme(normalized); ok && !userThemeNames[normalized] {
+			return userThemeError{name: normalized, err: errors.New("a built-in theme already has this name")}
+		}
+		pending[normalized] = theme
+	}
+
+	for len(pending) > 0 {
+		names := make([]string, 0, len(pending))
+		for name := range pending {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		progressed := false
+		for _, name := range names {
+			base := userThemeBaseName(pending[name])
+			if _, waiting := pending[base]; waiting && base != name {
+				continue
+			}
+			if err := registerUserTheme(name, pending[name], styles); err != nil {
+				return userThemeError{name: name, err: err}
+			}
+			delete(pending, name)
+			progressed = true
+		}
+		if !progressed {
+			return userThemeError{name: names[0], err: errors.New("themes extend each other in a cycle")}
+		}
+	}
+	return nil
+}
+
+func userThemeBaseName(theme userThemeConfig) string {
+	if strings.TrimSpace(theme.Extends) == "" {
+		return t.ThemeNameObsidianTide
+	}
+	return normalizeCLIValue(theme.Extends)
+}
+
+func registerUserTheme(name string, theme userThemeConfig, styles userThemeStyles) error {
+	baseName := userThemeBaseName(theme)
+	if baseName == name {
+		return errors.New("a theme cannot extend itself")
+	}
+	base, ok := t.GetTheme(baseName)
+	if !ok {
+		return fmt.Errorf("unknown base theme %q in extends", theme.Extends)
+	}
+
+	data := base
+	for _, key := range sortedKeys(theme.Colors) {
+		field, ok := themeUIColorFields[key]
+		if !ok {
+			return fmt.Errorf("unknown colour %q (available colours: %s)", key, strings.Join(sortedKeys(themeUIColorFields), ", "))
+		}
+		color, err := parseThemeColor(theme.Colors[key])
+		if err != nil {
+			return fmt.Errorf("colour %q: %w", key, err)
+		}
+		*field(&data) = color
+	}
+	if theme.Light != nil {
+		data.IsLight = *theme.Light
+	} else if _, ok := theme.Colors["background"]; ok {
+		data.IsLight = data.Background.IsLight()
+	}
+
+	diffColors := styles.DiffColors[baseName]
+	for _, key := range sortedKeys(theme.Diff) {
+		field, ok := themeDiffColorFields[key]
+		if !ok {
+			return fmt.Errorf("unknown diff colour %q (available diff colours: %s)", key, strings.Join(sortedKeys(themeDiffColorFields), ", "))
+		}
+		color, err := parseThemeColor(theme.Diff[key])
+		if err != nil {
+			return fmt.Errorf("diff colour %q: %w", key, err)
+		}
+		*field(&diffColors) = color
+	}
+
+	overrides, err := parseSyntaxStyleOverrides(theme.Syntax)
+	if err != nil {
+		return err
+	}
+	syntaxStyles := map[TokenRole]syntaxStyleOverride{}
+	for role, style := range styles.Syntax[baseName] {
+		syntaxStyles[role] = style
+	}
+	for role, style := range overrides {
+		syntaxStyles[role] = style
+	}
+
+	t.RegisterTheme(name, data)
+	styles.DiffColors[name] = diffColors
+	if resolvers, ok := styles.syntaxResolvers(baseName); ok {
+		styles.Resolvers[name] = resolvers
+	}
+	styles.Syntax[name] = syntaxStyles
+	userThemeNames[name] = true
+	return nil
+}
+
+// parseThemeColor accepts "#rgb", "#rrggbb" and "#rrggbbaa", with or
+// without the leading "#".
+func parseThemeColor(value string) (t.Color, error) {
+	color := t.Hex(strings.TrimSpace(value))
+	if !color.IsSet() {
+		return t.Color{}, fmt.Errorf("invalid colour %q (expected a hex colour such as \"#ff79c6\")", value)
+	}
+	return color, nil
+}
+
+// userThemeNameList returns the registered user themes in alphabetical order.
+func userThemeNameList() []string {
+	return sortedKeys(userThemeNames)
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	t "github.com/darrenburns/terma"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterUserThemes_ExtendsBuiltInTheme(tt *testing.T) {
+	light := true
+	styles := newUserThemeStyles()
+	require.NoError(tt, registerUserThemes(map[string]userThemeConfig{
+		"Test Extended": {
+			Extends: "tokyo-night",
+			Light:   &light,
+			Colors:  map[string]string{"background": "#fafafa", "text-muted": "#888"},
+			Diff:    map[string]string{"add-background": "#e6ffec", "remove-intraline": "#ff818266"},
+			Syntax:  map[string]syntaxStyleConfig{"keyword": {Foreground: "#ff79c6"}, "string-escape": {Foreground: "#00ff00"}},
+		},
+	}, styles))
+
+	name, err := parseThemeName("test_extended")
+	require.NoError(tt, err)
+	require.Equal(tt, "test-extended", name)
+	require.Contains(tt, userThemeNameList(), "test-extended")
+
+	theme, ok := t.GetTheme(name)
+	require.True(tt, ok)
+	base, _ := t.GetTheme(t.ThemeNameTokyoNight)
+	require.True(tt, theme.IsLight)
+	require.Equal(tt, t.Hex("#fafafa"), theme.Background)
+	require.Equal(tt, t.Hex("#888888"), theme.TextMuted)
+	require.Equal(tt, base.Primary, theme.Primary)
+
+	palette := newThemePalette(theme, PaletteVariantDefault, paletteOptions{ThemeStyles: styles})
+	lineStyle, ok := palette.LineStyleForKind(RenderedLineAdd)
+	require.True(tt, ok)
+	require.Equal(tt, t.Hex("#e6ffec"), lineStyle.BackgroundColor)
+	intraline, ok := palette.IntralineOverlayStyle(IntralineMarkRemove, IntralineStyleModeBackground)
+	require.True(tt, ok)
+	require.Equal(tt, t.Hex("#ff818266"), intraline.Background)
+	keyword, _ := palette.StyleForRole(TokenRoleSyntaxKeyword)
+	require.Equal(tt, t.Hex("#ff79c6"), keyword.Foreground)
+	escape, _ := palette.StyleForRole(TokenRoleSyntaxStringEscape)
+	require.Equal(tt, t.Hex("#00ff00"), escape.Foreground)
+	// Roles the theme leaves alone keep the base theme's overrides.
+	comment, _ := palette.StyleForRole(TokenRoleSyntaxComment)
+	require.Equal(tt, t.Hex("#565f89"), comment.Foreground)
+}
+
+func TestRegisterUserThemes_ExtendsAnotherUserThemeInAnyOrder(tt *testing.T) {
+	styles := newUserThemeStyles()
+	require.NoError(tt, registerUserThemes(map[string]userThemeConfig{
+		"test-child":  {Extends: "test-parent", Colors: map[string]string{"accent": "#123456"}},
+		"test-parent": {Colors: map[string]string{"background": "#101010"}, Diff: map[string]string{"add-background": "#003300"}, Syntax: map[string]syntaxStyleConfig{"comment": {Foreground: "#777777"}}},
+	}, styles))
+
+	child, ok := t.GetTheme("test-child")
+	require.True(tt, ok)
+	require.Equal(tt, t.Hex("#101010"), child.Background)
+	require.Equal(tt, t.Hex("#123456"), child.Accent)
+	require.False(tt, child.IsLight)
+
+	palette := newThemePalette(child, PaletteVariantDefault, paletteOptions{ThemeStyles: styles})
+	lineStyle, _ := palette.LineStyleForKind(RenderedLineAdd)
+	require.Equal(tt, t.Hex("#003300"), lineStyle.BackgroundColor)
+	comment, _ := palette.StyleForRole(TokenRoleSyntaxComment)
+	require.Equal(tt, t.Hex("#777777"), comment.Foreground)
+}
+
+func TestRegisterUserThemes_RejectsInvalidThemes(tt *testing.T) {
+	tests := map[string]struct {
+		themes  map[string]userThemeConfig
+		wantErr string
+	}{
+		"unknownColor":   {map[string]userThemeConfig{"test-bad": {Colors: map[string]string{"chartreuse": "#fff"}}}, `unknown colour "chartreuse"`},
+		"invalidHex":     {map[string]userThemeConfig{"test-bad": {Colors: map[string]string{"text": "blue"}}}, `invalid colour "blue"`},
+		"unknownDiff":    {map[string]userThemeConfig{"test-bad": {Diff: map[string]string{"add": "#fff"}}}, `unknown diff colour "add"`},
+		"unknownRole":    {map[string]userThemeConfig{"test-bad": {Syntax: map[string]syntaxStyleConfig{"macro": {Foreground: "#fff"}}}}, `unknown syntax role "macro"`},
+		"unknownBase":    {map[string]userThemeConfig{"test-bad": {Extends: "missing"}}, `unknown base theme "missing"`},
+		"builtInName":    {map[string]userThemeConfig{"nord": {}}, "built-in theme"},
+		"extendsItself":  {map[string]userThemeConfig{"test-bad": {Extends: "test-bad"}}, "cannot extend itself"},
+		"extendsInCycle": {map[string]userThemeConfig{"test-a": {Extends: "test-b"}, "test-b": {Extends: "test-a"}}, "cycle"},
+	}
+	for name, tc := range tests {
+		tt.Run(name, func(tt *testing.T) {
+			err := registerUserThemes(tc.themes, newUserThemeStyles())
+			require.ErrorContains(tt, err, tc.wantErr)
+		})
+	}
+}
+
+func TestLoadThemeFiles_RegistersYAMLFiles(tt *testing.T) {
+	dir := tt.TempDir()
+	require.NoError(tt, os.WriteFile(filepath.Join(dir, "test-file-theme.yaml"), []byte("extends: nord\ncolors:\n  primary: \"#abcdef\"\n"), 0o644))
+	require.NoError(tt, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a theme"), 0o644))
+
+	require.NoError(tt, loadThemeFiles(dir, newUserThemeStyles()))
+	theme, ok := t.GetTheme("test-file-theme")
+	require.True(tt, ok)
+	require.Equal(tt, t.Hex("#abcdef"), theme.Primary)
+
+	require.NoError(tt, loadThemeFiles(filepath.Join(dir, "missing"), newUserThemeStyles()))
+
+	require.NoError(tt, os.WriteFile(filepath.Join(dir, "test-broken.yml"), []byte("colors:\n  text: nope\n"), 0o644))
+	err := loadThemeFiles(dir, newUserThemeStyles())
+	require.ErrorContains(tt, err, "test-broken.yml")
+	require.ErrorContains(tt, err, `invalid colour "nope"`)
+}
@@ -0,0 +1,279 @@
+package main
+
+// Markers around removed and added words in word-diff mode, as in
+// `git diff --word-diff=plain`.
+const (
+	wordDiffRemoveOpen  = "[-"
+	wordDiffRemoveClose = "-]"
+	wordDiffAddOpen     = "{+"
+	wordDiffAddClose    = "+}"
+)
+
+// wordDiffSource is a line of a change block split into graphemes, with the
+// token role of each so merged lines keep their syntax highlighting.
+type wordDiffSource struct {
+	graphemes []string
+	roles     []TokenRole
+}
+
+// wordDiffToken is a non-space chunk of a change block line, as split by
+// splitIntralineChunks. Line indexes the block's removed or added lines, and
+// start and end are grapheme offsets within it.
+type wordDiffToken struct {
+	text  string
+	line  int
+	start int
+	end   int
+}
+
+// wordDiffMergedLine is a merged line being written: the added line it
+// follows, with removed words spliced in.
+type wordDiffMergedLine struct {
+	segments []RenderedSegment
+	open     IntralineMarkKind
+	cursor   int
+	tokens   int
+}
+
+// wordDiffWriter lays the words of a change block out along the added lines,
+// so reflowed text keeps the new line breaks and only changed words are
+// marked.
+type wordDiffWriter struct {
+	oldLines []wordDiffSource
+	newLines []wordDiffSource
+	merged   []wordDiffMergedLine
+	current  int
+	lastOld  *wordDiffToken
+}
+
+// buildWordDiffLines merges the removed and added lines of a change block
+// into one line per added line, with removed words shown as [-...-] and
+// added words as {+...+}. Words are matched across line breaks, so a
+// reflowed paragraph only marks the words that changed. It returns false
+// when the block is too big to match.
+func buildWordDiffLines(removes []RenderedDiffLine, adds []RenderedDiffLine) ([]RenderedDiffLine, bool) {
+	if len(removes) == 0 || len(adds) == 0 {
+		return nil, false
+	}
+	oldLines := make([]wordDiffSource, len(removes))
+	for idx, line := range removes {
+		oldLines[idx] = newWordDiffSource(line)
+	}
+	newLines := make([]wordDiffSource, len(adds))
+	for idx, line := range adds {
+		newLines[idx] = newWordDiffSource(line)
+	}
+	oldTokens := wordDiffTokens(oldLines)
+	newTokens := wordDiffTokens(newLines)
+	matches, ok := longestCommonSubsequence(wordDiffTokenTexts(oldTokens), wordDiffTokenTexts(newTokens))
+	if !ok {
+		return nil, false
+	}
+
+	writer := &wordDiffWriter{
+		oldLines: oldLines,
+		newLines: newLines,
+		merged:   make([]wordDiffMergedLine, len(adds)),
+	}
+	oldIdx, newIdx := 0, 0
+	writeGap := func(oldEnd int, newEnd int) {
+		for ; oldIdx < oldEnd; oldIdx++ {
+			writer.writeRemoved(oldTokens[oldIdx])
+		}
+		for ; newIdx < newEnd; newIdx++ {
+			writer.writeNew(newTokens[newIdx], IntralineMarkAdd)
+		}
+	}
+	for _, match := range matches {
+		writeGap(match[0], match[1])
+		writer.writeNew(newTokens[newIdx], IntralineMarkNone)
+		writer.lastOld = &oldTokens[oldIdx]
+		oldIdx++
+		newIdx++
+	}
+	writeGap(len(oldTokens), len(newTokens))
+
+	lines := make([]RenderedDiffLine, len(adds))
+	for idx := range writer.merged {
+		merged := &writer.merged[idx]
+		writer.closeMark(merged)
+		source := newLines[idx]
+		writer.appendSource(merged, source, merged.cursor, len(source.graphemes), IntralineMarkNone)
+		lines[idx] = newRenderedLine(RenderedLineWordDiff, 0, adds[idx].NewLine, " ", merged.segments)
+		// The text came from rendered lines, so its tabs are already
+		// expanded; only the width is needed from newRenderedLine, which
+		// drops intraline marks.
+		lines[idx].Segments = merged.segments
+	}
+	return lines, true
+}
+
+// buildWordDiffBlock merges the unmoved lines of a change block into
+// word-diff lines. Moved lines keep their own lines, since their words are
+// shown where they moved to.
+func buildWordDiffBlock(removes []RenderedDiffLine, adds []RenderedDiffLine) (hunkRenderedBlock, bool) {
+	var block hunkRenderedBlock
+	var unmovedRemoves, unmovedAdds []RenderedDiffLine
+	for _, line := range removes {
+		if line.Move != nil {
+			block.Removes = append(block.Removes, line)
+		} else {
+			unmovedRemoves = append(unmovedRemoves, line)
+		}
+	}
+	for _, line := range adds {
+		if line.Move != nil {
+			block.Adds = append(block.Adds, line)
+		} else {
+			unmovedAdds = append(unmovedAdds, line)
+		}
+	}
+	merged, ok := buildWordDiffLines(unmovedRemoves, unmovedAdds)
+	if !ok {
+		return hunkRenderedBlock{}, false
+	}
+	block.Merged = merged
+	return block, true
+}
+
+func newWordDiffSource(line RenderedDiffLine) wordDiffSource {
+	var source wordDiffSource
+	for _, segment := range line.Segments {
+		for _, grapheme := range splitGraphemes(segment.Text) {
+			source.graphemes = append(source.graphemes, grapheme)
+			source.roles = append(source.roles, segment.Role)
+		}
+	}
+	return source
+}
+
+// chunkGraphemes returns the graphemes of the line with whitespace glyphs
+// read as spaces, so they separate words rather than forming them.
+func (s wordDiffSource) chunkGraphemes() []string {
+	graphemes := make([]string, len(s.graphemes))
+	for idx, grapheme := range s.graphemes {
+		if s.roles[idx] == TokenRoleDiffWhitespace {
+			grapheme = " "
+		}
+		graphemes[idx] = grapheme
+	}
+	return graphemes
+}
+
+func wordDiffTokens(lines []wordDiffSource) []wordDiffToken {
+	var tokens []wordDiffToken
+	for lineIdx, line := range lines {
+		for _, chunk := range splitIntralineChunks(line.chunkGraphemes()) {
+			if chunk.kind == intralineChunkSpace {
+				continue
+			}
+			tokens = append(tokens, wordDiffToken{text: chunk.text, line: lineIdx, start: chunk.start, end: chunk.end})
+		}
+	}
+	return tokens
+}
+
+func wordDiffTokenTexts(tokens []wordDiffToken) []string {
+	texts := make([]string, len(tokens))
+	for idx, token := range tokens {
+		texts[idx] = token.text
+	}
+	return texts
+}
+
+// writeNew writes a token of an added line, moving on to its line first.
+func (w *wordDiffWriter) writeNew(token wordDiffToken, mark IntralineMarkKind) {
+	w.current = max(w.current, token.line)
+	merged := &w.merged[w.current]
+	source := w.newLines[token.line]
+	// A removed word already brought the space before it, so an added word
+	// that replaces it follows directly.
+	skipSpace := merged.open == IntralineMarkRemove && mark == IntralineMarkAdd
+	if merged.open != mark {
+		w.closeMark(merged)
+		if !skipSpace {
+			w.appendSource(merged, source, merged.cursor, token.start, IntralineMarkNone)
+		}
+		w.openMark(merged, mark)
+	} else {
+		w.appendSource(merged, source, merged.cursor, token.start, mark)
+	}
+	w.appendSource(merged, source, token.start, token.end, mark)
+	merged.cursor = token.end
+	merged.tokens++
+}
+
+// writeRemoved splices a token of a removed line into the current line.
+func (w *wordDiffWriter) writeRemoved(token wordDiffToken) {
+	merged := &w.merged[w.current]
+	if merged.tokens == 0 && merged.cursor == 0 {
+		// Keep the indentation of the line even when it starts with a
+		// removed word.
+		source := w.newLines[w.current]
+		indent := 0
+		for indent < len(source.graphemes) && isSpaceGrapheme(source.graphemes[indent]) {
+			indent++
+		}
+		w.appendSource(merged, source, 0, indent, IntralineMarkNone)
+		merged.cursor = indent
+	}
+
+	source := w.oldLines[token.line]
+	var space []RenderedSegment
+	switch {
+	case merged.tokens == 0:
+	case w.lastOld != nil && w.lastOld.line == token.line:
+		space = sourceSegments(source, w.lastOld.end, token.start)
+	default:
+		space = []RenderedSegment{{Text: " ", Role: TokenRoleSyntaxPlain}}
+	}
+
+	if merged.open != IntralineMarkRemove {
+		w.closeMark(merged)
+		for _, segment := range space {
+			appendSegmentWithMark(&merged.segments, segment.Role, IntralineMarkNone, segment.Text)
+		}
+		w.openMark(merged, IntralineMarkRemove)
+	} else {
+		for _, segment := range space {
+			appendSegmentWithMark(&merged.segments, segment.Role, IntralineMarkRemove, segment.Text)
+		}
+	}
+	w.appendSource(merged, source, token.start, token.end, IntralineMarkRemove)
+	merged.tokens++
+	w.lastOld = &token
+}
+
+func (w *wordDiffWriter) openMark(merged *wordDiffMergedLine, mark IntralineMarkKind) {
+	switch mark {
+	case IntralineMarkRemove:
+		appendSegmentWithMark(&merged.segments, TokenRoleSyntaxPlain, mark, wordDiffRemoveOpen)
+	case IntralineMarkAdd:
+		appendSegmentWithMark(&merged.segments, TokenRoleSyntaxPlain, mark, wordDiffAddOpen)
+	}
+	merged.open = mark
+}
+
+func (w *wordDiffWriter) closeMark(merged *wordDiffMergedLine) {
+	switch merged.open {
+	case IntralineMarkRemove:
+		appendSegmentWithMark(&merged.segments, TokenRoleSyntaxPlain, merged.open, wordDiffRemoveClose)
+	case IntralineMarkAdd:
+		appendSegmentWithMark(&merged.segments, TokenRoleSyntaxPlain, merged.open, wordDiffAddClose)
+	}
+	merged.open = IntralineMarkNone
+}
+
+func (w *wordDiffWriter) appendSource(merged *wordDiffMergedLine, source wordDiffSource, start int, end int, mark IntralineMarkKind) {
+	for _, segment := range sourceSegments(source, start, end) {
+		appendSegmentWithMark(&merged.segments, segment.Role, mark, segment.Text)
+	}
+}
+
+func sourceSegments(source wordDiffSource, start int, end int) []RenderedSegment {
+	var segments []RenderedSegment
+	for idx := max(0, start); idx < min(end, len(source.graphemes)); idx++ {
+		appendSegmentWithMark(&segments, source.roles[idx], IntralineMarkNone, source.graphemes[idx])
+	}
+	return segments
+}
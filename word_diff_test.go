@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func wordDiffTestLines(kind RenderedLineKind, texts ...string) []RenderedDiffLine {
+	lines := make([]RenderedDiffLine, 0, len(texts))
+	for idx, text := range texts {
+		line := newRenderedLine(kind, 0, 0, " ", []RenderedSegment{{Text: text, Role: TokenRoleSyntaxPlain}})
+		if kind == RenderedLineRemove {
+			line.OldLine = idx + 1
+		} else {
+			line.NewLine = idx + 1
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func wordDiffTexts(lines []RenderedDiffLine) []string {
+	texts := make([]string, 0, len(lines))
+	for _, line := range lines {
+		texts = append(texts, renderedLineText(line))
+	}
+	return texts
+}
+
+func TestBuildWordDiffLines_MarksReplacedWords(t *testing.T) {
+	lines, ok := buildWordDiffLines(
+		wordDiffTestLines(RenderedLineRemove, "    the quick brown fox"),
+		wordDiffTestLines(RenderedLineAdd, "    the slow brown fox jumps"),
+	)
+	require.True(t, ok)
+	require.Equal(t, []string{"    the [-quick-]{+slow+} brown fox {+jumps+}"}, wordDiffTexts(lines))
+	require.Equal(t, RenderedLineWordDiff, lines[0].Kind)
+	require.Zero(t, lines[0].OldLine)
+	require.Equal(t, 1, lines[0].NewLine)
+	require.Equal(t, indexRange(8, 17), markedIndicesForLine(lines[0], IntralineMarkRemove))
+	require.Equal(t, append(indexRange(17, 25), indexRange(36, 45)...), markedIndicesForLine(lines[0], IntralineMarkAdd))
+}
+
+func TestBuildWordDiffLines_FollowsReflowedLineBreaks(t *testing.T) {
+	lines, ok := buildWordDiffLines(
+		wordDiffTestLines(RenderedLineRemove,
+			"Prose diffs are hard to read when a",
+			"paragraph is reflowed after a small edit.",
+		),
+		wordDiffTestLines(RenderedLineAdd,
+			"Prose diffs are hard to read",
+			"when a paragraph is reflowed after a tiny",
+			"edit.",
+		),
+	)
+	require.True(t, ok)
+	require.Equal(t, []string{
+		"Prose diffs are hard to read",
+		"when a paragraph is reflowed after a [-small-]{+tiny+}",
+		"edit.",
+	}, wordDiffTexts(lines))
+	require.Equal(t, 1, lines[0].NewLine)
+	require.Equal(t, 2, lines[1].NewLine)
+	require.Equal(t, 3, lines[2].NewLine)
+}
+
+func TestBuildWordDiffLines_GroupsRunsOfRemovedWords(t *testing.T) {
+	lines, ok := buildWordDiffLines(
+		wordDiffTestLines(RenderedLineRemove, "keep these two words and this"),
+		wordDiffTestLines(RenderedLineAdd, "keep this"),
+	)
+	require.True(t, ok)
+	require.Equal(t, []string{"keep [-these two words and-] this"}, wordDiffTexts(lines))
+}